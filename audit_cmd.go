@@ -0,0 +1,51 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"ITKtest/config"
+	"ITKtest/internal/audit"
+	"ITKtest/internal/repository"
+)
+
+const auditUsage = "usage: main audit verify"
+
+// unchainedGrace is how many chain intervals a record may wait to be chained before verify
+// reports it
+const unchainedGrace = 5
+
+// runAudit implements the audit subcommand
+func runAudit(cfg *config.Config, args []string) error {
+	if len(args) != 1 || args[0] != "verify" {
+		return errors.New(auditUsage)
+	}
+	if cfg.StorageDriver != "postgres" {
+		return fmt.Errorf("the audit log is kept only with the postgres storage driver, not %q", cfg.StorageDriver)
+	}
+
+	db, _, err := openDatabase(cfg)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	grace := unchainedGrace * cfg.AuditChainInterval
+	report, err := audit.Verify(context.Background(), repository.NewAuditRepository(db, repository.TxConfig{}), time.Now().Add(-grace))
+	if err != nil {
+		return err
+	}
+	for _, problem := range report.Problems {
+		fmt.Println(problem)
+	}
+	fmt.Printf("Verified %d audit records, head %s\n", report.Records, report.Head)
+	if len(report.Problems) > 0 {
+		return fmt.Errorf("audit log has been tampered with: %d problems found", len(report.Problems))
+	}
+	if report.Unchained > 0 {
+		return fmt.Errorf("%d audit records written over %s ago are still unchained, so deleting them would go unnoticed", report.Unchained, grace)
+	}
+	return nil
+}
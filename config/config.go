@@ -1,47 +1,586 @@
 package config
 
 import (
+	"database/sql"
+	"errors"
+	"fmt"
 	"log"
+	"net/url"
 	"os"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
 
+	"ITKtest/internal/settings"
+
+	"github.com/google/uuid"
 	"github.com/joho/godotenv"
 )
 
 type DBConfig struct {
+	// URL is a full postgres:// connection URL; when set it takes precedence over the fields below
+	URL      string
 	Host     string
 	Port     string
 	User     string
 	Password string
 	DBName   string
 	SSLMode  string
+	// AutoCreate creates the database on first connect if it does not exist
+	AutoCreate      bool
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+	ConnMaxIdleTime time.Duration
+}
+
+// ReplicaConfig describes an optional read replica. Reads fall back to the primary when the
+// replica is unreachable or its replay lag exceeds MaxLag.
+type ReplicaConfig struct {
+	URL           string
+	MaxLag        time.Duration
+	CheckInterval time.Duration
+}
+
+// TxConfig controls isolation and retries of multi-statement writes
+type TxConfig struct {
+	Isolation   sql.IsolationLevel
+	MaxRetries  int
+	BaseBackoff time.Duration
+	MaxBackoff  time.Duration
+}
+
+// CacheConfig controls the balance cache. Entries are invalidated through the Postgres
+// notification feed, so with other storage drivers a write by another process is only seen
+// after TTL.
+type CacheConfig struct {
+	Enabled bool
+	Size    int
+	TTL     time.Duration
+}
+
+// TLSConfig enables HTTPS and gRPC over TLS when CertFile and KeyFile are set. ClientAuth
+// "optional" or "require" verifies client certificates against ClientCAFile; DebitPrincipals
+// then lists the certificate common names allowed to withdraw, AdminPrincipals those allowed to
+// use the reconciliation endpoint and metrics, and WebhookPrincipals and SettlementPrincipals,
+// when set, those allowed to manage webhooks and import settlement files, "*" meaning any
+// verified client.
+type TLSConfig struct {
+	CertFile             string
+	KeyFile              string
+	ClientCAFile         string
+	ClientAuth           string
+	DebitPrincipals      []string
+	AdminPrincipals      []string
+	WebhookPrincipals    []string
+	SettlementPrincipals []string
+}
+
+// Enabled reports whether the servers use TLS
+func (c TLSConfig) Enabled() bool {
+	return c.CertFile != ""
+}
+
+// SnapshotConfig controls the daily balance snapshots, taken at midnight in Location once
+// Grace has passed
+type SnapshotConfig struct {
+	Location      *time.Location
+	Grace         time.Duration
+	CheckInterval time.Duration
+}
+
+// ReconciliationConfig controls the job comparing wallet balances with their journal. It runs
+// at startup and then every Interval; zero runs it only on demand. ReportLimit caps how many
+// discrepancies of each kind a report lists.
+type ReconciliationConfig struct {
+	Interval    time.Duration
+	ReportLimit int
+}
+
+// SettlementConfig is the default column mapping of uploaded settlement files. WalletColumn is
+// optional; AmountDecimals is the number of fractional digits of amounts in the file.
+type SettlementConfig struct {
+	ReferenceColumn string
+	AmountColumn    string
+	WalletColumn    string
+	Delimiter       string
+	AmountDecimals  int
+}
+
+// BonusConfig controls promotional balances. Credits expire after DefaultTTL unless they name
+// their own expiry; ConsumptionOrder is "bonus_first" or "real_first", the part of a withdrawal
+// paid from bonus lots before or after the real balance. Expired lots are journaled every
+// ExpiryInterval.
+type BonusConfig struct {
+	DefaultTTL       time.Duration
+	ConsumptionOrder string
+	ExpiryInterval   time.Duration
+}
+
+// ScheduleConfig controls the worker executing scheduled operations. It looks for due runs
+// every PollInterval, claiming up to BatchSize of them for Lease; a run not finished within the
+// lease is retried by another worker.
+type ScheduleConfig struct {
+	PollInterval time.Duration
+	Lease        time.Duration
+	BatchSize    int
+}
+
+type OutboxConfig struct {
+	Publisher    string
+	FilePath     string
+	PollInterval time.Duration
+	BatchSize    int
+	MaxAttempts  int
+	MaxBackoff   time.Duration
+	Lease        time.Duration
+}
+
+type WebhookConfig struct {
+	MaxAttempts         int
+	BaseBackoff         time.Duration
+	MaxBackoff          time.Duration
+	Timeout             time.Duration
+	PollInterval        time.Duration
+	BatchSize           int
+	LowBalanceThreshold int64
+}
+
+// HotWallet designates a wallet whose balance is split across Shards rows
+type HotWallet struct {
+	WalletID uuid.UUID
+	Shards   int
 }
 
 type Config struct {
 	DB         DBConfig
-	ServerPort string
-}
+	Replica    ReplicaConfig
+	Tx         TxConfig
+	Cache      CacheConfig
+	TLS        TLSConfig
+	Snapshot   SnapshotConfig
+	Reconcile  ReconciliationConfig
+	Settlement SettlementConfig
+	Bonus      BonusConfig
+	Schedule   ScheduleConfig
+	Outbox     OutboxConfig
+	Webhook    WebhookConfig
+	HotWallets []HotWallet
+	// FeeScheduleFile is the YAML or JSON fee schedule applied to operations; empty charges no fees
+	FeeScheduleFile string
+	// AuditChainInterval is how often audit records written by changes are linked into the chain
+	AuditChainInterval time.Duration
+	// StorageDriver is "postgres", "sqlite" or "memory". The sqlite and memory drivers run
+	// without outbox, webhooks and database notifications.
+	StorageDriver string
+	SQLitePath    string
+	// AutoMigrate applies pending migrations on boot; DBReset rolls back and re-applies all of them
+	AutoMigrate bool
+	DBReset     bool
+	// LockingStrategy is "pessimistic" (SELECT ... FOR UPDATE) or "optimistic" (version compare-and-set)
+	LockingStrategy      string
+	OptimisticMaxRetries int
+	ServerPort           string
+	GRPCPort             string
+	// Runtime settings are applied again when the config is reloaded, see Changes
+	Runtime settings.Runtime
+	// File is the config file in use, if any; it is polled for changes every WatchInterval
+	File          string
+	WatchInterval time.Duration
 
-func getEnv(key, defaultValue string) string {
-	if value, exists := os.LookupEnv(key); exists {
-		return value
-	}
-	return defaultValue
+	// settings are the resolved keys, for config print
+	settings []Setting
 }
 
-func LoadConfig() (*Config, error) {
-	if err := godotenv.Load("config.env"); err != nil {
+// EnvFile is the dotenv file read as part of the environment
+const EnvFile = "config.env"
+
+// LoadConfig builds the configuration from defaults, a YAML or TOML file, the environment
+// (including config.env) and -key=value flags, each layer overriding the previous one. The file
+// is named by the -config flag or CONFIG_FILE. Any KEY can instead be read from the file named by
+// KEY_FILE, which is how secrets are usually mounted. All problems are reported in one joined
+// error. LoadConfig returns the arguments left after the flags.
+func LoadConfig(args []string) (*Config, []string, error) {
+	// config.env is read rather than loaded into the environment, so that a reload sees its changes
+	dotenv, err := godotenv.Read(EnvFile)
+	if err != nil {
 		log.Println("No .env file found, using environment variables")
 	}
+	env := func(key string) (string, bool) {
+		if value, ok := os.LookupEnv(key); ok {
+			return value, true
+		}
+		value, ok := dotenv[key]
+		return value, ok
+	}
 
-	return &Config{
-		DB: DBConfig{
-			Host:     getEnv("DB_HOST", "localhost"),
-			Port:     getEnv("DB_PORT", "5432"),
-			User:     getEnv("DB_USER", "postgres"),
-			Password: getEnv("DB_PASSWORD", "postgres"),
-			DBName:   getEnv("DB_NAME", "wallet_db"),
-			SSLMode:  getEnv("DB_SSLMODE", "disable"),
+	flags, configPath, rest, err := parseFlags(args)
+	if err != nil {
+		return nil, nil, err
+	}
+	if configPath == "" {
+		configPath, _ = env("CONFIG_FILE")
+	}
+	var file map[string]string
+	if configPath != "" {
+		if file, err = readFile(configPath); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	cfg, err := load(newLoader(file, flags, env))
+	if err != nil {
+		return nil, nil, err
+	}
+	cfg.File = configPath
+	return cfg, rest, nil
+}
+
+func load(l *loader) (*Config, error) {
+	cfg := &Config{
+		DB: loadDBConfig(l),
+		Replica: ReplicaConfig{
+			URL:           l.secret("REPLICA_DATABASE_URL", ""),
+			MaxLag:        l.duration("REPLICA_MAX_LAG", 5*time.Second),
+			CheckInterval: l.duration("REPLICA_CHECK_INTERVAL", 5*time.Second),
+		},
+		Tx: loadTxConfig(l),
+		Cache: CacheConfig{
+			Enabled: l.bool("BALANCE_CACHE_ENABLED", false),
+			Size:    l.int("BALANCE_CACHE_SIZE", 10000),
+			TTL:     l.duration("BALANCE_CACHE_TTL", 5*time.Second),
 		},
-		ServerPort: getEnv("SERVER_PORT", "8080"),
-	}, nil
+		TLS: TLSConfig{
+			CertFile:             l.string("TLS_CERT_FILE", ""),
+			KeyFile:              l.string("TLS_KEY_FILE", ""),
+			ClientCAFile:         l.string("TLS_CLIENT_CA_FILE", ""),
+			ClientAuth:           l.string("TLS_CLIENT_AUTH", "none"),
+			DebitPrincipals:      splitList(l.string("TLS_DEBIT_PRINCIPALS", "")),
+			AdminPrincipals:      splitList(l.string("TLS_ADMIN_PRINCIPALS", "")),
+			WebhookPrincipals:    splitList(l.string("TLS_WEBHOOK_PRINCIPALS", "")),
+			SettlementPrincipals: splitList(l.string("TLS_SETTLEMENT_PRINCIPALS", "")),
+		},
+		Snapshot: loadSnapshotConfig(l),
+		Reconcile: ReconciliationConfig{
+			Interval:    l.duration("RECONCILIATION_INTERVAL", 24*time.Hour),
+			ReportLimit: l.int("RECONCILIATION_REPORT_LIMIT", 100),
+		},
+		Settlement: SettlementConfig{
+			ReferenceColumn: l.string("SETTLEMENT_REFERENCE_COLUMN", "reference"),
+			AmountColumn:    l.string("SETTLEMENT_AMOUNT_COLUMN", "amount"),
+			WalletColumn:    l.string("SETTLEMENT_WALLET_COLUMN", ""),
+			Delimiter:       l.string("SETTLEMENT_DELIMITER", ","),
+			AmountDecimals:  l.int("SETTLEMENT_AMOUNT_DECIMALS", 0),
+		},
+		Bonus: BonusConfig{
+			DefaultTTL:       l.duration("BONUS_DEFAULT_TTL", 30*24*time.Hour),
+			ConsumptionOrder: l.string("BONUS_CONSUMPTION_ORDER", "bonus_first"),
+			ExpiryInterval:   l.duration("BONUS_EXPIRY_INTERVAL", time.Minute),
+		},
+		Schedule: ScheduleConfig{
+			PollInterval: l.duration("SCHEDULE_POLL_INTERVAL", 5*time.Second),
+			Lease:        l.duration("SCHEDULE_LEASE", 5*time.Minute),
+			BatchSize:    l.int("SCHEDULE_BATCH_SIZE", 100),
+		},
+		Outbox: OutboxConfig{
+			Publisher:    l.string("OUTBOX_PUBLISHER", "log"),
+			FilePath:     l.string("OUTBOX_FILE_PATH", "outbox_events.jsonl"),
+			PollInterval: l.duration("OUTBOX_POLL_INTERVAL", time.Second),
+			BatchSize:    l.int("OUTBOX_BATCH_SIZE", 100),
+			MaxAttempts:  l.int("OUTBOX_MAX_ATTEMPTS", 20),
+			MaxBackoff:   l.duration("OUTBOX_MAX_BACKOFF", time.Hour),
+			Lease:        l.duration("OUTBOX_LEASE", time.Minute),
+		},
+		Webhook:              loadWebhookConfig(l),
+		FeeScheduleFile:      l.string("FEE_SCHEDULE_FILE", ""),
+		AuditChainInterval:   l.duration("AUDIT_CHAIN_INTERVAL", time.Second),
+		StorageDriver:        l.string("STORAGE_DRIVER", "postgres"),
+		SQLitePath:           l.string("SQLITE_PATH", "wallet.db"),
+		AutoMigrate:          l.bool("DB_AUTO_MIGRATE", true),
+		DBReset:              l.bool("DB_RESET", false),
+		LockingStrategy:      l.string("LOCKING_STRATEGY", "pessimistic"),
+		OptimisticMaxRetries: l.int("OPTIMISTIC_MAX_RETRIES", 5),
+		ServerPort:           l.string("SERVER_PORT", "8080"),
+		GRPCPort:             l.string("GRPC_PORT", "9090"),
+		Runtime:              loadRuntime(l),
+		WatchInterval:        l.duration("CONFIG_WATCH_INTERVAL", 5*time.Second),
+	}
+
+	hotWallets, err := parseHotWallets(l.string("HOT_WALLETS", ""))
+	if err != nil {
+		l.errs = append(l.errs, err)
+	}
+	cfg.HotWallets = hotWallets
+
+	l.checkUnknown()
+	l.errs = append(l.errs, cfg.validate()...)
+	if len(l.errs) > 0 {
+		return nil, errors.Join(l.errs...)
+	}
+	cfg.settings = l.settings
+	return cfg, nil
+}
+
+func loadDBConfig(l *loader) DBConfig {
+	return DBConfig{
+		URL:             l.secret("DATABASE_URL", ""),
+		Host:            l.string("DB_HOST", "localhost"),
+		Port:            l.string("DB_PORT", "5432"),
+		User:            l.string("DB_USER", "postgres"),
+		Password:        l.secret("DB_PASSWORD", "postgres"),
+		DBName:          l.string("DB_NAME", "wallet_db"),
+		SSLMode:         l.string("DB_SSLMODE", "disable"),
+		AutoCreate:      l.bool("DB_AUTO_CREATE", true),
+		MaxOpenConns:    l.int("DB_MAX_OPEN_CONNS", 25),
+		MaxIdleConns:    l.int("DB_MAX_IDLE_CONNS", 25),
+		ConnMaxLifetime: l.duration("DB_CONN_MAX_LIFETIME", 5*time.Minute),
+		ConnMaxIdleTime: l.duration("DB_CONN_MAX_IDLE_TIME", 0),
+	}
+}
+
+func loadTxConfig(l *loader) TxConfig {
+	cfg := TxConfig{}
+
+	switch level := l.string("DB_ISOLATION_LEVEL", "read_committed"); level {
+	case "read_committed":
+		cfg.Isolation = sql.LevelReadCommitted
+	case "repeatable_read":
+		cfg.Isolation = sql.LevelRepeatableRead
+	case "serializable":
+		cfg.Isolation = sql.LevelSerializable
+	default:
+		l.errorf("invalid DB_ISOLATION_LEVEL %q: expected read_committed, repeatable_read or serializable", level)
+	}
+
+	cfg.MaxRetries = l.int("DB_TX_MAX_RETRIES", 3)
+	cfg.BaseBackoff = l.duration("DB_TX_BASE_BACKOFF", 10*time.Millisecond)
+	cfg.MaxBackoff = l.duration("DB_TX_MAX_BACKOFF", 500*time.Millisecond)
+	return cfg
+}
+
+// runtimeKeys are the keys read by loadRuntime
+var runtimeKeys = map[string]bool{
+	"LOG_LEVEL":                true,
+	"MAX_OPERATION_AMOUNT":     true,
+	"MAX_BATCH_SIZE":           true,
+	"RATE_LIMIT_RPS":           true,
+	"RATE_LIMIT_BURST":         true,
+	"READ_ONLY":                true,
+	"BATCH_OPERATIONS_ENABLED": true,
+}
+
+func loadRuntime(l *loader) settings.Runtime {
+	defaults := settings.Default()
+	rt := settings.Runtime{
+		MaxOperationAmount: int64(l.int("MAX_OPERATION_AMOUNT", int(defaults.MaxOperationAmount))),
+		MaxBatchSize:       l.int("MAX_BATCH_SIZE", defaults.MaxBatchSize),
+		RateLimit:          l.float("RATE_LIMIT_RPS", defaults.RateLimit),
+		RateLimitBurst:     l.int("RATE_LIMIT_BURST", defaults.RateLimitBurst),
+		ReadOnly:           l.bool("READ_ONLY", defaults.ReadOnly),
+		BatchOperations:    l.bool("BATCH_OPERATIONS_ENABLED", defaults.BatchOperations),
+	}
+	if err := rt.LogLevel.UnmarshalText([]byte(l.string("LOG_LEVEL", "info"))); err != nil {
+		l.errorf("invalid LOG_LEVEL: %w", err)
+	}
+	return rt
+}
+
+// Changes lists the keys whose values differ between two configurations, split into runtime
+// settings, which a reload applies, and everything else, which needs a restart
+func Changes(old, new *Config) (runtime, restart []string) {
+	previous := make(map[string]string, len(old.settings))
+	for _, s := range old.settings {
+		previous[s.Key] = s.Value
+	}
+	for _, s := range new.settings {
+		if value, ok := previous[s.Key]; ok && value == s.Value {
+			continue
+		}
+		if runtimeKeys[s.Key] {
+			runtime = append(runtime, s.Key)
+		} else {
+			restart = append(restart, s.Key)
+		}
+	}
+	return runtime, restart
+}
+
+// RuntimeValues returns the effective values of the settings that can change without a
+// restart, keyed by setting name
+func (c *Config) RuntimeValues() map[string]string {
+	values := make(map[string]string, len(runtimeKeys))
+	for _, s := range c.settings {
+		if runtimeKeys[s.Key] {
+			values[s.Key] = s.Redacted()
+		}
+	}
+	return values
+}
+
+func loadSnapshotConfig(l *loader) SnapshotConfig {
+	cfg := SnapshotConfig{
+		Grace:         l.duration("SNAPSHOT_GRACE", 5*time.Minute),
+		CheckInterval: l.duration("SNAPSHOT_CHECK_INTERVAL", time.Minute),
+	}
+	name := l.string("SNAPSHOT_TIME_ZONE", "UTC")
+	location, err := time.LoadLocation(name)
+	if err != nil {
+		l.errorf("invalid SNAPSHOT_TIME_ZONE %q: %w", name, err)
+		location = time.UTC
+	}
+	cfg.Location = location
+	return cfg
+}
+
+func loadWebhookConfig(l *loader) WebhookConfig {
+	return WebhookConfig{
+		MaxAttempts:         l.int("WEBHOOK_MAX_ATTEMPTS", 8),
+		BaseBackoff:         l.duration("WEBHOOK_BASE_BACKOFF", 5*time.Second),
+		MaxBackoff:          l.duration("WEBHOOK_MAX_BACKOFF", time.Hour),
+		Timeout:             l.duration("WEBHOOK_TIMEOUT", 10*time.Second),
+		PollInterval:        l.duration("WEBHOOK_POLL_INTERVAL", time.Second),
+		BatchSize:           l.int("WEBHOOK_BATCH_SIZE", 50),
+		LowBalanceThreshold: int64(l.int("WEBHOOK_LOW_BALANCE_THRESHOLD", 1000)),
+	}
+}
+
+// validate checks values that parse but make no sense, returning every problem found
+func (c *Config) validate() []error {
+	var errs []error
+	check := func(ok bool, format string, args ...any) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+
+	check(oneOf(c.StorageDriver, "postgres", "sqlite", "memory"), "invalid STORAGE_DRIVER %q: expected postgres, sqlite or memory", c.StorageDriver)
+	check(oneOf(c.LockingStrategy, "pessimistic", "optimistic"), "invalid LOCKING_STRATEGY %q: expected pessimistic or optimistic", c.LockingStrategy)
+	check(oneOf(c.Outbox.Publisher, "log", "file"), "invalid OUTBOX_PUBLISHER %q: expected log or file", c.Outbox.Publisher)
+	check(validPort(c.ServerPort), "invalid SERVER_PORT %q", c.ServerPort)
+	check(validPort(c.GRPCPort), "invalid GRPC_PORT %q", c.GRPCPort)
+	check(c.ServerPort != c.GRPCPort, "SERVER_PORT and GRPC_PORT must differ")
+
+	if c.StorageDriver == "postgres" {
+		if c.DB.URL != "" {
+			check(validDatabaseURL(c.DB.URL), "invalid DATABASE_URL: expected a postgres:// URL")
+		} else {
+			check(validPort(c.DB.Port), "invalid DB_PORT %q", c.DB.Port)
+		}
+		check(c.DB.MaxOpenConns >= 0, "DB_MAX_OPEN_CONNS must not be negative")
+		check(c.DB.MaxIdleConns >= 0, "DB_MAX_IDLE_CONNS must not be negative")
+		if c.Replica.URL != "" {
+			check(validDatabaseURL(c.Replica.URL), "invalid REPLICA_DATABASE_URL: expected a postgres:// URL")
+			check(c.Replica.CheckInterval > 0, "REPLICA_CHECK_INTERVAL must be positive")
+		}
+	}
+	if c.StorageDriver == "sqlite" {
+		check(c.SQLitePath != "", "SQLITE_PATH must be set")
+	}
+
+	check((c.TLS.CertFile == "") == (c.TLS.KeyFile == ""), "TLS_CERT_FILE and TLS_KEY_FILE must be set together")
+	check(oneOf(c.TLS.ClientAuth, "none", "optional", "require"), "invalid TLS_CLIENT_AUTH %q: expected none, optional or require", c.TLS.ClientAuth)
+	if c.TLS.ClientAuth != "none" {
+		check(c.TLS.Enabled(), "TLS_CLIENT_AUTH needs TLS_CERT_FILE and TLS_KEY_FILE")
+		check(c.TLS.ClientCAFile != "", "TLS_CLIENT_AUTH needs TLS_CLIENT_CA_FILE")
+	}
+	check(len(c.TLS.DebitPrincipals) == 0 || c.TLS.ClientAuth != "none", "TLS_DEBIT_PRINCIPALS needs TLS_CLIENT_AUTH optional or require")
+	check(len(c.TLS.AdminPrincipals) == 0 || c.TLS.ClientAuth != "none", "TLS_ADMIN_PRINCIPALS needs TLS_CLIENT_AUTH optional or require")
+	check(len(c.TLS.WebhookPrincipals) == 0 || c.TLS.ClientAuth != "none", "TLS_WEBHOOK_PRINCIPALS needs TLS_CLIENT_AUTH optional or require")
+	check(len(c.TLS.SettlementPrincipals) == 0 || c.TLS.ClientAuth != "none", "TLS_SETTLEMENT_PRINCIPALS needs TLS_CLIENT_AUTH optional or require")
+	check(c.Tx.MaxRetries >= 0, "DB_TX_MAX_RETRIES must not be negative")
+	check(c.Tx.BaseBackoff <= c.Tx.MaxBackoff, "DB_TX_BASE_BACKOFF must not exceed DB_TX_MAX_BACKOFF")
+	check(c.OptimisticMaxRetries >= 0, "OPTIMISTIC_MAX_RETRIES must not be negative")
+	if c.Cache.Enabled {
+		check(c.Cache.Size > 0, "BALANCE_CACHE_SIZE must be positive")
+		check(c.Cache.TTL > 0, "BALANCE_CACHE_TTL must be positive")
+	}
+	check(c.Outbox.PollInterval > 0, "OUTBOX_POLL_INTERVAL must be positive")
+	check(c.Outbox.BatchSize > 0, "OUTBOX_BATCH_SIZE must be positive")
+	check(c.Outbox.MaxAttempts > 0, "OUTBOX_MAX_ATTEMPTS must be positive")
+	check(c.Outbox.Lease > 0, "OUTBOX_LEASE must be positive")
+	check(c.Webhook.MaxAttempts > 0, "WEBHOOK_MAX_ATTEMPTS must be positive")
+	check(c.Webhook.BaseBackoff <= c.Webhook.MaxBackoff, "WEBHOOK_BASE_BACKOFF must not exceed WEBHOOK_MAX_BACKOFF")
+	check(c.Webhook.Timeout > 0, "WEBHOOK_TIMEOUT must be positive")
+	check(c.Webhook.PollInterval > 0, "WEBHOOK_POLL_INTERVAL must be positive")
+	check(c.Webhook.BatchSize > 0, "WEBHOOK_BATCH_SIZE must be positive")
+	check(c.Snapshot.Grace >= 0, "SNAPSHOT_GRACE must not be negative")
+	check(c.Snapshot.CheckInterval > 0, "SNAPSHOT_CHECK_INTERVAL must be positive")
+	check(c.Reconcile.Interval >= 0, "RECONCILIATION_INTERVAL must not be negative")
+	check(c.Reconcile.ReportLimit > 0, "RECONCILIATION_REPORT_LIMIT must be positive")
+	check(c.Settlement.ReferenceColumn != "", "SETTLEMENT_REFERENCE_COLUMN must be set")
+	check(c.Settlement.AmountColumn != "", "SETTLEMENT_AMOUNT_COLUMN must be set")
+	check(utf8.RuneCountInString(c.Settlement.Delimiter) == 1, "SETTLEMENT_DELIMITER must be a single character")
+	check(c.Settlement.AmountDecimals >= 0 && c.Settlement.AmountDecimals <= 6, "SETTLEMENT_AMOUNT_DECIMALS must be between 0 and 6")
+	check(c.Bonus.DefaultTTL > 0, "BONUS_DEFAULT_TTL must be positive")
+	check(oneOf(c.Bonus.ConsumptionOrder, "bonus_first", "real_first"), "invalid BONUS_CONSUMPTION_ORDER %q: expected bonus_first or real_first", c.Bonus.ConsumptionOrder)
+	check(c.Bonus.ExpiryInterval > 0, "BONUS_EXPIRY_INTERVAL must be positive")
+	check(c.Schedule.PollInterval > 0, "SCHEDULE_POLL_INTERVAL must be positive")
+	check(c.Schedule.Lease > 0, "SCHEDULE_LEASE must be positive")
+	check(c.Schedule.BatchSize > 0, "SCHEDULE_BATCH_SIZE must be positive")
+	check(c.AuditChainInterval > 0, "AUDIT_CHAIN_INTERVAL must be positive")
+	check(c.WatchInterval >= 0, "CONFIG_WATCH_INTERVAL must not be negative")
+	check(c.Runtime.MaxOperationAmount >= 0, "MAX_OPERATION_AMOUNT must not be negative")
+	check(c.Runtime.MaxBatchSize > 0 && c.Runtime.MaxBatchSize <= 1000, "MAX_BATCH_SIZE must be between 1 and 1000")
+	check(c.Runtime.RateLimit >= 0, "RATE_LIMIT_RPS must not be negative")
+	if c.Runtime.RateLimit > 0 {
+		check(c.Runtime.RateLimitBurst >= 1, "RATE_LIMIT_BURST must be at least 1")
+	}
+	return errs
+}
+
+func oneOf(value string, allowed ...string) bool {
+	for _, a := range allowed {
+		if value == a {
+			return true
+		}
+	}
+	return false
+}
+
+func validPort(port string) bool {
+	n, err := strconv.Atoi(port)
+	return err == nil && n > 0 && n <= 65535
+}
+
+func validDatabaseURL(dsn string) bool {
+	u, err := url.Parse(dsn)
+	return err == nil && (u.Scheme == "postgres" || u.Scheme == "postgresql")
+}
+
+// splitList parses a comma-separated list, dropping empty items
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// parseHotWallets parses a comma-separated list of walletId:shards pairs
+func parseHotWallets(value string) ([]HotWallet, error) {
+	var hotWallets []HotWallet
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+
+		idStr, shardsStr, ok := strings.Cut(item, ":")
+		if !ok {
+			return nil, fmt.Errorf("invalid HOT_WALLETS entry %q: expected walletId:shards", item)
+		}
+		walletID, err := uuid.Parse(idStr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid HOT_WALLETS entry %q: %w", item, err)
+		}
+		shards, err := strconv.Atoi(shardsStr)
+		if err != nil || shards <= 0 {
+			return nil, fmt.Errorf("invalid HOT_WALLETS entry %q: shards must be a positive integer", item)
+		}
+		hotWallets = append(hotWallets, HotWallet{WalletID: walletID, Shards: shards})
+	}
+	return hotWallets, nil
 }
@@ -0,0 +1,332 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// Layers a setting can come from, lowest precedence first
+const (
+	SourceDefault = "default"
+	SourceFile    = "file"
+	SourceEnv     = "env"
+	SourceFlag    = "flag"
+)
+
+// fileSuffix marks a setting whose value is read from the named file, e.g. DB_PASSWORD_FILE
+const fileSuffix = "_FILE"
+
+// Setting is the effective value of one configuration key and the layer it came from
+type Setting struct {
+	Key    string
+	Value  string
+	Source string
+	Secret bool
+}
+
+// Redacted returns the value safe for printing: secrets are masked, and URLs keep everything
+// except the password
+func (s Setting) Redacted() string {
+	if !s.Secret || s.Value == "" {
+		return s.Value
+	}
+	if u, err := url.Parse(s.Value); err == nil && u.Scheme != "" && u.Host != "" {
+		if _, ok := u.User.Password(); ok {
+			return u.Redacted()
+		}
+		return s.Value
+	}
+	return "xxxxx"
+}
+
+// loader resolves keys across the layers. Keys are named like environment variables; files and
+// flags name them in lower case with "-" or nesting in place of "_". Parse errors are collected
+// rather than returned, so that a bad config is reported in full at once.
+type loader struct {
+	file     map[string]string
+	flags    map[string]string
+	env      func(string) (string, bool)
+	used     map[string]bool
+	settings []Setting
+	errs     []error
+}
+
+func newLoader(file, flags map[string]string, env func(string) (string, bool)) *loader {
+	return &loader{file: file, flags: flags, env: env, used: make(map[string]bool)}
+}
+
+func (l *loader) errorf(format string, args ...any) {
+	l.errs = append(l.errs, fmt.Errorf(format, args...))
+}
+
+// lookup finds key in the highest layer that sets it, directly or through key_FILE
+func (l *loader) lookup(key string) (string, string, bool) {
+	l.used[key] = true
+	l.used[key+fileSuffix] = true
+
+	layers := []struct {
+		source string
+		get    func(string) (string, bool)
+	}{
+		{SourceFlag, mapLookup(l.flags)},
+		{SourceEnv, l.env},
+		{SourceFile, mapLookup(l.file)},
+	}
+	for _, layer := range layers {
+		if value, ok := layer.get(key); ok {
+			return value, layer.source, true
+		}
+		if path, ok := layer.get(key + fileSuffix); ok {
+			data, err := os.ReadFile(path)
+			if err != nil {
+				l.errorf("invalid %s%s: %w", key, fileSuffix, err)
+				return "", layer.source, false
+			}
+			return strings.TrimRight(string(data), "\r\n"), layer.source, true
+		}
+	}
+	return "", SourceDefault, false
+}
+
+func mapLookup(m map[string]string) func(string) (string, bool) {
+	return func(key string) (string, bool) {
+		value, ok := m[key]
+		return value, ok
+	}
+}
+
+func (l *loader) record(key, value, source string, secret bool) {
+	l.settings = append(l.settings, Setting{Key: key, Value: value, Source: source, Secret: secret})
+}
+
+func (l *loader) string(key, defaultValue string) string {
+	value, source, ok := l.lookup(key)
+	if !ok {
+		value = defaultValue
+	}
+	l.record(key, value, source, false)
+	return value
+}
+
+// secret is string for values such as passwords that config print must not show
+func (l *loader) secret(key, defaultValue string) string {
+	value, source, ok := l.lookup(key)
+	if !ok {
+		value = defaultValue
+	}
+	l.record(key, value, source, true)
+	return value
+}
+
+func (l *loader) int(key string, defaultValue int) int {
+	value, source, ok := l.lookup(key)
+	if !ok {
+		l.record(key, strconv.Itoa(defaultValue), source, false)
+		return defaultValue
+	}
+	l.record(key, value, source, false)
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		l.errorf("invalid %s: %w", key, err)
+	}
+	return n
+}
+
+func (l *loader) float(key string, defaultValue float64) float64 {
+	value, source, ok := l.lookup(key)
+	if !ok {
+		l.record(key, strconv.FormatFloat(defaultValue, 'g', -1, 64), source, false)
+		return defaultValue
+	}
+	l.record(key, value, source, false)
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		l.errorf("invalid %s: %w", key, err)
+	}
+	return f
+}
+
+func (l *loader) bool(key string, defaultValue bool) bool {
+	value, source, ok := l.lookup(key)
+	if !ok {
+		l.record(key, strconv.FormatBool(defaultValue), source, false)
+		return defaultValue
+	}
+	l.record(key, value, source, false)
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		l.errorf("invalid %s: %w", key, err)
+	}
+	return b
+}
+
+func (l *loader) duration(key string, defaultValue time.Duration) time.Duration {
+	value, source, ok := l.lookup(key)
+	if !ok {
+		l.record(key, defaultValue.String(), source, false)
+		return defaultValue
+	}
+	l.record(key, value, source, false)
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		l.errorf("invalid %s: %w", key, err)
+	}
+	return d
+}
+
+// checkUnknown reports keys set in the file or flags that no setting reads, which are
+// usually typos
+func (l *loader) checkUnknown() {
+	for _, layer := range []struct {
+		name   string
+		values map[string]string
+	}{{"config file", l.file}, {"flag", l.flags}} {
+		keys := make([]string, 0, len(layer.values))
+		for key := range layer.values {
+			if !l.used[key] {
+				keys = append(keys, key)
+			}
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			l.errorf("unknown setting %s in %s", key, layer.name)
+		}
+	}
+}
+
+// parseFlags splits args into -key=value settings and the remaining arguments. Flags come
+// before the command; -config also accepts its value as the next argument.
+func parseFlags(args []string) (map[string]string, string, []string, error) {
+	flags := make(map[string]string)
+	var configPath string
+	for len(args) > 0 {
+		arg := args[0]
+		if arg == "--" {
+			return flags, configPath, args[1:], nil
+		}
+		if !strings.HasPrefix(arg, "-") || arg == "-" {
+			break
+		}
+		args = args[1:]
+
+		name, value, hasValue := strings.Cut(strings.TrimLeft(arg, "-"), "=")
+		if name == "config" {
+			if !hasValue {
+				if len(args) == 0 {
+					return nil, "", nil, errors.New("flag -config needs a value")
+				}
+				value, args = args[0], args[1:]
+			}
+			configPath = value
+			continue
+		}
+		if !hasValue {
+			return nil, "", nil, fmt.Errorf("flag %s needs a value: use -%s=value", arg, name)
+		}
+		flags[keyName(name)] = value
+	}
+	return flags, configPath, args, nil
+}
+
+// readFile loads a YAML or TOML config file and flattens it into keys, so that
+//
+//	db:
+//	  host: localhost
+//
+// sets DB_HOST. Lists are joined with commas.
+func readFile(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	var tree map[string]any
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &tree)
+	case ".toml":
+		err = toml.Unmarshal(data, &tree)
+	default:
+		return nil, fmt.Errorf("unsupported config file format %q: expected .yaml, .yml or .toml", ext)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+
+	values := make(map[string]string)
+	if err := flatten(values, "", tree); err != nil {
+		return nil, fmt.Errorf("invalid config file %s: %w", path, err)
+	}
+	return values, nil
+}
+
+func flatten(values map[string]string, prefix string, tree map[string]any) error {
+	for name, value := range tree {
+		key := keyName(name)
+		if prefix != "" {
+			key = prefix + "_" + key
+		}
+
+		switch v := value.(type) {
+		case map[string]any:
+			if err := flatten(values, key, v); err != nil {
+				return err
+			}
+		case []any:
+			items := make([]string, 0, len(v))
+			for _, item := range v {
+				s, err := scalar(key, item)
+				if err != nil {
+					return err
+				}
+				items = append(items, s)
+			}
+			values[key] = strings.Join(items, ",")
+		default:
+			s, err := scalar(key, v)
+			if err != nil {
+				return err
+			}
+			values[key] = s
+		}
+	}
+	return nil
+}
+
+func scalar(key string, value any) (string, error) {
+	switch v := value.(type) {
+	case nil:
+		return "", nil
+	case string, bool, int, int64, uint64, float64:
+		return fmt.Sprint(v), nil
+	default:
+		return "", fmt.Errorf("%s: unsupported value %v", key, v)
+	}
+}
+
+// keyName turns a file or flag name such as "max-open-conns" into MAX_OPEN_CONNS
+func keyName(name string) string {
+	return strings.ToUpper(strings.ReplaceAll(name, "-", "_"))
+}
+
+// WriteSettings prints the effective configuration, one KEY=value per line with the layer it
+// came from, with secrets redacted
+func (c *Config) WriteSettings(w io.Writer) error {
+	for _, s := range c.settings {
+		if _, err := fmt.Fprintf(w, "%s=%s # %s\n", s.Key, s.Redacted(), s.Source); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,18 @@
+package main
+
+import (
+	"errors"
+	"os"
+
+	"ITKtest/config"
+)
+
+const configUsage = "usage: main config print"
+
+// runConfig implements the config subcommand
+func runConfig(cfg *config.Config, args []string) error {
+	if len(args) != 1 || args[0] != "print" {
+		return errors.New(configUsage)
+	}
+	return cfg.WriteSettings(os.Stdout)
+}
@@ -2,97 +2,161 @@ package database
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
 	"log"
-	"time"
+	"net/url"
+	"strings"
 
 	"ITKtest/config"
 
-	"github.com/golang-migrate/migrate/v4"
-	"github.com/golang-migrate/migrate/v4/database/postgres"
-	_ "github.com/golang-migrate/migrate/v4/source/file"
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
 )
 
-func Connect(cfg config.DBConfig) (*sql.DB, error) {
-	// Подключение к PostgreSQL без указания базы данных для проверки её существования
-	connStr := fmt.Sprintf(
-		"host=%s port=%s user=%s password=%s sslmode=%s",
-		cfg.Host, cfg.Port, cfg.User, cfg.Password, cfg.SSLMode,
-	)
-
-	// Подключение к серверу PostgreSQL
-	db, err := sql.Open("postgres", connStr)
-	if err != nil {
-		return nil, fmt.Errorf("failed to open database: %w", err)
-	}
+// sqlStateInvalidCatalogName is returned when connecting to a database that does not exist
+const sqlStateInvalidCatalogName = "3D000"
 
-	// Проверка существования базы данных
-	var dbExists bool
-	err = db.QueryRow("SELECT EXISTS(SELECT 1 FROM pg_database WHERE datname = $1)", cfg.DBName).Scan(&dbExists)
+func Connect(cfg config.DBConfig) (*sql.DB, error) {
+	db, err := open(cfg)
 	if err != nil {
-		db.Close()
-		return nil, fmt.Errorf("failed to check database existence: %w", err)
-	}
+		var pqErr *pq.Error
+		if !cfg.AutoCreate || !errors.As(err, &pqErr) || pqErr.Code != sqlStateInvalidCatalogName {
+			return nil, err
+		}
 
-	// Если база данных не существует, создаём её
-	if !dbExists {
-		_, err = db.Exec(fmt.Sprintf("CREATE DATABASE %s", cfg.DBName))
-		if err != nil {
-			db.Close()
-			return nil, fmt.Errorf("failed to create database: %w", err)
+		// Базы данных ещё нет: создаём её через служебную базу postgres
+		if err := createDatabase(cfg); err != nil {
+			return nil, err
+		}
+		if db, err = open(cfg); err != nil {
+			return nil, err
 		}
-		log.Printf("Database %s created successfully", cfg.DBName)
 	}
 
-	// Закрываем соединение с сервером PostgreSQL
-	db.Close()
-
-	// Подключение к конкретной базе данных
-	connStr = fmt.Sprintf(
-		"host=%s port=%s user=%s password=%s dbname=%s sslmode=%s",
-		cfg.Host, cfg.Port, cfg.User, cfg.Password, cfg.DBName, cfg.SSLMode,
-	)
+	log.Println("Successfully connected to database")
+	return db, nil
+}
 
-	db, err = sql.Open("postgres", connStr)
+// Open configures a connection pool without connecting, for databases such as a read replica
+// that may be unavailable at startup
+func Open(cfg config.DBConfig) (*sql.DB, error) {
+	db, err := sql.Open("postgres", ConnectionString(cfg))
 	if err != nil {
 		return nil, fmt.Errorf("failed to open database: %w", err)
 	}
 
-	// Set connection pool settings
-	db.SetMaxOpenConns(25)
-	db.SetMaxIdleConns(25)
-	db.SetConnMaxLifetime(5 * time.Minute)
+	db.SetMaxOpenConns(cfg.MaxOpenConns)
+	db.SetMaxIdleConns(cfg.MaxIdleConns)
+	db.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+	db.SetConnMaxIdleTime(cfg.ConnMaxIdleTime)
+	return db, nil
+}
+
+func open(cfg config.DBConfig) (*sql.DB, error) {
+	db, err := Open(cfg)
+	if err != nil {
+		return nil, err
+	}
 
 	// Проверка соединения с базой данных
 	if err = db.Ping(); err != nil {
 		db.Close()
 		return nil, fmt.Errorf("failed to ping database: %w", err)
 	}
-
-	log.Println("Successfully connected to database")
 	return db, nil
 }
 
-func RunMigrations(db *sql.DB, reset bool) error {
-	driver, err := postgres.WithInstance(db, &postgres.Config{})
+// createDatabase creates the application database. It needs the CREATEDB privilege, which is
+// why Connect only calls it when the database is missing and AutoCreate is set.
+func createDatabase(cfg config.DBConfig) error {
+	name, err := DatabaseName(cfg)
 	if err != nil {
-		return fmt.Errorf("failed to create migration driver: %w", err)
+		return err
 	}
-
-	m, err := migrate.NewWithDatabaseInstance(
-		"file://migrations",
-		"postgres",
-		driver,
-	)
+	maintenance, err := maintenanceConnectionString(cfg)
 	if err != nil {
-		return fmt.Errorf("failed to create migration instance: %w", err)
+		return err
 	}
 
-	if err := m.Up(); err != nil && err != migrate.ErrNoChange {
-		return fmt.Errorf("failed to run migrations: %w", err)
+	db, err := sql.Open("postgres", maintenance)
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
 	}
+	defer db.Close()
 
-	log.Println("Database migrations applied successfully")
+	if _, err := db.Exec("CREATE DATABASE " + pq.QuoteIdentifier(name)); err != nil {
+		return fmt.Errorf("failed to create database: %w", err)
+	}
+	log.Printf("Database %s created successfully", name)
 	return nil
 }
+
+// ConnectionString returns the DSN of the application database: DATABASE_URL if set, otherwise
+// a keyword/value string built from the individual settings
+func ConnectionString(cfg config.DBConfig) string {
+	if cfg.URL != "" {
+		return cfg.URL
+	}
+	return keywordValueDSN(cfg, cfg.DBName)
+}
+
+// DatabaseName returns the name of the application database
+func DatabaseName(cfg config.DBConfig) (string, error) {
+	if cfg.URL == "" {
+		return cfg.DBName, nil
+	}
+	u, err := parseURL(cfg.URL)
+	if err != nil {
+		return "", err
+	}
+	name := strings.TrimPrefix(u.Path, "/")
+	if name == "" {
+		return "", errors.New("DATABASE_URL does not name a database")
+	}
+	return name, nil
+}
+
+// maintenanceConnectionString points at the postgres database of the same server
+func maintenanceConnectionString(cfg config.DBConfig) (string, error) {
+	if cfg.URL == "" {
+		return keywordValueDSN(cfg, "postgres"), nil
+	}
+	u, err := parseURL(cfg.URL)
+	if err != nil {
+		return "", err
+	}
+	u.Path = "/postgres"
+	return u.String(), nil
+}
+
+func parseURL(dsn string) (*url.URL, error) {
+	u, err := url.Parse(dsn)
+	if err != nil || (u.Scheme != "postgres" && u.Scheme != "postgresql") {
+		return nil, errors.New("DATABASE_URL must be a postgres:// URL")
+	}
+	return u, nil
+}
+
+func keywordValueDSN(cfg config.DBConfig, dbName string) string {
+	params := []struct{ key, value string }{
+		{"host", cfg.Host},
+		{"port", cfg.Port},
+		{"user", cfg.User},
+		{"password", cfg.Password},
+		{"dbname", dbName},
+		{"sslmode", cfg.SSLMode},
+	}
+	parts := make([]string, 0, len(params))
+	for _, p := range params {
+		parts = append(parts, p.key+"="+quoteDSNValue(p.value))
+	}
+	return strings.Join(parts, " ")
+}
+
+// quoteDSNValue quotes a keyword/value DSN value so spaces, quotes and backslashes in e.g. a
+// password cannot change other settings
+func quoteDSNValue(value string) string {
+	value = strings.ReplaceAll(value, `\`, `\\`)
+	value = strings.ReplaceAll(value, `'`, `\'`)
+	return "'" + value + "'"
+}
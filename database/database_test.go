@@ -0,0 +1,52 @@
+package database
+
+import (
+	"testing"
+
+	"ITKtest/config"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConnectionString(t *testing.T) {
+	cfg := config.DBConfig{
+		Host:     "db",
+		Port:     "5432",
+		User:     "wallet",
+		Password: `p@ss word' sslmode=disable \`,
+		DBName:   "wallet_db",
+		SSLMode:  "require",
+	}
+	assert.Equal(t,
+		`host='db' port='5432' user='wallet' password='p@ss word\' sslmode=disable \\' dbname='wallet_db' sslmode='require'`,
+		ConnectionString(cfg))
+
+	maintenance, err := maintenanceConnectionString(cfg)
+	assert.NoError(t, err)
+	assert.Contains(t, maintenance, `dbname='postgres'`)
+
+	name, err := DatabaseName(cfg)
+	assert.NoError(t, err)
+	assert.Equal(t, "wallet_db", name)
+}
+
+func TestConnectionString_URL(t *testing.T) {
+	cfg := config.DBConfig{
+		URL:    "postgres://wallet:secret@db:5432/wallet_db?sslmode=verify-full",
+		DBName: "ignored",
+	}
+	assert.Equal(t, cfg.URL, ConnectionString(cfg))
+
+	name, err := DatabaseName(cfg)
+	assert.NoError(t, err)
+	assert.Equal(t, "wallet_db", name)
+
+	maintenance, err := maintenanceConnectionString(cfg)
+	assert.NoError(t, err)
+	assert.Equal(t, "postgres://wallet:secret@db:5432/postgres?sslmode=verify-full", maintenance)
+
+	_, err = DatabaseName(config.DBConfig{URL: "postgres://db:5432"})
+	assert.EqualError(t, err, "DATABASE_URL does not name a database")
+	_, err = DatabaseName(config.DBConfig{URL: "mysql://db/wallet"})
+	assert.EqualError(t, err, "DATABASE_URL must be a postgres:// URL")
+}
@@ -0,0 +1,169 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+
+	"ITKtest/migrations"
+
+	"github.com/golang-migrate/migrate/v4"
+	migratedb "github.com/golang-migrate/migrate/v4/database"
+	"github.com/golang-migrate/migrate/v4/database/postgres"
+	"github.com/golang-migrate/migrate/v4/database/sqlite"
+	"github.com/golang-migrate/migrate/v4/source"
+	"github.com/golang-migrate/migrate/v4/source/iofs"
+)
+
+// Migrator applies the migrations embedded in the binary and knows the schema version the
+// binary was built for
+type Migrator struct {
+	m      *migrate.Migrate
+	latest uint
+}
+
+// NewMigrator returns a Migrator for the PostgreSQL schema of db
+func NewMigrator(db *sql.DB) (*Migrator, error) {
+	driver, err := postgres.WithInstance(db, &postgres.Config{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create migration driver: %w", err)
+	}
+	return newMigrator(migrations.Postgres, ".", "postgres", driver)
+}
+
+// NewSQLiteMigrator returns a Migrator for the SQLite schema of db
+func NewSQLiteMigrator(db *sql.DB) (*Migrator, error) {
+	driver, err := sqlite.WithInstance(db, &sqlite.Config{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create migration driver: %w", err)
+	}
+	return newMigrator(migrations.SQLite, "sqlite", "sqlite", driver)
+}
+
+func newMigrator(fsys fs.FS, dir, databaseName string, driver migratedb.Driver) (*Migrator, error) {
+	src, err := iofs.New(fsys, dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read embedded migrations: %w", err)
+	}
+	latest, err := latestVersion(src)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read embedded migrations: %w", err)
+	}
+
+	m, err := migrate.NewWithInstance("iofs", src, databaseName, driver)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create migration instance: %w", err)
+	}
+	return &Migrator{m: m, latest: latest}, nil
+}
+
+func latestVersion(src source.Driver) (uint, error) {
+	version, err := src.First()
+	if err != nil {
+		return 0, err
+	}
+	for {
+		next, err := src.Next(version)
+		if errors.Is(err, os.ErrNotExist) {
+			return version, nil
+		}
+		if err != nil {
+			return 0, err
+		}
+		version = next
+	}
+}
+
+// Latest is the newest migration embedded in the binary
+func (m *Migrator) Latest() uint {
+	return m.latest
+}
+
+// Version returns the current schema version; 0 means no migration has been applied
+func (m *Migrator) Version() (uint, bool, error) {
+	version, dirty, err := m.m.Version()
+	if errors.Is(err, migrate.ErrNilVersion) {
+		return 0, false, nil
+	}
+	return version, dirty, err
+}
+
+func (m *Migrator) Up() error {
+	if err := m.m.Up(); err != nil && !errors.Is(err, migrate.ErrNoChange) {
+		return fmt.Errorf("failed to run migrations: %w", err)
+	}
+	return nil
+}
+
+// Down rolls back the last n migrations
+func (m *Migrator) Down(n int) error {
+	if n <= 0 {
+		return errors.New("number of migrations to roll back must be positive")
+	}
+	if err := m.m.Steps(-n); err != nil {
+		return fmt.Errorf("failed to roll back migrations: %w", err)
+	}
+	return nil
+}
+
+// Force sets the schema version without running migrations and clears the dirty flag, after a
+// failed migration has been repaired by hand
+func (m *Migrator) Force(version int) error {
+	return m.m.Force(version)
+}
+
+// Reset rolls back every migration and applies them again, losing all data
+func (m *Migrator) Reset() error {
+	if err := m.m.Down(); err != nil && !errors.Is(err, migrate.ErrNoChange) {
+		return fmt.Errorf("failed to roll back migrations: %w", err)
+	}
+	return m.Up()
+}
+
+// Check fails when the schema is dirty, newer than the binary, or older than the binary
+func (m *Migrator) Check() error {
+	version, dirty, err := m.Version()
+	if err != nil {
+		return fmt.Errorf("failed to read schema version: %w", err)
+	}
+	switch {
+	case dirty:
+		return fmt.Errorf("schema version %d is dirty: repair it and run `migrate force %d`", version, version)
+	case version > m.latest:
+		return fmt.Errorf("schema version %d is ahead of this binary (%d)", version, m.latest)
+	case version < m.latest:
+		return fmt.Errorf("schema version %d is behind this binary (%d): run `migrate up`", version, m.latest)
+	}
+	return nil
+}
+
+// PrepareSchema is run on boot. It refuses a dirty schema or one written by a newer binary,
+// resets the schema if asked to, applies pending migrations if autoMigrate is set, and
+// finally requires the schema to match the binary.
+func PrepareSchema(m *Migrator, autoMigrate, reset bool) error {
+	version, dirty, err := m.Version()
+	if err != nil {
+		return fmt.Errorf("failed to read schema version: %w", err)
+	}
+	if dirty || version > m.latest {
+		return m.Check()
+	}
+
+	switch {
+	case reset:
+		if err := m.Reset(); err != nil {
+			return err
+		}
+		log.Println("Database schema reset")
+	case autoMigrate:
+		if err := m.Up(); err != nil {
+			return err
+		}
+		log.Println("Database migrations applied successfully")
+	}
+
+	return m.Check()
+}
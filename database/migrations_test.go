@@ -0,0 +1,72 @@
+package database
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+
+	"github.com/golang-migrate/migrate/v4/database/sqlite"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestMigrator(t *testing.T) *Migrator {
+	_, migrator := openTestDB(t)
+	return migrator
+}
+
+func openTestDB(t *testing.T) (*sql.DB, *Migrator) {
+	db, err := ConnectSQLite(filepath.Join(t.TempDir(), "wallet.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	migrator, err := NewSQLiteMigrator(db)
+	require.NoError(t, err)
+	return db, migrator
+}
+
+func TestPrepareSchema(t *testing.T) {
+	t.Run("applies embedded migrations", func(t *testing.T) {
+		migrator := newTestMigrator(t)
+		require.NoError(t, PrepareSchema(migrator, true, false))
+
+		version, dirty, err := migrator.Version()
+		require.NoError(t, err)
+		assert.False(t, dirty)
+		assert.Equal(t, migrator.Latest(), version)
+	})
+
+	t.Run("refuses an outdated schema without auto-migrate", func(t *testing.T) {
+		migrator := newTestMigrator(t)
+		assert.ErrorContains(t, PrepareSchema(migrator, false, false), "run `migrate up`")
+	})
+
+	t.Run("refuses a dirty schema", func(t *testing.T) {
+		db, migrator := openTestDB(t)
+		require.NoError(t, migrator.Up())
+
+		// Mark the version dirty the way a failed migration would
+		driver, err := sqlite.WithInstance(db, &sqlite.Config{})
+		require.NoError(t, err)
+		require.NoError(t, driver.SetVersion(int(migrator.Latest()), true))
+
+		assert.ErrorContains(t, PrepareSchema(migrator, true, false), "is dirty")
+	})
+
+	t.Run("refuses a schema ahead of the binary", func(t *testing.T) {
+		migrator := newTestMigrator(t)
+		require.NoError(t, migrator.Force(int(migrator.Latest())+1))
+
+		assert.ErrorContains(t, PrepareSchema(migrator, true, false), "ahead of this binary")
+	})
+
+	t.Run("reset", func(t *testing.T) {
+		migrator := newTestMigrator(t)
+		require.NoError(t, migrator.Up())
+		require.NoError(t, PrepareSchema(migrator, false, true))
+
+		version, _, err := migrator.Version()
+		require.NoError(t, err)
+		assert.Equal(t, migrator.Latest(), version)
+	})
+}
@@ -0,0 +1,34 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"net/url"
+
+	_ "modernc.org/sqlite"
+)
+
+// ConnectSQLite opens the SQLite database file at path, creating it if needed. Transactions
+// start with BEGIN IMMEDIATE so a writer takes the database lock before reading balances, and
+// WAL mode lets readers proceed while a write is in progress.
+func ConnectSQLite(path string) (*sql.DB, error) {
+	params := url.Values{}
+	params.Set("_txlock", "immediate")
+	params.Add("_pragma", "busy_timeout(5000)")
+	params.Add("_pragma", "journal_mode(WAL)")
+	params.Add("_pragma", "foreign_keys(1)")
+
+	db, err := sql.Open("sqlite", "file:"+path+"?"+params.Encode())
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+
+	if err = db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to ping database: %w", err)
+	}
+
+	log.Printf("Successfully opened SQLite database %s", path)
+	return db, nil
+}
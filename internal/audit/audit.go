@@ -0,0 +1,100 @@
+// Package audit builds the hash chain of the audit log and checks it
+package audit
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"ITKtest/internal/models"
+)
+
+// SystemActor is the actor of changes not made on behalf of a request, such as startup
+// configuration
+const SystemActor = "system"
+
+type actorKey struct{}
+type requestIDKey struct{}
+
+// WithRequest records who makes the request and its id, for the audit records of the changes
+// it makes
+func WithRequest(ctx context.Context, actor, requestID string) context.Context {
+	ctx = context.WithValue(ctx, actorKey{}, actor)
+	return context.WithValue(ctx, requestIDKey{}, requestID)
+}
+
+// Actor returns the actor recorded in ctx, or SystemActor
+func Actor(ctx context.Context) string {
+	if actor, _ := ctx.Value(actorKey{}).(string); actor != "" {
+		return actor
+	}
+	return SystemActor
+}
+
+// RequestID returns the request id recorded in ctx, or ""
+func RequestID(ctx context.Context) string {
+	requestID, _ := ctx.Value(requestIDKey{}).(string)
+	return requestID
+}
+
+// NewRecord builds an unchained record of action on subject made by the actor of ctx. before
+// and after are stored as JSON; nil means the subject did not exist before or after.
+func NewRecord(ctx context.Context, action, subject string, before, after any) (models.AuditRecord, error) {
+	beforeJSON, err := json.Marshal(before)
+	if err != nil {
+		return models.AuditRecord{}, fmt.Errorf("failed to encode audit before value: %w", err)
+	}
+	afterJSON, err := json.Marshal(after)
+	if err != nil {
+		return models.AuditRecord{}, fmt.Errorf("failed to encode audit after value: %w", err)
+	}
+	return models.AuditRecord{
+		Action:    action,
+		Actor:     Actor(ctx),
+		RequestID: RequestID(ctx),
+		Subject:   subject,
+		Before:    beforeJSON,
+		After:     afterJSON,
+		// Postgres keeps microseconds, and the hash must match what is read back
+		CreatedAt: time.Now().UTC().Truncate(time.Microsecond),
+	}, nil
+}
+
+// Chain makes record the successor of prev, whose zero value stands for the start of the log
+func Chain(prev, record models.AuditRecord) models.AuditRecord {
+	record.Seq = prev.Seq + 1
+	record.PrevHash = prev.Hash
+	record.Hash = Hash(record)
+	return record
+}
+
+// Hash is the SHA-256 of the record contents and the hash of the previous record
+func Hash(record models.AuditRecord) string {
+	// A struct marshals its fields in a fixed order, so equal records always hash the same
+	contents, _ := json.Marshal(struct {
+		Seq       int64           `json:"seq"`
+		Action    string          `json:"action"`
+		Actor     string          `json:"actor"`
+		RequestID string          `json:"requestId"`
+		Subject   string          `json:"subject"`
+		Before    json.RawMessage `json:"before"`
+		After     json.RawMessage `json:"after"`
+		CreatedAt string          `json:"createdAt"`
+		PrevHash  string          `json:"prevHash"`
+	}{
+		Seq:       record.Seq,
+		Action:    record.Action,
+		Actor:     record.Actor,
+		RequestID: record.RequestID,
+		Subject:   record.Subject,
+		Before:    record.Before,
+		After:     record.After,
+		CreatedAt: record.CreatedAt.UTC().Format(time.RFC3339Nano),
+		PrevHash:  record.PrevHash,
+	})
+	sum := sha256.Sum256(contents)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,120 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"ITKtest/internal/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type sliceLog []models.AuditRecord
+
+func (l sliceLog) CountUnchained(_ context.Context, writtenBefore time.Time) (int64, error) {
+	return 0, nil
+}
+
+func (l sliceLog) Walk(_ context.Context, fn func(models.AuditRecord) error) error {
+	for _, record := range l {
+		if err := fn(record); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func chain(t *testing.T, n int) sliceLog {
+	ctx := WithRequest(context.Background(), "cert:teller", "req-1")
+	var log sliceLog
+	var prev models.AuditRecord
+	for i := 0; i < n; i++ {
+		record, err := NewRecord(ctx, models.AuditWalletDeposit, "wallet", map[string]int{"balance": i}, map[string]int{"balance": i + 1})
+		require.NoError(t, err)
+		prev = Chain(prev, record)
+		log = append(log, prev)
+	}
+	return log
+}
+
+func TestNewRecord(t *testing.T) {
+	record, err := NewRecord(context.Background(), models.AuditWebhookRegistered, "endpoint", nil, map[string]string{"url": "https://example.com"})
+	require.NoError(t, err)
+	assert.Equal(t, SystemActor, record.Actor)
+	assert.Equal(t, "", record.RequestID)
+	assert.Equal(t, json.RawMessage("null"), record.Before)
+	assert.Equal(t, json.RawMessage(`{"url":"https://example.com"}`), record.After)
+}
+
+func TestVerify(t *testing.T) {
+	t.Run("intact chain", func(t *testing.T) {
+		log := chain(t, 4)
+		report, err := Verify(context.Background(), log, time.Now())
+		require.NoError(t, err)
+		assert.Equal(t, int64(4), report.Records)
+		assert.Empty(t, report.Problems)
+		assert.Equal(t, log[3].Hash, report.Head)
+		assert.Equal(t, log[2].Hash, log[3].PrevHash)
+	})
+
+	t.Run("modified record", func(t *testing.T) {
+		log := chain(t, 4)
+		log[1].After = json.RawMessage(`{"balance":1000000}`)
+		report, err := Verify(context.Background(), log, time.Now())
+		require.NoError(t, err)
+		assert.Equal(t, []Problem{{Seq: 2, Reason: "contents do not match the hash"}}, report.Problems)
+	})
+
+	t.Run("modified record with recomputed hash", func(t *testing.T) {
+		log := chain(t, 4)
+		log[1].Actor = "someone-else"
+		log[1].Hash = Hash(log[1])
+		report, err := Verify(context.Background(), log, time.Now())
+		require.NoError(t, err)
+		assert.Equal(t, []Problem{{Seq: 3, Reason: "previous hash does not match the previous record"}}, report.Problems)
+	})
+
+	t.Run("deleted records", func(t *testing.T) {
+		log := chain(t, 5)
+		report, err := Verify(context.Background(), append(sliceLog{log[0]}, log[3:]...), time.Now())
+		require.NoError(t, err)
+		assert.Equal(t, []Problem{{Seq: 4, Reason: "records 2 to 3 are missing"}}, report.Problems)
+
+		report, err = Verify(context.Background(), log[1:], time.Now())
+		require.NoError(t, err)
+		assert.Equal(t, []Problem{{Seq: 2, Reason: "record 1 is missing"}}, report.Problems)
+	})
+}
+
+// pendingLog chains the sizes in batches one call at a time, then returns err
+type pendingLog struct {
+	batches []int
+	err     error
+}
+
+func (l *pendingLog) ChainPending(_ context.Context, limit int) (int, error) {
+	if len(l.batches) == 0 {
+		return 0, l.err
+	}
+	n := l.batches[0]
+	l.batches = l.batches[1:]
+	return n, nil
+}
+
+func TestChainer_RunDue(t *testing.T) {
+	chainer := NewChainer(&pendingLog{batches: []int{2, 2, 1}}, time.Second)
+	chainer.batchSize = 2
+	n, err := chainer.RunDue(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 5, n)
+
+	chainer = NewChainer(&pendingLog{batches: []int{2}, err: errors.New("connection reset")}, time.Second)
+	chainer.batchSize = 2
+	n, err = chainer.RunDue(context.Background())
+	assert.Error(t, err)
+	assert.Equal(t, 2, n)
+}
@@ -0,0 +1,61 @@
+package audit
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// chainBatchSize is the number of records chained per transaction
+const chainBatchSize = 1000
+
+// Pending chains the records written since the last call, up to limit at a time
+type Pending interface {
+	ChainPending(ctx context.Context, limit int) (int, error)
+}
+
+// Chainer links the records written by changes into the hash chain. Changes write their
+// records unchained, so that they do not queue behind the head of the chain; a record is
+// covered by Verify once it is chained. Several instances may run the chainer.
+type Chainer struct {
+	log       Pending
+	interval  time.Duration
+	batchSize int
+}
+
+func NewChainer(log Pending, interval time.Duration) *Chainer {
+	return &Chainer{log: log, interval: interval, batchSize: chainBatchSize}
+}
+
+// Run chains pending records every interval until ctx is cancelled
+func (c *Chainer) Run(ctx context.Context) {
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := c.RunDue(ctx); err != nil && ctx.Err() == nil {
+			log.Printf("audit chain: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunDue chains every pending record in batches and returns how many it chained
+func (c *Chainer) RunDue(ctx context.Context) (int, error) {
+	total := 0
+	for {
+		n, err := c.log.ChainPending(ctx, c.batchSize)
+		total += n
+		if err != nil {
+			return total, err
+		}
+		if n < c.batchSize {
+			return total, nil
+		}
+	}
+}
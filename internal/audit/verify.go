@@ -0,0 +1,74 @@
+package audit
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"ITKtest/internal/models"
+)
+
+// Problem is a break in the audit chain found by Verify
+type Problem struct {
+	Seq    int64
+	Reason string
+}
+
+func (p Problem) String() string {
+	return fmt.Sprintf("record %d: %s", p.Seq, p.Reason)
+}
+
+// Report is the result of Verify. Head is the hash of the last record: deleting records from
+// the end of the log leaves a valid chain, so it is worth keeping Head outside the database
+// and checking that a later report still contains it. Unchained counts the records written
+// before the cutoff given to Verify that are still not chained: until a record is chained,
+// deleting it leaves no trace, so they mean the chainer has stopped or fallen behind.
+type Report struct {
+	Records   int64
+	Head      string
+	Problems  []Problem
+	Unchained int64
+}
+
+// Walker calls fn for every chained audit record in sequence order, and counts the records
+// written before a time that are still waiting to be chained
+type Walker interface {
+	Walk(ctx context.Context, fn func(models.AuditRecord) error) error
+	CountUnchained(ctx context.Context, writtenBefore time.Time) (int64, error)
+}
+
+// Verify recomputes the chain and reports every record that was modified, and every gap left
+// by deleted records. After a problem it carries on from the stored hash, so that one edit is
+// reported once rather than breaking every later record. Records written before chainedBy
+// should have been chained by now; those that are not are counted in Report.Unchained.
+func Verify(ctx context.Context, log Walker, chainedBy time.Time) (Report, error) {
+	var report Report
+	var prev models.AuditRecord
+	err := log.Walk(ctx, func(record models.AuditRecord) error {
+		report.Records++
+		switch {
+		case record.Seq != prev.Seq+1:
+			report.Problems = append(report.Problems, Problem{Seq: record.Seq, Reason: missing(prev.Seq+1, record.Seq-1)})
+		case record.PrevHash != prev.Hash:
+			report.Problems = append(report.Problems, Problem{Seq: record.Seq, Reason: "previous hash does not match the previous record"})
+		}
+		if Hash(record) != record.Hash {
+			report.Problems = append(report.Problems, Problem{Seq: record.Seq, Reason: "contents do not match the hash"})
+		}
+		prev = record
+		return nil
+	})
+	report.Head = prev.Hash
+	if err != nil {
+		return report, err
+	}
+	report.Unchained, err = log.CountUnchained(ctx, chainedBy)
+	return report, err
+}
+
+func missing(from, to int64) string {
+	if from == to {
+		return fmt.Sprintf("record %d is missing", from)
+	}
+	return fmt.Sprintf("records %d to %d are missing", from, to)
+}
@@ -0,0 +1,34 @@
+package auth
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+)
+
+type principalKey struct{}
+
+// WithPrincipal records the authenticated client making the request
+func WithPrincipal(ctx context.Context, principal string) context.Context {
+	return context.WithValue(ctx, principalKey{}, principal)
+}
+
+// Principal returns the authenticated client of ctx, or "" for anonymous requests
+func Principal(ctx context.Context) string {
+	principal, _ := ctx.Value(principalKey{}).(string)
+	return principal
+}
+
+// PrincipalFromTLS maps a verified client certificate to a principal: the common name of its
+// subject. Connections without a verified certificate, or whose certificate has no common name,
+// are anonymous.
+func PrincipalFromTLS(state *tls.ConnectionState) string {
+	if state == nil || len(state.VerifiedChains) == 0 || len(state.VerifiedChains[0]) == 0 {
+		return ""
+	}
+	return principalFromCertificate(state.VerifiedChains[0][0])
+}
+
+func principalFromCertificate(cert *x509.Certificate) string {
+	return cert.Subject.CommonName
+}
@@ -0,0 +1,131 @@
+package auth
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// CertReloader serves a certificate and an optional client CA bundle from files, reloading them
+// when they change so that certificates can be rotated without a restart
+type CertReloader struct {
+	certFile   string
+	keyFile    string
+	caFile     string
+	clientAuth tls.ClientAuthType
+
+	mu        sync.RWMutex
+	cert      *tls.Certificate
+	clientCAs *x509.CertPool
+	stamp     string
+}
+
+// NewCertReloader loads the certificate, its key and, when caFile is set, the CA bundle that
+// client certificates are verified against. clientAuth is tls.NoClientCert without a bundle.
+func NewCertReloader(certFile, keyFile, caFile string, clientAuth tls.ClientAuthType) (*CertReloader, error) {
+	if caFile == "" && clientAuth != tls.NoClientCert {
+		return nil, errors.New("client certificate verification needs a CA bundle")
+	}
+	r := &CertReloader{certFile: certFile, keyFile: keyFile, caFile: caFile, clientAuth: clientAuth}
+	if err := r.Reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Reload reads the files again. On error the previously loaded certificates stay in use.
+func (r *CertReloader) Reload() error {
+	stamp := r.fileStamp()
+
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return fmt.Errorf("failed to load TLS certificate: %w", err)
+	}
+
+	var clientCAs *x509.CertPool
+	if r.caFile != "" {
+		pem, err := os.ReadFile(r.caFile)
+		if err != nil {
+			return fmt.Errorf("failed to read client CA bundle: %w", err)
+		}
+		clientCAs = x509.NewCertPool()
+		if !clientCAs.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("client CA bundle %s contains no certificates", r.caFile)
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.cert = &cert
+	r.clientCAs = clientCAs
+	r.stamp = stamp
+	return nil
+}
+
+// Run reloads the files every interval if they changed, until ctx is cancelled
+func (r *CertReloader) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		r.mu.RLock()
+		unchanged := r.stamp == r.fileStamp()
+		r.mu.RUnlock()
+		if unchanged {
+			continue
+		}
+		if err := r.Reload(); err != nil {
+			log.Printf("TLS certificate reload failed, keeping the current certificate: %v", err)
+			continue
+		}
+		log.Println("TLS certificates reloaded")
+	}
+}
+
+// fileStamp identifies the contents of the files by size and modification time
+func (r *CertReloader) fileStamp() string {
+	var stamp string
+	for _, path := range []string{r.certFile, r.keyFile, r.caFile} {
+		if path == "" {
+			continue
+		}
+		if info, err := os.Stat(path); err == nil {
+			stamp += fmt.Sprintf("%s:%d:%d;", path, info.Size(), info.ModTime().UnixNano())
+		}
+	}
+	return stamp
+}
+
+// ServerConfig returns a TLS config that picks up reloaded files on each handshake. nextProtos
+// are the ALPN protocols of the server, e.g. "h2" for gRPC.
+func (r *CertReloader) ServerConfig(nextProtos ...string) *tls.Config {
+	config := func() *tls.Config {
+		r.mu.RLock()
+		defer r.mu.RUnlock()
+		return &tls.Config{
+			MinVersion:   tls.VersionTLS12,
+			Certificates: []tls.Certificate{*r.cert},
+			ClientCAs:    r.clientCAs,
+			ClientAuth:   r.clientAuth,
+			NextProtos:   nextProtos,
+		}
+	}
+
+	base := config()
+	base.GetConfigForClient = func(*tls.ClientHelloInfo) (*tls.Config, error) {
+		return config(), nil
+	}
+	return base
+}
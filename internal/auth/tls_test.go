@@ -0,0 +1,171 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pem  []byte
+}
+
+func newTestCA(t *testing.T) *testCA {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return &testCA{cert: cert, key: key, pem: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})}
+}
+
+// issue returns PEM encoded certificate and key signed by the CA
+func (ca *testCA) issue(t *testing.T, commonName string, serial int64, usage x509.ExtKeyUsage) ([]byte, []byte) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: commonName},
+		DNSNames:     []string{"localhost"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+}
+
+func writeFile(t *testing.T, path string, data []byte) {
+	require.NoError(t, os.WriteFile(path, data, 0o600))
+}
+
+// serve starts an HTTPS server that answers with the principal of the client
+func serve(t *testing.T, config *tls.Config) string {
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", config)
+	require.NoError(t, err)
+	server := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, PrincipalFromTLS(r.TLS))
+	})}
+	go server.Serve(ln)
+	t.Cleanup(func() { server.Close() })
+	return "https://" + ln.Addr().String()
+}
+
+func TestCertReloader_MutualTLS(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCA(t)
+	certPEM, keyPEM := ca.issue(t, "localhost", 2, x509.ExtKeyUsageServerAuth)
+	certFile, keyFile, caFile := filepath.Join(dir, "server.crt"), filepath.Join(dir, "server.key"), filepath.Join(dir, "ca.crt")
+	writeFile(t, certFile, certPEM)
+	writeFile(t, keyFile, keyPEM)
+	writeFile(t, caFile, ca.pem)
+
+	reloader, err := NewCertReloader(certFile, keyFile, caFile, tls.VerifyClientCertIfGiven)
+	require.NoError(t, err)
+	url := serve(t, reloader.ServerConfig("http/1.1"))
+
+	roots := x509.NewCertPool()
+	roots.AppendCertsFromPEM(ca.pem)
+	clientCertPEM, clientKeyPEM := ca.issue(t, "partner-a", 3, x509.ExtKeyUsageClientAuth)
+	clientCert, err := tls.X509KeyPair(clientCertPEM, clientKeyPEM)
+	require.NoError(t, err)
+
+	get := func(certs ...tls.Certificate) (string, *x509.Certificate) {
+		client := &http.Client{Transport: &http.Transport{
+			TLSClientConfig:   &tls.Config{RootCAs: roots, Certificates: certs},
+			DisableKeepAlives: true,
+		}}
+		resp, err := client.Get(url)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		return string(body), resp.TLS.PeerCertificates[0]
+	}
+
+	principal, serverCert := get(clientCert)
+	assert.Equal(t, "partner-a", principal)
+	assert.Equal(t, int64(2), serverCert.SerialNumber.Int64())
+
+	// Without a client certificate the connection is anonymous
+	principal, _ = get()
+	assert.Empty(t, principal)
+
+	// A rotated certificate is served after a reload without restarting the listener
+	certPEM, keyPEM = ca.issue(t, "localhost", 4, x509.ExtKeyUsageServerAuth)
+	writeFile(t, certFile, certPEM)
+	writeFile(t, keyFile, keyPEM)
+	require.NoError(t, reloader.Reload())
+	_, serverCert = get(clientCert)
+	assert.Equal(t, int64(4), serverCert.SerialNumber.Int64())
+
+	// A broken file keeps the previous certificate in use
+	writeFile(t, certFile, []byte("garbage"))
+	assert.Error(t, reloader.Reload())
+	_, serverCert = get(clientCert)
+	assert.Equal(t, int64(4), serverCert.SerialNumber.Int64())
+}
+
+func TestCertReloader_RequireClientCert(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCA(t)
+	other := newTestCA(t)
+	certPEM, keyPEM := ca.issue(t, "localhost", 2, x509.ExtKeyUsageServerAuth)
+	certFile, keyFile, caFile := filepath.Join(dir, "server.crt"), filepath.Join(dir, "server.key"), filepath.Join(dir, "ca.crt")
+	writeFile(t, certFile, certPEM)
+	writeFile(t, keyFile, keyPEM)
+	writeFile(t, caFile, ca.pem)
+
+	_, err := NewCertReloader(certFile, keyFile, "", tls.RequireAndVerifyClientCert)
+	assert.Error(t, err)
+
+	reloader, err := NewCertReloader(certFile, keyFile, caFile, tls.RequireAndVerifyClientCert)
+	require.NoError(t, err)
+	url := serve(t, reloader.ServerConfig("http/1.1"))
+
+	roots := x509.NewCertPool()
+	roots.AppendCertsFromPEM(ca.pem)
+	// A certificate from an unknown CA is rejected like no certificate at all
+	untrustedPEM, untrustedKeyPEM := other.issue(t, "intruder", 3, x509.ExtKeyUsageClientAuth)
+	untrusted, err := tls.X509KeyPair(untrustedPEM, untrustedKeyPEM)
+	require.NoError(t, err)
+
+	for _, certs := range [][]tls.Certificate{nil, {untrusted}} {
+		client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: roots, Certificates: certs}}}
+		_, err := client.Get(url)
+		assert.Error(t, err)
+	}
+}
@@ -0,0 +1,64 @@
+package bonus
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"ITKtest/internal/repository"
+)
+
+// expiryBatchSize is the number of lots expired per transaction
+const expiryBatchSize = 500
+
+// Expirer journals the expiry of bonus lots. A lot stops counting towards the bonus balance as
+// soon as it expires; the expirer records what was left of it and zeroes it. Expiry is
+// idempotent, so several instances may run the expirer.
+type Expirer struct {
+	repo      repository.BonusRepository
+	interval  time.Duration
+	batchSize int
+	now       func() time.Time
+}
+
+func NewExpirer(repo repository.BonusRepository, interval time.Duration) *Expirer {
+	return &Expirer{repo: repo, interval: interval, batchSize: expiryBatchSize, now: time.Now}
+}
+
+// Run expires due lots every interval until ctx is cancelled
+func (e *Expirer) Run(ctx context.Context) {
+	ticker := time.NewTicker(e.interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := e.RunDue(ctx); err != nil && ctx.Err() == nil {
+			log.Printf("bonus expiry: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunDue expires every lot due as of now in batches and returns how many it expired
+func (e *Expirer) RunDue(ctx context.Context) (int, error) {
+	now := e.now()
+	total := 0
+	for {
+		n, err := e.repo.ExpireLots(ctx, now, e.batchSize)
+		total += n
+		if err != nil {
+			return total, err
+		}
+		if n < e.batchSize {
+			break
+		}
+	}
+	if total > 0 {
+		log.Printf("Expired %d bonus lots", total)
+	}
+	return total, nil
+}
@@ -0,0 +1,38 @@
+package bonus
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"ITKtest/internal/repository"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestExpirer_RunDue(t *testing.T) {
+	repo := &repository.MockBonusRepository{}
+	expirer := NewExpirer(repo, time.Minute)
+	expirer.batchSize = 2
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	expirer.now = func() time.Time { return now }
+
+	// Full batches are followed by another one until a short batch
+	repo.On("ExpireLots", mock.Anything, now, 2).Return(2, nil).Twice()
+	repo.On("ExpireLots", mock.Anything, now, 2).Return(1, nil).Once()
+	n, err := expirer.RunDue(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 5, n)
+
+	// A failure stops the run with the lots expired so far
+	now = now.Add(time.Minute)
+	repo.On("ExpireLots", mock.Anything, now, 2).Return(2, nil).Once()
+	repo.On("ExpireLots", mock.Anything, now, 2).Return(0, errors.New("connection reset")).Once()
+	n, err = expirer.RunDue(context.Background())
+	assert.Error(t, err)
+	assert.Equal(t, 2, n)
+
+	repo.AssertExpectations(t)
+}
@@ -0,0 +1,152 @@
+package cache
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+
+	"ITKtest/internal/models"
+
+	"github.com/google/uuid"
+)
+
+// BalanceCache is an LRU cache of wallets whose entries expire after a TTL. Entries are dropped
+// when this instance changes a wallet and, through the Postgres notification feed, when any
+// other instance does.
+type BalanceCache struct {
+	mu         sync.Mutex
+	maxEntries int
+	ttl        time.Duration
+	order      *list.List
+	entries    map[uuid.UUID]*list.Element
+	// generation grows on every invalidation, so a value read from the database before an
+	// invalidation is not stored after it
+	generation uint64
+	now        func() time.Time
+}
+
+type cacheEntry struct {
+	wallet    models.Wallet
+	expiresAt time.Time
+}
+
+func NewBalanceCache(maxEntries int, ttl time.Duration) *BalanceCache {
+	return &BalanceCache{
+		maxEntries: maxEntries,
+		ttl:        ttl,
+		order:      list.New(),
+		entries:    make(map[uuid.UUID]*list.Element),
+		now:        time.Now,
+	}
+}
+
+// TTL is the longest time an entry is served
+func (c *BalanceCache) TTL() time.Duration {
+	return c.ttl
+}
+
+// Get returns the cached wallet, if any, and the generation to pass to Set after a miss
+func (c *BalanceCache) Get(walletID uuid.UUID) (models.Wallet, uint64, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.entries[walletID]
+	if !ok {
+		return models.Wallet{}, c.generation, false
+	}
+	entry := elem.Value.(*cacheEntry)
+	if !c.now().Before(entry.expiresAt) {
+		c.removeElement(elem)
+		return models.Wallet{}, c.generation, false
+	}
+	c.order.MoveToFront(elem)
+	return entry.wallet, c.generation, true
+}
+
+// Set stores wallet unless an invalidation happened since generation was returned by Get
+func (c *BalanceCache) Set(wallet models.Wallet, generation uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if generation != c.generation {
+		return
+	}
+
+	entry := &cacheEntry{wallet: wallet, expiresAt: c.now().Add(c.ttl)}
+	if elem, ok := c.entries[wallet.ID]; ok {
+		elem.Value = entry
+		c.order.MoveToFront(elem)
+		return
+	}
+
+	c.entries[wallet.ID] = c.order.PushFront(entry)
+	for c.order.Len() > c.maxEntries {
+		c.removeElement(c.order.Back())
+	}
+}
+
+func (c *BalanceCache) Invalidate(walletID uuid.UUID) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.generation++
+	if elem, ok := c.entries[walletID]; ok {
+		c.removeElement(elem)
+	}
+}
+
+// Purge drops every entry
+func (c *BalanceCache) Purge() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.generation++
+	c.order.Init()
+	c.entries = make(map[uuid.UUID]*list.Element)
+}
+
+func (c *BalanceCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
+
+func (c *BalanceCache) removeElement(elem *list.Element) {
+	c.order.Remove(elem)
+	delete(c.entries, elem.Value.(*cacheEntry).wallet.ID)
+}
+
+// Publish invalidates the wallet of a journal entry committed by any instance
+func (c *BalanceCache) Publish(transaction models.Transaction) {
+	c.Invalidate(transaction.WalletID)
+}
+
+// FeedInterrupted purges the cache, since invalidations may have been missed
+func (c *BalanceCache) FeedInterrupted() {
+	c.Purge()
+}
+
+// Status tells how a balance read was served
+type Status string
+
+const (
+	StatusHit    Status = "HIT"
+	StatusMiss   Status = "MISS"
+	StatusBypass Status = "BYPASS"
+)
+
+type statusKey struct{}
+
+// WithStatus returns a context in which the caching layer records the Status of a read
+func WithStatus(ctx context.Context) (context.Context, *Status) {
+	status := new(Status)
+	return context.WithValue(ctx, statusKey{}, status), status
+}
+
+// SetStatus records status in ctx if it was prepared by WithStatus
+func SetStatus(ctx context.Context, status Status) {
+	if s, ok := ctx.Value(statusKey{}).(*Status); ok {
+		*s = status
+	}
+}
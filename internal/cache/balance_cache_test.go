@@ -0,0 +1,110 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"ITKtest/internal/models"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBalanceCache_GetSet(t *testing.T) {
+	c := NewBalanceCache(10, time.Minute)
+	wallet := models.Wallet{ID: uuid.New(), Balance: 100}
+
+	_, generation, ok := c.Get(wallet.ID)
+	assert.False(t, ok)
+
+	c.Set(wallet, generation)
+	cached, _, ok := c.Get(wallet.ID)
+	assert.True(t, ok)
+	assert.Equal(t, wallet, cached)
+}
+
+func TestBalanceCache_TTL(t *testing.T) {
+	now := time.Now()
+	c := NewBalanceCache(10, time.Second)
+	c.now = func() time.Time { return now }
+	wallet := models.Wallet{ID: uuid.New(), Balance: 100}
+
+	_, generation, _ := c.Get(wallet.ID)
+	c.Set(wallet, generation)
+
+	now = now.Add(999 * time.Millisecond)
+	_, _, ok := c.Get(wallet.ID)
+	assert.True(t, ok)
+
+	now = now.Add(time.Millisecond)
+	_, _, ok = c.Get(wallet.ID)
+	assert.False(t, ok)
+	assert.Equal(t, 0, c.Len())
+}
+
+func TestBalanceCache_EvictsLeastRecentlyUsed(t *testing.T) {
+	c := NewBalanceCache(2, time.Minute)
+	first := models.Wallet{ID: uuid.New()}
+	second := models.Wallet{ID: uuid.New()}
+	third := models.Wallet{ID: uuid.New()}
+
+	_, generation, _ := c.Get(first.ID)
+	c.Set(first, generation)
+	c.Set(second, generation)
+	// Touch first so second becomes the least recently used
+	c.Get(first.ID)
+	c.Set(third, generation)
+
+	assert.Equal(t, 2, c.Len())
+	_, _, ok := c.Get(first.ID)
+	assert.True(t, ok)
+	_, _, ok = c.Get(second.ID)
+	assert.False(t, ok)
+	_, _, ok = c.Get(third.ID)
+	assert.True(t, ok)
+}
+
+func TestBalanceCache_Invalidate(t *testing.T) {
+	c := NewBalanceCache(10, time.Minute)
+	wallet := models.Wallet{ID: uuid.New(), Balance: 100}
+
+	_, generation, _ := c.Get(wallet.ID)
+	c.Set(wallet, generation)
+	c.Publish(models.Transaction{WalletID: wallet.ID})
+
+	_, _, ok := c.Get(wallet.ID)
+	assert.False(t, ok)
+}
+
+func TestBalanceCache_SetAfterInvalidateIsDropped(t *testing.T) {
+	c := NewBalanceCache(10, time.Minute)
+	wallet := models.Wallet{ID: uuid.New(), Balance: 100}
+
+	// A read misses, a write invalidates the wallet, then the stale read result arrives
+	_, generation, _ := c.Get(wallet.ID)
+	c.Invalidate(wallet.ID)
+	c.Set(wallet, generation)
+
+	_, _, ok := c.Get(wallet.ID)
+	assert.False(t, ok)
+}
+
+func TestBalanceCache_FeedInterrupted(t *testing.T) {
+	c := NewBalanceCache(10, time.Minute)
+	_, generation, _ := c.Get(uuid.Nil)
+	c.Set(models.Wallet{ID: uuid.New()}, generation)
+	c.Set(models.Wallet{ID: uuid.New()}, generation)
+
+	c.FeedInterrupted()
+	assert.Equal(t, 0, c.Len())
+}
+
+func TestStatus(t *testing.T) {
+	// Without WithStatus the status is simply not recorded
+	SetStatus(context.Background(), StatusHit)
+
+	ctx, status := WithStatus(context.Background())
+	SetStatus(ctx, StatusMiss)
+	assert.Equal(t, StatusMiss, *status)
+}
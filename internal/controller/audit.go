@@ -0,0 +1,82 @@
+package controller
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"strings"
+
+	"ITKtest/internal/audit"
+	"ITKtest/internal/auth"
+
+	"github.com/google/uuid"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+)
+
+// RequestIDHeader carries the request id recorded in the audit log. A client may set it to
+// correlate its own logs; otherwise one is generated. It is echoed in the response.
+const RequestIDHeader = "X-Request-Id"
+
+// maxRequestIDLength bounds client-supplied request ids; longer ones are replaced
+const maxRequestIDLength = 128
+
+func requestID(supplied string) string {
+	if supplied != "" && len(supplied) <= maxRequestIDLength {
+		return supplied
+	}
+	return uuid.NewString()
+}
+
+// auditActor names the caller: the client certificate principal, or else the client address
+func auditActor(ctx context.Context, addr string) string {
+	if principal := auth.Principal(ctx); principal != "" {
+		return "cert:" + principal
+	}
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		addr = host
+	}
+	return "ip:" + addr
+}
+
+// AuditContext is an HTTP middleware recording the actor and request id of the request for
+// the audit log. It must run after ClientPrincipal.
+func AuditContext(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := requestID(r.Header.Get(RequestIDHeader))
+		w.Header().Set(RequestIDHeader, id)
+		ctx := audit.WithRequest(r.Context(), auditActor(r.Context(), r.RemoteAddr), id)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+func grpcAuditContext(ctx context.Context) (context.Context, string) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	supplied := ""
+	if values := md.Get(strings.ToLower(RequestIDHeader)); len(values) > 0 {
+		supplied = values[0]
+	}
+	id := requestID(supplied)
+
+	addr := ""
+	if p, ok := peer.FromContext(ctx); ok {
+		addr = p.Addr.String()
+	}
+	return audit.WithRequest(ctx, auditActor(ctx, addr), id), id
+}
+
+// AuditContextUnaryInterceptor records the actor and request id of unary calls and returns
+// the request id in the x-request-id header. It must run after ClientPrincipalUnaryInterceptor.
+func AuditContextUnaryInterceptor(ctx context.Context, req interface{}, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	ctx, id := grpcAuditContext(ctx)
+	_ = grpc.SetHeader(ctx, metadata.Pairs(strings.ToLower(RequestIDHeader), id))
+	return handler(ctx, req)
+}
+
+// AuditContextStreamInterceptor records the actor and request id of streaming calls
+func AuditContextStreamInterceptor(srv interface{}, ss grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	ctx, id := grpcAuditContext(ss.Context())
+	_ = ss.SetHeader(metadata.Pairs(strings.ToLower(RequestIDHeader), id))
+	return handler(srv, &contextServerStream{ServerStream: ss, ctx: ctx})
+}
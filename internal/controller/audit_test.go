@@ -0,0 +1,53 @@
+package controller
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"ITKtest/internal/audit"
+	"ITKtest/internal/auth"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAuditContext(t *testing.T) {
+	tests := []struct {
+		name      string
+		principal string
+		requestID string
+		actor     string
+		keepID    bool
+	}{
+		{name: "anonymous client", actor: "ip:192.0.2.1", keepID: false},
+		{name: "client certificate", principal: "teller", actor: "cert:teller"},
+		{name: "supplied request id", requestID: "req-42", actor: "ip:192.0.2.1", keepID: true},
+		{name: "oversized request id", requestID: strings.Repeat("x", maxRequestIDLength+1), actor: "ip:192.0.2.1", keepID: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var actor, id string
+			handler := AuditContext(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				actor, id = audit.Actor(r.Context()), audit.RequestID(r.Context())
+			}))
+
+			req := httptest.NewRequest("POST", "/api/v1/wallet", nil)
+			req.RemoteAddr = "192.0.2.1:51234"
+			if tt.principal != "" {
+				req = req.WithContext(auth.WithPrincipal(req.Context(), tt.principal))
+			}
+			if tt.requestID != "" {
+				req.Header.Set(RequestIDHeader, tt.requestID)
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.actor, actor)
+			assert.NotEmpty(t, id)
+			assert.Equal(t, id, w.Header().Get(RequestIDHeader))
+			assert.Equal(t, tt.keepID, id == tt.requestID)
+		})
+	}
+}
@@ -0,0 +1,126 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"ITKtest/internal/models"
+	"ITKtest/internal/service"
+	"ITKtest/responder"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+type BalanceHistoryController struct {
+	service   service.BalanceHistoryService
+	responder responder.Responder
+}
+
+func NewBalanceHistoryController(service service.BalanceHistoryService, responder responder.Responder) *BalanceHistoryController {
+	return &BalanceHistoryController{
+		service:   service,
+		responder: responder,
+	}
+}
+
+// GetBalanceAt returns the balance of a wallet as of the RFC 3339 timestamp in the at parameter
+func (c *BalanceHistoryController) GetBalanceAt(w http.ResponseWriter, r *http.Request) {
+	walletID, err := uuid.Parse(chi.URLParam(r, "walletId"))
+	if err != nil {
+		c.responder.Error(w, http.StatusBadRequest, "Invalid wallet ID")
+		return
+	}
+
+	at, err := time.Parse(time.RFC3339, r.URL.Query().Get("at"))
+	if err != nil {
+		c.responder.Error(w, http.StatusBadRequest, "Parameter at must be an RFC 3339 timestamp")
+		return
+	}
+
+	balance, err := c.service.GetBalanceAt(r.Context(), walletID, at)
+	if err != nil {
+		if errors.Is(err, service.ErrFutureTime) {
+			c.responder.Error(w, http.StatusBadRequest, "Parameter at must not be in the future")
+			return
+		}
+		apiErr := walletError(err)
+		c.responder.Error(w, apiErr.httpStatus, apiErr.message)
+		return
+	}
+
+	c.responder.OutputJSON(w, http.StatusOK, models.WalletBalanceAtResponse{
+		WalletID: walletID,
+		Balance:  balance,
+		At:       at,
+	})
+}
+
+// GetStatement streams the statement of a wallet between the RFC 3339 timestamps in the from
+// and to parameters (to defaults to now) as a CSV or JSONL download chosen by format. Errors
+// found before the first line get a JSON error response; later ones can only end the download.
+func (c *BalanceHistoryController) GetStatement(w http.ResponseWriter, r *http.Request) {
+	walletID, err := uuid.Parse(chi.URLParam(r, "walletId"))
+	if err != nil {
+		c.responder.Error(w, http.StatusBadRequest, "Invalid wallet ID")
+		return
+	}
+
+	query := r.URL.Query()
+	from, err := time.Parse(time.RFC3339, query.Get("from"))
+	if err != nil {
+		c.responder.Error(w, http.StatusBadRequest, "Parameter from must be an RFC 3339 timestamp")
+		return
+	}
+	to := time.Now()
+	if query.Has("to") {
+		if to, err = time.Parse(time.RFC3339, query.Get("to")); err != nil {
+			c.responder.Error(w, http.StatusBadRequest, "Parameter to must be an RFC 3339 timestamp")
+			return
+		}
+	}
+	format := query.Get("format")
+	if format == "" {
+		format = "csv"
+	}
+	if !slices.Contains(responder.StreamFormats, format) {
+		c.responder.Error(w, http.StatusBadRequest, fmt.Sprintf("Parameter format must be one of %s", strings.Join(responder.StreamFormats, ", ")))
+		return
+	}
+
+	var stream responder.StreamWriter
+	err = c.service.WriteStatement(r.Context(), walletID, from, to, func(line models.StatementLine) error {
+		if stream == nil {
+			var err error
+			if stream, err = responder.NewStream(w, format, "statement-"+walletID.String()); err != nil {
+				return err
+			}
+		}
+		return stream.Write(line)
+	})
+	if stream != nil {
+		if err == nil {
+			err = stream.Close()
+		}
+		if err != nil {
+			slog.Warn("Statement download aborted", "wallet", walletID, "error", err)
+		}
+		return
+	}
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrInvalidRange):
+			c.responder.Error(w, http.StatusBadRequest, "Parameter from must not be after to")
+		case errors.Is(err, service.ErrFutureTime):
+			c.responder.Error(w, http.StatusBadRequest, "Parameter to must not be in the future")
+		default:
+			apiErr := walletError(err)
+			c.responder.Error(w, apiErr.httpStatus, apiErr.message)
+		}
+	}
+}
@@ -0,0 +1,220 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"ITKtest/internal/models"
+	"ITKtest/internal/repository"
+	"ITKtest/internal/service"
+	"ITKtest/responder"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestBalanceHistoryController_GetBalanceAt(t *testing.T) {
+	walletID := uuid.New()
+	at := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name            string
+		path            string
+		mockSetup       func(*service.MockBalanceHistoryService)
+		expectedStatus  int
+		expectedBalance int64
+		expectedError   string
+	}{
+		{
+			name: "balance at timestamp",
+			path: "/api/v1/wallets/" + walletID.String() + "/balance?at=2026-03-01T03:00:00%2B03:00",
+			mockSetup: func(m *service.MockBalanceHistoryService) {
+				m.On("GetBalanceAt", mock.Anything, walletID, mock.MatchedBy(at.Equal)).Return(int64(750), nil)
+			},
+			expectedStatus:  http.StatusOK,
+			expectedBalance: 750,
+		},
+		{
+			name:           "missing timestamp",
+			path:           "/api/v1/wallets/" + walletID.String() + "/balance",
+			mockSetup:      func(m *service.MockBalanceHistoryService) {},
+			expectedStatus: http.StatusBadRequest,
+			expectedError:  "RFC 3339",
+		},
+		{
+			name:           "invalid wallet ID",
+			path:           "/api/v1/wallets/invalid/balance?at=2026-03-01T00:00:00Z",
+			mockSetup:      func(m *service.MockBalanceHistoryService) {},
+			expectedStatus: http.StatusBadRequest,
+			expectedError:  "Invalid wallet ID",
+		},
+		{
+			name: "future timestamp",
+			path: "/api/v1/wallets/" + walletID.String() + "/balance?at=2026-03-01T00:00:00Z",
+			mockSetup: func(m *service.MockBalanceHistoryService) {
+				m.On("GetBalanceAt", mock.Anything, walletID, mock.Anything).
+					Return(int64(0), service.ErrFutureTime)
+			},
+			expectedStatus: http.StatusBadRequest,
+			expectedError:  "Parameter at must not be in the future",
+		},
+		{
+			name: "wallet not found",
+			path: "/api/v1/wallets/" + walletID.String() + "/balance?at=2026-03-01T00:00:00Z",
+			mockSetup: func(m *service.MockBalanceHistoryService) {
+				m.On("GetBalanceAt", mock.Anything, walletID, mock.Anything).Return(int64(0), repository.ErrWalletNotFound)
+			},
+			expectedStatus: http.StatusNotFound,
+			expectedError:  "Wallet not found",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := &service.MockBalanceHistoryService{}
+			tt.mockSetup(mockService)
+			controller := NewBalanceHistoryController(mockService, responder.NewJSONResponder())
+
+			r := chi.NewRouter()
+			r.Get("/api/v1/wallets/{walletId}/balance", controller.GetBalanceAt)
+
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest("GET", tt.path, nil))
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			if tt.expectedError != "" {
+				var response map[string]string
+				assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+				assert.Contains(t, response["error"], tt.expectedError)
+			} else {
+				var response models.WalletBalanceAtResponse
+				assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+				assert.Equal(t, tt.expectedBalance, response.Balance)
+				assert.True(t, at.Equal(response.At))
+			}
+			mockService.AssertExpectations(t)
+		})
+	}
+}
+
+func TestBalanceHistoryController_GetStatement(t *testing.T) {
+	walletID := uuid.New()
+	from := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	to := from.Add(24 * time.Hour)
+	lines := []models.StatementLine{
+		{Type: models.StatementOpening, Balance: 1000, At: from},
+		{Type: models.StatementTransaction, TransactionID: 7, OperationType: models.DEPOSIT, Amount: 500, Reference: "bank-1", Balance: 1500, At: from.Add(time.Hour)},
+		{Type: models.StatementClosing, Balance: 1500, At: to},
+	}
+	base := "/api/v1/wallets/" + walletID.String() + "/statement?from=2026-03-01T00:00:00Z&to=2026-03-02T00:00:00Z"
+
+	tests := []struct {
+		name           string
+		path           string
+		mockSetup      func(*service.MockBalanceHistoryService)
+		expectedStatus int
+		expectedType   string
+		expectedBody   string
+		expectedError  string
+	}{
+		{
+			name: "csv by default",
+			path: base,
+			mockSetup: func(m *service.MockBalanceHistoryService) {
+				m.On("WriteStatement", mock.Anything, walletID, mock.MatchedBy(from.Equal), mock.MatchedBy(to.Equal), mock.Anything).Return(lines, nil)
+			},
+			expectedStatus: http.StatusOK,
+			expectedType:   "text/csv; charset=utf-8",
+			expectedBody: "type,transaction_id,operation_type,amount,reference,balance,at\n" +
+				"opening,,,,,1000,2026-03-01T00:00:00Z\n" +
+				"transaction,7,DEPOSIT,500,bank-1,1500,2026-03-01T01:00:00Z\n" +
+				"closing,,,,,1500,2026-03-02T00:00:00Z\n",
+		},
+		{
+			name: "jsonl",
+			path: base + "&format=jsonl",
+			mockSetup: func(m *service.MockBalanceHistoryService) {
+				m.On("WriteStatement", mock.Anything, walletID, mock.Anything, mock.Anything, mock.Anything).Return(lines, nil)
+			},
+			expectedStatus: http.StatusOK,
+			expectedType:   "application/x-ndjson",
+			expectedBody: `{"type":"opening","balance":1000,"at":"2026-03-01T00:00:00Z"}` + "\n" +
+				`{"type":"transaction","transactionId":7,"operationType":"DEPOSIT","amount":500,"reference":"bank-1","balance":1500,"at":"2026-03-01T01:00:00Z"}` + "\n" +
+				`{"type":"closing","balance":1500,"at":"2026-03-02T00:00:00Z"}` + "\n",
+		},
+		{
+			name:           "unsupported format",
+			path:           base + "&format=pdf",
+			mockSetup:      func(m *service.MockBalanceHistoryService) {},
+			expectedStatus: http.StatusBadRequest,
+			expectedError:  "format must be one of csv, jsonl",
+		},
+		{
+			name:           "missing from",
+			path:           "/api/v1/wallets/" + walletID.String() + "/statement",
+			mockSetup:      func(m *service.MockBalanceHistoryService) {},
+			expectedStatus: http.StatusBadRequest,
+			expectedError:  "Parameter from",
+		},
+		{
+			name: "wallet not found",
+			path: base,
+			mockSetup: func(m *service.MockBalanceHistoryService) {
+				m.On("WriteStatement", mock.Anything, walletID, mock.Anything, mock.Anything, mock.Anything).Return(nil, repository.ErrWalletNotFound)
+			},
+			expectedStatus: http.StatusNotFound,
+			expectedError:  "Wallet not found",
+		},
+		{
+			name: "invalid range",
+			path: base,
+			mockSetup: func(m *service.MockBalanceHistoryService) {
+				m.On("WriteStatement", mock.Anything, walletID, mock.Anything, mock.Anything, mock.Anything).
+					Return(nil, service.ErrInvalidRange)
+			},
+			expectedStatus: http.StatusBadRequest,
+			expectedError:  "Parameter from must not be after to",
+		},
+		{
+			name: "database error",
+			path: base,
+			mockSetup: func(m *service.MockBalanceHistoryService) {
+				m.On("WriteStatement", mock.Anything, walletID, mock.Anything, mock.Anything, mock.Anything).
+					Return(nil, errors.New("pq: invalid input syntax"))
+			},
+			expectedStatus: http.StatusInternalServerError,
+			expectedError:  "Internal server error",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := &service.MockBalanceHistoryService{}
+			tt.mockSetup(mockService)
+			controller := NewBalanceHistoryController(mockService, responder.NewJSONResponder())
+
+			r := chi.NewRouter()
+			r.Get("/api/v1/wallets/{walletId}/statement", controller.GetStatement)
+
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest("GET", tt.path, nil))
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			if tt.expectedError != "" {
+				var response map[string]string
+				assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+				assert.Contains(t, response["error"], tt.expectedError)
+			} else {
+				assert.Equal(t, tt.expectedType, w.Header().Get("Content-Type"))
+				assert.Contains(t, w.Header().Get("Content-Disposition"), "attachment")
+				assert.Equal(t, tt.expectedBody, w.Body.String())
+			}
+			mockService.AssertExpectations(t)
+		})
+	}
+}
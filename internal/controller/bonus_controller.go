@@ -0,0 +1,119 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"unicode/utf8"
+
+	"ITKtest/internal/models"
+	"ITKtest/internal/service"
+	"ITKtest/responder"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+type BonusController struct {
+	service   service.BonusService
+	responder responder.Responder
+}
+
+func NewBonusController(service service.BonusService, responder responder.Responder) *BonusController {
+	return &BonusController{
+		service:   service,
+		responder: responder,
+	}
+}
+
+// CreditBonus credits a promotional lot to the wallet, creating the wallet if needed
+func (c *BonusController) CreditBonus(w http.ResponseWriter, r *http.Request) {
+	walletID, err := uuid.Parse(chi.URLParam(r, "walletId"))
+	if err != nil {
+		c.responder.Error(w, http.StatusBadRequest, "Invalid wallet ID")
+		return
+	}
+
+	var req models.BonusCreditRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		c.responder.Error(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if req.Amount <= 0 {
+		c.responder.Error(w, http.StatusBadRequest, "Amount must be positive")
+		return
+	}
+	if utf8.RuneCountInString(req.Reference) > models.MaxReferenceLength {
+		c.responder.Error(w, http.StatusBadRequest, fmt.Sprintf("Reference must be at most %d characters", models.MaxReferenceLength))
+		return
+	}
+
+	lot, err := c.service.CreditBonus(r.Context(), walletID, req)
+	if err != nil {
+		if errors.Is(err, service.ErrPastExpiry) {
+			c.responder.Error(w, http.StatusBadRequest, "Expiry must be in the future")
+			return
+		}
+		apiErr := walletError(err)
+		c.responder.Error(w, apiErr.httpStatus, apiErr.message)
+		return
+	}
+
+	c.responder.OutputJSON(w, http.StatusCreated, lot)
+}
+
+// GetBonusBalance returns the promotional balance of the wallet and its unexpired lots
+func (c *BonusController) GetBonusBalance(w http.ResponseWriter, r *http.Request) {
+	walletID, err := uuid.Parse(chi.URLParam(r, "walletId"))
+	if err != nil {
+		c.responder.Error(w, http.StatusBadRequest, "Invalid wallet ID")
+		return
+	}
+
+	balance, err := c.service.GetBonusBalance(r.Context(), walletID)
+	if err != nil {
+		apiErr := walletError(err)
+		c.responder.Error(w, apiErr.httpStatus, apiErr.message)
+		return
+	}
+
+	c.responder.OutputJSON(w, http.StatusOK, balance)
+}
+
+// ListBonusEntries returns the bonus journal of the wallet: credits, spending and expiries of
+// its lots, paged by the afterId and limit parameters like the transaction journal
+func (c *BonusController) ListBonusEntries(w http.ResponseWriter, r *http.Request) {
+	walletID, err := uuid.Parse(chi.URLParam(r, "walletId"))
+	if err != nil {
+		c.responder.Error(w, http.StatusBadRequest, "Invalid wallet ID")
+		return
+	}
+
+	var afterID int64
+	if s := r.URL.Query().Get("afterId"); s != "" {
+		afterID, err = strconv.ParseInt(s, 10, 64)
+		if err != nil || afterID < 0 {
+			c.responder.Error(w, http.StatusBadRequest, "Invalid afterId")
+			return
+		}
+	}
+	limit := defaultTransactionsLimit
+	if s := r.URL.Query().Get("limit"); s != "" {
+		limit, err = strconv.Atoi(s)
+		if err != nil || limit <= 0 || limit > maxTransactionsLimit {
+			c.responder.Error(w, http.StatusBadRequest, "Invalid limit")
+			return
+		}
+	}
+
+	entries, err := c.service.ListBonusEntries(r.Context(), walletID, afterID, limit)
+	if err != nil {
+		apiErr := walletError(err)
+		c.responder.Error(w, apiErr.httpStatus, apiErr.message)
+		return
+	}
+
+	c.responder.OutputJSON(w, http.StatusOK, entries)
+}
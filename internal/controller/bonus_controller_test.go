@@ -0,0 +1,151 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"ITKtest/internal/models"
+	"ITKtest/internal/service"
+	"ITKtest/responder"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func newBonusRouter(m *service.MockBonusService) chi.Router {
+	controller := NewBonusController(m, responder.NewJSONResponder())
+	r := chi.NewRouter()
+	r.Post("/api/v1/wallets/{walletId}/bonus", controller.CreditBonus)
+	r.Get("/api/v1/wallets/{walletId}/bonus", controller.GetBonusBalance)
+	r.Get("/api/v1/wallets/{walletId}/bonus/entries", controller.ListBonusEntries)
+	return r
+}
+
+func TestBonusController_CreditBonus(t *testing.T) {
+	walletID := uuid.New()
+	expiresAt := time.Date(2026, 4, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name           string
+		body           string
+		mockSetup      func(*service.MockBonusService)
+		expectedStatus int
+		expectedError  string
+	}{
+		{
+			name: "credit with expiry",
+			body: `{"amount": 500, "expiresAt": "2026-04-01T00:00:00Z", "reference": "spring"}`,
+			mockSetup: func(m *service.MockBonusService) {
+				m.On("CreditBonus", mock.Anything, walletID, mock.MatchedBy(func(req models.BonusCreditRequest) bool {
+					return req.Amount == 500 && req.ExpiresAt.Equal(expiresAt) && req.Reference == "spring"
+				})).Return(&models.BonusLot{ID: 3, WalletID: walletID, Amount: 500, Remaining: 500, ExpiresAt: expiresAt}, nil)
+			},
+			expectedStatus: http.StatusCreated,
+		},
+		{
+			name:           "non-positive amount",
+			body:           `{"amount": 0}`,
+			mockSetup:      func(m *service.MockBonusService) {},
+			expectedStatus: http.StatusBadRequest,
+			expectedError:  "Amount must be positive",
+		},
+		{
+			name: "expiry in the past",
+			body: `{"amount": 500, "expiresAt": "2020-01-01T00:00:00Z"}`,
+			mockSetup: func(m *service.MockBonusService) {
+				m.On("CreditBonus", mock.Anything, walletID, mock.Anything).
+					Return(nil, service.ErrPastExpiry)
+			},
+			expectedStatus: http.StatusBadRequest,
+			expectedError:  "Expiry must be in the future",
+		},
+		{
+			name: "database error",
+			body: `{"amount": 500}`,
+			mockSetup: func(m *service.MockBonusService) {
+				m.On("CreditBonus", mock.Anything, walletID, mock.Anything).
+					Return(nil, errors.New("pq: invalid input value"))
+			},
+			expectedStatus: http.StatusInternalServerError,
+			expectedError:  "Internal server error",
+		},
+		{
+			name:           "invalid body",
+			body:           `{"amount": "many"}`,
+			mockSetup:      func(m *service.MockBonusService) {},
+			expectedStatus: http.StatusBadRequest,
+			expectedError:  "Invalid request body",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := &service.MockBonusService{}
+			tt.mockSetup(mockService)
+
+			w := httptest.NewRecorder()
+			path := "/api/v1/wallets/" + walletID.String() + "/bonus"
+			newBonusRouter(mockService).ServeHTTP(w, httptest.NewRequest("POST", path, strings.NewReader(tt.body)))
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			if tt.expectedError != "" {
+				var response map[string]string
+				assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+				assert.Contains(t, response["error"], tt.expectedError)
+			} else {
+				var lot models.BonusLot
+				assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &lot))
+				assert.Equal(t, int64(3), lot.ID)
+				assert.Equal(t, int64(500), lot.Remaining)
+			}
+			mockService.AssertExpectations(t)
+		})
+	}
+}
+
+func TestBonusController_GetBonusBalance(t *testing.T) {
+	walletID := uuid.New()
+	mockService := &service.MockBonusService{}
+	mockService.On("GetBonusBalance", mock.Anything, walletID).Return(&models.BonusBalanceResponse{
+		WalletID: walletID,
+		Balance:  75,
+		Lots:     []models.BonusLot{{ID: 1, Remaining: 30}, {ID: 2, Remaining: 45}},
+	}, nil)
+
+	w := httptest.NewRecorder()
+	newBonusRouter(mockService).ServeHTTP(w, httptest.NewRequest("GET", "/api/v1/wallets/"+walletID.String()+"/bonus", nil))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var response models.BonusBalanceResponse
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, int64(75), response.Balance)
+	assert.Len(t, response.Lots, 2)
+}
+
+func TestBonusController_ListBonusEntries(t *testing.T) {
+	walletID := uuid.New()
+	mockService := &service.MockBonusService{}
+	mockService.On("ListBonusEntries", mock.Anything, walletID, int64(10), 2).Return([]models.BonusEntry{
+		{ID: 11, LotID: 1, Kind: models.BonusSpend, Amount: 30},
+		{ID: 12, LotID: 1, Kind: models.BonusExpire, Amount: 5},
+	}, nil)
+	router := newBonusRouter(mockService)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/api/v1/wallets/"+walletID.String()+"/bonus/entries?afterId=10&limit=2", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	var entries []models.BonusEntry
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &entries))
+	assert.Len(t, entries, 2)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/api/v1/wallets/"+walletID.String()+"/bonus/entries?limit=5000", nil))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	mockService.AssertExpectations(t)
+}
@@ -0,0 +1,59 @@
+package controller
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"ITKtest/internal/repository"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// ReadConsistencyHeader set to "strong" makes the request read from the primary database, so a
+// client sees its own writes even when balance reads are normally served by a replica
+const ReadConsistencyHeader = "X-Read-Consistency"
+
+func strongConsistency(value string) bool {
+	return strings.EqualFold(strings.TrimSpace(value), "strong")
+}
+
+// ReadConsistency is an HTTP middleware applying ReadConsistencyHeader to the request context
+func ReadConsistency(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strongConsistency(r.Header.Get(ReadConsistencyHeader)) {
+			r = r.WithContext(repository.WithPrimaryReads(r.Context()))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func grpcReadConsistency(ctx context.Context) context.Context {
+	md, _ := metadata.FromIncomingContext(ctx)
+	for _, value := range md.Get(strings.ToLower(ReadConsistencyHeader)) {
+		if strongConsistency(value) {
+			return repository.WithPrimaryReads(ctx)
+		}
+	}
+	return ctx
+}
+
+// ReadConsistencyUnaryInterceptor applies the x-read-consistency metadata key to unary calls
+func ReadConsistencyUnaryInterceptor(ctx context.Context, req interface{}, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	return handler(grpcReadConsistency(ctx), req)
+}
+
+// ReadConsistencyStreamInterceptor applies the x-read-consistency metadata key to streaming calls
+func ReadConsistencyStreamInterceptor(srv interface{}, ss grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	return handler(srv, &contextServerStream{ServerStream: ss, ctx: grpcReadConsistency(ss.Context())})
+}
+
+type contextServerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *contextServerStream) Context() context.Context {
+	return s.ctx
+}
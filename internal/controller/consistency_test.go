@@ -0,0 +1,40 @@
+package controller
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"ITKtest/internal/repository"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadConsistency(t *testing.T) {
+	tests := []struct {
+		name    string
+		header  string
+		primary bool
+	}{
+		{name: "no header", header: "", primary: false},
+		{name: "strong", header: "strong", primary: true},
+		{name: "case insensitive", header: " Strong ", primary: true},
+		{name: "eventual", header: "eventual", primary: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var primary bool
+			handler := ReadConsistency(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				primary = repository.PrimaryReads(r.Context())
+			}))
+
+			req := httptest.NewRequest("GET", "/api/v1/wallets/123", nil)
+			if tt.header != "" {
+				req.Header.Set(ReadConsistencyHeader, tt.header)
+			}
+			handler.ServeHTTP(httptest.NewRecorder(), req)
+			assert.Equal(t, tt.primary, primary)
+		})
+	}
+}
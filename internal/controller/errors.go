@@ -0,0 +1,63 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"unicode/utf8"
+
+	"ITKtest/internal/models"
+	"ITKtest/internal/repository"
+	"ITKtest/internal/service"
+
+	"google.golang.org/grpc/codes"
+)
+
+// apiError is how a wallet service error is presented to clients of both the HTTP and gRPC APIs
+type apiError struct {
+	httpStatus int
+	grpcCode   codes.Code
+	message    string
+}
+
+func walletError(err error) apiError {
+	switch {
+	case errors.Is(err, repository.ErrInsufficientFunds):
+		return apiError{http.StatusBadRequest, codes.FailedPrecondition, "Insufficient funds"}
+	case errors.Is(err, repository.ErrVersionConflict):
+		return apiError{http.StatusConflict, codes.Aborted, "Wallet version conflict"}
+	case errors.Is(err, repository.ErrShardedVersion):
+		return apiError{http.StatusBadRequest, codes.FailedPrecondition, "Expected version is not supported for sharded wallets"}
+	case errors.Is(err, repository.ErrWalletNotFound):
+		return apiError{http.StatusNotFound, codes.NotFound, "Wallet not found"}
+	case errors.Is(err, service.ErrDebitUnauthorized):
+		return apiError{http.StatusForbidden, codes.PermissionDenied, "Debit requires an authorized client certificate"}
+	case errors.Is(err, service.ErrReadOnly):
+		return apiError{http.StatusServiceUnavailable, codes.Unavailable, "Service is in read-only mode"}
+	case errors.Is(err, service.ErrBatchDisabled):
+		return apiError{http.StatusForbidden, codes.PermissionDenied, "Batch operations are disabled"}
+	case errors.Is(err, service.ErrAmountTooLarge):
+		return apiError{http.StatusBadRequest, codes.InvalidArgument, "Amount exceeds the operation limit"}
+	case errors.Is(err, service.ErrBatchTooLarge):
+		return apiError{http.StatusBadRequest, codes.InvalidArgument, "Batch exceeds the size limit"}
+	default:
+		return apiError{http.StatusInternalServerError, codes.Internal, "Internal server error"}
+	}
+}
+
+// validateWalletOperation returns the client-facing message of the first invalid field, or ""
+func validateWalletOperation(req models.WalletOperationRequest) string {
+	if req.Amount <= 0 {
+		return "Amount must be positive"
+	}
+	if req.OperationType != models.DEPOSIT && req.OperationType != models.WITHDRAW {
+		return "Operation type must be DEPOSIT or WITHDRAW"
+	}
+	if utf8.RuneCountInString(req.Reference) > models.MaxReferenceLength {
+		return fmt.Sprintf("Reference must be at most %d characters", models.MaxReferenceLength)
+	}
+	if req.Purchase && req.OperationType != models.WITHDRAW {
+		return "Purchase is only valid for WITHDRAW"
+	}
+	return ""
+}
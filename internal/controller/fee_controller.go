@@ -0,0 +1,39 @@
+package controller
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"ITKtest/internal/fees"
+	"ITKtest/internal/models"
+	"ITKtest/responder"
+)
+
+type FeeController struct {
+	schedule  *fees.Schedule
+	responder responder.Responder
+}
+
+// NewFeeController creates a controller quoting fees of schedule; a nil schedule quotes no fees
+func NewFeeController(schedule *fees.Schedule, responder responder.Responder) *FeeController {
+	return &FeeController{
+		schedule:  schedule,
+		responder: responder,
+	}
+}
+
+// QuoteOperation returns the fee that the operation in the body would be charged, without
+// executing it
+func (c *FeeController) QuoteOperation(w http.ResponseWriter, r *http.Request) {
+	var req models.WalletOperationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		c.responder.Error(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if msg := validateWalletOperation(req); msg != "" {
+		c.responder.Error(w, http.StatusBadRequest, msg)
+		return
+	}
+
+	c.responder.OutputJSON(w, http.StatusOK, c.schedule.Quote(req))
+}
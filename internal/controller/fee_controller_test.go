@@ -0,0 +1,63 @@
+package controller
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"ITKtest/internal/fees"
+	"ITKtest/internal/models"
+	"ITKtest/internal/service"
+	"ITKtest/responder"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFeeController_QuoteOperation(t *testing.T) {
+	schedule := &fees.Schedule{FeeWallet: uuid.New(), Rules: []fees.Rule{{Name: "withdrawals", OperationType: models.WITHDRAW, Fixed: 30}}}
+	controller := NewFeeController(schedule, responder.NewJSONResponder())
+	walletID := uuid.New()
+
+	quote := func(body interface{}) *httptest.ResponseRecorder {
+		data, _ := json.Marshal(body)
+		w := httptest.NewRecorder()
+		controller.QuoteOperation(w, httptest.NewRequest("POST", "/api/v1/wallet/quote", bytes.NewReader(data)))
+		return w
+	}
+
+	w := quote(models.WalletOperationRequest{WalletID: walletID, OperationType: models.WITHDRAW, Amount: 1000})
+	require.Equal(t, http.StatusOK, w.Code)
+	var response models.FeeQuote
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, models.FeeQuote{WalletID: walletID, OperationType: models.WITHDRAW, Amount: 1000, Fee: 30, Rule: "withdrawals", BalanceChange: -1030}, response)
+
+	w = quote(models.WalletOperationRequest{WalletID: walletID, OperationType: models.WITHDRAW, Amount: -5})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "Amount must be positive")
+}
+
+func TestWalletController_HandleWalletOperation_Fee(t *testing.T) {
+	walletID, feeWallet := uuid.New(), uuid.New()
+	mockService := &service.MockWalletService{}
+	mockService.On("ProcessWalletOperation", mock.Anything, mock.Anything).Return(nil).Run(func(args mock.Arguments) {
+		fees.SetCharge(args.Get(0).(context.Context), models.OperationFee{Amount: 30, FeeWalletID: feeWallet, Rule: "withdrawals"})
+	})
+	controller := NewWalletController(mockService, responder.NewJSONResponder())
+
+	body, _ := json.Marshal(models.WalletOperationRequest{WalletID: walletID, OperationType: models.WITHDRAW, Amount: 1000})
+	w := httptest.NewRecorder()
+	controller.HandleWalletOperation(w, httptest.NewRequest("POST", "/api/v1/wallet", bytes.NewReader(body)))
+
+	require.Equal(t, http.StatusOK, w.Code)
+	var response models.WalletOperationResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, "success", response.Status)
+	require.NotNil(t, response.Fee)
+	assert.Equal(t, models.OperationFee{Amount: 30, FeeWalletID: feeWallet, Rule: "withdrawals"}, *response.Fee)
+}
@@ -0,0 +1,30 @@
+package controller
+
+import (
+	"expvar"
+	"fmt"
+	"net/http"
+)
+
+// MetricsHandler serves the named expvar variables as one JSON object. Unlike expvar.Handler it
+// leaves out cmdline, which carries the command line flags, database passwords included, and
+// memstats.
+func MetricsHandler(names ...string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		fmt.Fprint(w, "{")
+		first := true
+		for _, name := range names {
+			v := expvar.Get(name)
+			if v == nil {
+				continue
+			}
+			if !first {
+				fmt.Fprint(w, ",")
+			}
+			first = false
+			fmt.Fprintf(w, "\n%q: %s", name, v)
+		}
+		fmt.Fprint(w, "\n}\n")
+	})
+}
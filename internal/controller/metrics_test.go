@@ -0,0 +1,25 @@
+package controller
+
+import (
+	"encoding/json"
+	"expvar"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMetricsHandler(t *testing.T) {
+	counters := expvar.NewMap("metrics_handler_test")
+	counters.Add("retries", 3)
+
+	w := httptest.NewRecorder()
+	MetricsHandler("metrics_handler_test", "unknown").ServeHTTP(w, httptest.NewRequest("GET", "/debug/vars", nil))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var body map[string]map[string]int
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	assert.Equal(t, map[string]map[string]int{"metrics_handler_test": {"retries": 3}}, body)
+	assert.NotContains(t, w.Body.String(), "cmdline")
+}
@@ -0,0 +1,70 @@
+package controller
+
+import (
+	"context"
+	"net/http"
+
+	"ITKtest/internal/auth"
+	"ITKtest/internal/service"
+	"ITKtest/responder"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+)
+
+// ClientPrincipal is an HTTP middleware recording the principal of a verified client
+// certificate in the request context
+func ClientPrincipal(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if principal := auth.PrincipalFromTLS(r.TLS); principal != "" {
+			r = r.WithContext(auth.WithPrincipal(r.Context(), principal))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func grpcPrincipal(ctx context.Context) context.Context {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return ctx
+	}
+	info, ok := p.AuthInfo.(credentials.TLSInfo)
+	if !ok {
+		return ctx
+	}
+	if principal := auth.PrincipalFromTLS(&info.State); principal != "" {
+		return auth.WithPrincipal(ctx, principal)
+	}
+	return ctx
+}
+
+// ClientPrincipalUnaryInterceptor records the client certificate principal of unary calls
+func ClientPrincipalUnaryInterceptor(ctx context.Context, req interface{}, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	return handler(grpcPrincipal(ctx), req)
+}
+
+// ClientPrincipalStreamInterceptor records the client certificate principal of streaming calls
+func ClientPrincipalStreamInterceptor(srv interface{}, ss grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	return handler(srv, &contextServerStream{ServerStream: ss, ctx: grpcPrincipal(ss.Context())})
+}
+
+// RequirePrincipal is an HTTP middleware letting through only the clients whose certificate
+// principal is listed, "*" meaning any verified client. Without principals it refuses every
+// request, so that the endpoints it guards stay closed until clients are allowed in.
+func RequirePrincipal(principals []string, responder responder.Responder) func(http.Handler) http.Handler {
+	allowed := make(map[string]bool, len(principals))
+	for _, p := range principals {
+		allowed[p] = true
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			principal := auth.Principal(r.Context())
+			if principal == "" || !(allowed[service.AnyPrincipal] || allowed[principal]) {
+				responder.Error(w, http.StatusForbidden, "This endpoint requires an authorized client certificate")
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
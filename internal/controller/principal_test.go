@@ -0,0 +1,44 @@
+package controller
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"ITKtest/internal/auth"
+	"ITKtest/responder"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequirePrincipal(t *testing.T) {
+	tests := []struct {
+		name       string
+		principals []string
+		principal  string
+		wantStatus int
+	}{
+		{name: "no principals configured", principal: "ops", wantStatus: http.StatusForbidden},
+		{name: "anonymous client", principals: []string{"*"}, wantStatus: http.StatusForbidden},
+		{name: "listed client", principals: []string{"ops", "billing"}, principal: "ops", wantStatus: http.StatusOK},
+		{name: "unlisted client", principals: []string{"billing"}, principal: "ops", wantStatus: http.StatusForbidden},
+		{name: "any verified client", principals: []string{"*"}, principal: "ops", wantStatus: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := RequirePrincipal(tt.principals, responder.NewJSONResponder())(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			}))
+
+			req := httptest.NewRequest("GET", "/api/v1/admin/reconciliation", nil)
+			if tt.principal != "" {
+				req = req.WithContext(auth.WithPrincipal(req.Context(), tt.principal))
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.wantStatus, w.Code)
+		})
+	}
+}
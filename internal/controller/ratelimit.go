@@ -0,0 +1,132 @@
+package controller
+
+import (
+	"context"
+	"log/slog"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"ITKtest/internal/settings"
+	"ITKtest/responder"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// maxRateLimitBuckets is the number of tracked clients above which idle ones are forgotten
+const maxRateLimitBuckets = 10000
+
+// RateLimiter limits the request rate of each client IP with a token bucket. The rate and burst
+// are read from the runtime settings on every request, so a reload applies immediately.
+type RateLimiter struct {
+	settings  *settings.Store
+	responder responder.Responder
+
+	mu      sync.Mutex
+	buckets map[string]*bucket
+	now     func() time.Time
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+func NewRateLimiter(store *settings.Store, responder responder.Responder) *RateLimiter {
+	return &RateLimiter{
+		settings:  store,
+		responder: responder,
+		buckets:   make(map[string]*bucket),
+		now:       time.Now,
+	}
+}
+
+// Allow takes a token from the client's bucket
+func (l *RateLimiter) Allow(client string) bool {
+	rt := l.settings.Load()
+	if rt.RateLimit <= 0 {
+		return true
+	}
+	burst := float64(rt.RateLimitBurst)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	b, ok := l.buckets[client]
+	if !ok {
+		if len(l.buckets) >= maxRateLimitBuckets {
+			l.sweep(now, rt.RateLimit, burst)
+		}
+		b = &bucket{tokens: burst, last: now}
+		l.buckets[client] = b
+	}
+
+	b.tokens = min(burst, b.tokens+now.Sub(b.last).Seconds()*rt.RateLimit)
+	b.last = now
+	if b.tokens < 1 {
+		slog.Debug("Rate limit exceeded", "client", client)
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// sweep forgets clients whose buckets have refilled, since a new bucket starts full anyway
+func (l *RateLimiter) sweep(now time.Time, rate, burst float64) {
+	for client, b := range l.buckets {
+		if b.tokens+now.Sub(b.last).Seconds()*rate >= burst {
+			delete(l.buckets, client)
+		}
+	}
+}
+
+// Middleware rejects requests over the limit with 429 Too Many Requests
+func (l *RateLimiter) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		client, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			client = r.RemoteAddr
+		}
+		if !l.Allow(client) {
+			w.Header().Set("Retry-After", "1")
+			l.responder.Error(w, http.StatusTooManyRequests, "Too many requests")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (l *RateLimiter) allowGRPC(ctx context.Context) error {
+	client := ""
+	if p, ok := peer.FromContext(ctx); ok {
+		client = p.Addr.String()
+		if host, _, err := net.SplitHostPort(client); err == nil {
+			client = host
+		}
+	}
+	if !l.Allow(client) {
+		return status.Error(codes.ResourceExhausted, "Too many requests")
+	}
+	return nil
+}
+
+// UnaryInterceptor rejects calls over the limit with ResourceExhausted
+func (l *RateLimiter) UnaryInterceptor(ctx context.Context, req interface{}, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	if err := l.allowGRPC(ctx); err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+// StreamInterceptor counts opening a stream as one request
+func (l *RateLimiter) StreamInterceptor(srv interface{}, ss grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if err := l.allowGRPC(ss.Context()); err != nil {
+		return err
+	}
+	return handler(srv, ss)
+}
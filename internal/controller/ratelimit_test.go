@@ -0,0 +1,64 @@
+package controller
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"ITKtest/internal/settings"
+	"ITKtest/responder"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRateLimiter_Allow(t *testing.T) {
+	rt := settings.Default()
+	rt.RateLimit = 2
+	rt.RateLimitBurst = 3
+	store := settings.NewStore(rt)
+
+	now := time.Now()
+	limiter := NewRateLimiter(store, responder.NewJSONResponder())
+	limiter.now = func() time.Time { return now }
+
+	for i := 0; i < 3; i++ {
+		assert.True(t, limiter.Allow("10.0.0.1"))
+	}
+	assert.False(t, limiter.Allow("10.0.0.1"))
+	// Other clients have their own bucket
+	assert.True(t, limiter.Allow("10.0.0.2"))
+
+	// Two requests per second refill one token in half a second
+	now = now.Add(500 * time.Millisecond)
+	assert.True(t, limiter.Allow("10.0.0.1"))
+	assert.False(t, limiter.Allow("10.0.0.1"))
+
+	// Disabling the limit on reload applies immediately
+	rt.RateLimit = 0
+	store.Swap(rt)
+	assert.True(t, limiter.Allow("10.0.0.1"))
+}
+
+func TestRateLimiter_Middleware(t *testing.T) {
+	rt := settings.Default()
+	rt.RateLimit = 1
+	rt.RateLimitBurst = 1
+	limiter := NewRateLimiter(settings.NewStore(rt), responder.NewJSONResponder())
+	handler := limiter.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	serve := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/api/v1/wallets/x", nil)
+		req.RemoteAddr = "10.0.0.1:5000"
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+
+	assert.Equal(t, http.StatusOK, serve().Code)
+	w := serve()
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "1", w.Header().Get("Retry-After"))
+}
@@ -0,0 +1,34 @@
+package controller
+
+import (
+	"net/http"
+
+	"ITKtest/internal/models"
+	"ITKtest/internal/reconcile"
+	"ITKtest/responder"
+)
+
+type ReconciliationController struct {
+	reconciler *reconcile.Reconciler
+	responder  responder.Responder
+}
+
+func NewReconciliationController(reconciler *reconcile.Reconciler, responder responder.Responder) *ReconciliationController {
+	return &ReconciliationController{
+		reconciler: reconciler,
+		responder:  responder,
+	}
+}
+
+// GetStatus returns whether a reconciliation is running and the latest report, null until the
+// first one finishes
+func (c *ReconciliationController) GetStatus(w http.ResponseWriter, r *http.Request) {
+	running, report := c.reconciler.Status()
+	c.responder.OutputJSON(w, http.StatusOK, models.ReconciliationStatusResponse{Running: running, Report: report})
+}
+
+// Run queues a reconciliation; its report replaces the latest one when it finishes
+func (c *ReconciliationController) Run(w http.ResponseWriter, r *http.Request) {
+	c.reconciler.Trigger()
+	c.responder.OutputJSON(w, http.StatusAccepted, map[string]string{"status": "queued"})
+}
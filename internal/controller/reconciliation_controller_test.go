@@ -0,0 +1,55 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"ITKtest/internal/models"
+	"ITKtest/internal/reconcile"
+	"ITKtest/internal/repository"
+	"ITKtest/responder"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReconciliationController(t *testing.T) {
+	repo := &repository.MockReconciliationRepository{}
+	reconciler := reconcile.NewReconciler(repo, 0, 100)
+	controller := NewReconciliationController(reconciler, responder.NewJSONResponder())
+
+	getStatus := func() models.ReconciliationStatusResponse {
+		w := httptest.NewRecorder()
+		controller.GetStatus(w, httptest.NewRequest("GET", "/api/v1/admin/reconciliation", nil))
+		assert.Equal(t, http.StatusOK, w.Code)
+		var response models.ReconciliationStatusResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		return response
+	}
+
+	// No report before the first run
+	assert.Nil(t, getStatus().Report)
+
+	walletID := uuid.New()
+	repo.On("Reconcile", mock.Anything, 100).Return(&models.ReconciliationReport{
+		WalletsChecked: 2,
+		MismatchCount:  1,
+		Mismatches:     []models.WalletDiscrepancy{{WalletID: walletID, StoredBalance: 10, JournalBalance: 0}},
+	}, nil)
+	_, err := reconciler.RunOnce(context.Background())
+	require.NoError(t, err)
+
+	response := getStatus()
+	require.NotNil(t, response.Report)
+	assert.Equal(t, int64(1), response.Report.MismatchCount)
+	assert.Equal(t, walletID, response.Report.Mismatches[0].WalletID)
+
+	w := httptest.NewRecorder()
+	controller.Run(w, httptest.NewRequest("POST", "/api/v1/admin/reconciliation", nil))
+	assert.Equal(t, http.StatusAccepted, w.Code)
+}
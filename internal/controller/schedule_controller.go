@@ -0,0 +1,149 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"ITKtest/internal/models"
+	"ITKtest/internal/repository"
+	"ITKtest/internal/service"
+	"ITKtest/responder"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+type ScheduleController struct {
+	service   service.ScheduleService
+	responder responder.Responder
+}
+
+func NewScheduleController(service service.ScheduleService, responder responder.Responder) *ScheduleController {
+	return &ScheduleController{
+		service:   service,
+		responder: responder,
+	}
+}
+
+// CreateSchedule schedules a one-off or recurring DEPOSIT, WITHDRAW or TRANSFER
+func (c *ScheduleController) CreateSchedule(w http.ResponseWriter, r *http.Request) {
+	var req models.ScheduleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		c.responder.Error(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	s, err := c.service.CreateSchedule(r.Context(), req)
+	if err != nil {
+		c.error(w, err)
+		return
+	}
+	c.responder.OutputJSON(w, http.StatusCreated, s)
+}
+
+// ListSchedules returns the schedules debiting or crediting the wallet in the walletId parameter
+func (c *ScheduleController) ListSchedules(w http.ResponseWriter, r *http.Request) {
+	walletID, err := uuid.Parse(r.URL.Query().Get("walletId"))
+	if err != nil {
+		c.responder.Error(w, http.StatusBadRequest, "Parameter walletId must be a wallet ID")
+		return
+	}
+
+	schedules, err := c.service.ListSchedules(r.Context(), walletID)
+	if err != nil {
+		c.error(w, err)
+		return
+	}
+	c.responder.OutputJSON(w, http.StatusOK, schedules)
+}
+
+func (c *ScheduleController) GetSchedule(w http.ResponseWriter, r *http.Request) {
+	c.withSchedule(w, r, c.service.GetSchedule)
+}
+
+func (c *ScheduleController) PauseSchedule(w http.ResponseWriter, r *http.Request) {
+	c.withSchedule(w, r, c.service.PauseSchedule)
+}
+
+func (c *ScheduleController) ResumeSchedule(w http.ResponseWriter, r *http.Request) {
+	c.withSchedule(w, r, c.service.ResumeSchedule)
+}
+
+func (c *ScheduleController) CancelSchedule(w http.ResponseWriter, r *http.Request) {
+	c.withSchedule(w, r, c.service.CancelSchedule)
+}
+
+// ListRuns returns the outcome of each run of the schedule, paged by the afterId and limit
+// parameters like the transaction journal
+func (c *ScheduleController) ListRuns(w http.ResponseWriter, r *http.Request) {
+	id, ok := c.scheduleID(w, r)
+	if !ok {
+		return
+	}
+
+	var afterID int64
+	var err error
+	if s := r.URL.Query().Get("afterId"); s != "" {
+		afterID, err = strconv.ParseInt(s, 10, 64)
+		if err != nil || afterID < 0 {
+			c.responder.Error(w, http.StatusBadRequest, "Invalid afterId")
+			return
+		}
+	}
+	limit := defaultTransactionsLimit
+	if s := r.URL.Query().Get("limit"); s != "" {
+		limit, err = strconv.Atoi(s)
+		if err != nil || limit <= 0 || limit > maxTransactionsLimit {
+			c.responder.Error(w, http.StatusBadRequest, "Invalid limit")
+			return
+		}
+	}
+
+	runs, err := c.service.ListRuns(r.Context(), id, afterID, limit)
+	if err != nil {
+		c.error(w, err)
+		return
+	}
+	c.responder.OutputJSON(w, http.StatusOK, runs)
+}
+
+func (c *ScheduleController) withSchedule(w http.ResponseWriter, r *http.Request, fn func(ctx context.Context, id int64) (*models.Schedule, error)) {
+	id, ok := c.scheduleID(w, r)
+	if !ok {
+		return
+	}
+
+	s, err := fn(r.Context(), id)
+	if err != nil {
+		c.error(w, err)
+		return
+	}
+	c.responder.OutputJSON(w, http.StatusOK, s)
+}
+
+func (c *ScheduleController) scheduleID(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "scheduleId"), 10, 64)
+	if err != nil {
+		c.responder.Error(w, http.StatusBadRequest, "Invalid schedule ID")
+		return 0, false
+	}
+	return id, true
+}
+
+func (c *ScheduleController) error(w http.ResponseWriter, err error) {
+	var invalid *service.InvalidScheduleError
+	switch {
+	case errors.Is(err, repository.ErrScheduleNotFound):
+		c.responder.Error(w, http.StatusNotFound, "Schedule not found")
+	case errors.As(err, &invalid):
+		c.responder.Error(w, http.StatusBadRequest, "Invalid schedule: "+invalid.Reason)
+	case errors.Is(err, service.ErrScheduleStatus):
+		c.responder.Error(w, http.StatusConflict, "Schedule status does not allow this change")
+	default:
+		apiErr := walletError(err)
+		c.responder.Error(w, apiErr.httpStatus, apiErr.message)
+	}
+}
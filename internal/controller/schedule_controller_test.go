@@ -0,0 +1,198 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"ITKtest/internal/models"
+	"ITKtest/internal/repository"
+	"ITKtest/internal/service"
+	"ITKtest/responder"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func newScheduleRouter(m *service.MockScheduleService) chi.Router {
+	controller := NewScheduleController(m, responder.NewJSONResponder())
+	r := chi.NewRouter()
+	r.Post("/api/v1/schedules", controller.CreateSchedule)
+	r.Get("/api/v1/schedules", controller.ListSchedules)
+	r.Get("/api/v1/schedules/{scheduleId}", controller.GetSchedule)
+	r.Get("/api/v1/schedules/{scheduleId}/runs", controller.ListRuns)
+	r.Post("/api/v1/schedules/{scheduleId}/pause", controller.PauseSchedule)
+	r.Post("/api/v1/schedules/{scheduleId}/resume", controller.ResumeSchedule)
+	r.Post("/api/v1/schedules/{scheduleId}/cancel", controller.CancelSchedule)
+	return r
+}
+
+func TestScheduleController_CreateSchedule(t *testing.T) {
+	walletID := uuid.New()
+	tests := []struct {
+		name           string
+		body           string
+		mockSetup      func(*service.MockScheduleService)
+		expectedStatus int
+		expectedError  string
+	}{
+		{
+			name: "recurring withdrawal",
+			body: `{"walletId": "` + walletID.String() + `", "operationType": "WITHDRAW", "amount": 100, "cron": "0 9 * * 1", "timeZone": "Europe/Moscow"}`,
+			mockSetup: func(m *service.MockScheduleService) {
+				m.On("CreateSchedule", mock.Anything, models.ScheduleRequest{
+					WalletID: walletID, OperationType: models.WITHDRAW, Amount: 100, Cron: "0 9 * * 1", TimeZone: "Europe/Moscow",
+				}).Return(&models.Schedule{ID: 5, WalletID: walletID, Status: models.ScheduleActive}, nil)
+			},
+			expectedStatus: http.StatusCreated,
+		},
+		{
+			name: "invalid schedule",
+			body: `{"walletId": "` + walletID.String() + `", "operationType": "DEPOSIT", "amount": 100}`,
+			mockSetup: func(m *service.MockScheduleService) {
+				m.On("CreateSchedule", mock.Anything, mock.Anything).
+					Return(nil, &service.InvalidScheduleError{Reason: "set runAt, cron or interval"})
+			},
+			expectedStatus: http.StatusBadRequest,
+			expectedError:  "Invalid schedule: set runAt, cron or interval",
+		},
+		{
+			name: "database error",
+			body: `{"walletId": "` + walletID.String() + `", "operationType": "DEPOSIT", "amount": 100, "interval": "1h"}`,
+			mockSetup: func(m *service.MockScheduleService) {
+				m.On("CreateSchedule", mock.Anything, mock.Anything).
+					Return(nil, errors.New("pq: invalid input value"))
+			},
+			expectedStatus: http.StatusInternalServerError,
+			expectedError:  "Internal server error",
+		},
+		{
+			name:           "invalid body",
+			body:           `{"amount": "many"}`,
+			mockSetup:      func(m *service.MockScheduleService) {},
+			expectedStatus: http.StatusBadRequest,
+			expectedError:  "Invalid request body",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := &service.MockScheduleService{}
+			tt.mockSetup(mockService)
+
+			w := httptest.NewRecorder()
+			newScheduleRouter(mockService).ServeHTTP(w, httptest.NewRequest("POST", "/api/v1/schedules", strings.NewReader(tt.body)))
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			if tt.expectedError != "" {
+				var response map[string]string
+				assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+				assert.Contains(t, response["error"], tt.expectedError)
+			} else {
+				var s models.Schedule
+				assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &s))
+				assert.Equal(t, int64(5), s.ID)
+			}
+			mockService.AssertExpectations(t)
+		})
+	}
+}
+
+func TestScheduleController_ListSchedules(t *testing.T) {
+	walletID := uuid.New()
+	mockService := &service.MockScheduleService{}
+	mockService.On("ListSchedules", mock.Anything, walletID).Return([]models.Schedule{{ID: 1}, {ID: 2}}, nil)
+	router := newScheduleRouter(mockService)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/api/v1/schedules?walletId="+walletID.String(), nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	var schedules []models.Schedule
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &schedules))
+	assert.Len(t, schedules, 2)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/api/v1/schedules", nil))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	mockService.AssertExpectations(t)
+}
+
+func TestScheduleController_StatusChanges(t *testing.T) {
+	tests := []struct {
+		name           string
+		path           string
+		mockSetup      func(*service.MockScheduleService)
+		expectedStatus int
+	}{
+		{
+			name: "pause",
+			path: "/api/v1/schedules/3/pause",
+			mockSetup: func(m *service.MockScheduleService) {
+				m.On("PauseSchedule", mock.Anything, int64(3)).Return(&models.Schedule{ID: 3, Status: models.SchedulePaused}, nil)
+			},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name: "resume a cancelled schedule",
+			path: "/api/v1/schedules/3/resume",
+			mockSetup: func(m *service.MockScheduleService) {
+				m.On("ResumeSchedule", mock.Anything, int64(3)).Return(nil, fmt.Errorf("%w: cannot resume a cancelled schedule", service.ErrScheduleStatus))
+			},
+			expectedStatus: http.StatusConflict,
+		},
+		{
+			name: "cancel an unknown schedule",
+			path: "/api/v1/schedules/4/cancel",
+			mockSetup: func(m *service.MockScheduleService) {
+				m.On("CancelSchedule", mock.Anything, int64(4)).Return(nil, repository.ErrScheduleNotFound)
+			},
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:           "invalid schedule ID",
+			path:           "/api/v1/schedules/abc/pause",
+			mockSetup:      func(m *service.MockScheduleService) {},
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := &service.MockScheduleService{}
+			tt.mockSetup(mockService)
+
+			w := httptest.NewRecorder()
+			newScheduleRouter(mockService).ServeHTTP(w, httptest.NewRequest("POST", tt.path, nil))
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			mockService.AssertExpectations(t)
+		})
+	}
+}
+
+func TestScheduleController_ListRuns(t *testing.T) {
+	mockService := &service.MockScheduleService{}
+	mockService.On("ListRuns", mock.Anything, int64(3), int64(10), 2).Return([]models.ScheduleRun{
+		{ID: 11, ScheduleID: 3, Status: models.RunSucceeded},
+		{ID: 12, ScheduleID: 3, Status: models.RunFailed, Error: "insufficient funds"},
+	}, nil)
+	router := newScheduleRouter(mockService)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/api/v1/schedules/3/runs?afterId=10&limit=2", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	var runs []models.ScheduleRun
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &runs))
+	assert.Len(t, runs, 2)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/api/v1/schedules/3/runs?afterId=-1", nil))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	mockService.AssertExpectations(t)
+}
@@ -0,0 +1,114 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"ITKtest/internal/settlement"
+	"ITKtest/responder"
+)
+
+// maxSettlementFileSize caps uploaded settlement files; a day of PSP payouts fits comfortably
+const maxSettlementFileSize = 32 << 20
+
+type SettlementController struct {
+	matcher   *settlement.Matcher
+	mapping   settlement.Mapping
+	responder responder.Responder
+}
+
+// NewSettlementController creates a controller matching uploaded files with the default mapping
+func NewSettlementController(matcher *settlement.Matcher, mapping settlement.Mapping, responder responder.Responder) *SettlementController {
+	return &SettlementController{
+		matcher:   matcher,
+		mapping:   mapping,
+		responder: responder,
+	}
+}
+
+// ImportSettlement matches the settlement file in the request body against wallet deposits. The
+// referenceColumn, amountColumn, walletColumn, delimiter and decimals parameters override the
+// default mapping. The report is returned as JSON, or with format csv or jsonl as a download of
+// its lines.
+func (c *SettlementController) ImportSettlement(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	mapping := c.mapping
+	if query.Has("referenceColumn") {
+		mapping.ReferenceColumn = query.Get("referenceColumn")
+	}
+	if query.Has("amountColumn") {
+		mapping.AmountColumn = query.Get("amountColumn")
+	}
+	if query.Has("walletColumn") {
+		mapping.WalletColumn = query.Get("walletColumn")
+	}
+	if query.Has("delimiter") {
+		delimiter := query.Get("delimiter")
+		if utf8.RuneCountInString(delimiter) != 1 {
+			c.responder.Error(w, http.StatusBadRequest, "Parameter delimiter must be a single character")
+			return
+		}
+		mapping.Delimiter, _ = utf8.DecodeRuneInString(delimiter)
+	}
+	if query.Has("decimals") {
+		decimals, err := strconv.Atoi(query.Get("decimals"))
+		if err != nil || decimals < 0 || decimals > 6 {
+			c.responder.Error(w, http.StatusBadRequest, "Parameter decimals must be between 0 and 6")
+			return
+		}
+		mapping.Decimals = decimals
+	}
+	if mapping.ReferenceColumn == "" || mapping.AmountColumn == "" {
+		c.responder.Error(w, http.StatusBadRequest, "Reference and amount columns must be named")
+		return
+	}
+	format := query.Get("format")
+	if format == "" {
+		format = "json"
+	}
+	if format != "json" && !slices.Contains(responder.StreamFormats, format) {
+		c.responder.Error(w, http.StatusBadRequest, fmt.Sprintf("Parameter format must be one of json, %s", strings.Join(responder.StreamFormats, ", ")))
+		return
+	}
+
+	report, err := c.matcher.Import(r.Context(), http.MaxBytesReader(w, r.Body, maxSettlementFileSize), mapping)
+	var parseErr *settlement.ParseError
+	var tooLarge *http.MaxBytesError
+	switch {
+	case errors.As(err, &tooLarge):
+		c.responder.Error(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("Settlement file must be at most %d bytes", tooLarge.Limit))
+		return
+	case errors.As(err, &parseErr):
+		c.responder.Error(w, http.StatusBadRequest, "Invalid settlement file: "+parseErr.Error())
+		return
+	case err != nil:
+		apiErr := walletError(err)
+		c.responder.Error(w, apiErr.httpStatus, apiErr.message)
+		return
+	}
+
+	if format == "json" {
+		c.responder.OutputJSON(w, http.StatusOK, report)
+		return
+	}
+	stream, err := responder.NewStream(w, format, "settlement-report")
+	if err == nil {
+		for _, result := range report.Results {
+			if err = stream.Write(result); err != nil {
+				break
+			}
+		}
+	}
+	if err == nil {
+		err = stream.Close()
+	}
+	if err != nil {
+		slog.Warn("Settlement report download aborted", "error", err)
+	}
+}
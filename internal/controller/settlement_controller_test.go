@@ -0,0 +1,125 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"ITKtest/internal/models"
+	"ITKtest/internal/repository"
+	"ITKtest/internal/settlement"
+	"ITKtest/responder"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSettlementController_ImportSettlement(t *testing.T) {
+	walletID := uuid.New()
+	mapping := settlement.Mapping{ReferenceColumn: "reference", AmountColumn: "amount", Delimiter: ',', Decimals: 2}
+	tests := []struct {
+		name           string
+		query          string
+		body           string
+		mockSetup      func(*repository.MockSettlementRepository)
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name: "json report",
+			body: "reference,amount\nbank-1,5.00\nbank-2,1.00\n",
+			mockSetup: func(m *repository.MockSettlementRepository) {
+				m.On("DepositsByReference", mock.Anything, []string{"bank-1", "bank-2"}).
+					Return(map[string][]models.Transaction{"bank-1": {{ID: 7, WalletID: walletID, Amount: 500}}}, nil)
+			},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:  "csv report with overridden mapping",
+			query: "?referenceColumn=ref&amountColumn=sum&delimiter=%3B&decimals=0&format=csv",
+			body:  "sum;ref\n500;bank-1\n",
+			mockSetup: func(m *repository.MockSettlementRepository) {
+				m.On("DepositsByReference", mock.Anything, []string{"bank-1"}).
+					Return(map[string][]models.Transaction{"bank-1": {{ID: 7, WalletID: walletID, Amount: 500}}}, nil)
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody: "line,reference,amount,wallet_id,status,reason,transaction_ids\n" +
+				"2,bank-1,500,,matched,,7\n",
+		},
+		{
+			name:           "invalid file",
+			body:           "reference,amount\nbank-1,5.001\n",
+			mockSetup:      func(m *repository.MockSettlementRepository) {},
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"error":"Invalid settlement file: line 2: amount \"5.001\" has more than 2 decimal places"}` + "\n",
+		},
+		{
+			name:           "invalid delimiter",
+			query:          "?delimiter=%3B%3B",
+			mockSetup:      func(m *repository.MockSettlementRepository) {},
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"error":"Parameter delimiter must be a single character"}` + "\n",
+		},
+		{
+			name:           "invalid format",
+			query:          "?format=xml",
+			mockSetup:      func(m *repository.MockSettlementRepository) {},
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"error":"Parameter format must be one of json, csv, jsonl"}` + "\n",
+		},
+		{
+			name:           "file too large",
+			body:           "reference,amount\n" + strings.Repeat("bank-1,1\n", maxSettlementFileSize/9+1),
+			mockSetup:      func(m *repository.MockSettlementRepository) {},
+			expectedStatus: http.StatusRequestEntityTooLarge,
+		},
+		{
+			name: "database error",
+			body: "reference,amount\nbank-1,5\n",
+			mockSetup: func(m *repository.MockSettlementRepository) {
+				m.On("DepositsByReference", mock.Anything, mock.Anything).Return(nil, errors.New("connection refused"))
+			},
+			expectedStatus: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &repository.MockSettlementRepository{}
+			tt.mockSetup(repo)
+			controller := NewSettlementController(settlement.NewMatcher(repo), mapping, responder.NewJSONResponder())
+
+			w := httptest.NewRecorder()
+			controller.ImportSettlement(w, httptest.NewRequest("POST", "/api/v1/admin/settlements"+tt.query, strings.NewReader(tt.body)))
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			if tt.expectedBody != "" {
+				assert.Equal(t, tt.expectedBody, w.Body.String())
+			}
+			repo.AssertExpectations(t)
+		})
+	}
+
+	t.Run("json report contents", func(t *testing.T) {
+		repo := &repository.MockSettlementRepository{}
+		repo.On("DepositsByReference", mock.Anything, []string{"bank-1", "bank-2"}).
+			Return(map[string][]models.Transaction{"bank-1": {{ID: 7, WalletID: walletID, Amount: 400}}}, nil)
+		controller := NewSettlementController(settlement.NewMatcher(repo), mapping, responder.NewJSONResponder())
+
+		w := httptest.NewRecorder()
+		controller.ImportSettlement(w, httptest.NewRequest("POST", "/api/v1/admin/settlements", strings.NewReader("reference,amount\nbank-1,5\nbank-2,1\n")))
+
+		require.Equal(t, http.StatusOK, w.Code)
+		var report models.SettlementReport
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &report))
+		assert.Equal(t, 2, report.Lines)
+		assert.Equal(t, 1, report.Mismatched)
+		assert.Equal(t, 1, report.Unmatched)
+		assert.Equal(t, "deposit amount is 400", report.Results[0].Reason)
+	})
+}
@@ -2,9 +2,14 @@ package controller
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
+	"strconv"
 	"strings"
 
+	"ITKtest/internal/cache"
+	"ITKtest/internal/fees"
 	"ITKtest/internal/models"
 	"ITKtest/internal/service"
 	"ITKtest/responder"
@@ -13,6 +18,11 @@ import (
 	"github.com/google/uuid"
 )
 
+// CacheStatusHeader tells whether a balance was served by the balance cache: HIT, MISS, or
+// BYPASS for reads with strong consistency. gRPC GetBalance sends it as x-cache header
+// metadata. It is absent when the cache is disabled.
+const CacheStatusHeader = "X-Cache"
+
 type WalletController struct {
 	service   service.WalletService
 	responder responder.Responder
@@ -33,27 +43,100 @@ func (c *WalletController) HandleWalletOperation(w http.ResponseWriter, r *http.
 		return
 	}
 	
-	if req.Amount <= 0 {
-		c.responder.Error(w, http.StatusBadRequest, "Amount must be positive")
+	if msg := validateWalletOperation(req); msg != "" {
+		c.responder.Error(w, http.StatusBadRequest, msg)
 		return
 	}
 
-	if req.OperationType != models.DEPOSIT && req.OperationType != models.WITHDRAW {
-		c.responder.Error(w, http.StatusBadRequest, "Operation type must be DEPOSIT or WITHDRAW")
-		return
+	// If-Match carries the same precondition as expectedVersion in the body
+	ifMatch := r.Header.Get("If-Match")
+	if ifMatch != "" && req.ExpectedVersion == nil {
+		version, ok := parseETag(ifMatch)
+		if !ok {
+			c.responder.Error(w, http.StatusBadRequest, "Invalid If-Match header")
+			return
+		}
+		req.ExpectedVersion = &version
 	}
 
 	// Process operation
-	if err := c.service.ProcessWalletOperation(r.Context(), req); err != nil {
-		if strings.Contains(err.Error(), "insufficient funds") {
-			c.responder.Error(w, http.StatusBadRequest, "Insufficient funds")
+	ctx, fee := fees.WithCharge(r.Context())
+	if err := c.service.ProcessWalletOperation(ctx, req); err != nil {
+		apiErr := walletError(err)
+		if apiErr.httpStatus == http.StatusConflict && ifMatch != "" {
+			apiErr.httpStatus = http.StatusPreconditionFailed
+		}
+		c.responder.Error(w, apiErr.httpStatus, apiErr.message)
+		return
+	}
+
+	resp := models.WalletOperationResponse{Status: "success"}
+	if fee.Amount > 0 {
+		resp.Fee = fee
+	}
+	c.responder.OutputJSON(w, http.StatusOK, resp)
+}
+
+const maxBatchSize = 1000
+
+func (c *WalletController) HandleBatchOperation(w http.ResponseWriter, r *http.Request) {
+	var req models.BatchOperationRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		c.responder.Error(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if req.Mode == "" {
+		req.Mode = models.BatchAtomic
+	}
+	if req.Mode != models.BatchAtomic && req.Mode != models.BatchBestEffort {
+		c.responder.Error(w, http.StatusBadRequest, "Mode must be atomic or best_effort")
+		return
+	}
+
+	if len(req.Operations) == 0 || len(req.Operations) > maxBatchSize {
+		c.responder.Error(w, http.StatusBadRequest, fmt.Sprintf("Batch must contain between 1 and %d operations", maxBatchSize))
+		return
+	}
+
+	// In best-effort mode invalid items are reported as failed results instead
+	if req.Mode == models.BatchAtomic {
+		for i, op := range req.Operations {
+			if msg := validateWalletOperation(op); msg != "" {
+				c.responder.Error(w, http.StatusBadRequest, fmt.Sprintf("Operation %d: %s", i, msg))
+				return
+			}
+		}
+	}
+
+	resp, err := c.service.ProcessBatch(r.Context(), req)
+	if err != nil {
+		var batchErr *models.BatchOperationError
+		if errors.As(err, &batchErr) {
+			apiErr := walletError(batchErr.Err)
+			c.responder.Error(w, apiErr.httpStatus, fmt.Sprintf("Operation %d: %s", batchErr.Index, apiErr.message))
 			return
 		}
-		c.responder.Error(w, http.StatusInternalServerError, "Internal server error")
+		apiErr := walletError(err)
+		c.responder.Error(w, apiErr.httpStatus, apiErr.message)
 		return
 	}
 
-	c.responder.OutputJSON(w, http.StatusOK, map[string]string{"status": "success"})
+	// Failed best-effort items are reported like a failed single operation, never with the raw error
+	for i := range resp.Results {
+		result := &resp.Results[i]
+		if result.Err == nil {
+			continue
+		}
+		if msg := validateWalletOperation(req.Operations[i]); msg != "" {
+			result.Error = msg
+		} else {
+			result.Error = walletError(result.Err).message
+		}
+	}
+
+	c.responder.OutputJSON(w, http.StatusOK, resp)
 }
 
 func (c *WalletController) GetWalletBalance(w http.ResponseWriter, r *http.Request) {
@@ -65,20 +148,45 @@ func (c *WalletController) GetWalletBalance(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
-	balance, err := c.service.GetWalletBalance(r.Context(), walletID)
+	ctx, cacheStatus := cache.WithStatus(r.Context())
+	wallet, err := c.service.GetWallet(ctx, walletID)
+	if *cacheStatus != "" {
+		w.Header().Set(CacheStatusHeader, string(*cacheStatus))
+	}
 	if err != nil {
-		if strings.Contains(err.Error(), "not found") {
-			c.responder.Error(w, http.StatusNotFound, "Wallet not found")
+		apiErr := walletError(err)
+		c.responder.Error(w, apiErr.httpStatus, apiErr.message)
+		return
+	}
+
+	// Sharded wallets change without bumping the version, so they get no ETag
+	if wallet.ShardCount == 0 {
+		etag := formatETag(wallet.Version)
+		w.Header().Set("ETag", etag)
+		if r.Header.Get("If-None-Match") == etag {
+			w.WriteHeader(http.StatusNotModified)
 			return
 		}
-		c.responder.Error(w, http.StatusInternalServerError, "Internal server error")
-		return
 	}
 
 	response := models.WalletBalanceResponse{
 		WalletID: walletID,
-		Balance:  balance,
+		Balance:  wallet.Balance,
+		Version:  wallet.Version,
 	}
 
 	c.responder.OutputJSON(w, http.StatusOK, response)
+}
+
+func formatETag(version int64) string {
+	return `"` + strconv.FormatInt(version, 10) + `"`
+}
+
+func parseETag(etag string) (int64, bool) {
+	etag = strings.TrimPrefix(strings.TrimSpace(etag), "W/")
+	if len(etag) < 2 || etag[0] != '"' || etag[len(etag)-1] != '"' {
+		return 0, false
+	}
+	version, err := strconv.ParseInt(etag[1:len(etag)-1], 10, 64)
+	return version, err == nil
 }
\ No newline at end of file
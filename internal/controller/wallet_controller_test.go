@@ -7,8 +7,11 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
+	"ITKtest/internal/cache"
 	"ITKtest/internal/models"
+	"ITKtest/internal/repository"
 	"ITKtest/internal/service"
 	"ITKtest/responder"
 
@@ -79,6 +82,18 @@ func TestWalletController_HandleWalletOperation(t *testing.T) {
 			expectedStatus: http.StatusBadRequest,
 			expectedError:  "Operation type must be DEPOSIT or WITHDRAW",
 		},
+		{
+			name: "purchase deposit",
+			requestBody: models.WalletOperationRequest{
+				WalletID:      uuid.New(),
+				OperationType: models.DEPOSIT,
+				Amount:        1000,
+				Purchase:      true,
+			},
+			mockSetup:      func(m *service.MockWalletService) {},
+			expectedStatus: http.StatusBadRequest,
+			expectedError:  "Purchase is only valid for WITHDRAW",
+		},
 		{
 			name: "insufficient funds",
 			requestBody: models.WalletOperationRequest{
@@ -88,7 +103,7 @@ func TestWalletController_HandleWalletOperation(t *testing.T) {
 			},
 			mockSetup: func(m *service.MockWalletService) {
 				m.On("ProcessWalletOperation", mock.Anything, mock.Anything).
-					Return(errors.New("insufficient funds"))
+					Return(repository.ErrInsufficientFunds)
 			},
 			expectedStatus: http.StatusBadRequest,
 			expectedError:  "Insufficient funds",
@@ -150,14 +165,40 @@ func TestWalletController_GetWalletBalance(t *testing.T) {
 		walletID        string
 		mockSetup       func(*service.MockWalletService)
 		expectedStatus  int
+		ifNoneMatch     string
 		expectedError   string
 		expectedBalance int64
+		expectedETag    string
 	}{
 		{
 			name:     "successful balance retrieval",
 			walletID: walletID.String(),
 			mockSetup: func(m *service.MockWalletService) {
-				m.On("GetWalletBalance", mock.Anything, walletID).Return(int64(2500), nil)
+				m.On("GetWallet", mock.Anything, walletID).
+					Return(&models.Wallet{ID: walletID, Balance: 2500, Version: 7}, nil)
+			},
+			expectedStatus:  http.StatusOK,
+			expectedBalance: 2500,
+			expectedETag:    `"7"`,
+		},
+		{
+			name:        "not modified",
+			walletID:    walletID.String(),
+			ifNoneMatch: `"7"`,
+			mockSetup: func(m *service.MockWalletService) {
+				m.On("GetWallet", mock.Anything, walletID).
+					Return(&models.Wallet{ID: walletID, Balance: 2500, Version: 7}, nil)
+			},
+			expectedStatus: http.StatusNotModified,
+			expectedETag:   `"7"`,
+		},
+		{
+			name:        "sharded wallet has no ETag",
+			walletID:    walletID.String(),
+			ifNoneMatch: `"0"`,
+			mockSetup: func(m *service.MockWalletService) {
+				m.On("GetWallet", mock.Anything, walletID).
+					Return(&models.Wallet{ID: walletID, Balance: 2500, ShardCount: 4}, nil)
 			},
 			expectedStatus:  http.StatusOK,
 			expectedBalance: 2500,
@@ -173,8 +214,8 @@ func TestWalletController_GetWalletBalance(t *testing.T) {
 			name:     "wallet not found",
 			walletID: walletID.String(),
 			mockSetup: func(m *service.MockWalletService) {
-				m.On("GetWalletBalance", mock.Anything, walletID).
-					Return(int64(0), errors.New("wallet not found"))
+				m.On("GetWallet", mock.Anything, walletID).
+					Return(nil, repository.ErrWalletNotFound)
 			},
 			expectedStatus: http.StatusNotFound,
 			expectedError:  "Wallet not found",
@@ -183,8 +224,8 @@ func TestWalletController_GetWalletBalance(t *testing.T) {
 			name:     "internal server error",
 			walletID: walletID.String(),
 			mockSetup: func(m *service.MockWalletService) {
-				m.On("GetWalletBalance", mock.Anything, walletID).
-					Return(int64(0), errors.New("database error"))
+				m.On("GetWallet", mock.Anything, walletID).
+					Return(nil, errors.New("database error"))
 			},
 			expectedStatus: http.StatusInternalServerError,
 			expectedError:  "Internal server error",
@@ -203,11 +244,15 @@ func TestWalletController_GetWalletBalance(t *testing.T) {
 			r.Get("/api/v1/wallets/{walletId}", controller.GetWalletBalance)
 
 			req := httptest.NewRequest("GET", "/api/v1/wallets/"+tt.walletID, nil)
+			if tt.ifNoneMatch != "" {
+				req.Header.Set("If-None-Match", tt.ifNoneMatch)
+			}
 			w := httptest.NewRecorder()
 
 			r.ServeHTTP(w, req)
 
 			assert.Equal(t, tt.expectedStatus, w.Code)
+			assert.Equal(t, tt.expectedETag, w.Header().Get("ETag"))
 
 			if tt.expectedError != "" {
 				var response map[string]string
@@ -226,3 +271,256 @@ func TestWalletController_GetWalletBalance(t *testing.T) {
 		})
 	}
 }
+
+func TestWalletController_HandleWalletOperation_Version(t *testing.T) {
+	version := int64(3)
+	tests := []struct {
+		name            string
+		ifMatch         string
+		expectedVersion *int64
+		mockSetup       func(*service.MockWalletService)
+		expectedStatus  int
+		expectedError   string
+	}{
+		{
+			name:    "If-Match sets expected version",
+			ifMatch: `"3"`,
+			mockSetup: func(m *service.MockWalletService) {
+				m.On("ProcessWalletOperation", mock.Anything, mock.MatchedBy(func(req models.WalletOperationRequest) bool {
+					return req.ExpectedVersion != nil && *req.ExpectedVersion == 3
+				})).Return(nil)
+			},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "malformed If-Match",
+			ifMatch:        "3",
+			mockSetup:      func(m *service.MockWalletService) {},
+			expectedStatus: http.StatusBadRequest,
+			expectedError:  "Invalid If-Match header",
+		},
+		{
+			name:    "stale If-Match",
+			ifMatch: `"2"`,
+			mockSetup: func(m *service.MockWalletService) {
+				m.On("ProcessWalletOperation", mock.Anything, mock.Anything).
+					Return(repository.ErrVersionConflict)
+			},
+			expectedStatus: http.StatusPreconditionFailed,
+			expectedError:  "Wallet version conflict",
+		},
+		{
+			name:            "stale expectedVersion",
+			expectedVersion: &version,
+			mockSetup: func(m *service.MockWalletService) {
+				m.On("ProcessWalletOperation", mock.Anything, mock.Anything).
+					Return(repository.ErrVersionConflict)
+			},
+			expectedStatus: http.StatusConflict,
+			expectedError:  "Wallet version conflict",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := &service.MockWalletService{}
+			controller := NewWalletController(mockService, responder.NewJSONResponder())
+
+			tt.mockSetup(mockService)
+
+			body, _ := json.Marshal(models.WalletOperationRequest{
+				WalletID:        uuid.New(),
+				OperationType:   models.DEPOSIT,
+				Amount:          100,
+				ExpectedVersion: tt.expectedVersion,
+			})
+			req := httptest.NewRequest("POST", "/api/v1/wallet", bytes.NewReader(body))
+			if tt.ifMatch != "" {
+				req.Header.Set("If-Match", tt.ifMatch)
+			}
+			w := httptest.NewRecorder()
+
+			controller.HandleWalletOperation(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			if tt.expectedError != "" {
+				var response map[string]string
+				assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+				assert.Contains(t, response["error"], tt.expectedError)
+			}
+
+			mockService.AssertExpectations(t)
+		})
+	}
+}
+
+func TestWalletController_HandleBatchOperation(t *testing.T) {
+	walletID := uuid.New()
+	tests := []struct {
+		name           string
+		requestBody    interface{}
+		mockSetup      func(*service.MockWalletService)
+		expectedStatus int
+		expectedError  string
+	}{
+		{
+			name: "atomic batch",
+			requestBody: models.BatchOperationRequest{
+				Mode: models.BatchAtomic,
+				Operations: []models.WalletOperationRequest{
+					{WalletID: walletID, OperationType: models.DEPOSIT, Amount: 100},
+				},
+			},
+			mockSetup: func(m *service.MockWalletService) {
+				m.On("ProcessBatch", mock.Anything, mock.Anything).
+					Return(&models.BatchOperationResponse{Mode: models.BatchAtomic, Succeeded: 1}, nil)
+			},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name: "empty batch",
+			requestBody: models.BatchOperationRequest{
+				Mode: models.BatchAtomic,
+			},
+			mockSetup:      func(m *service.MockWalletService) {},
+			expectedStatus: http.StatusBadRequest,
+			expectedError:  "Batch must contain between 1 and",
+		},
+		{
+			name: "unknown mode",
+			requestBody: models.BatchOperationRequest{
+				Mode: "sometimes",
+				Operations: []models.WalletOperationRequest{
+					{WalletID: walletID, OperationType: models.DEPOSIT, Amount: 100},
+				},
+			},
+			mockSetup:      func(m *service.MockWalletService) {},
+			expectedStatus: http.StatusBadRequest,
+			expectedError:  "Mode must be atomic or best_effort",
+		},
+		{
+			name: "invalid item in atomic batch",
+			requestBody: models.BatchOperationRequest{
+				Mode: models.BatchAtomic,
+				Operations: []models.WalletOperationRequest{
+					{WalletID: walletID, OperationType: models.DEPOSIT, Amount: 100},
+					{WalletID: walletID, OperationType: models.DEPOSIT, Amount: 0},
+				},
+			},
+			mockSetup:      func(m *service.MockWalletService) {},
+			expectedStatus: http.StatusBadRequest,
+			expectedError:  "Operation 1: Amount must be positive",
+		},
+		{
+			name: "atomic batch rolled back",
+			requestBody: models.BatchOperationRequest{
+				Mode: models.BatchAtomic,
+				Operations: []models.WalletOperationRequest{
+					{WalletID: walletID, OperationType: models.WITHDRAW, Amount: 100},
+				},
+			},
+			mockSetup: func(m *service.MockWalletService) {
+				m.On("ProcessBatch", mock.Anything, mock.Anything).
+					Return(nil, &models.BatchOperationError{Index: 0, Err: repository.ErrInsufficientFunds})
+			},
+			expectedStatus: http.StatusBadRequest,
+			expectedError:  "Operation 0: Insufficient funds",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := &service.MockWalletService{}
+			controller := NewWalletController(mockService, responder.NewJSONResponder())
+
+			tt.mockSetup(mockService)
+
+			body, _ := json.Marshal(tt.requestBody)
+			req := httptest.NewRequest("POST", "/api/v1/wallet/batch", bytes.NewReader(body))
+			w := httptest.NewRecorder()
+
+			controller.HandleBatchOperation(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+
+			if tt.expectedError != "" {
+				var response map[string]string
+				err := json.Unmarshal(w.Body.Bytes(), &response)
+				assert.NoError(t, err)
+				assert.Contains(t, response["error"], tt.expectedError)
+			}
+
+			mockService.AssertExpectations(t)
+		})
+	}
+}
+
+func TestWalletController_HandleBatchOperation_BestEffortErrors(t *testing.T) {
+	walletID := uuid.New()
+	mockService := &service.MockWalletService{}
+	mockService.On("ProcessBatch", mock.Anything, mock.Anything).Return(&models.BatchOperationResponse{
+		Mode:      models.BatchBestEffort,
+		Succeeded: 1,
+		Failed:    3,
+		Results: []models.BatchItemResult{
+			{Index: 0, WalletID: walletID, Status: "success"},
+			{Index: 1, WalletID: walletID, Status: "failed", Err: repository.ErrInsufficientFunds},
+			{Index: 2, WalletID: walletID, Status: "failed", Err: errors.New("pq: deadlock detected on relation wallets")},
+			{Index: 3, WalletID: walletID, Status: "failed", Err: errors.New("amount must be positive")},
+		},
+	}, nil)
+	controller := NewWalletController(mockService, responder.NewJSONResponder())
+
+	body, _ := json.Marshal(models.BatchOperationRequest{
+		Mode: models.BatchBestEffort,
+		Operations: []models.WalletOperationRequest{
+			{WalletID: walletID, OperationType: models.DEPOSIT, Amount: 100},
+			{WalletID: walletID, OperationType: models.WITHDRAW, Amount: 500},
+			{WalletID: walletID, OperationType: models.WITHDRAW, Amount: 10},
+			{WalletID: walletID, OperationType: models.DEPOSIT, Amount: 0},
+		},
+	})
+	req := httptest.NewRequest("POST", "/api/v1/wallet/batch", bytes.NewReader(body))
+	w := httptest.NewRecorder()
+
+	controller.HandleBatchOperation(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var response models.BatchOperationResponse
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, "", response.Results[0].Error)
+	assert.Equal(t, "Insufficient funds", response.Results[1].Error)
+	assert.Equal(t, "Internal server error", response.Results[2].Error)
+	assert.Equal(t, "Amount must be positive", response.Results[3].Error)
+	mockService.AssertExpectations(t)
+}
+
+func TestWalletController_GetWalletBalance_CacheStatus(t *testing.T) {
+	walletID := uuid.New()
+	mockService := &service.MockWalletService{}
+	mockService.On("GetWallet", mock.Anything, walletID).
+		Return(&models.Wallet{ID: walletID, Balance: 1000, Version: 1}, nil)
+
+	cached := service.NewCachedWalletService(mockService, cache.NewBalanceCache(10, time.Minute))
+	controller := NewWalletController(cached, responder.NewJSONResponder())
+
+	r := chi.NewRouter()
+	r.Use(ReadConsistency)
+	r.Get("/api/v1/wallets/{walletId}", controller.GetWalletBalance)
+
+	get := func(consistency string) string {
+		req := httptest.NewRequest("GET", "/api/v1/wallets/"+walletID.String(), nil)
+		if consistency != "" {
+			req.Header.Set(ReadConsistencyHeader, consistency)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+		return w.Header().Get(CacheStatusHeader)
+	}
+
+	assert.Equal(t, "MISS", get(""))
+	assert.Equal(t, "HIT", get(""))
+	assert.Equal(t, "BYPASS", get("strong"))
+	mockService.AssertNumberOfCalls(t, "GetWallet", 2)
+}
@@ -0,0 +1,170 @@
+package controller
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"ITKtest/internal/models"
+	"ITKtest/internal/repository"
+	"ITKtest/internal/service"
+	"ITKtest/internal/stream"
+	"ITKtest/responder"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+const (
+	replayBatchSize   = 500
+	keepAliveInterval = 15 * time.Second
+	// lateCommitWindow bounds the time between creating a journal entry and committing it for a
+	// resumed stream to still deliver the entry when it committed after a higher id
+	lateCommitWindow = time.Minute
+)
+
+// replayedEntries remembers the entries a replay sent that may also arrive live: those created
+// within lateCommitWindow before the subscription started
+type replayedEntries struct {
+	since time.Time
+	ids   map[int64]bool
+}
+
+func newReplayedEntries(subscribedAt time.Time) *replayedEntries {
+	return &replayedEntries{since: subscribedAt.Add(-lateCommitWindow), ids: make(map[int64]bool)}
+}
+
+func (r *replayedEntries) add(transaction models.Transaction) {
+	if !transaction.CreatedAt.Before(r.since) {
+		r.ids[transaction.ID] = true
+	}
+}
+
+// sent reports whether a live entry was already replayed
+func (r *replayedEntries) sent(transaction models.Transaction) bool {
+	if r.ids[transaction.ID] {
+		delete(r.ids, transaction.ID)
+		return true
+	}
+	return false
+}
+
+type WalletEventsController struct {
+	service   service.WalletService
+	broker    *stream.Broker
+	responder responder.Responder
+}
+
+func NewWalletEventsController(service service.WalletService, broker *stream.Broker, responder responder.Responder) *WalletEventsController {
+	return &WalletEventsController{
+		service:   service,
+		broker:    broker,
+		responder: responder,
+	}
+}
+
+// StreamWalletEvents streams balance changes as Server-Sent Events. Event ids are journal ids,
+// so a client reconnecting with Last-Event-ID receives every entry it missed. Entries of sharded
+// wallets commit out of id order, so the replay also repeats the entries created shortly before
+// Last-Event-ID; clients drop ids they have already seen.
+func (c *WalletEventsController) StreamWalletEvents(w http.ResponseWriter, r *http.Request) {
+	walletID, err := uuid.Parse(chi.URLParam(r, "walletId"))
+	if err != nil {
+		c.responder.Error(w, http.StatusBadRequest, "Invalid wallet ID")
+		return
+	}
+
+	lastEventIDStr := r.Header.Get("Last-Event-ID")
+	if lastEventIDStr == "" {
+		lastEventIDStr = r.URL.Query().Get("lastEventId")
+	}
+	var lastEventID int64
+	if lastEventIDStr != "" {
+		lastEventID, err = strconv.ParseInt(lastEventIDStr, 10, 64)
+		if err != nil || lastEventID < 0 {
+			c.responder.Error(w, http.StatusBadRequest, "Invalid Last-Event-ID")
+			return
+		}
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		c.responder.Error(w, http.StatusInternalServerError, "Streaming unsupported")
+		return
+	}
+
+	// Subscribe before reading the journal so nothing committed in between is lost
+	events, unsubscribe := c.broker.Subscribe(walletID)
+	defer unsubscribe()
+	replayed := newReplayedEntries(time.Now())
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	if lastEventIDStr != "" {
+		// A lagging replica could miss entries committed just before the subscription started
+		replayCtx := repository.WithPrimaryReads(r.Context())
+		afterID, err := c.service.ResumeAfterID(replayCtx, walletID, lastEventID, lateCommitWindow)
+		if err != nil {
+			return
+		}
+		for {
+			transactions, err := c.service.ListTransactions(replayCtx, walletID, afterID, replayBatchSize)
+			if err != nil {
+				return
+			}
+			for _, transaction := range transactions {
+				if err := writeEvent(w, transaction); err != nil {
+					return
+				}
+				replayed.add(transaction)
+				afterID = transaction.ID
+			}
+			flusher.Flush()
+			if len(transactions) < replayBatchSize {
+				break
+			}
+		}
+	}
+
+	keepAlive := time.NewTicker(keepAliveInterval)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case transaction, ok := <-events:
+			if !ok {
+				return
+			}
+			if replayed.sent(transaction) {
+				continue
+			}
+			if err := writeEvent(w, transaction); err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-keepAlive.C:
+			if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+func writeEvent(w http.ResponseWriter, transaction models.Transaction) error {
+	data, err := json.Marshal(transaction)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "id: %d\nevent: balance\ndata: %s\n\n", transaction.ID, data)
+	return err
+}
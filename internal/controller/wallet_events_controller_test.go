@@ -0,0 +1,103 @@
+package controller
+
+import (
+	"bufio"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"ITKtest/internal/models"
+	"ITKtest/internal/service"
+	"ITKtest/internal/stream"
+	"ITKtest/responder"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func readEventIDs(t *testing.T, reader *bufio.Reader, count int) []string {
+	var ids []string
+	for len(ids) < count {
+		line, err := reader.ReadString('\n')
+		if !assert.NoError(t, err) {
+			return ids
+		}
+		if strings.HasPrefix(line, "id: ") {
+			ids = append(ids, strings.TrimSpace(strings.TrimPrefix(line, "id: ")))
+		}
+	}
+	return ids
+}
+
+func TestWalletEventsController_StreamWalletEvents(t *testing.T) {
+	walletID := uuid.New()
+
+	t.Run("resumes from Last-Event-ID and continues live", func(t *testing.T) {
+		now := time.Now()
+		mockService := &service.MockWalletService{}
+		mockService.On("ResumeAfterID", mock.Anything, walletID, int64(5), lateCommitWindow).Return(int64(4), nil)
+		mockService.On("ListTransactions", mock.Anything, walletID, int64(4), replayBatchSize).
+			Return([]models.Transaction{
+				{ID: 5, WalletID: walletID, OperationType: models.DEPOSIT, Amount: 500, BalanceAfter: 500, CreatedAt: now},
+				{ID: 6, WalletID: walletID, OperationType: models.DEPOSIT, Amount: 100, BalanceAfter: 600, CreatedAt: now},
+				{ID: 7, WalletID: walletID, OperationType: models.WITHDRAW, Amount: 50, BalanceAfter: 550, CreatedAt: now},
+			}, nil)
+
+		broker := stream.NewBroker()
+		controller := NewWalletEventsController(mockService, broker, responder.NewJSONResponder())
+		r := chi.NewRouter()
+		r.Get("/api/v1/wallets/{walletId}/events", controller.StreamWalletEvents)
+		server := httptest.NewServer(r)
+		defer server.Close()
+
+		req, _ := http.NewRequest("GET", server.URL+"/api/v1/wallets/"+walletID.String()+"/events", nil)
+		req.Header.Set("Last-Event-ID", "5")
+		resp, err := http.DefaultClient.Do(req)
+		assert.NoError(t, err)
+		defer resp.Body.Close()
+
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+
+		reader := bufio.NewReader(resp.Body)
+		assert.Equal(t, []string{"5", "6", "7"}, readEventIDs(t, reader, 3))
+
+		// An entry already replayed from the journal is not sent twice, while one committing after
+		// a higher id is still delivered
+		broker.Publish(models.Transaction{ID: 7, WalletID: walletID, CreatedAt: now})
+		broker.Publish(models.Transaction{ID: 3, WalletID: walletID, OperationType: models.DEPOSIT, Amount: 20, BalanceAfter: 570, CreatedAt: now})
+		broker.Publish(models.Transaction{ID: 8, WalletID: walletID, OperationType: models.DEPOSIT, Amount: 10, BalanceAfter: 580, CreatedAt: now})
+		assert.Equal(t, []string{"3", "8"}, readEventIDs(t, reader, 2))
+
+		mockService.AssertExpectations(t)
+	})
+
+	t.Run("invalid Last-Event-ID", func(t *testing.T) {
+		controller := NewWalletEventsController(&service.MockWalletService{}, stream.NewBroker(), responder.NewJSONResponder())
+		r := chi.NewRouter()
+		r.Get("/api/v1/wallets/{walletId}/events", controller.StreamWalletEvents)
+
+		req := httptest.NewRequest("GET", "/api/v1/wallets/"+walletID.String()+"/events", nil)
+		req.Header.Set("Last-Event-ID", "abc")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("invalid wallet ID", func(t *testing.T) {
+		controller := NewWalletEventsController(&service.MockWalletService{}, stream.NewBroker(), responder.NewJSONResponder())
+		r := chi.NewRouter()
+		r.Get("/api/v1/wallets/{walletId}/events", controller.StreamWalletEvents)
+
+		req := httptest.NewRequest("GET", "/api/v1/wallets/invalid-uuid/events", nil)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+}
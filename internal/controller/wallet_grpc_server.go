@@ -0,0 +1,208 @@
+package controller
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	walletv1 "ITKtest/api/wallet/v1"
+	"ITKtest/internal/cache"
+	"ITKtest/internal/fees"
+	"ITKtest/internal/models"
+	"ITKtest/internal/repository"
+	"ITKtest/internal/service"
+	"ITKtest/internal/stream"
+
+	"github.com/google/uuid"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+const (
+	defaultTransactionsLimit = 100
+	maxTransactionsLimit     = 1000
+)
+
+// WalletGRPCServer serves the wallet service over gRPC with the same validation and error
+// mapping as WalletController
+type WalletGRPCServer struct {
+	walletv1.UnimplementedWalletServiceServer
+
+	service service.WalletService
+	broker  *stream.Broker
+}
+
+func NewWalletGRPCServer(service service.WalletService, broker *stream.Broker) *WalletGRPCServer {
+	return &WalletGRPCServer{
+		service: service,
+		broker:  broker,
+	}
+}
+
+func (s *WalletGRPCServer) ProcessOperation(ctx context.Context, req *walletv1.ProcessOperationRequest) (*walletv1.ProcessOperationResponse, error) {
+	walletID, err := uuid.Parse(req.GetWalletId())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, "Invalid wallet ID")
+	}
+
+	op := models.WalletOperationRequest{
+		WalletID:        walletID,
+		OperationType:   operationTypeFromProto(req.GetOperationType()),
+		Amount:          req.GetAmount(),
+		ExpectedVersion: req.ExpectedVersion,
+		Reference:       req.GetReference(),
+		Purchase:        req.GetPurchase(),
+	}
+	if msg := validateWalletOperation(op); msg != "" {
+		return nil, status.Error(codes.InvalidArgument, msg)
+	}
+
+	ctx, fee := fees.WithCharge(ctx)
+	if err := s.service.ProcessWalletOperation(ctx, op); err != nil {
+		return nil, grpcError(err)
+	}
+
+	return &walletv1.ProcessOperationResponse{Status: "success", Fee: fee.Amount}, nil
+}
+
+func (s *WalletGRPCServer) GetBalance(ctx context.Context, req *walletv1.GetBalanceRequest) (*walletv1.GetBalanceResponse, error) {
+	walletID, err := uuid.Parse(req.GetWalletId())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, "Invalid wallet ID")
+	}
+
+	ctx, cacheStatus := cache.WithStatus(ctx)
+	wallet, err := s.service.GetWallet(ctx, walletID)
+	if *cacheStatus != "" {
+		// Fails only outside a gRPC call, e.g. in tests
+		_ = grpc.SetHeader(ctx, metadata.Pairs(strings.ToLower(CacheStatusHeader), string(*cacheStatus)))
+	}
+	if err != nil {
+		return nil, grpcError(err)
+	}
+
+	return &walletv1.GetBalanceResponse{WalletId: walletID.String(), Balance: wallet.Balance, Version: wallet.Version}, nil
+}
+
+func (s *WalletGRPCServer) ListTransactions(ctx context.Context, req *walletv1.ListTransactionsRequest) (*walletv1.ListTransactionsResponse, error) {
+	walletID, err := uuid.Parse(req.GetWalletId())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, "Invalid wallet ID")
+	}
+
+	limit := int(req.GetLimit())
+	switch {
+	case limit < 0 || limit > maxTransactionsLimit:
+		return nil, status.Error(codes.InvalidArgument, "Invalid limit")
+	case limit == 0:
+		limit = defaultTransactionsLimit
+	}
+
+	transactions, err := s.service.ListTransactions(ctx, walletID, req.GetAfterId(), limit)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+
+	resp := &walletv1.ListTransactionsResponse{}
+	for _, transaction := range transactions {
+		resp.Transactions = append(resp.Transactions, transactionToProto(transaction))
+	}
+	return resp, nil
+}
+
+// WatchBalance mirrors the SSE stream: subscribe first, replay from last_event_id if given, then
+// forward live entries. As there, a replay may repeat entries created shortly before last_event_id.
+func (s *WalletGRPCServer) WatchBalance(req *walletv1.WatchBalanceRequest, srv walletv1.WalletService_WatchBalanceServer) error {
+	walletID, err := uuid.Parse(req.GetWalletId())
+	if err != nil {
+		return status.Error(codes.InvalidArgument, "Invalid wallet ID")
+	}
+
+	ctx := srv.Context()
+	events, unsubscribe := s.broker.Subscribe(walletID)
+	defer unsubscribe()
+	replayed := newReplayedEntries(time.Now())
+
+	if req.LastEventId != nil {
+		// Replay from the primary, as StreamWalletEvents does
+		replayCtx := repository.WithPrimaryReads(ctx)
+		afterID, err := s.service.ResumeAfterID(replayCtx, walletID, req.GetLastEventId(), lateCommitWindow)
+		if err != nil {
+			return grpcError(err)
+		}
+		for {
+			transactions, err := s.service.ListTransactions(replayCtx, walletID, afterID, replayBatchSize)
+			if err != nil {
+				return grpcError(err)
+			}
+			for _, transaction := range transactions {
+				if err := srv.Send(transactionToProto(transaction)); err != nil {
+					return err
+				}
+				replayed.add(transaction)
+				afterID = transaction.ID
+			}
+			if len(transactions) < replayBatchSize {
+				break
+			}
+		}
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case transaction, ok := <-events:
+			if !ok {
+				return status.Error(codes.Unavailable, "Stream interrupted, resume with last_event_id")
+			}
+			if replayed.sent(transaction) {
+				continue
+			}
+			if err := srv.Send(transactionToProto(transaction)); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func grpcError(err error) error {
+	apiErr := walletError(err)
+	return status.Error(apiErr.grpcCode, apiErr.message)
+}
+
+func operationTypeFromProto(operationType walletv1.OperationType) models.OperationType {
+	switch operationType {
+	case walletv1.OperationType_OPERATION_TYPE_DEPOSIT:
+		return models.DEPOSIT
+	case walletv1.OperationType_OPERATION_TYPE_WITHDRAW:
+		return models.WITHDRAW
+	}
+	return ""
+}
+
+func operationTypeToProto(operationType models.OperationType) walletv1.OperationType {
+	switch operationType {
+	case models.DEPOSIT:
+		return walletv1.OperationType_OPERATION_TYPE_DEPOSIT
+	case models.WITHDRAW:
+		return walletv1.OperationType_OPERATION_TYPE_WITHDRAW
+	}
+	return walletv1.OperationType_OPERATION_TYPE_UNSPECIFIED
+}
+
+func transactionToProto(transaction models.Transaction) *walletv1.Transaction {
+	return &walletv1.Transaction{
+		Id:            transaction.ID,
+		WalletId:      transaction.WalletID.String(),
+		OperationType: operationTypeToProto(transaction.OperationType),
+		Amount:        transaction.Amount,
+		BalanceAfter:  transaction.BalanceAfter,
+		CreatedAt:     timestamppb.New(transaction.CreatedAt),
+		Reference:     transaction.Reference,
+		FeeFor:        transaction.FeeFor,
+	}
+}
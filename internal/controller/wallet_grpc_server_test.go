@@ -0,0 +1,151 @@
+package controller
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	walletv1 "ITKtest/api/wallet/v1"
+	"ITKtest/internal/models"
+	"ITKtest/internal/repository"
+	"ITKtest/internal/service"
+	"ITKtest/internal/stream"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/proto"
+)
+
+func newGRPCClient(t *testing.T, mockService *service.MockWalletService, broker *stream.Broker) walletv1.WalletServiceClient {
+	lis := bufconn.Listen(1 << 20)
+	server := grpc.NewServer()
+	walletv1.RegisterWalletServiceServer(server, NewWalletGRPCServer(mockService, broker))
+	go server.Serve(lis)
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	assert.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+
+	return walletv1.NewWalletServiceClient(conn)
+}
+
+func TestWalletGRPCServer_ProcessOperation(t *testing.T) {
+	walletID := uuid.New()
+	tests := []struct {
+		name         string
+		request      *walletv1.ProcessOperationRequest
+		mockSetup    func(*service.MockWalletService)
+		expectedCode codes.Code
+	}{
+		{
+			name: "successful deposit",
+			request: &walletv1.ProcessOperationRequest{
+				WalletId:      walletID.String(),
+				OperationType: walletv1.OperationType_OPERATION_TYPE_DEPOSIT,
+				Amount:        1000,
+			},
+			mockSetup: func(m *service.MockWalletService) {
+				m.On("ProcessWalletOperation", mock.Anything, models.WalletOperationRequest{
+					WalletID:      walletID,
+					OperationType: models.DEPOSIT,
+					Amount:        1000,
+				}).Return(nil)
+			},
+			expectedCode: codes.OK,
+		},
+		{
+			name:         "invalid wallet ID",
+			request:      &walletv1.ProcessOperationRequest{WalletId: "invalid-uuid", Amount: 1000},
+			mockSetup:    func(m *service.MockWalletService) {},
+			expectedCode: codes.InvalidArgument,
+		},
+		{
+			name: "unspecified operation type",
+			request: &walletv1.ProcessOperationRequest{
+				WalletId: walletID.String(),
+				Amount:   1000,
+			},
+			mockSetup:    func(m *service.MockWalletService) {},
+			expectedCode: codes.InvalidArgument,
+		},
+		{
+			name: "insufficient funds",
+			request: &walletv1.ProcessOperationRequest{
+				WalletId:      walletID.String(),
+				OperationType: walletv1.OperationType_OPERATION_TYPE_WITHDRAW,
+				Amount:        1000,
+			},
+			mockSetup: func(m *service.MockWalletService) {
+				m.On("ProcessWalletOperation", mock.Anything, mock.Anything).Return(repository.ErrInsufficientFunds)
+			},
+			expectedCode: codes.FailedPrecondition,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := &service.MockWalletService{}
+			tt.mockSetup(mockService)
+			client := newGRPCClient(t, mockService, stream.NewBroker())
+
+			_, err := client.ProcessOperation(context.Background(), tt.request)
+
+			assert.Equal(t, tt.expectedCode, status.Code(err))
+			mockService.AssertExpectations(t)
+		})
+	}
+}
+
+func TestWalletGRPCServer_GetBalance(t *testing.T) {
+	walletID := uuid.New()
+	mockService := &service.MockWalletService{}
+	mockService.On("GetWallet", mock.Anything, walletID).Return(&models.Wallet{ID: walletID, Balance: 2500, Version: 7}, nil).Once()
+	mockService.On("GetWallet", mock.Anything, walletID).Return(nil, repository.ErrWalletNotFound).Once()
+	client := newGRPCClient(t, mockService, stream.NewBroker())
+
+	resp, err := client.GetBalance(context.Background(), &walletv1.GetBalanceRequest{WalletId: walletID.String()})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2500), resp.GetBalance())
+	assert.Equal(t, int64(7), resp.GetVersion())
+
+	_, err = client.GetBalance(context.Background(), &walletv1.GetBalanceRequest{WalletId: walletID.String()})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+	mockService.AssertExpectations(t)
+}
+
+func TestWalletGRPCServer_WatchBalance(t *testing.T) {
+	walletID := uuid.New()
+	mockService := &service.MockWalletService{}
+	mockService.On("ResumeAfterID", mock.Anything, walletID, int64(3), lateCommitWindow).Return(int64(3), nil)
+	mockService.On("ListTransactions", mock.Anything, walletID, int64(3), replayBatchSize).
+		Return([]models.Transaction{{ID: 4, WalletID: walletID, OperationType: models.DEPOSIT, Amount: 100, BalanceAfter: 100}}, nil)
+	broker := stream.NewBroker()
+	client := newGRPCClient(t, mockService, broker)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	watch, err := client.WatchBalance(ctx, &walletv1.WatchBalanceRequest{WalletId: walletID.String(), LastEventId: proto.Int64(3)})
+	assert.NoError(t, err)
+
+	first, err := watch.Recv()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(4), first.GetId())
+	assert.Equal(t, walletv1.OperationType_OPERATION_TYPE_DEPOSIT, first.GetOperationType())
+
+	// An entry committing after a higher id is not dropped
+	broker.Publish(models.Transaction{ID: 2, WalletID: walletID, OperationType: models.WITHDRAW, Amount: 40, BalanceAfter: 60})
+	second, err := watch.Recv()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), second.GetId())
+	assert.Equal(t, int64(60), second.GetBalanceAfter())
+	mockService.AssertExpectations(t)
+}
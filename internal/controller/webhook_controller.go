@@ -0,0 +1,122 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"ITKtest/internal/models"
+	"ITKtest/internal/repository"
+	"ITKtest/internal/service"
+	"ITKtest/responder"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+const defaultDeadLetterLimit = 100
+
+type WebhookController struct {
+	service   service.WebhookService
+	responder responder.Responder
+}
+
+func NewWebhookController(service service.WebhookService, responder responder.Responder) *WebhookController {
+	return &WebhookController{
+		service:   service,
+		responder: responder,
+	}
+}
+
+func (c *WebhookController) RegisterEndpoint(w http.ResponseWriter, r *http.Request) {
+	var req models.RegisterWebhookRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		c.responder.Error(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	endpoint, err := c.service.RegisterEndpoint(r.Context(), req)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrInvalidWebhookURL):
+			c.responder.Error(w, http.StatusBadRequest, "URL must be an absolute http or https URL")
+		case errors.Is(err, service.ErrInvalidEventTypes):
+			c.responder.Error(w, http.StatusBadRequest, "Event types must list at least one known event type")
+		default:
+			c.responder.Error(w, http.StatusInternalServerError, "Internal server error")
+		}
+		return
+	}
+
+	c.responder.OutputJSON(w, http.StatusCreated, endpoint)
+}
+
+func (c *WebhookController) ListEndpoints(w http.ResponseWriter, r *http.Request) {
+	endpoints, err := c.service.ListEndpoints(r.Context())
+	if err != nil {
+		c.responder.Error(w, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+
+	c.responder.OutputJSON(w, http.StatusOK, endpoints)
+}
+
+func (c *WebhookController) DeleteEndpoint(w http.ResponseWriter, r *http.Request) {
+	endpointID, err := uuid.Parse(chi.URLParam(r, "endpointId"))
+	if err != nil {
+		c.responder.Error(w, http.StatusBadRequest, "Invalid endpoint ID")
+		return
+	}
+
+	if err := c.service.DeleteEndpoint(r.Context(), endpointID); err != nil {
+		if errors.Is(err, repository.ErrWebhookEndpointNotFound) {
+			c.responder.Error(w, http.StatusNotFound, "Webhook endpoint not found")
+			return
+		}
+		c.responder.Error(w, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (c *WebhookController) ListDeadLetters(w http.ResponseWriter, r *http.Request) {
+	limit := defaultDeadLetterLimit
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		n, err := strconv.Atoi(limitStr)
+		if err != nil || n <= 0 {
+			c.responder.Error(w, http.StatusBadRequest, "Invalid limit")
+			return
+		}
+		limit = n
+	}
+
+	deadLetters, err := c.service.ListDeadLetters(r.Context(), limit)
+	if err != nil {
+		c.responder.Error(w, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+
+	c.responder.OutputJSON(w, http.StatusOK, deadLetters)
+}
+
+func (c *WebhookController) ReplayDeadLetter(w http.ResponseWriter, r *http.Request) {
+	deadLetterID, err := strconv.ParseInt(chi.URLParam(r, "deadLetterId"), 10, 64)
+	if err != nil {
+		c.responder.Error(w, http.StatusBadRequest, "Invalid dead letter ID")
+		return
+	}
+
+	if err := c.service.ReplayDeadLetter(r.Context(), deadLetterID); err != nil {
+		if errors.Is(err, repository.ErrDeadLetterNotFound) {
+			c.responder.Error(w, http.StatusNotFound, "Dead letter not found")
+			return
+		}
+		c.responder.Error(w, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+
+	c.responder.OutputJSON(w, http.StatusAccepted, map[string]string{"status": "queued"})
+}
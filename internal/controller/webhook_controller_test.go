@@ -0,0 +1,180 @@
+package controller
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"ITKtest/internal/models"
+	"ITKtest/internal/repository"
+	"ITKtest/internal/service"
+	"ITKtest/responder"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func newWebhookRouter(controller *WebhookController) chi.Router {
+	r := chi.NewRouter()
+	r.Route("/api/v1/webhooks", func(r chi.Router) {
+		r.Post("/", controller.RegisterEndpoint)
+		r.Delete("/{endpointId}", controller.DeleteEndpoint)
+		r.Get("/dead-letters", controller.ListDeadLetters)
+		r.Post("/dead-letters/{deadLetterId}/replay", controller.ReplayDeadLetter)
+	})
+	return r
+}
+
+func TestWebhookController(t *testing.T) {
+	endpointID := uuid.New()
+	tests := []struct {
+		name           string
+		method         string
+		path           string
+		requestBody    interface{}
+		mockSetup      func(*service.MockWebhookService)
+		expectedStatus int
+		expectedError  string
+	}{
+		{
+			name:   "register endpoint",
+			method: "POST",
+			path:   "/api/v1/webhooks",
+			requestBody: models.RegisterWebhookRequest{
+				URL:        "https://example.com/hook",
+				EventTypes: []models.WebhookEventType{models.WebhookDeposit},
+			},
+			mockSetup: func(m *service.MockWebhookService) {
+				m.On("RegisterEndpoint", mock.Anything, mock.Anything).
+					Return(&models.WebhookEndpoint{ID: endpointID, Secret: "generated"}, nil)
+			},
+			expectedStatus: http.StatusCreated,
+		},
+		{
+			name:   "register endpoint with invalid url",
+			method: "POST",
+			path:   "/api/v1/webhooks",
+			requestBody: models.RegisterWebhookRequest{
+				URL:        "ftp://example.com",
+				EventTypes: []models.WebhookEventType{models.WebhookDeposit},
+			},
+			mockSetup: func(m *service.MockWebhookService) {
+				m.On("RegisterEndpoint", mock.Anything, mock.Anything).
+					Return(nil, service.ErrInvalidWebhookURL)
+			},
+			expectedStatus: http.StatusBadRequest,
+			expectedError:  "URL must be an absolute http or https URL",
+		},
+		{
+			name:   "register endpoint with unknown event type",
+			method: "POST",
+			path:   "/api/v1/webhooks",
+			requestBody: models.RegisterWebhookRequest{
+				URL:        "https://example.com/hook",
+				EventTypes: []models.WebhookEventType{"wallet_frozen"},
+			},
+			mockSetup: func(m *service.MockWebhookService) {
+				m.On("RegisterEndpoint", mock.Anything, mock.Anything).
+					Return(nil, fmt.Errorf("%w: unknown %q", service.ErrInvalidEventTypes, "wallet_frozen"))
+			},
+			expectedStatus: http.StatusBadRequest,
+			expectedError:  "Event types must list at least one known event type",
+		},
+		{
+			name:   "register endpoint failing in the database",
+			method: "POST",
+			path:   "/api/v1/webhooks",
+			requestBody: models.RegisterWebhookRequest{
+				URL:        "https://example.com/hook",
+				EventTypes: []models.WebhookEventType{models.WebhookDeposit},
+			},
+			mockSetup: func(m *service.MockWebhookService) {
+				m.On("RegisterEndpoint", mock.Anything, mock.Anything).
+					Return(nil, errors.New(`pq: invalid input syntax for type uuid`))
+			},
+			expectedStatus: http.StatusInternalServerError,
+			expectedError:  "Internal server error",
+		},
+		{
+			name:   "delete missing endpoint",
+			method: "DELETE",
+			path:   "/api/v1/webhooks/" + endpointID.String(),
+			mockSetup: func(m *service.MockWebhookService) {
+				m.On("DeleteEndpoint", mock.Anything, endpointID).Return(repository.ErrWebhookEndpointNotFound)
+			},
+			expectedStatus: http.StatusNotFound,
+			expectedError:  "Webhook endpoint not found",
+		},
+		{
+			name:   "list dead letters",
+			method: "GET",
+			path:   "/api/v1/webhooks/dead-letters?limit=5",
+			mockSetup: func(m *service.MockWebhookService) {
+				m.On("ListDeadLetters", mock.Anything, 5).Return([]models.WebhookDeadLetter{}, nil)
+			},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "list dead letters with invalid limit",
+			method:         "GET",
+			path:           "/api/v1/webhooks/dead-letters?limit=-1",
+			mockSetup:      func(m *service.MockWebhookService) {},
+			expectedStatus: http.StatusBadRequest,
+			expectedError:  "Invalid limit",
+		},
+		{
+			name:   "replay dead letter",
+			method: "POST",
+			path:   "/api/v1/webhooks/dead-letters/42/replay",
+			mockSetup: func(m *service.MockWebhookService) {
+				m.On("ReplayDeadLetter", mock.Anything, int64(42)).Return(nil)
+			},
+			expectedStatus: http.StatusAccepted,
+		},
+		{
+			name:   "replay unknown dead letter",
+			method: "POST",
+			path:   "/api/v1/webhooks/dead-letters/42/replay",
+			mockSetup: func(m *service.MockWebhookService) {
+				m.On("ReplayDeadLetter", mock.Anything, int64(42)).Return(repository.ErrDeadLetterNotFound)
+			},
+			expectedStatus: http.StatusNotFound,
+			expectedError:  "Dead letter not found",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := &service.MockWebhookService{}
+			controller := NewWebhookController(mockService, responder.NewJSONResponder())
+
+			tt.mockSetup(mockService)
+
+			var body []byte
+			if tt.requestBody != nil {
+				body, _ = json.Marshal(tt.requestBody)
+			}
+			req := httptest.NewRequest(tt.method, tt.path, bytes.NewReader(body))
+			w := httptest.NewRecorder()
+
+			newWebhookRouter(controller).ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+
+			if tt.expectedError != "" {
+				var response map[string]string
+				err := json.Unmarshal(w.Body.Bytes(), &response)
+				assert.NoError(t, err)
+				assert.Contains(t, response["error"], tt.expectedError)
+			}
+
+			mockService.AssertExpectations(t)
+		})
+	}
+}
@@ -0,0 +1,22 @@
+package fees
+
+import (
+	"context"
+
+	"ITKtest/internal/models"
+)
+
+type chargeKey struct{}
+
+// WithCharge returns a context in which the fee charged for a successful operation is recorded
+func WithCharge(ctx context.Context) (context.Context, *models.OperationFee) {
+	charge := new(models.OperationFee)
+	return context.WithValue(ctx, chargeKey{}, charge), charge
+}
+
+// SetCharge records fee in ctx if it was prepared by WithCharge
+func SetCharge(ctx context.Context, fee models.OperationFee) {
+	if c, ok := ctx.Value(chargeKey{}).(*models.OperationFee); ok {
+		*c = fee
+	}
+}
@@ -0,0 +1,172 @@
+package fees
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+
+	"ITKtest/internal/models"
+
+	"github.com/google/uuid"
+	"gopkg.in/yaml.v3"
+)
+
+// maxBasisPoints is a fee of 100% of the amount
+const maxBasisPoints = 10000
+
+// Class is the tenant and tier a wallet belongs to for fee purposes
+type Class struct {
+	Tenant string `yaml:"tenant"`
+	Tier   string `yaml:"tier"`
+}
+
+// Rule is a fee of Fixed minor units plus BasisPoints hundredths of a percent of the amount,
+// rounded half up and kept between MinFee and MaxFee. Empty criteria match anything and a zero
+// MaxAmount or MaxFee is unbounded, so that tiers by amount are rules with adjacent ranges.
+type Rule struct {
+	Name          string               `yaml:"name"`
+	OperationType models.OperationType `yaml:"operationType"`
+	Tenant        string               `yaml:"tenant"`
+	Tier          string               `yaml:"tier"`
+	MinAmount     int64                `yaml:"minAmount"`
+	MaxAmount     int64                `yaml:"maxAmount"`
+	Fixed         int64                `yaml:"fixed"`
+	BasisPoints   int64                `yaml:"basisPoints"`
+	MinFee        int64                `yaml:"minFee"`
+	MaxFee        int64                `yaml:"maxFee"`
+}
+
+func (r Rule) matches(op models.WalletOperationRequest, class Class) bool {
+	return (r.OperationType == "" || r.OperationType == op.OperationType) &&
+		(r.Tenant == "" || r.Tenant == class.Tenant) &&
+		(r.Tier == "" || r.Tier == class.Tier) &&
+		op.Amount >= r.MinAmount &&
+		(r.MaxAmount == 0 || op.Amount <= r.MaxAmount)
+}
+
+// fee computes the fee of amount; BasisPoints is at most 100%, so the percentage never overflows
+func (r Rule) fee(amount int64) int64 {
+	percentage := amount/maxBasisPoints*r.BasisPoints + (amount%maxBasisPoints*r.BasisPoints+maxBasisPoints/2)/maxBasisPoints
+	fee := r.Fixed + percentage
+	if fee < r.MinFee {
+		fee = r.MinFee
+	}
+	if r.MaxFee > 0 && fee > r.MaxFee {
+		fee = r.MaxFee
+	}
+	return fee
+}
+
+// Schedule holds the fee rules, applied first match wins, the wallet that collects the fees and
+// the class of wallets that rules select by tenant or tier. Wallets not listed have no tenant
+// or tier. The fee wallet itself is never charged.
+type Schedule struct {
+	FeeWallet uuid.UUID
+	Wallets   map[uuid.UUID]Class
+	Rules     []Rule
+}
+
+// scheduleFile is the layout of a fee schedule file
+type scheduleFile struct {
+	FeeWallet string           `yaml:"feeWallet"`
+	Wallets   map[string]Class `yaml:"wallets"`
+	Rules     []Rule           `yaml:"rules"`
+}
+
+// LoadSchedule reads a fee schedule from a YAML or JSON file
+func LoadSchedule(path string) (*Schedule, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	schedule, err := ParseSchedule(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid fee schedule %s: %w", path, err)
+	}
+	return schedule, nil
+}
+
+// ParseSchedule parses and validates a fee schedule in YAML or JSON
+func ParseSchedule(data []byte) (*Schedule, error) {
+	var file scheduleFile
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&file); err != nil {
+		return nil, err
+	}
+
+	feeWallet, err := uuid.Parse(file.FeeWallet)
+	if err != nil {
+		return nil, fmt.Errorf("invalid feeWallet %q", file.FeeWallet)
+	}
+	schedule := &Schedule{FeeWallet: feeWallet, Wallets: make(map[uuid.UUID]Class, len(file.Wallets)), Rules: file.Rules}
+	for id, class := range file.Wallets {
+		walletID, err := uuid.Parse(id)
+		if err != nil {
+			return nil, fmt.Errorf("invalid wallet ID %q", id)
+		}
+		schedule.Wallets[walletID] = class
+	}
+
+	var errs []error
+	for i := range schedule.Rules {
+		rule := &schedule.Rules[i]
+		if rule.Name == "" {
+			rule.Name = fmt.Sprintf("rule %d", i+1)
+		}
+		check := func(ok bool, message string) {
+			if !ok {
+				errs = append(errs, fmt.Errorf("%s: %s", rule.Name, message))
+			}
+		}
+		check(rule.OperationType == "" || rule.OperationType == models.DEPOSIT || rule.OperationType == models.WITHDRAW,
+			"operationType must be DEPOSIT or WITHDRAW")
+		check(rule.MinAmount >= 0 && rule.MaxAmount >= 0, "amount range must not be negative")
+		check(rule.MaxAmount == 0 || rule.MaxAmount >= rule.MinAmount, "maxAmount must not be below minAmount")
+		check(rule.Fixed >= 0, "fixed must not be negative")
+		check(rule.BasisPoints >= 0 && rule.BasisPoints <= maxBasisPoints, "basisPoints must be between 0 and 10000")
+		check(rule.MinFee >= 0 && rule.MaxFee >= 0, "fee caps must not be negative")
+		check(rule.MaxFee == 0 || rule.MaxFee >= rule.MinFee, "maxFee must not be below minFee")
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	return schedule, nil
+}
+
+// Fee returns the fee of op under the first matching rule, or nil if no rule charges one. A nil
+// schedule charges nothing.
+func (s *Schedule) Fee(op models.WalletOperationRequest) *models.OperationFee {
+	if s == nil || op.WalletID == s.FeeWallet {
+		return nil
+	}
+	class := s.Wallets[op.WalletID]
+	for _, rule := range s.Rules {
+		if !rule.matches(op, class) {
+			continue
+		}
+		amount := rule.fee(op.Amount)
+		if amount == 0 {
+			return nil
+		}
+		return &models.OperationFee{Amount: amount, FeeWalletID: s.FeeWallet, Rule: rule.Name}
+	}
+	return nil
+}
+
+// Quote returns the fee op would be charged and its effect on the balance, without executing it
+func (s *Schedule) Quote(op models.WalletOperationRequest) models.FeeQuote {
+	quote := models.FeeQuote{WalletID: op.WalletID, OperationType: op.OperationType, Amount: op.Amount}
+	if fee := s.Fee(op); fee != nil {
+		quote.Fee = fee.Amount
+		quote.Rule = fee.Rule
+	}
+	quote.BalanceChange = -quote.Fee
+	if op.OperationType == models.WITHDRAW {
+		quote.BalanceChange -= op.Amount
+	} else {
+		quote.BalanceChange += op.Amount
+	}
+	return quote
+}
@@ -0,0 +1,136 @@
+package fees
+
+import (
+	"context"
+	"math"
+	"testing"
+
+	"ITKtest/internal/models"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testSchedule = `
+feeWallet: 00000000-0000-0000-0000-0000000000fe
+wallets:
+  00000000-0000-0000-0000-000000000001: {tenant: acme, tier: gold}
+  00000000-0000-0000-0000-000000000002: {tenant: acme}
+rules:
+  - name: gold withdrawals
+    operationType: WITHDRAW
+    tier: gold
+    fixed: 0
+  - name: acme deposits
+    operationType: DEPOSIT
+    tenant: acme
+    basisPoints: 50
+    minFee: 10
+    maxFee: 100
+  - name: small withdrawals
+    operationType: WITHDRAW
+    maxAmount: 9999
+    fixed: 25
+  - operationType: WITHDRAW
+    minAmount: 10000
+    fixed: 25
+    basisPoints: 125
+`
+
+func TestSchedule_Fee(t *testing.T) {
+	schedule, err := ParseSchedule([]byte(testSchedule))
+	require.NoError(t, err)
+
+	gold := uuid.MustParse("00000000-0000-0000-0000-000000000001")
+	acme := uuid.MustParse("00000000-0000-0000-0000-000000000002")
+	other := uuid.New()
+	tests := []struct {
+		name          string
+		walletID      uuid.UUID
+		operationType models.OperationType
+		amount        int64
+		expectedFee   int64
+		expectedRule  string
+	}{
+		{"first match wins", gold, models.WITHDRAW, 50000, 0, ""},
+		{"minimum fee", acme, models.DEPOSIT, 1000, 10, "acme deposits"},
+		{"percentage", acme, models.DEPOSIT, 5000, 25, "acme deposits"},
+		{"percentage rounds half up", acme, models.DEPOSIT, 4900, 25, "acme deposits"},
+		{"maximum fee", acme, models.DEPOSIT, 1000000, 100, "acme deposits"},
+		{"no matching rule", other, models.DEPOSIT, 1000, 0, ""},
+		{"lower amount tier", other, models.WITHDRAW, 9999, 25, "small withdrawals"},
+		{"upper amount tier", other, models.WITHDRAW, 10000, 150, "rule 4"},
+		{"largest amount does not overflow", other, models.WITHDRAW, math.MaxInt64, 25 + 115292150460684698, "rule 4"},
+		{"fee wallet is never charged", schedule.FeeWallet, models.WITHDRAW, 10000, 0, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			op := models.WalletOperationRequest{WalletID: tt.walletID, OperationType: tt.operationType, Amount: tt.amount}
+			fee := schedule.Fee(op)
+			if tt.expectedFee == 0 {
+				assert.Nil(t, fee)
+				return
+			}
+			require.NotNil(t, fee)
+			assert.Equal(t, models.OperationFee{Amount: tt.expectedFee, FeeWalletID: schedule.FeeWallet, Rule: tt.expectedRule}, *fee)
+		})
+	}
+}
+
+func TestSchedule_Quote(t *testing.T) {
+	schedule, err := ParseSchedule([]byte(testSchedule))
+	require.NoError(t, err)
+	walletID := uuid.New()
+
+	quote := schedule.Quote(models.WalletOperationRequest{WalletID: walletID, OperationType: models.WITHDRAW, Amount: 20000})
+	assert.Equal(t, models.FeeQuote{WalletID: walletID, OperationType: models.WITHDRAW, Amount: 20000, Fee: 275, Rule: "rule 4", BalanceChange: -20275}, quote)
+
+	var none *Schedule
+	quote = none.Quote(models.WalletOperationRequest{WalletID: walletID, OperationType: models.DEPOSIT, Amount: 500})
+	assert.Equal(t, int64(0), quote.Fee)
+	assert.Equal(t, int64(500), quote.BalanceChange)
+}
+
+func TestParseSchedule_Invalid(t *testing.T) {
+	tests := []struct {
+		name          string
+		schedule      string
+		expectedError string
+	}{
+		{"missing fee wallet", "rules: []", `invalid feeWallet ""`},
+		{"unknown field", "feeWallet: 00000000-0000-0000-0000-0000000000fe\nrule: []", "field rule not found"},
+		{"invalid wallet", "feeWallet: 00000000-0000-0000-0000-0000000000fe\nwallets: {gold: {tier: gold}}", `invalid wallet ID "gold"`},
+		{
+			name: "invalid rules",
+			schedule: `
+feeWallet: 00000000-0000-0000-0000-0000000000fe
+rules:
+  - operationType: TRANSFER
+  - name: caps
+    basisPoints: 20000
+    minFee: 10
+    maxFee: 5
+`,
+			expectedError: "rule 1: operationType must be DEPOSIT or WITHDRAW\n" +
+				"caps: basisPoints must be between 0 and 10000\n" +
+				"caps: maxFee must not be below minFee",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseSchedule([]byte(tt.schedule))
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.expectedError)
+		})
+	}
+}
+
+func TestWithCharge(t *testing.T) {
+	// Without WithCharge the fee is dropped
+	SetCharge(context.Background(), models.OperationFee{Amount: 1})
+
+	ctx, charge := WithCharge(context.Background())
+	SetCharge(ctx, models.OperationFee{Amount: 7, Rule: "r"})
+	assert.Equal(t, models.OperationFee{Amount: 7, Rule: "r"}, *charge)
+}
@@ -0,0 +1,39 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// AuditRecord is an entry of the tamper-evident audit log. Records are numbered without gaps
+// and each one carries the hash of the record before it, so that editing or deleting any
+// record breaks the chain.
+type AuditRecord struct {
+	Seq       int64           `json:"seq"`
+	Action    string          `json:"action"`
+	Actor     string          `json:"actor"`
+	RequestID string          `json:"requestId"`
+	Subject   string          `json:"subject"`
+	Before    json.RawMessage `json:"before"`
+	After     json.RawMessage `json:"after"`
+	CreatedAt time.Time       `json:"createdAt"`
+	PrevHash  string          `json:"prevHash"`
+	Hash      string          `json:"hash"`
+}
+
+// Audited actions
+const (
+	AuditWalletDeposit           = "wallet.deposit"
+	AuditWalletWithdraw          = "wallet.withdraw"
+	AuditWalletShardingEnabled   = "wallet.sharding_enabled"
+	AuditBonusCredited           = "bonus.credited"
+	AuditBonusSpent              = "bonus.spent"
+	AuditBonusExpired            = "bonus.expired"
+	AuditScheduleCreated         = "schedule.created"
+	AuditScheduleStatusChanged   = "schedule.status_changed"
+	AuditWebhookRegistered       = "webhook.registered"
+	AuditWebhookDeleted          = "webhook.deleted"
+	AuditWebhookDeadLetterReplay = "webhook.dead_letter_replayed"
+	AuditRuntimeSettingsLoaded   = "settings.loaded"
+	AuditRuntimeSettingsChanged  = "settings.changed"
+)
@@ -0,0 +1,66 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// BonusUse is whether and in which order a purchase spends the promotional balance of its wallet
+type BonusUse string
+
+const (
+	// BonusFirst pays a purchase from bonus lots first and the rest from the real balance
+	BonusFirst BonusUse = "bonus_first"
+	// RealFirst pays a purchase from the real balance first and the rest from bonus lots
+	RealFirst BonusUse = "real_first"
+)
+
+// Bonus journal entry kinds
+const (
+	BonusCredit = "CREDIT"
+	BonusSpend  = "SPEND"
+	BonusExpire = "EXPIRE"
+)
+
+// BonusLot is a promotional credit of a wallet. It is spent by purchases, never by other
+// withdrawals, until Remaining reaches zero or ExpiresAt passes.
+type BonusLot struct {
+	ID        int64     `json:"id"`
+	WalletID  uuid.UUID `json:"walletId"`
+	Amount    int64     `json:"amount"`
+	Remaining int64     `json:"remaining"`
+	ExpiresAt time.Time `json:"expiresAt"`
+	Reference string    `json:"reference,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// BonusEntry is an entry of the bonus journal: the credit of a lot, the part of it spent by a
+// purchase, or what was left of it when it expired
+type BonusEntry struct {
+	ID       int64     `json:"id"`
+	WalletID uuid.UUID `json:"walletId"`
+	LotID    int64     `json:"lotId"`
+	Kind     string    `json:"kind"`
+	Amount   int64     `json:"amount"`
+	// TransactionID is the wallet journal entry of the purchase that spent the lot, unset when
+	// bonus lots paid all of it
+	TransactionID *int64    `json:"transactionId,omitempty"`
+	CreatedAt     time.Time `json:"createdAt"`
+}
+
+// BonusCreditRequest credits a promotional lot to a wallet. ExpiresAt defaults to the
+// configured lifetime of bonus credits.
+type BonusCreditRequest struct {
+	Amount    int64      `json:"amount"`
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+	Reference string     `json:"reference,omitempty"`
+}
+
+// BonusBalanceResponse is the promotional balance of a wallet and the lots it is made of, in
+// the order purchases spend them
+type BonusBalanceResponse struct {
+	WalletID uuid.UUID  `json:"walletId"`
+	Balance  int64      `json:"balance"`
+	Lots     []BonusLot `json:"lots"`
+}
@@ -0,0 +1,40 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// WalletDiscrepancy is a wallet whose stored balance, including its shards, disagrees with its
+// journal or is negative
+type WalletDiscrepancy struct {
+	WalletID       uuid.UUID `json:"walletId"`
+	StoredBalance  int64     `json:"storedBalance"`
+	JournalBalance int64     `json:"journalBalance"`
+}
+
+// ReconciliationReport is the result of comparing every wallet balance with the sum of its
+// journal. Counts cover everything found; the lists hold at most the report limit of each kind.
+type ReconciliationReport struct {
+	StartedAt                time.Time           `json:"startedAt"`
+	FinishedAt               time.Time           `json:"finishedAt"`
+	WalletsChecked           int64               `json:"walletsChecked"`
+	MismatchCount            int64               `json:"mismatchCount"`
+	NegativeBalanceCount     int64               `json:"negativeBalanceCount"`
+	OrphanedTransactionCount int64               `json:"orphanedTransactionCount"`
+	Mismatches               []WalletDiscrepancy `json:"mismatches"`
+	NegativeBalances         []WalletDiscrepancy `json:"negativeBalances"`
+	OrphanedTransactions     []Transaction       `json:"orphanedTransactions"`
+}
+
+// Clean reports whether nothing was found
+func (r *ReconciliationReport) Clean() bool {
+	return r.MismatchCount == 0 && r.NegativeBalanceCount == 0 && r.OrphanedTransactionCount == 0
+}
+
+// ReconciliationStatusResponse is the state of the reconciliation job
+type ReconciliationStatusResponse struct {
+	Running bool                  `json:"running"`
+	Report  *ReconciliationReport `json:"report"`
+}
@@ -0,0 +1,89 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// TRANSFER is the operation type of schedules moving funds from one wallet to another, run as
+// an atomic batch of a withdrawal and a deposit
+const TRANSFER OperationType = "TRANSFER"
+
+// Schedule statuses
+const (
+	ScheduleActive    = "active"
+	SchedulePaused    = "paused"
+	ScheduleCancelled = "cancelled"
+	// ScheduleCompleted is a one-off schedule that has run, or a recurring one that reached MaxRuns
+	ScheduleCompleted = "completed"
+)
+
+// Schedule run outcomes
+const (
+	RunSucceeded = "succeeded"
+	RunFailed    = "failed"
+)
+
+// MinScheduleInterval is the shortest interval of recurring schedules
+const MinScheduleInterval = time.Minute
+
+// Schedule is a wallet operation executed at RunAt, or repeatedly by the Cron expression in
+// TimeZone or every IntervalSeconds starting at RunAt
+type Schedule struct {
+	ID            int64         `json:"id"`
+	WalletID      uuid.UUID     `json:"walletId"`
+	OperationType OperationType `json:"operationType"`
+	Amount        int64         `json:"amount"`
+	// ToWalletID is the wallet credited by transfers
+	ToWalletID      *uuid.UUID `json:"toWalletId,omitempty"`
+	Reference       string     `json:"reference,omitempty"`
+	RunAt           *time.Time `json:"runAt,omitempty"`
+	Cron            string     `json:"cron,omitempty"`
+	TimeZone        string     `json:"timeZone,omitempty"`
+	IntervalSeconds int64      `json:"intervalSeconds,omitempty"`
+	// MaxRuns completes a recurring schedule after this many runs; zero runs it until cancelled
+	MaxRuns   int        `json:"maxRuns,omitempty"`
+	Status    string     `json:"status"`
+	NextRunAt *time.Time `json:"nextRunAt,omitempty"`
+	RunCount  int        `json:"runCount"`
+	// Principal is the client certificate principal that created the schedule; runs are
+	// authorized as that client
+	Principal string    `json:"principal,omitempty"`
+	CreatedBy string    `json:"createdBy"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// Recurring reports whether the schedule runs more than once
+func (s Schedule) Recurring() bool {
+	return s.Cron != "" || s.IntervalSeconds > 0
+}
+
+// ScheduleRequest creates a schedule. Interval is a Go duration such as "24h". One-off
+// schedules set only RunAt; recurring ones set Cron or Interval, and RunAt to start later than
+// now.
+type ScheduleRequest struct {
+	WalletID      uuid.UUID     `json:"walletId"`
+	OperationType OperationType `json:"operationType"`
+	Amount        int64         `json:"amount"`
+	ToWalletID    *uuid.UUID    `json:"toWalletId,omitempty"`
+	Reference     string        `json:"reference,omitempty"`
+	RunAt         *time.Time    `json:"runAt,omitempty"`
+	Cron          string        `json:"cron,omitempty"`
+	TimeZone      string        `json:"timeZone,omitempty"`
+	Interval      string        `json:"interval,omitempty"`
+	MaxRuns       int           `json:"maxRuns,omitempty"`
+}
+
+// ScheduleRun is the outcome of one execution of a schedule. IdempotencyKey identifies the run
+// in the journal, so that a run retried after a crash is not applied twice.
+type ScheduleRun struct {
+	ID             int64     `json:"id"`
+	ScheduleID     int64     `json:"scheduleId"`
+	DueAt          time.Time `json:"dueAt"`
+	IdempotencyKey string    `json:"idempotencyKey"`
+	Status         string    `json:"status"`
+	Error          string    `json:"error,omitempty"`
+	FinishedAt     time.Time `json:"finishedAt"`
+}
@@ -0,0 +1,73 @@
+package models
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// Settlement line statuses
+const (
+	// SettlementMatched is a line paid into the wallet as exactly one deposit of the same amount
+	SettlementMatched = "matched"
+	// SettlementUnmatched is a line with no deposit carrying its reference
+	SettlementUnmatched = "unmatched"
+	// SettlementMismatched is a line whose deposit disagrees with it, or whose reference is not unique
+	SettlementMismatched = "mismatched"
+)
+
+// SettlementLine is a payment listed in a bank or PSP settlement file. Amount is in minor units;
+// WalletID is set when the file names the credited wallet.
+type SettlementLine struct {
+	Line      int        `json:"line"`
+	Reference string     `json:"reference"`
+	Amount    int64      `json:"amount"`
+	WalletID  *uuid.UUID `json:"walletId,omitempty"`
+}
+
+// SettlementResult is a settlement line with the outcome of matching it to wallet deposits
+type SettlementResult struct {
+	SettlementLine
+	Status         string  `json:"status"`
+	Reason         string  `json:"reason,omitempty"`
+	TransactionIDs []int64 `json:"transactionIds,omitempty"`
+}
+
+func (r SettlementResult) CSVHeader() []string {
+	return []string{"line", "reference", "amount", "wallet_id", "status", "reason", "transaction_ids"}
+}
+
+func (r SettlementResult) CSVRow() []string {
+	walletID := ""
+	if r.WalletID != nil {
+		walletID = r.WalletID.String()
+	}
+	ids := make([]string, len(r.TransactionIDs))
+	for i, id := range r.TransactionIDs {
+		ids[i] = strconv.FormatInt(id, 10)
+	}
+	return []string{
+		strconv.Itoa(r.Line),
+		r.Reference,
+		strconv.FormatInt(r.Amount, 10),
+		walletID,
+		r.Status,
+		r.Reason,
+		strings.Join(ids, " "),
+	}
+}
+
+// SettlementReport is the result of matching a settlement file against wallet deposits
+type SettlementReport struct {
+	Lines      int                `json:"lines"`
+	Matched    int                `json:"matched"`
+	Unmatched  int                `json:"unmatched"`
+	Mismatched int                `json:"mismatched"`
+	Results    []SettlementResult `json:"results"`
+}
+
+// Clean reports whether every line matched
+func (r *SettlementReport) Clean() bool {
+	return r.Matched == r.Lines
+}
@@ -0,0 +1,38 @@
+package models
+
+import (
+	"strconv"
+	"time"
+)
+
+// Kinds of statement lines
+const (
+	StatementOpening     = "opening"
+	StatementTransaction = "transaction"
+	StatementClosing     = "closing"
+)
+
+// StatementLine is one line of a wallet statement: the opening balance, a transaction with the
+// running balance after it, or the closing balance
+type StatementLine struct {
+	Type          string        `json:"type"`
+	TransactionID int64         `json:"transactionId,omitempty"`
+	OperationType OperationType `json:"operationType,omitempty"`
+	Amount        int64         `json:"amount,omitempty"`
+	Reference     string        `json:"reference,omitempty"`
+	Balance       int64         `json:"balance"`
+	At            time.Time     `json:"at"`
+}
+
+func (l StatementLine) CSVHeader() []string {
+	return []string{"type", "transaction_id", "operation_type", "amount", "reference", "balance", "at"}
+}
+
+func (l StatementLine) CSVRow() []string {
+	row := []string{l.Type, "", string(l.OperationType), "", l.Reference, strconv.FormatInt(l.Balance, 10), l.At.Format(time.RFC3339Nano)}
+	if l.Type == StatementTransaction {
+		row[1] = strconv.FormatInt(l.TransactionID, 10)
+		row[3] = strconv.FormatInt(l.Amount, 10)
+	}
+	return row
+}
@@ -1,6 +1,8 @@
 package models
 
 import (
+	"encoding/json"
+	"fmt"
 	"time"
 
 	"github.com/google/uuid"
@@ -13,20 +15,163 @@ const (
 	WITHDRAW OperationType = "WITHDRAW"
 )
 
+// MaxReferenceLength is the longest external reference the journal stores
+const MaxReferenceLength = 128
+
 type WalletOperationRequest struct {
 	WalletID      uuid.UUID     `json:"walletId"`
 	OperationType OperationType `json:"operationType"`
 	Amount        int64         `json:"amount"`
+	// ExpectedVersion makes the operation fail with a conflict unless the wallet is at this version
+	ExpectedVersion *int64 `json:"expectedVersion,omitempty"`
+	// Reference is an optional external id, such as a bank transfer id, kept in the journal so
+	// that settlement files can be matched against deposits
+	Reference string `json:"reference,omitempty"`
+	// Purchase marks a withdrawal paying for something inside the service, which may spend bonus
+	// funds; other withdrawals, such as payouts to a bank account or card, never do
+	Purchase bool `json:"purchase,omitempty"`
+	// Fee is set by the fee schedule, never by clients, and charged in the same transaction
+	Fee *OperationFee `json:"-"`
+	// Bonus is set by the bonus policy, never by clients, for purchases that may spend bonus lots
+	Bonus BonusUse `json:"-"`
+	// IdempotencyKey is set by scheduled runs, never by clients; an operation with a key that was
+	// already applied fails with ErrDuplicateOperation of the repository
+	IdempotencyKey string `json:"-"`
+}
+
+// OperationFee is the fee of an operation: debited from the wallet on top of the operation and
+// credited to the fee wallet, each as a journal entry of its own
+type OperationFee struct {
+	Amount      int64     `json:"amount"`
+	FeeWalletID uuid.UUID `json:"feeWalletId"`
+	Rule        string    `json:"rule"`
+}
+
+// WalletOperationResponse is the outcome of a single operation
+type WalletOperationResponse struct {
+	Status string        `json:"status"`
+	Fee    *OperationFee `json:"fee,omitempty"`
+}
+
+// FeeQuote is the fee an operation would be charged if it were executed now, and the change it
+// would make to the balance of the wallet
+type FeeQuote struct {
+	WalletID      uuid.UUID     `json:"walletId"`
+	OperationType OperationType `json:"operationType"`
+	Amount        int64         `json:"amount"`
+	Fee           int64         `json:"fee"`
+	Rule          string        `json:"rule,omitempty"`
+	BalanceChange int64         `json:"balanceChange"`
 }
 
 type Wallet struct {
-	ID        uuid.UUID `json:"id" db:"id"`
-	Balance   int64     `json:"balance" db:"balance"`
-	CreatedAt time.Time `json:"createdAt" db:"created_at"`
-	UpdatedAt time.Time `json:"updatedAt" db:"updated_at"`
+	ID         uuid.UUID `json:"id" db:"id"`
+	Balance    int64     `json:"balance" db:"balance"`
+	ShardCount int       `json:"shardCount" db:"shard_count"`
+	Version    int64     `json:"version" db:"version"`
+	CreatedAt  time.Time `json:"createdAt" db:"created_at"`
+	UpdatedAt  time.Time `json:"updatedAt" db:"updated_at"`
 }
 
 type WalletBalanceResponse struct {
 	WalletID uuid.UUID `json:"walletId"`
 	Balance  int64     `json:"balance"`
+	Version  int64     `json:"version"`
+}
+
+// WalletBalanceAtResponse is the balance of a wallet as of a point in time
+type WalletBalanceAtResponse struct {
+	WalletID uuid.UUID `json:"walletId"`
+	Balance  int64     `json:"balance"`
+	At       time.Time `json:"at"`
+}
+
+const EventWalletBalanceChanged = "WalletBalanceChanged"
+
+// WalletBalanceChangedEvent is the payload stored in the outbox for every successful operation
+type WalletBalanceChangedEvent struct {
+	TransactionID int64         `json:"transactionId"`
+	WalletID      uuid.UUID     `json:"walletId"`
+	OperationType OperationType `json:"operationType"`
+	Amount        int64         `json:"amount"`
+	BalanceBefore int64         `json:"balanceBefore"`
+	BalanceAfter  int64         `json:"balanceAfter"`
+	OccurredAt    time.Time     `json:"occurredAt"`
+	Reference     string        `json:"reference,omitempty"`
+	FeeFor        *int64        `json:"feeFor,omitempty"`
+}
+
+type OutboxEvent struct {
+	ID          int64           `json:"id" db:"id"`
+	AggregateID uuid.UUID       `json:"aggregateId" db:"aggregate_id"`
+	EventType   string          `json:"eventType" db:"event_type"`
+	Payload     json.RawMessage `json:"payload" db:"payload"`
+	Attempts    int             `json:"attempts" db:"attempts"`
+	CreatedAt   time.Time       `json:"createdAt" db:"created_at"`
+	PublishedAt *time.Time      `json:"publishedAt,omitempty" db:"published_at"`
+}
+
+// Transaction is an entry of the wallet journal
+type Transaction struct {
+	ID            int64         `json:"id" db:"id"`
+	WalletID      uuid.UUID     `json:"walletId" db:"wallet_id"`
+	OperationType OperationType `json:"operationType" db:"operation_type"`
+	Amount        int64         `json:"amount" db:"amount"`
+	BalanceAfter  int64         `json:"balanceAfter" db:"balance_after"`
+	CreatedAt     time.Time     `json:"createdAt" db:"created_at"`
+	Reference     string        `json:"reference,omitempty" db:"reference"`
+	// FeeFor is the id of the entry this fee entry was charged for
+	FeeFor *int64 `json:"feeFor,omitempty" db:"fee_for"`
+}
+
+// SignedAmount is the change the entry made to the balance
+func (t Transaction) SignedAmount() int64 {
+	if t.OperationType == WITHDRAW {
+		return -t.Amount
+	}
+	return t.Amount
+}
+
+type BatchMode string
+
+const (
+	BatchAtomic     BatchMode = "atomic"
+	BatchBestEffort BatchMode = "best_effort"
+)
+
+type BatchOperationRequest struct {
+	Mode       BatchMode                `json:"mode"`
+	Operations []WalletOperationRequest `json:"operations"`
+}
+
+// BatchItemResult is the outcome of one batch operation. Err is the service error of a failed
+// item and Error the message the API presents for it.
+type BatchItemResult struct {
+	Index    int       `json:"index"`
+	WalletID uuid.UUID `json:"walletId"`
+	Status   string    `json:"status"`
+	Fee      int64     `json:"fee,omitempty"`
+	Error    string    `json:"error,omitempty"`
+	Err      error     `json:"-"`
+}
+
+type BatchOperationResponse struct {
+	Mode      BatchMode         `json:"mode"`
+	Succeeded int               `json:"succeeded"`
+	Failed    int               `json:"failed"`
+	Results   []BatchItemResult `json:"results"`
+}
+
+// BatchOperationError reports which operation of an atomic batch caused it to be rolled back
+type BatchOperationError struct {
+	Index int
+	Err   error
+}
+
+func (e *BatchOperationError) Error() string {
+	return fmt.Sprintf("operation %d: %v", e.Index, e.Err)
+}
+
+func (e *BatchOperationError) Unwrap() error {
+	return e.Err
 }
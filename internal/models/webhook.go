@@ -0,0 +1,72 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type WebhookEventType string
+
+const (
+	WebhookDeposit    WebhookEventType = "deposit"
+	WebhookWithdrawal WebhookEventType = "withdrawal"
+	// WebhookFee is sent for both journal entries of a fee: the charge and the fee wallet credit
+	WebhookFee        WebhookEventType = "fee"
+	WebhookLowBalance WebhookEventType = "low_balance"
+)
+
+func (t WebhookEventType) Valid() bool {
+	switch t {
+	case WebhookDeposit, WebhookWithdrawal, WebhookFee, WebhookLowBalance:
+		return true
+	}
+	return false
+}
+
+type WebhookEndpoint struct {
+	ID         uuid.UUID          `json:"id" db:"id"`
+	URL        string             `json:"url" db:"url"`
+	Secret     string             `json:"secret,omitempty" db:"secret"`
+	EventTypes []WebhookEventType `json:"eventTypes" db:"event_types"`
+	Active     bool               `json:"active" db:"active"`
+	CreatedAt  time.Time          `json:"createdAt" db:"created_at"`
+}
+
+type RegisterWebhookRequest struct {
+	URL        string             `json:"url"`
+	Secret     string             `json:"secret"`
+	EventTypes []WebhookEventType `json:"eventTypes"`
+}
+
+// WebhookPayload is the body POSTed to integrators
+type WebhookPayload struct {
+	EventID   int64            `json:"eventId"`
+	EventType WebhookEventType `json:"eventType"`
+	CreatedAt time.Time        `json:"createdAt"`
+	Data      json.RawMessage  `json:"data"`
+}
+
+type WebhookDelivery struct {
+	ID          int64            `json:"id" db:"id"`
+	EndpointID  uuid.UUID        `json:"endpointId" db:"endpoint_id"`
+	EndpointURL string           `json:"-"`
+	Secret      string           `json:"-"`
+	EventID     int64            `json:"eventId" db:"event_id"`
+	EventType   WebhookEventType `json:"eventType" db:"event_type"`
+	Payload     json.RawMessage  `json:"payload" db:"payload"`
+	Attempts    int              `json:"attempts" db:"attempts"`
+}
+
+type WebhookDeadLetter struct {
+	ID         int64            `json:"id" db:"id"`
+	EndpointID uuid.UUID        `json:"endpointId" db:"endpoint_id"`
+	EventID    int64            `json:"eventId" db:"event_id"`
+	EventType  WebhookEventType `json:"eventType" db:"event_type"`
+	Payload    json.RawMessage  `json:"payload" db:"payload"`
+	Attempts   int              `json:"attempts" db:"attempts"`
+	LastError  string           `json:"lastError" db:"last_error"`
+	FailedAt   time.Time        `json:"failedAt" db:"failed_at"`
+	ReplayedAt *time.Time       `json:"replayedAt,omitempty" db:"replayed_at"`
+}
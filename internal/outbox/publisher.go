@@ -0,0 +1,79 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+
+	"ITKtest/internal/models"
+)
+
+// Publisher delivers outbox events to downstream consumers
+type Publisher interface {
+	Publish(ctx context.Context, event models.OutboxEvent) error
+}
+
+// LogPublisher writes every event to the standard logger
+type LogPublisher struct{}
+
+func NewLogPublisher() *LogPublisher {
+	return &LogPublisher{}
+}
+
+func (p *LogPublisher) Publish(ctx context.Context, event models.OutboxEvent) error {
+	log.Printf("outbox event %d %s for %s: %s", event.ID, event.EventType, event.AggregateID, event.Payload)
+	return nil
+}
+
+// FilePublisher appends every event as a JSON line to a file
+type FilePublisher struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+func NewFilePublisher(path string) (*FilePublisher, error) {
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open outbox file: %w", err)
+	}
+	return &FilePublisher{file: file}, nil
+}
+
+func (p *FilePublisher) Publish(ctx context.Context, event models.OutboxEvent) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	_, err = p.file.Write(append(data, '\n'))
+	return err
+}
+
+func (p *FilePublisher) Close() error {
+	return p.file.Close()
+}
+
+// MultiPublisher fans an event out to several publishers. A failure makes the relay retry
+// the event for all of them, so every publisher must tolerate duplicates.
+type MultiPublisher struct {
+	publishers []Publisher
+}
+
+func NewMultiPublisher(publishers ...Publisher) *MultiPublisher {
+	return &MultiPublisher{publishers: publishers}
+}
+
+func (p *MultiPublisher) Publish(ctx context.Context, event models.OutboxEvent) error {
+	for _, publisher := range p.publishers {
+		if err := publisher.Publish(ctx, event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,101 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"ITKtest/internal/repository"
+)
+
+// Relay periodically moves pending outbox events to a Publisher. A failed event is retried with
+// exponential backoff starting at the poll interval and dead-lettered after maxAttempts, so it
+// does not hold back the events behind it.
+type Relay struct {
+	repo        repository.OutboxRepository
+	publisher   Publisher
+	interval    time.Duration
+	batchSize   int
+	maxAttempts int
+	maxBackoff  time.Duration
+	lease       time.Duration
+}
+
+func NewRelay(repo repository.OutboxRepository, publisher Publisher, interval time.Duration, batchSize, maxAttempts int, maxBackoff, lease time.Duration) *Relay {
+	return &Relay{
+		repo:        repo,
+		publisher:   publisher,
+		interval:    interval,
+		batchSize:   batchSize,
+		maxAttempts: maxAttempts,
+		maxBackoff:  maxBackoff,
+		lease:       lease,
+	}
+}
+
+// Run polls the outbox until ctx is cancelled
+func (r *Relay) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := r.Flush(ctx); err != nil && ctx.Err() == nil {
+			log.Printf("outbox relay: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Flush publishes pending events batch by batch until the outbox is drained. Failed deliveries
+// do not stop it; they are returned together once the outbox is drained.
+func (r *Relay) Flush(ctx context.Context) (int, error) {
+	total := 0
+	var failures []error
+	for {
+		events, err := r.repo.ClaimPending(ctx, r.batchSize, r.lease)
+		if err != nil {
+			return total, errors.Join(append(failures, err)...)
+		}
+
+		for _, event := range events {
+			pubErr := r.publisher.Publish(ctx, event)
+			switch {
+			case pubErr == nil:
+				err = r.repo.MarkPublished(ctx, event.ID)
+				total++
+			case event.Attempts+1 >= r.maxAttempts:
+				err = r.repo.MoveToDeadLetter(ctx, event.ID, pubErr.Error())
+				failures = append(failures, fmt.Errorf("event %d dead-lettered after %d attempts: %w", event.ID, event.Attempts+1, pubErr))
+			default:
+				err = r.repo.MarkFailed(ctx, event.ID, pubErr.Error(), time.Now().Add(r.Backoff(event.Attempts+1)))
+				failures = append(failures, fmt.Errorf("event %d: %w", event.ID, pubErr))
+			}
+			if err != nil {
+				return total, errors.Join(append(failures, err)...)
+			}
+		}
+
+		if len(events) < r.batchSize {
+			return total, errors.Join(failures...)
+		}
+	}
+}
+
+// Backoff returns the delay before the next attempt after the given number of failed attempts
+func (r *Relay) Backoff(attempts int) time.Duration {
+	backoff := r.interval
+	for i := 1; i < attempts; i++ {
+		backoff *= 2
+		if backoff >= r.maxBackoff {
+			return r.maxBackoff
+		}
+	}
+	return backoff
+}
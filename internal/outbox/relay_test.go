@@ -0,0 +1,136 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"ITKtest/internal/models"
+	"ITKtest/internal/repository"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// failingPublisher fails the events whose id is listed
+type failingPublisher map[int64]error
+
+func (p failingPublisher) Publish(ctx context.Context, event models.OutboxEvent) error {
+	return p[event.ID]
+}
+
+func TestRelay_Flush(t *testing.T) {
+	events := func(ids ...int64) []models.OutboxEvent {
+		var events []models.OutboxEvent
+		for _, id := range ids {
+			events = append(events, models.OutboxEvent{ID: id, EventType: models.EventWalletBalanceChanged})
+		}
+		return events
+	}
+
+	tests := []struct {
+		name          string
+		publisher     Publisher
+		mockSetup     func(*repository.MockOutboxRepository)
+		expectedCount int
+		expectedError string
+	}{
+		{
+			name:      "drains several batches",
+			publisher: NewLogPublisher(),
+			mockSetup: func(m *repository.MockOutboxRepository) {
+				m.On("ClaimPending", mock.Anything, 2, time.Minute).Return(events(1, 2), nil).Once()
+				m.On("ClaimPending", mock.Anything, 2, time.Minute).Return(events(3, 4), nil).Once()
+				m.On("ClaimPending", mock.Anything, 2, time.Minute).Return(events(5), nil).Once()
+				m.On("MarkPublished", mock.Anything, mock.Anything).Return(nil).Times(5)
+			},
+			expectedCount: 5,
+		},
+		{
+			name:      "empty outbox",
+			publisher: NewLogPublisher(),
+			mockSetup: func(m *repository.MockOutboxRepository) {
+				m.On("ClaimPending", mock.Anything, 2, time.Minute).Return([]models.OutboxEvent(nil), nil).Once()
+			},
+			expectedCount: 0,
+		},
+		{
+			name:      "schedules a failed event for retry",
+			publisher: failingPublisher{1: errors.New("broker unavailable")},
+			mockSetup: func(m *repository.MockOutboxRepository) {
+				m.On("ClaimPending", mock.Anything, 2, time.Minute).Return(events(1), nil).Once()
+				m.On("MarkFailed", mock.Anything, int64(1), "broker unavailable", mock.Anything).Return(nil).Once()
+			},
+			expectedCount: 0,
+			expectedError: "event 1: broker unavailable",
+		},
+		{
+			name:      "dead-letters after the last attempt",
+			publisher: failingPublisher{1: errors.New("malformed payload")},
+			mockSetup: func(m *repository.MockOutboxRepository) {
+				m.On("ClaimPending", mock.Anything, 2, time.Minute).
+					Return([]models.OutboxEvent{{ID: 1, Attempts: 2}, {ID: 2}}, nil).Once()
+				m.On("ClaimPending", mock.Anything, 2, time.Minute).Return([]models.OutboxEvent(nil), nil).Once()
+				m.On("MoveToDeadLetter", mock.Anything, int64(1), "malformed payload").Return(nil).Once()
+				m.On("MarkPublished", mock.Anything, int64(2)).Return(nil).Once()
+			},
+			expectedCount: 1,
+			expectedError: "event 1 dead-lettered after 3 attempts: malformed payload",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := &repository.MockOutboxRepository{}
+			tt.mockSetup(mockRepo)
+
+			relay := NewRelay(mockRepo, tt.publisher, time.Second, 2, 3, time.Hour, time.Minute)
+			n, err := relay.Flush(context.Background())
+
+			if tt.expectedError != "" {
+				assert.EqualError(t, err, tt.expectedError)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tt.expectedCount, n)
+			mockRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestRelay_Backoff(t *testing.T) {
+	relay := NewRelay(&repository.MockOutboxRepository{}, NewLogPublisher(), time.Second, 10, 5, 5*time.Second, time.Minute)
+
+	assert.Equal(t, time.Second, relay.Backoff(1))
+	assert.Equal(t, 2*time.Second, relay.Backoff(2))
+	assert.Equal(t, 4*time.Second, relay.Backoff(3))
+	assert.Equal(t, 5*time.Second, relay.Backoff(4))
+}
+
+func TestFilePublisher_Publish(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.jsonl")
+	publisher, err := NewFilePublisher(path)
+	assert.NoError(t, err)
+
+	event := models.OutboxEvent{
+		ID:          1,
+		AggregateID: uuid.New(),
+		EventType:   models.EventWalletBalanceChanged,
+		Payload:     json.RawMessage(`{"amount":100}`),
+	}
+	assert.NoError(t, publisher.Publish(context.Background(), event))
+	assert.NoError(t, publisher.Close())
+
+	data, err := os.ReadFile(path)
+	assert.NoError(t, err)
+
+	var written models.OutboxEvent
+	assert.NoError(t, json.Unmarshal(data, &written))
+	assert.Equal(t, event.AggregateID, written.AggregateID)
+	assert.JSONEq(t, `{"amount":100}`, string(written.Payload))
+}
@@ -0,0 +1,126 @@
+package reconcile
+
+import (
+	"context"
+	"expvar"
+	"log"
+	"sync"
+	"time"
+
+	"ITKtest/internal/models"
+	"ITKtest/internal/repository"
+)
+
+// metrics is published under /debug/vars and describes the latest reconciliation
+var metrics = expvar.NewMap("reconciliation")
+
+// Reconciler runs the reconciliation at startup, every interval and on demand, and keeps the
+// latest report. Runs never overlap.
+type Reconciler struct {
+	repo     repository.ReconciliationRepository
+	interval time.Duration
+	limit    int
+	trigger  chan struct{}
+
+	mu      sync.Mutex
+	running bool
+	last    *models.ReconciliationReport
+}
+
+func NewReconciler(repo repository.ReconciliationRepository, interval time.Duration, limit int) *Reconciler {
+	return &Reconciler{
+		repo:     repo,
+		interval: interval,
+		limit:    limit,
+		trigger:  make(chan struct{}, 1),
+	}
+}
+
+// Run reconciles now and then every interval and whenever triggered, until ctx is cancelled
+func (r *Reconciler) Run(ctx context.Context) {
+	var tick <-chan time.Time
+	if r.interval > 0 {
+		ticker := time.NewTicker(r.interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	for {
+		if _, err := r.RunOnce(ctx); err != nil && ctx.Err() == nil {
+			log.Printf("reconciliation: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-tick:
+		case <-r.trigger:
+		}
+	}
+}
+
+// Trigger asks Run for a reconciliation as soon as the current one, if any, finishes. Triggers
+// arriving while one is already queued are merged into it.
+func (r *Reconciler) Trigger() {
+	select {
+	case r.trigger <- struct{}{}:
+	default:
+	}
+}
+
+// Status returns whether a reconciliation is running and the latest report, nil before the
+// first one finishes
+func (r *Reconciler) Status() (bool, *models.ReconciliationReport) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.running, r.last
+}
+
+// RunOnce reconciles, records the report and metrics and logs what was found
+func (r *Reconciler) RunOnce(ctx context.Context) (*models.ReconciliationReport, error) {
+	r.setRunning(true)
+	defer r.setRunning(false)
+
+	metrics.Add("runs", 1)
+	report, err := r.repo.Reconcile(ctx, r.limit)
+	if err != nil {
+		metrics.Add("failures", 1)
+		return nil, err
+	}
+
+	r.mu.Lock()
+	r.last = report
+	r.mu.Unlock()
+	publish(report)
+
+	if report.Clean() {
+		log.Printf("Reconciliation found no discrepancies in %d wallets", report.WalletsChecked)
+	} else {
+		log.Printf("Reconciliation found discrepancies: %d balance mismatches, %d negative balances, %d orphaned journal entries",
+			report.MismatchCount, report.NegativeBalanceCount, report.OrphanedTransactionCount)
+		for _, d := range report.Mismatches {
+			log.Printf("Wallet %s balance %d does not match its journal balance %d", d.WalletID, d.StoredBalance, d.JournalBalance)
+		}
+	}
+	return report, nil
+}
+
+func (r *Reconciler) setRunning(running bool) {
+	r.mu.Lock()
+	r.running = running
+	r.mu.Unlock()
+}
+
+func publish(report *models.ReconciliationReport) {
+	set := func(key string, value int64) {
+		v := new(expvar.Int)
+		v.Set(value)
+		metrics.Set(key, v)
+	}
+	set("last_run_unix", report.FinishedAt.Unix())
+	set("last_duration_ms", report.FinishedAt.Sub(report.StartedAt).Milliseconds())
+	set("wallets_checked", report.WalletsChecked)
+	set("mismatches", report.MismatchCount)
+	set("negative_balances", report.NegativeBalanceCount)
+	set("orphaned_transactions", report.OrphanedTransactionCount)
+}
@@ -0,0 +1,67 @@
+package reconcile
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"testing"
+	"time"
+
+	"ITKtest/internal/models"
+	"ITKtest/internal/repository"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReconciler_RunOnce(t *testing.T) {
+	repo := &repository.MockReconciliationRepository{}
+	reconciler := NewReconciler(repo, 0, 50)
+
+	running, last := reconciler.Status()
+	assert.False(t, running)
+	assert.Nil(t, last)
+
+	now := time.Now()
+	report := &models.ReconciliationReport{StartedAt: now, FinishedAt: now, WalletsChecked: 3, MismatchCount: 1,
+		Mismatches: []models.WalletDiscrepancy{{StoredBalance: 5, JournalBalance: 4}}}
+	repo.On("Reconcile", mock.Anything, 50).Return(report, nil).Once()
+	got, err := reconciler.RunOnce(context.Background())
+	require.NoError(t, err)
+	assert.Same(t, report, got)
+
+	_, last = reconciler.Status()
+	assert.Same(t, report, last)
+	assert.Equal(t, "1", metrics.Get("mismatches").(*expvar.Int).String())
+
+	// A failed run keeps the previous report
+	repo.On("Reconcile", mock.Anything, 50).Return(nil, errors.New("connection refused")).Once()
+	_, err = reconciler.RunOnce(context.Background())
+	assert.Error(t, err)
+	_, last = reconciler.Status()
+	assert.Same(t, report, last)
+	repo.AssertExpectations(t)
+}
+
+func TestReconciler_Trigger(t *testing.T) {
+	repo := &repository.MockReconciliationRepository{}
+	reconciler := NewReconciler(repo, 0, 10)
+
+	done := make(chan struct{}, 2)
+	repo.On("Reconcile", mock.Anything, 10).Return(&models.ReconciliationReport{}, nil).
+		Run(func(mock.Arguments) { done <- struct{}{} })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go reconciler.Run(ctx)
+
+	// Run reconciles at startup and then only when triggered, as the interval is zero
+	<-done
+	reconciler.Trigger()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("triggered reconciliation did not run")
+	}
+}
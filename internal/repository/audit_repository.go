@@ -0,0 +1,159 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"ITKtest/internal/audit"
+	"ITKtest/internal/models"
+)
+
+// AuditRepository stores the hash-chained audit log. Changes to wallets and webhooks write
+// their records in their own transaction; Append is for changes made outside the database.
+// Records are written unchained and join the chain when ChainPending runs.
+type AuditRepository interface {
+	Append(ctx context.Context, action, subject string, before, after any) error
+	// ChainPending chains up to limit records in the order their transactions committed them
+	// and returns how many it chained
+	ChainPending(ctx context.Context, limit int) (int, error)
+	// Walk calls fn for every chained record in sequence order
+	Walk(ctx context.Context, fn func(models.AuditRecord) error) error
+	// CountUnchained counts the records written before writtenBefore that are not chained yet
+	CountUnchained(ctx context.Context, writtenBefore time.Time) (int64, error)
+}
+
+type auditRepository struct {
+	db    *sql.DB
+	tx    *txRunner
+	chain *txRunner
+}
+
+func NewAuditRepository(db *sql.DB, txConfig TxConfig) AuditRepository {
+	// Chaining reads the head of the chain after waiting for the lock, so it needs a snapshot
+	// per statement whatever the configured isolation
+	chainConfig := txConfig
+	chainConfig.Isolation = sql.LevelReadCommitted
+	return &auditRepository{db: db, tx: newTxRunner(db, txConfig), chain: newTxRunner(db, chainConfig)}
+}
+
+func (r *auditRepository) Append(ctx context.Context, action, subject string, before, after any) error {
+	return r.tx.run(ctx, func(tx *sql.Tx) error {
+		return insertAuditRecord(ctx, tx, action, subject, before, after)
+	})
+}
+
+// Walk reads the log without loading it into memory
+func (r *auditRepository) Walk(ctx context.Context, fn func(models.AuditRecord) error) error {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT seq, action, actor, request_id, subject, before_value, after_value, created_at, prev_hash, hash
+		FROM audit_log
+		WHERE seq IS NOT NULL
+		ORDER BY seq
+	`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var record models.AuditRecord
+		var before, after string
+		err := rows.Scan(&record.Seq, &record.Action, &record.Actor, &record.RequestID, &record.Subject,
+			&before, &after, &record.CreatedAt, &record.PrevHash, &record.Hash)
+		if err != nil {
+			return err
+		}
+		record.Before, record.After = []byte(before), []byte(after)
+		if err := fn(record); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+func (r *auditRepository) CountUnchained(ctx context.Context, writtenBefore time.Time) (int64, error) {
+	var n int64
+	err := r.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM audit_log WHERE seq IS NULL AND created_at < $1", writtenBefore).Scan(&n)
+	return n, err
+}
+
+// auditChainLock is the advisory lock key serializing the chainers
+const auditChainLock = 0x61756469
+
+func (r *auditRepository) ChainPending(ctx context.Context, limit int) (int, error) {
+	var chained int
+	err := r.chain.run(ctx, func(tx *sql.Tx) error {
+		chained = 0
+		if _, err := tx.ExecContext(ctx, "SELECT pg_advisory_xact_lock($1)", auditChainLock); err != nil {
+			return err
+		}
+
+		var prev models.AuditRecord
+		err := tx.QueryRowContext(ctx, "SELECT seq, hash FROM audit_log WHERE seq IS NOT NULL ORDER BY seq DESC LIMIT 1").Scan(&prev.Seq, &prev.Hash)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return err
+		}
+
+		// A record whose transaction commits after a later one is simply chained after it
+		rows, err := tx.QueryContext(ctx, `
+			SELECT id, action, actor, request_id, subject, before_value, after_value, created_at
+			FROM audit_log
+			WHERE seq IS NULL
+			ORDER BY id
+			LIMIT $1
+		`, limit)
+		if err != nil {
+			return err
+		}
+		var ids []int64
+		var records []models.AuditRecord
+		for rows.Next() {
+			var id int64
+			var record models.AuditRecord
+			var before, after string
+			err := rows.Scan(&id, &record.Action, &record.Actor, &record.RequestID, &record.Subject,
+				&before, &after, &record.CreatedAt)
+			if err != nil {
+				rows.Close()
+				return err
+			}
+			record.Before, record.After = []byte(before), []byte(after)
+			ids = append(ids, id)
+			records = append(records, record)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		for i, record := range records {
+			prev = audit.Chain(prev, record)
+			_, err := tx.ExecContext(ctx, "UPDATE audit_log SET seq = $2, prev_hash = $3, hash = $4 WHERE id = $1",
+				ids[i], prev.Seq, prev.PrevHash, prev.Hash)
+			if err != nil {
+				return err
+			}
+		}
+		chained = len(records)
+		return nil
+	})
+	return chained, err
+}
+
+// insertAuditRecord writes an unchained record inside tx. It takes no lock, so concurrent
+// transactions do not queue behind each other's audit records.
+func insertAuditRecord(ctx context.Context, tx *sql.Tx, action, subject string, before, after any) error {
+	record, err := audit.NewRecord(ctx, action, subject, before, after)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO audit_log (action, actor, request_id, subject, before_value, after_value, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+	`, record.Action, record.Actor, record.RequestID, record.Subject,
+		string(record.Before), string(record.After), record.CreatedAt)
+	return err
+}
@@ -0,0 +1,59 @@
+package repository_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"ITKtest/internal/audit"
+	"ITKtest/internal/models"
+	"ITKtest/internal/repository"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuditRepository_Postgres(t *testing.T) {
+	db := openConformanceDB(t)
+	ctx := audit.WithRequest(context.Background(), "cert:teller", "req-"+uuid.NewString())
+	walletID := uuid.New()
+
+	repo := repository.NewWalletRepository(db, repository.TxConfig{})
+	require.NoError(t, repo.CreateWallet(ctx, walletID))
+	require.NoError(t, repo.UpdateWalletBalance(ctx, walletID, 500, models.DEPOSIT))
+	require.NoError(t, repo.UpdateWalletBalance(ctx, walletID, 200, models.WITHDRAW))
+
+	// Records join the chain, and the walk, once chained
+	auditLog := repository.NewAuditRepository(db, repository.TxConfig{})
+	_, err := audit.NewChainer(auditLog, time.Second).RunDue(ctx)
+	require.NoError(t, err)
+	var records []models.AuditRecord
+	require.NoError(t, auditLog.Walk(ctx, func(record models.AuditRecord) error {
+		if record.Subject == walletID.String() {
+			records = append(records, record)
+		}
+		return nil
+	}))
+	require.Len(t, records, 2)
+	assert.Equal(t, models.AuditWalletWithdraw, records[1].Action)
+	assert.Equal(t, "cert:teller", records[1].Actor)
+	assert.Equal(t, audit.RequestID(ctx), records[1].RequestID)
+	assert.JSONEq(t, `{"balance":500}`, string(records[1].Before))
+	var after map[string]int64
+	require.NoError(t, json.Unmarshal(records[1].After, &after))
+	assert.Equal(t, int64(300), after["balance"])
+
+	// Other tests share the database, so only the chain as a whole can be checked
+	report, err := audit.Verify(ctx, auditLog, time.Now())
+	require.NoError(t, err)
+	assert.Empty(t, report.Problems)
+
+	_, err = db.Exec("UPDATE audit_log SET after_value = '{}' WHERE seq = $1", records[1].Seq)
+	assert.ErrorContains(t, err, "append-only")
+	_, err = db.Exec("UPDATE audit_log SET seq = NULL WHERE seq = $1", records[1].Seq)
+	assert.ErrorContains(t, err, "append-only")
+	_, err = db.Exec("DELETE FROM audit_log WHERE seq = $1", records[1].Seq)
+	assert.ErrorContains(t, err, "append-only")
+}
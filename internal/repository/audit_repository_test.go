@@ -0,0 +1,102 @@
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"ITKtest/internal/audit"
+	"ITKtest/internal/models"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+// expectAuditRecord expects the statement of insertAuditRecord
+func expectAuditRecord(mock sqlmock.Sqlmock) {
+	mock.ExpectExec(`INSERT INTO audit_log`).WillReturnResult(sqlmock.NewResult(0, 1))
+}
+
+func TestAuditRepository_Append(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	ctx := audit.WithRequest(context.Background(), "cert:ops", "req-1")
+
+	// The record is written unchained, without waiting for the head of the chain
+	mock.ExpectBegin()
+	mock.ExpectExec(`INSERT INTO audit_log \(action, actor, request_id, subject, before_value, after_value, created_at\)`).
+		WithArgs(models.AuditRuntimeSettingsChanged, "cert:ops", "req-1", "runtime",
+			`{"READ_ONLY":"false"}`, `{"READ_ONLY":"true"}`, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	err = NewAuditRepository(db, TxConfig{}).Append(ctx, models.AuditRuntimeSettingsChanged, "runtime",
+		map[string]string{"READ_ONLY": "false"}, map[string]string{"READ_ONLY": "true"})
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestAuditRepository_Walk(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	record, err := audit.NewRecord(context.Background(), models.AuditWalletDeposit, "wallet", nil, map[string]int{"balance": 5})
+	assert.NoError(t, err)
+	record = audit.Chain(models.AuditRecord{}, record)
+
+	mock.ExpectQuery(`SELECT seq, action, actor, request_id, subject, before_value, after_value, created_at, prev_hash, hash\s+FROM audit_log\s+WHERE seq IS NOT NULL\s+ORDER BY seq`).
+		WillReturnRows(sqlmock.NewRows([]string{"seq", "action", "actor", "request_id", "subject", "before_value", "after_value", "created_at", "prev_hash", "hash"}).
+			AddRow(record.Seq, record.Action, record.Actor, record.RequestID, record.Subject,
+				string(record.Before), string(record.After), record.CreatedAt.In(time.FixedZone("MSK", 3*3600)), record.PrevHash, record.Hash))
+	chainedBy := time.Now().Add(-time.Minute)
+	mock.ExpectQuery(`SELECT COUNT\(\*\) FROM audit_log WHERE seq IS NULL AND created_at < \$1`).
+		WithArgs(chainedBy).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(2))
+
+	report, err := audit.Verify(context.Background(), NewAuditRepository(db, TxConfig{}), chainedBy)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), report.Records)
+	assert.Empty(t, report.Problems)
+	assert.Equal(t, record.Hash, report.Head)
+	assert.Equal(t, int64(2), report.Unchained)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestAuditRepository_ChainPending(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	first, err := audit.NewRecord(context.Background(), models.AuditWalletDeposit, "wallet", nil, map[string]int{"balance": 5})
+	assert.NoError(t, err)
+	second, err := audit.NewRecord(context.Background(), models.AuditWalletWithdraw, "wallet", map[string]int{"balance": 5}, map[string]int{"balance": 2})
+	assert.NoError(t, err)
+	head := audit.Chain(models.AuditRecord{Seq: 40}, first)
+	first, second = audit.Chain(head, first), audit.Chain(audit.Chain(head, first), second)
+
+	// Pending records follow the head of the chain in id order
+	mock.ExpectBegin()
+	mock.ExpectExec(`SELECT pg_advisory_xact_lock\(\$1\)`).WithArgs(auditChainLock).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(`SELECT seq, hash FROM audit_log WHERE seq IS NOT NULL ORDER BY seq DESC LIMIT 1`).
+		WillReturnRows(sqlmock.NewRows([]string{"seq", "hash"}).AddRow(head.Seq, head.Hash))
+	rows := sqlmock.NewRows([]string{"id", "action", "actor", "request_id", "subject", "before_value", "after_value", "created_at"})
+	for i, record := range []models.AuditRecord{first, second} {
+		rows.AddRow(int64(70+i), record.Action, record.Actor, record.RequestID, record.Subject, string(record.Before), string(record.After), record.CreatedAt)
+	}
+	mock.ExpectQuery(`FROM audit_log\s+WHERE seq IS NULL\s+ORDER BY id\s+LIMIT \$1`).WithArgs(10).WillReturnRows(rows)
+	mock.ExpectExec(`UPDATE audit_log SET seq = \$2, prev_hash = \$3, hash = \$4 WHERE id = \$1`).
+		WithArgs(int64(70), int64(42), head.Hash, first.Hash).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`UPDATE audit_log SET seq = \$2, prev_hash = \$3, hash = \$4 WHERE id = \$1`).
+		WithArgs(int64(71), int64(43), first.Hash, second.Hash).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	n, err := NewAuditRepository(db, TxConfig{}).ChainPending(context.Background(), 10)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, n)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,300 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"ITKtest/internal/models"
+
+	"github.com/google/uuid"
+)
+
+// BonusRepository stores the promotional balances of wallets as lots with their own journal.
+// Purchases spend lots in the transaction of the wallet repository, see applyBonusWithdrawal.
+type BonusRepository interface {
+	// CreditLot stores lot, creating its wallet if needed, and sets its id, remaining amount and
+	// creation time
+	CreditLot(ctx context.Context, lot *models.BonusLot) error
+	// ActiveLots returns the lots of a wallet with funds left that have not expired as of now, in
+	// the order purchases spend them
+	ActiveLots(ctx context.Context, walletID uuid.UUID, now time.Time) ([]models.BonusLot, error)
+	// ListEntries returns bonus journal entries of a wallet with id greater than afterID in id order
+	ListEntries(ctx context.Context, walletID uuid.UUID, afterID int64, limit int) ([]models.BonusEntry, error)
+	// ExpireLots journals and zeroes what is left of up to limit lots expired as of now and
+	// returns how many it expired. Lots being spent meanwhile are left for the next call.
+	ExpireLots(ctx context.Context, now time.Time, limit int) (int, error)
+}
+
+type bonusRepository struct {
+	db *sql.DB
+	tx *txRunner
+}
+
+func NewBonusRepository(db *sql.DB, txConfig TxConfig) BonusRepository {
+	return &bonusRepository{db: db, tx: newTxRunner(db, txConfig)}
+}
+
+func (r *bonusRepository) CreditLot(ctx context.Context, lot *models.BonusLot) error {
+	return r.tx.run(ctx, func(tx *sql.Tx) error {
+		now := time.Now()
+		_, err := tx.ExecContext(ctx, `
+			INSERT INTO wallets (id, balance, created_at, updated_at)
+			VALUES ($1, 0, $2, $2)
+			ON CONFLICT (id) DO NOTHING
+		`, lot.WalletID, now)
+		if err != nil {
+			return err
+		}
+
+		err = tx.QueryRowContext(ctx, `
+			INSERT INTO bonus_lots (wallet_id, amount, remaining, expires_at, reference, created_at)
+			VALUES ($1, $2, $2, $3, NULLIF($4, ''), $5)
+			RETURNING id
+		`, lot.WalletID, lot.Amount, lot.ExpiresAt, lot.Reference, now).Scan(&lot.ID)
+		if err != nil {
+			return err
+		}
+		lot.Remaining, lot.CreatedAt = lot.Amount, now
+
+		entry := models.BonusEntry{WalletID: lot.WalletID, LotID: lot.ID, Kind: models.BonusCredit, Amount: lot.Amount, CreatedAt: now}
+		if err := insertBonusEntry(ctx, tx, entry); err != nil {
+			return err
+		}
+		return insertAuditRecord(ctx, tx, models.AuditBonusCredited, lot.WalletID.String(), nil, lot)
+	})
+}
+
+func (r *bonusRepository) ActiveLots(ctx context.Context, walletID uuid.UUID, now time.Time) ([]models.BonusLot, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT id, wallet_id, amount, remaining, expires_at, COALESCE(reference, ''), created_at
+		FROM bonus_lots
+		WHERE wallet_id = $1 AND remaining > 0 AND expires_at > $2
+		ORDER BY expires_at, id
+	`, walletID, now)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	lots := []models.BonusLot{}
+	for rows.Next() {
+		var lot models.BonusLot
+		if err := rows.Scan(&lot.ID, &lot.WalletID, &lot.Amount, &lot.Remaining, &lot.ExpiresAt, &lot.Reference, &lot.CreatedAt); err != nil {
+			return nil, err
+		}
+		lots = append(lots, lot)
+	}
+	return lots, rows.Err()
+}
+
+func (r *bonusRepository) ListEntries(ctx context.Context, walletID uuid.UUID, afterID int64, limit int) ([]models.BonusEntry, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT id, wallet_id, lot_id, kind, amount, transaction_id, created_at
+		FROM bonus_entries
+		WHERE wallet_id = $1 AND id > $2
+		ORDER BY id
+		LIMIT $3
+	`, walletID, afterID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []models.BonusEntry{}
+	for rows.Next() {
+		var entry models.BonusEntry
+		if err := rows.Scan(&entry.ID, &entry.WalletID, &entry.LotID, &entry.Kind, &entry.Amount, &entry.TransactionID, &entry.CreatedAt); err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err()
+}
+
+func (r *bonusRepository) ExpireLots(ctx context.Context, now time.Time, limit int) (int, error) {
+	var expired int
+	err := r.tx.run(ctx, func(tx *sql.Tx) error {
+		rows, err := tx.QueryContext(ctx, `
+			SELECT id, wallet_id, remaining
+			FROM bonus_lots
+			WHERE remaining > 0 AND expires_at <= $1
+			ORDER BY expires_at, id
+			LIMIT $2
+			FOR UPDATE SKIP LOCKED
+		`, now, limit)
+		if err != nil {
+			return err
+		}
+		var lots []models.BonusLot
+		for rows.Next() {
+			var lot models.BonusLot
+			if err := rows.Scan(&lot.ID, &lot.WalletID, &lot.Remaining); err != nil {
+				rows.Close()
+				return err
+			}
+			lots = append(lots, lot)
+		}
+		err = rows.Err()
+		rows.Close()
+		if err != nil {
+			return err
+		}
+
+		for _, lot := range lots {
+			if _, err := tx.ExecContext(ctx, "UPDATE bonus_lots SET remaining = 0 WHERE id = $1", lot.ID); err != nil {
+				return err
+			}
+			entry := models.BonusEntry{WalletID: lot.WalletID, LotID: lot.ID, Kind: models.BonusExpire, Amount: lot.Remaining, CreatedAt: now}
+			if err := insertBonusEntry(ctx, tx, entry); err != nil {
+				return err
+			}
+		}
+		for _, lot := range lots {
+			err := insertAuditRecord(ctx, tx, models.AuditBonusExpired, lot.WalletID.String(),
+				auditBonus{LotID: lot.ID, Remaining: lot.Remaining},
+				auditBonus{LotID: lot.ID})
+			if err != nil {
+				return err
+			}
+		}
+		expired = len(lots)
+		return nil
+	})
+	return expired, err
+}
+
+// auditBonus is the audited state of a bonus lot, or of all the lots of a wallet when LotID is zero
+type auditBonus struct {
+	LotID         int64 `json:"lotId,omitempty"`
+	Remaining     int64 `json:"remaining"`
+	TransactionID int64 `json:"transactionId,omitempty"`
+}
+
+// spendsBonus reports whether op is a purchase that may be paid partly from bonus lots
+func spendsBonus(op models.WalletOperationRequest) bool {
+	return op.OperationType == models.WITHDRAW && op.Bonus != "" && op.Purchase
+}
+
+// applyBonusWithdrawal pays a purchase partly from the unexpired bonus lots of its wallet,
+// before or after the real balance as op.Bonus asks, and returns the id of its journal entry.
+// The entry carries only the part paid from the balance, so that the journal keeps adding up to
+// the balance; bonus entries link the lots spent to it. A purchase paid entirely from the lots
+// leaves no entry, and so no outbox, stream or webhook event, and returns 0, unless its fee or
+// idempotency key needs one to refer to.
+// Sharded wallets belong to the business rather than to customers and pay without bonus.
+func applyBonusWithdrawal(ctx context.Context, tx *sql.Tx, op models.WalletOperationRequest) (int64, error) {
+	var shardCount int
+	err := tx.QueryRowContext(ctx, "SELECT shard_count FROM wallets WHERE id = $1", op.WalletID).Scan(&shardCount)
+	if err != nil {
+		return 0, err
+	}
+	if shardCount > 0 {
+		return applyEntry(ctx, tx, op, nil)
+	}
+
+	// Lock the wallet row before the lots, as every withdrawal does
+	var balance, version int64
+	err = tx.QueryRowContext(ctx, "SELECT balance, version FROM wallets WHERE id = $1 FOR UPDATE", op.WalletID).Scan(&balance, &version)
+	if err != nil {
+		return 0, err
+	}
+	// Checked here too, as a purchase paid from the lots alone journals no entry
+	if op.ExpectedVersion != nil && *op.ExpectedVersion != version {
+		return 0, ErrVersionConflict
+	}
+	now := time.Now()
+	lots, err := lockBonusLots(ctx, tx, op.WalletID, now)
+	if err != nil {
+		return 0, err
+	}
+
+	var available int64
+	for _, lot := range lots {
+		available += lot.Remaining
+	}
+	fromBonus := min(available, op.Amount)
+	if op.Bonus == models.RealFirst {
+		fromBonus = min(available, max(op.Amount-balance, 0))
+	}
+
+	real := op
+	real.Amount -= fromBonus
+	var transactionID int64
+	if real.Amount > 0 || op.Fee != nil || op.IdempotencyKey != "" {
+		if transactionID, err = applyEntry(ctx, tx, real, nil); err != nil {
+			return 0, err
+		}
+	}
+	if fromBonus == 0 {
+		return transactionID, nil
+	}
+	return transactionID, spendBonus(ctx, tx, op.WalletID, lots, fromBonus, transactionID, now)
+}
+
+// lockBonusLots locks the lots of a wallet with funds left that have not expired as of now, in
+// spending order: the ones expiring first are spent first
+func lockBonusLots(ctx context.Context, tx *sql.Tx, walletID uuid.UUID, now time.Time) ([]models.BonusLot, error) {
+	rows, err := tx.QueryContext(ctx, `
+		SELECT id, remaining
+		FROM bonus_lots
+		WHERE wallet_id = $1 AND remaining > 0 AND expires_at > $2
+		ORDER BY expires_at, id
+		FOR UPDATE
+	`, walletID, now)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var lots []models.BonusLot
+	for rows.Next() {
+		lot := models.BonusLot{WalletID: walletID}
+		if err := rows.Scan(&lot.ID, &lot.Remaining); err != nil {
+			return nil, err
+		}
+		lots = append(lots, lot)
+	}
+	return lots, rows.Err()
+}
+
+// spendBonus takes amount from lots in order, journaling the part taken from each one as spent
+// by the entry transactionID, or by no entry when it is 0
+func spendBonus(ctx context.Context, tx *sql.Tx, walletID uuid.UUID, lots []models.BonusLot, amount, transactionID int64, now time.Time) error {
+	var spentBy *int64
+	if transactionID != 0 {
+		spentBy = &transactionID
+	}
+	var before int64
+	for _, lot := range lots {
+		before += lot.Remaining
+	}
+
+	left := amount
+	for _, lot := range lots {
+		if left == 0 {
+			break
+		}
+		spent := min(lot.Remaining, left)
+		if _, err := tx.ExecContext(ctx, "UPDATE bonus_lots SET remaining = remaining - $1 WHERE id = $2", spent, lot.ID); err != nil {
+			return err
+		}
+		entry := models.BonusEntry{WalletID: walletID, LotID: lot.ID, Kind: models.BonusSpend, Amount: spent, TransactionID: spentBy, CreatedAt: now}
+		if err := insertBonusEntry(ctx, tx, entry); err != nil {
+			return err
+		}
+		left -= spent
+	}
+
+	return insertAuditRecord(ctx, tx, models.AuditBonusSpent, walletID.String(),
+		auditBonus{Remaining: before},
+		auditBonus{Remaining: before - amount, TransactionID: transactionID})
+}
+
+func insertBonusEntry(ctx context.Context, tx *sql.Tx, entry models.BonusEntry) error {
+	_, err := tx.ExecContext(ctx, `
+		INSERT INTO bonus_entries (wallet_id, lot_id, kind, amount, transaction_id, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+	`, entry.WalletID, entry.LotID, entry.Kind, entry.Amount, entry.TransactionID, entry.CreatedAt)
+	return err
+}
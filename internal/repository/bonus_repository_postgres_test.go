@@ -0,0 +1,95 @@
+package repository_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"ITKtest/internal/models"
+	"ITKtest/internal/repository"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBonusRepository_Postgres(t *testing.T) {
+	db := openConformanceDB(t)
+	ctx := context.Background()
+
+	wallets := repository.NewWalletRepository(db, repository.TxConfig{})
+	bonuses := repository.NewBonusRepository(db, repository.TxConfig{})
+	walletID := uuid.New()
+	now := time.Now()
+
+	// Crediting a lot creates the wallet; the lot expiring first is spent first
+	late := &models.BonusLot{WalletID: walletID, Amount: 50, ExpiresAt: now.Add(48 * time.Hour)}
+	early := &models.BonusLot{WalletID: walletID, Amount: 30, ExpiresAt: now.Add(time.Hour), Reference: "promo-1"}
+	require.NoError(t, bonuses.CreditLot(ctx, late))
+	require.NoError(t, bonuses.CreditLot(ctx, early))
+	require.NoError(t, wallets.UpdateWalletBalance(ctx, walletID, 100, models.DEPOSIT))
+
+	withdraw := func(amount int64, use models.BonusUse, purchase bool) error {
+		return wallets.ApplyOperation(ctx, models.WalletOperationRequest{WalletID: walletID, OperationType: models.WITHDRAW, Amount: amount, Bonus: use, Purchase: purchase})
+	}
+	require.NoError(t, withdraw(40, models.BonusFirst, true))
+	lots, err := bonuses.ActiveLots(ctx, walletID, now)
+	require.NoError(t, err)
+	require.Len(t, lots, 1)
+	assert.Equal(t, late.ID, lots[0].ID)
+	assert.Equal(t, int64(40), lots[0].Remaining)
+
+	// Other withdrawals never spend bonus; real first spends lots only for what the balance lacks
+	err = withdraw(101, models.BonusFirst, false)
+	assert.ErrorContains(t, err, "insufficient funds")
+	require.NoError(t, withdraw(60, models.RealFirst, true))
+	err = withdraw(81, models.RealFirst, true)
+	assert.ErrorContains(t, err, "insufficient funds")
+	require.NoError(t, withdraw(80, models.RealFirst, true))
+
+	wallet, err := wallets.GetWallet(ctx, walletID)
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), wallet.Balance)
+	lots, err = bonuses.ActiveLots(ctx, walletID, now)
+	require.NoError(t, err)
+	assert.Empty(t, lots)
+
+	// The journal carries the part paid from the balance, linked to the lots spent with it; the
+	// purchase paid from the lots alone has no entry
+	transactions, err := wallets.ListTransactions(ctx, walletID, 0, 10)
+	require.NoError(t, err)
+	require.Len(t, transactions, 3)
+	assert.Equal(t, int64(60), transactions[1].Amount)
+	assert.Equal(t, int64(40), transactions[2].Amount)
+
+	entries, err := bonuses.ListEntries(ctx, walletID, 0, 10)
+	require.NoError(t, err)
+	var kinds []string
+	for _, entry := range entries {
+		kinds = append(kinds, entry.Kind)
+	}
+	assert.Equal(t, []string{models.BonusCredit, models.BonusCredit, models.BonusSpend, models.BonusSpend, models.BonusSpend}, kinds)
+	assert.Nil(t, entries[2].TransactionID)
+	assert.Nil(t, entries[3].TransactionID)
+	assert.Equal(t, transactions[2].ID, *entries[4].TransactionID)
+
+	// Expired lots stop counting at once and are journaled by ExpireLots
+	expiring := &models.BonusLot{WalletID: walletID, Amount: 25, ExpiresAt: now.Add(time.Hour)}
+	require.NoError(t, bonuses.CreditLot(ctx, expiring))
+	lots, err = bonuses.ActiveLots(ctx, walletID, now.Add(2*time.Hour))
+	require.NoError(t, err)
+	assert.Empty(t, lots)
+	for {
+		n, err := bonuses.ExpireLots(ctx, now.Add(2*time.Hour), 100)
+		require.NoError(t, err)
+		if n < 100 {
+			break
+		}
+	}
+	entries, err = bonuses.ListEntries(ctx, walletID, entries[len(entries)-1].ID, 10)
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	assert.Equal(t, models.BonusExpire, entries[1].Kind)
+	assert.Equal(t, expiring.ID, entries[1].LotID)
+	assert.Equal(t, int64(25), entries[1].Amount)
+}
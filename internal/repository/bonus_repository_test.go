@@ -0,0 +1,167 @@
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"ITKtest/internal/models"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// expectBonusLots expects a purchase from an unsharded wallet with balance to lock it and the
+// lots given as id and remaining pairs
+func expectBonusLots(mock sqlmock.Sqlmock, walletID uuid.UUID, balance int, lots [][2]int64) {
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT shard_count FROM wallets WHERE id = \$1`).
+		WithArgs(walletID).
+		WillReturnRows(sqlmock.NewRows([]string{"shard_count"}).AddRow(0))
+	mock.ExpectQuery(`SELECT balance, version FROM wallets WHERE id = \$1 FOR UPDATE`).
+		WithArgs(walletID).
+		WillReturnRows(sqlmock.NewRows([]string{"balance", "version"}).AddRow(balance, 0))
+	rows := sqlmock.NewRows([]string{"id", "remaining"})
+	for _, lot := range lots {
+		rows.AddRow(lot[0], lot[1])
+	}
+	mock.ExpectQuery(`FROM bonus_lots\s+WHERE wallet_id = \$1 AND remaining > 0 AND expires_at > \$2\s+ORDER BY expires_at, id\s+FOR UPDATE`).
+		WithArgs(walletID, sqlmock.AnyArg()).
+		WillReturnRows(rows)
+}
+
+// expectBonusWithdrawal expects expectBonusLots, then the journal entry of a withdrawal of
+// amount from the balance
+func expectBonusWithdrawal(mock sqlmock.Sqlmock, walletID uuid.UUID, balance, amount int, lots [][2]int64) {
+	expectBonusLots(mock, walletID, balance, lots)
+	mock.ExpectQuery(`SELECT shard_count FROM wallets WHERE id = \$1`).
+		WithArgs(walletID).
+		WillReturnRows(sqlmock.NewRows([]string{"shard_count"}).AddRow(0))
+	mock.ExpectQuery(`SELECT balance, shard_count, version FROM wallets WHERE id = \$1 FOR UPDATE`).
+		WithArgs(walletID).
+		WillReturnRows(sqlmock.NewRows([]string{"balance", "shard_count", "version"}).AddRow(balance, 0, 0))
+	mock.ExpectExec(`UPDATE wallets SET balance`).
+		WithArgs(balance-amount, sqlmock.AnyArg(), walletID).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectQuery(`INSERT INTO wallet_transactions`).
+		WithArgs(walletID, models.WITHDRAW, amount, balance-amount, sqlmock.AnyArg(), "", nil).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(7))
+	mock.ExpectExec(`SELECT pg_notify`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`INSERT INTO outbox_events`).WillReturnResult(sqlmock.NewResult(1, 1))
+	expectAuditRecord(mock)
+}
+
+func expectBonusSpend(mock sqlmock.Sqlmock, walletID uuid.UUID, lotID, amount int64) {
+	mock.ExpectExec(`UPDATE bonus_lots SET remaining = remaining - \$1 WHERE id = \$2`).
+		WithArgs(amount, lotID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`INSERT INTO bonus_entries`).
+		WithArgs(walletID, lotID, models.BonusSpend, amount, sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+}
+
+func TestWalletRepository_ApplyOperationSpendsBonus(t *testing.T) {
+	walletID := uuid.New()
+	withdrawal := func(amount int64, use models.BonusUse) models.WalletOperationRequest {
+		return models.WalletOperationRequest{WalletID: walletID, OperationType: models.WITHDRAW, Amount: amount, Purchase: true, Bonus: use}
+	}
+
+	t.Run("bonus first spends the lots expiring first and journals nothing from the balance", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		require.NoError(t, err)
+		defer db.Close()
+
+		expectBonusLots(mock, walletID, 100, [][2]int64{{1, 30}, {2, 50}})
+		expectBonusSpend(mock, walletID, 1, 30)
+		expectBonusSpend(mock, walletID, 2, 30)
+		expectAuditRecord(mock)
+		mock.ExpectCommit()
+
+		err = NewWalletRepository(db, TxConfig{}).ApplyOperation(context.Background(), withdrawal(60, models.BonusFirst))
+		assert.NoError(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("real first spends lots only for what the balance does not cover", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		require.NoError(t, err)
+		defer db.Close()
+
+		expectBonusWithdrawal(mock, walletID, 100, 100, [][2]int64{{1, 30}})
+		expectBonusSpend(mock, walletID, 1, 20)
+		expectAuditRecord(mock)
+		mock.ExpectCommit()
+
+		err = NewWalletRepository(db, TxConfig{}).ApplyOperation(context.Background(), withdrawal(120, models.RealFirst))
+		assert.NoError(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("a purchase paid from the lots alone still checks the expected version", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		require.NoError(t, err)
+		defer db.Close()
+
+		mock.ExpectBegin()
+		mock.ExpectQuery(`SELECT shard_count FROM wallets WHERE id = \$1`).
+			WithArgs(walletID).
+			WillReturnRows(sqlmock.NewRows([]string{"shard_count"}).AddRow(0))
+		mock.ExpectQuery(`SELECT balance, version FROM wallets WHERE id = \$1 FOR UPDATE`).
+			WithArgs(walletID).
+			WillReturnRows(sqlmock.NewRows([]string{"balance", "version"}).AddRow(100, 4))
+		mock.ExpectRollback()
+
+		op := withdrawal(60, models.BonusFirst)
+		stale := int64(3)
+		op.ExpectedVersion = &stale
+		err = NewWalletRepository(db, TxConfig{}).ApplyOperation(context.Background(), op)
+		assert.ErrorIs(t, err, ErrVersionConflict)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("real first without a shortfall leaves the lots alone", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		require.NoError(t, err)
+		defer db.Close()
+
+		expectBonusWithdrawal(mock, walletID, 100, 40, [][2]int64{{1, 30}})
+		mock.ExpectCommit()
+
+		err = NewWalletRepository(db, TxConfig{}).ApplyOperation(context.Background(), withdrawal(40, models.RealFirst))
+		assert.NoError(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestBonusRepository_ExpireLots(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	walletID := uuid.New()
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	mock.ExpectBegin()
+	mock.ExpectQuery(`FROM bonus_lots\s+WHERE remaining > 0 AND expires_at <= \$1\s+ORDER BY expires_at, id\s+LIMIT \$2\s+FOR UPDATE SKIP LOCKED`).
+		WithArgs(now, 100).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "wallet_id", "remaining"}).
+			AddRow(1, walletID, 30).
+			AddRow(2, walletID, 5))
+	for _, lot := range [][2]int64{{1, 30}, {2, 5}} {
+		mock.ExpectExec(`UPDATE bonus_lots SET remaining = 0 WHERE id = \$1`).
+			WithArgs(lot[0]).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(`INSERT INTO bonus_entries`).
+			WithArgs(walletID, lot[0], models.BonusExpire, lot[1], nil, now).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+	}
+	expectAuditRecord(mock)
+	expectAuditRecord(mock)
+	mock.ExpectCommit()
+
+	n, err := NewBonusRepository(db, TxConfig{}).ExpireLots(context.Background(), now, 100)
+	require.NoError(t, err)
+	assert.Equal(t, 2, n)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
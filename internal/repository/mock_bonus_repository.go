@@ -0,0 +1,42 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"ITKtest/internal/models"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/mock"
+)
+
+// MockBonusRepository мок репозитория бонусных балансов
+type MockBonusRepository struct {
+	mock.Mock
+}
+
+// CreditLot sets the lot id to the one set with Return(id, err)
+func (m *MockBonusRepository) CreditLot(ctx context.Context, lot *models.BonusLot) error {
+	args := m.Called(ctx, lot)
+	if id, ok := args.Get(0).(int64); ok {
+		lot.ID, lot.Remaining = id, lot.Amount
+	}
+	return args.Error(1)
+}
+
+func (m *MockBonusRepository) ActiveLots(ctx context.Context, walletID uuid.UUID, now time.Time) ([]models.BonusLot, error) {
+	args := m.Called(ctx, walletID, now)
+	lots, _ := args.Get(0).([]models.BonusLot)
+	return lots, args.Error(1)
+}
+
+func (m *MockBonusRepository) ListEntries(ctx context.Context, walletID uuid.UUID, afterID int64, limit int) ([]models.BonusEntry, error) {
+	args := m.Called(ctx, walletID, afterID, limit)
+	entries, _ := args.Get(0).([]models.BonusEntry)
+	return entries, args.Error(1)
+}
+
+func (m *MockBonusRepository) ExpireLots(ctx context.Context, now time.Time, limit int) (int, error) {
+	args := m.Called(ctx, now, limit)
+	return args.Int(0), args.Error(1)
+}
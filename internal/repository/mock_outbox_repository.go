@@ -0,0 +1,35 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"ITKtest/internal/models"
+
+	"github.com/stretchr/testify/mock"
+)
+
+// MockOutboxRepository мок репозитория outbox
+type MockOutboxRepository struct {
+	mock.Mock
+}
+
+func (m *MockOutboxRepository) ClaimPending(ctx context.Context, limit int, lease time.Duration) ([]models.OutboxEvent, error) {
+	args := m.Called(ctx, limit, lease)
+	return args.Get(0).([]models.OutboxEvent), args.Error(1)
+}
+
+func (m *MockOutboxRepository) MarkPublished(ctx context.Context, eventID int64) error {
+	args := m.Called(ctx, eventID)
+	return args.Error(0)
+}
+
+func (m *MockOutboxRepository) MarkFailed(ctx context.Context, eventID int64, lastError string, nextAttemptAt time.Time) error {
+	args := m.Called(ctx, eventID, lastError, nextAttemptAt)
+	return args.Error(0)
+}
+
+func (m *MockOutboxRepository) MoveToDeadLetter(ctx context.Context, eventID int64, lastError string) error {
+	args := m.Called(ctx, eventID, lastError)
+	return args.Error(0)
+}
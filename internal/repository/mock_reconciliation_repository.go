@@ -0,0 +1,20 @@
+package repository
+
+import (
+	"context"
+
+	"ITKtest/internal/models"
+
+	"github.com/stretchr/testify/mock"
+)
+
+// MockReconciliationRepository мок репозитория сверки балансов
+type MockReconciliationRepository struct {
+	mock.Mock
+}
+
+func (m *MockReconciliationRepository) Reconcile(ctx context.Context, limit int) (*models.ReconciliationReport, error) {
+	args := m.Called(ctx, limit)
+	report, _ := args.Get(0).(*models.ReconciliationReport)
+	return report, args.Error(1)
+}
@@ -0,0 +1,68 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"ITKtest/internal/models"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/mock"
+)
+
+// MockScheduleRepository мок репозитория запланированных операций
+type MockScheduleRepository struct {
+	mock.Mock
+}
+
+// CreateSchedule sets the schedule id to the one set with Return(id, err)
+func (m *MockScheduleRepository) CreateSchedule(ctx context.Context, s *models.Schedule) error {
+	args := m.Called(ctx, s)
+	if id, ok := args.Get(0).(int64); ok {
+		s.ID = id
+	}
+	return args.Error(1)
+}
+
+func (m *MockScheduleRepository) GetSchedule(ctx context.Context, id int64) (*models.Schedule, error) {
+	args := m.Called(ctx, id)
+	s, _ := args.Get(0).(*models.Schedule)
+	return s, args.Error(1)
+}
+
+func (m *MockScheduleRepository) ListSchedules(ctx context.Context, walletID uuid.UUID) ([]models.Schedule, error) {
+	args := m.Called(ctx, walletID)
+	schedules, _ := args.Get(0).([]models.Schedule)
+	return schedules, args.Error(1)
+}
+
+// UpdateSchedule applies update to a copy of the schedule set with Return(schedule, err)
+func (m *MockScheduleRepository) UpdateSchedule(ctx context.Context, id int64, update func(*models.Schedule) error) (*models.Schedule, error) {
+	args := m.Called(ctx, id, update)
+	s, _ := args.Get(0).(*models.Schedule)
+	if s == nil || args.Error(1) != nil {
+		return nil, args.Error(1)
+	}
+	updated := *s
+	if err := update(&updated); err != nil {
+		return nil, err
+	}
+	return &updated, nil
+}
+
+func (m *MockScheduleRepository) ListRuns(ctx context.Context, scheduleID int64, afterID int64, limit int) ([]models.ScheduleRun, error) {
+	args := m.Called(ctx, scheduleID, afterID, limit)
+	runs, _ := args.Get(0).([]models.ScheduleRun)
+	return runs, args.Error(1)
+}
+
+func (m *MockScheduleRepository) ClaimDue(ctx context.Context, now, leaseUntil time.Time, limit int) ([]models.Schedule, error) {
+	args := m.Called(ctx, now, leaseUntil, limit)
+	schedules, _ := args.Get(0).([]models.Schedule)
+	return schedules, args.Error(1)
+}
+
+func (m *MockScheduleRepository) CompleteRun(ctx context.Context, run models.ScheduleRun, next *time.Time) error {
+	args := m.Called(ctx, run, next)
+	return args.Error(0)
+}
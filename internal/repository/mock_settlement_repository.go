@@ -0,0 +1,20 @@
+package repository
+
+import (
+	"context"
+
+	"ITKtest/internal/models"
+
+	"github.com/stretchr/testify/mock"
+)
+
+// MockSettlementRepository мок репозитория сверки с файлами расчётов
+type MockSettlementRepository struct {
+	mock.Mock
+}
+
+func (m *MockSettlementRepository) DepositsByReference(ctx context.Context, references []string) (map[string][]models.Transaction, error) {
+	args := m.Called(ctx, references)
+	deposits, _ := args.Get(0).(map[string][]models.Transaction)
+	return deposits, args.Error(1)
+}
@@ -0,0 +1,38 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"ITKtest/internal/models"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/mock"
+)
+
+// MockSnapshotRepository мок репозитория снимков балансов
+type MockSnapshotRepository struct {
+	mock.Mock
+}
+
+func (m *MockSnapshotRepository) TakeSnapshots(ctx context.Context, asOf time.Time) (int64, error) {
+	args := m.Called(ctx, asOf)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockSnapshotRepository) BalanceAt(ctx context.Context, walletID uuid.UUID, at time.Time) (int64, error) {
+	args := m.Called(ctx, walletID, at)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+// StreamTransactions passes the transactions set with Return(transactions, err) to fn
+func (m *MockSnapshotRepository) StreamTransactions(ctx context.Context, walletID uuid.UUID, from, to time.Time, fn func(models.Transaction) error) error {
+	args := m.Called(ctx, walletID, from, to, fn)
+	transactions, _ := args.Get(0).([]models.Transaction)
+	for _, t := range transactions {
+		if err := fn(t); err != nil {
+			return err
+		}
+	}
+	return args.Error(1)
+}
@@ -2,6 +2,7 @@ package repository
 
 import (
 	"context"
+	"time"
 
 	"ITKtest/internal/models"
 
@@ -27,7 +28,37 @@ func (m *MockWalletRepository) GetWallet(ctx context.Context, walletID uuid.UUID
 	return args.Get(0).(*models.Wallet), args.Error(1)
 }
 
+func (m *MockWalletRepository) ApplyOperation(ctx context.Context, op models.WalletOperationRequest) error {
+	args := m.Called(ctx, op)
+	return args.Error(0)
+}
+
 func (m *MockWalletRepository) UpdateWalletBalance(ctx context.Context, walletID uuid.UUID, amount int64, operationType models.OperationType) error {
 	args := m.Called(ctx, walletID, amount, operationType)
 	return args.Error(0)
-}
\ No newline at end of file
+}
+
+func (m *MockWalletRepository) ListTransactions(ctx context.Context, walletID uuid.UUID, afterID int64, limit int) ([]models.Transaction, error) {
+	args := m.Called(ctx, walletID, afterID, limit)
+	return args.Get(0).([]models.Transaction), args.Error(1)
+}
+
+func (m *MockWalletRepository) ResumeAfterID(ctx context.Context, walletID uuid.UUID, lastID int64, window time.Duration) (int64, error) {
+	args := m.Called(ctx, walletID, lastID, window)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockWalletRepository) UpdateWalletBalancesAtomic(ctx context.Context, operations []models.WalletOperationRequest) error {
+	args := m.Called(ctx, operations)
+	return args.Error(0)
+}
+
+func (m *MockWalletRepository) EnableSharding(ctx context.Context, walletID uuid.UUID, shards int) error {
+	args := m.Called(ctx, walletID, shards)
+	return args.Error(0)
+}
+
+func (m *MockWalletRepository) UpdateWalletBalanceWithVersion(ctx context.Context, walletID uuid.UUID, amount int64, operationType models.OperationType, expectedVersion int64) error {
+	args := m.Called(ctx, walletID, amount, operationType, expectedVersion)
+	return args.Error(0)
+}
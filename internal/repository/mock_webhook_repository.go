@@ -0,0 +1,66 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"ITKtest/internal/models"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/mock"
+)
+
+// MockWebhookRepository мок репозитория вебхуков
+type MockWebhookRepository struct {
+	mock.Mock
+}
+
+func (m *MockWebhookRepository) CreateEndpoint(ctx context.Context, endpoint *models.WebhookEndpoint) error {
+	args := m.Called(ctx, endpoint)
+	return args.Error(0)
+}
+
+func (m *MockWebhookRepository) ListEndpoints(ctx context.Context) ([]models.WebhookEndpoint, error) {
+	args := m.Called(ctx)
+	return args.Get(0).([]models.WebhookEndpoint), args.Error(1)
+}
+
+func (m *MockWebhookRepository) DeleteEndpoint(ctx context.Context, endpointID uuid.UUID) error {
+	args := m.Called(ctx, endpointID)
+	return args.Error(0)
+}
+
+func (m *MockWebhookRepository) EnqueueDeliveries(ctx context.Context, eventID int64, eventType models.WebhookEventType, payload []byte) (int, error) {
+	args := m.Called(ctx, eventID, eventType, payload)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockWebhookRepository) ClaimDueDeliveries(ctx context.Context, limit int, lease time.Duration) ([]models.WebhookDelivery, error) {
+	args := m.Called(ctx, limit, lease)
+	return args.Get(0).([]models.WebhookDelivery), args.Error(1)
+}
+
+func (m *MockWebhookRepository) MarkDelivered(ctx context.Context, deliveryID int64) error {
+	args := m.Called(ctx, deliveryID)
+	return args.Error(0)
+}
+
+func (m *MockWebhookRepository) MarkFailed(ctx context.Context, deliveryID int64, lastError string, nextAttemptAt time.Time) error {
+	args := m.Called(ctx, deliveryID, lastError, nextAttemptAt)
+	return args.Error(0)
+}
+
+func (m *MockWebhookRepository) MoveToDeadLetter(ctx context.Context, deliveryID int64, lastError string) error {
+	args := m.Called(ctx, deliveryID, lastError)
+	return args.Error(0)
+}
+
+func (m *MockWebhookRepository) ListDeadLetters(ctx context.Context, limit int) ([]models.WebhookDeadLetter, error) {
+	args := m.Called(ctx, limit)
+	return args.Get(0).([]models.WebhookDeadLetter), args.Error(1)
+}
+
+func (m *MockWebhookRepository) ReplayDeadLetter(ctx context.Context, deadLetterID int64) error {
+	args := m.Called(ctx, deadLetterID)
+	return args.Error(0)
+}
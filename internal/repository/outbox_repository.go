@@ -0,0 +1,107 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"ITKtest/internal/models"
+
+	"github.com/google/uuid"
+)
+
+type OutboxRepository interface {
+	ClaimPending(ctx context.Context, limit int, lease time.Duration) ([]models.OutboxEvent, error)
+	MarkPublished(ctx context.Context, eventID int64) error
+	MarkFailed(ctx context.Context, eventID int64, lastError string, nextAttemptAt time.Time) error
+	MoveToDeadLetter(ctx context.Context, eventID int64, lastError string) error
+}
+
+type outboxRepository struct {
+	db *sql.DB
+}
+
+func NewOutboxRepository(db *sql.DB) OutboxRepository {
+	return &outboxRepository{db: db}
+}
+
+func insertOutboxEvent(ctx context.Context, tx *sql.Tx, aggregateID uuid.UUID, eventType string, payload interface{}) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal outbox event: %w", err)
+	}
+
+	_, err = tx.ExecContext(ctx,
+		"INSERT INTO outbox_events (aggregate_id, event_type, payload, created_at) VALUES ($1, $2, $3, $4)",
+		aggregateID, eventType, data, time.Now())
+	return err
+}
+
+// ClaimPending leases up to limit due events in insertion order by pushing next_attempt_at
+// forward, so the events are published outside any transaction and concurrent relays skip them
+// meanwhile. With several relays, or after a failed attempt, events of one wallet may be
+// published out of order; consumers order them by event id.
+func (r *outboxRepository) ClaimPending(ctx context.Context, limit int, lease time.Duration) ([]models.OutboxEvent, error) {
+	now := time.Now()
+	query := `
+		WITH due AS (
+			SELECT id
+			FROM outbox_events
+			WHERE published_at IS NULL AND dead_lettered_at IS NULL AND next_attempt_at <= $1
+			ORDER BY id
+			LIMIT $2
+			FOR UPDATE SKIP LOCKED
+		)
+		UPDATE outbox_events e
+		SET next_attempt_at = $3
+		FROM due
+		WHERE e.id = due.id
+		RETURNING e.id, e.aggregate_id, e.event_type, e.payload, e.attempts, e.created_at
+	`
+	rows, err := r.db.QueryContext(ctx, query, now, limit, now.Add(lease))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []models.OutboxEvent
+	for rows.Next() {
+		var event models.OutboxEvent
+		if err := rows.Scan(&event.ID, &event.AggregateID, &event.EventType, &event.Payload, &event.Attempts, &event.CreatedAt); err != nil {
+			return nil, err
+		}
+		events = append(events, event)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// RETURNING does not keep the order of the CTE
+	sort.Slice(events, func(i, j int) bool { return events[i].ID < events[j].ID })
+	return events, nil
+}
+
+func (r *outboxRepository) MarkPublished(ctx context.Context, eventID int64) error {
+	_, err := r.db.ExecContext(ctx,
+		"UPDATE outbox_events SET published_at = $1, attempts = attempts + 1, last_error = NULL WHERE id = $2",
+		time.Now(), eventID)
+	return err
+}
+
+func (r *outboxRepository) MarkFailed(ctx context.Context, eventID int64, lastError string, nextAttemptAt time.Time) error {
+	_, err := r.db.ExecContext(ctx,
+		"UPDATE outbox_events SET attempts = attempts + 1, last_error = $1, next_attempt_at = $2 WHERE id = $3",
+		lastError, nextAttemptAt, eventID)
+	return err
+}
+
+// MoveToDeadLetter stops retrying an event; it stays in the table with its last error
+func (r *outboxRepository) MoveToDeadLetter(ctx context.Context, eventID int64, lastError string) error {
+	_, err := r.db.ExecContext(ctx,
+		"UPDATE outbox_events SET attempts = attempts + 1, last_error = $1, dead_lettered_at = $2 WHERE id = $3",
+		lastError, time.Now(), eventID)
+	return err
+}
@@ -0,0 +1,59 @@
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"ITKtest/internal/models"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOutboxRepository_ClaimPending(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	walletID := uuid.New()
+	now := time.Now()
+	mock.ExpectQuery(`WITH due AS \( SELECT id FROM outbox_events WHERE published_at IS NULL AND dead_lettered_at IS NULL AND next_attempt_at <= \$1 ORDER BY id LIMIT \$2 FOR UPDATE SKIP LOCKED \) UPDATE outbox_events e SET next_attempt_at = \$3`).
+		WithArgs(sqlmock.AnyArg(), 10, sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "aggregate_id", "event_type", "payload", "attempts", "created_at"}).
+			AddRow(2, walletID, models.EventWalletBalanceChanged, []byte(`{}`), 0, now).
+			AddRow(1, walletID, models.EventWalletBalanceChanged, []byte(`{}`), 3, now))
+
+	events, err := NewOutboxRepository(db).ClaimPending(context.Background(), 10, time.Minute)
+
+	assert.NoError(t, err)
+	assert.Len(t, events, 2)
+	assert.Equal(t, int64(1), events[0].ID)
+	assert.Equal(t, 3, events[0].Attempts)
+	assert.Equal(t, int64(2), events[1].ID)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestOutboxRepository_MarkOutcome(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+	repo := NewOutboxRepository(db)
+	retryAt := time.Now().Add(time.Minute)
+
+	mock.ExpectExec(`UPDATE outbox_events SET published_at = \$1, attempts = attempts \+ 1, last_error = NULL WHERE id = \$2`).
+		WithArgs(sqlmock.AnyArg(), 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`UPDATE outbox_events SET attempts = attempts \+ 1, last_error = \$1, next_attempt_at = \$2 WHERE id = \$3`).
+		WithArgs("broker unavailable", retryAt, 2).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`UPDATE outbox_events SET attempts = attempts \+ 1, last_error = \$1, dead_lettered_at = \$2 WHERE id = \$3`).
+		WithArgs("malformed payload", sqlmock.AnyArg(), 3).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	assert.NoError(t, repo.MarkPublished(context.Background(), 1))
+	assert.NoError(t, repo.MarkFailed(context.Background(), 2, "broker unavailable", retryAt))
+	assert.NoError(t, repo.MoveToDeadLetter(context.Background(), 3, "malformed payload"))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
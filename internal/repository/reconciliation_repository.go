@@ -0,0 +1,122 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"ITKtest/internal/models"
+)
+
+// ReconciliationRepository recomputes wallet balances from the journal to find drift caused by
+// bugs or by editing balances directly
+type ReconciliationRepository interface {
+	// Reconcile checks every wallet and journal entry, listing at most limit findings of each kind
+	Reconcile(ctx context.Context, limit int) (*models.ReconciliationReport, error)
+}
+
+type reconciliationRepository struct {
+	db *sql.DB
+}
+
+func NewReconciliationRepository(db *sql.DB) ReconciliationRepository {
+	return &reconciliationRepository{db: db}
+}
+
+// walletDiscrepancies compares the stored balance of each wallet, which for a sharded wallet is
+// spread over its shard rows, with the signed sum of its journal
+const walletDiscrepancies = `
+	WITH journal AS (
+		SELECT wallet_id, SUM(CASE WHEN operation_type = 'WITHDRAW' THEN -amount ELSE amount END) AS balance
+		FROM wallet_transactions
+		GROUP BY wallet_id
+	), shards AS (
+		SELECT wallet_id, SUM(balance) AS balance
+		FROM wallet_shards
+		GROUP BY wallet_id
+	)
+	SELECT w.id, w.balance + COALESCE(s.balance, 0), COALESCE(j.balance, 0)
+	FROM wallets w
+	LEFT JOIN shards s ON s.wallet_id = w.id
+	LEFT JOIN journal j ON j.wallet_id = w.id
+	WHERE w.balance + COALESCE(s.balance, 0) <> COALESCE(j.balance, 0)
+		OR w.balance + COALESCE(s.balance, 0) < 0
+	ORDER BY w.id`
+
+// Reconcile reads everything in one repeatable-read snapshot, so operations committed while it
+// runs cannot show up as a mismatch between a balance and a journal read at different times
+func (r *reconciliationRepository) Reconcile(ctx context.Context, limit int) (*models.ReconciliationReport, error) {
+	tx, err := r.db.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	report := &models.ReconciliationReport{
+		StartedAt:            time.Now(),
+		Mismatches:           []models.WalletDiscrepancy{},
+		NegativeBalances:     []models.WalletDiscrepancy{},
+		OrphanedTransactions: []models.Transaction{},
+	}
+
+	if err := tx.QueryRowContext(ctx, "SELECT COUNT(*) FROM wallets").Scan(&report.WalletsChecked); err != nil {
+		return nil, err
+	}
+
+	rows, err := tx.QueryContext(ctx, walletDiscrepancies)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var d models.WalletDiscrepancy
+		if err := rows.Scan(&d.WalletID, &d.StoredBalance, &d.JournalBalance); err != nil {
+			return nil, err
+		}
+		if d.StoredBalance != d.JournalBalance {
+			report.MismatchCount++
+			if len(report.Mismatches) < limit {
+				report.Mismatches = append(report.Mismatches, d)
+			}
+		}
+		if d.StoredBalance < 0 {
+			report.NegativeBalanceCount++
+			if len(report.NegativeBalances) < limit {
+				report.NegativeBalances = append(report.NegativeBalances, d)
+			}
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// The foreign key keeps the journal consistent unless it was bypassed, e.g. with
+	// session_replication_role = replica
+	rows, err = tx.QueryContext(ctx, `
+		SELECT t.id, t.wallet_id, t.operation_type, t.amount, t.balance_after, t.created_at
+		FROM wallet_transactions t
+		LEFT JOIN wallets w ON w.id = t.wallet_id
+		WHERE w.id IS NULL
+		ORDER BY t.id
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var t models.Transaction
+		if err := rows.Scan(&t.ID, &t.WalletID, &t.OperationType, &t.Amount, &t.BalanceAfter, &t.CreatedAt); err != nil {
+			return nil, err
+		}
+		report.OrphanedTransactionCount++
+		if len(report.OrphanedTransactions) < limit {
+			report.OrphanedTransactions = append(report.OrphanedTransactions, t)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	report.FinishedAt = time.Now()
+	return report, nil
+}
@@ -0,0 +1,39 @@
+package repository_test
+
+import (
+	"context"
+	"testing"
+
+	"ITKtest/internal/models"
+	"ITKtest/internal/repository"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReconciliationRepository_Postgres(t *testing.T) {
+	db := openConformanceDB(t)
+	ctx := context.Background()
+
+	repo := repository.NewWalletRepository(db, repository.TxConfig{})
+	consistent, drifted := uuid.New(), uuid.New()
+	for _, walletID := range []uuid.UUID{consistent, drifted} {
+		require.NoError(t, repo.CreateWallet(ctx, walletID))
+		require.NoError(t, repo.UpdateWalletBalance(ctx, walletID, 500, models.DEPOSIT))
+		require.NoError(t, repo.UpdateWalletBalance(ctx, walletID, 200, models.WITHDRAW))
+	}
+	_, err := db.Exec("UPDATE wallets SET balance = balance + 1000 WHERE id = $1", drifted)
+	require.NoError(t, err)
+
+	// Other tests share the database, so only these wallets are checked
+	report, err := repository.NewReconciliationRepository(db).Reconcile(ctx, 1000000)
+	require.NoError(t, err)
+	var found []models.WalletDiscrepancy
+	for _, d := range report.Mismatches {
+		if d.WalletID == consistent || d.WalletID == drifted {
+			found = append(found, d)
+		}
+	}
+	assert.Equal(t, []models.WalletDiscrepancy{{WalletID: drifted, StoredBalance: 1300, JournalBalance: 300}}, found)
+}
@@ -0,0 +1,46 @@
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReconciliationRepository_Reconcile(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	drifted, overdrawn, another := uuid.New(), uuid.New(), uuid.New()
+	missing := uuid.New()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT COUNT\(\*\) FROM wallets`).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(10))
+	mock.ExpectQuery(`WITH journal AS .* FROM wallets w`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "stored", "journal"}).
+			AddRow(drifted, 900, 1000).
+			AddRow(overdrawn, -50, -50).
+			AddRow(another, 10, 0))
+	mock.ExpectQuery(`FROM wallet_transactions t\s+LEFT JOIN wallets w ON w.id = t.wallet_id\s+WHERE w.id IS NULL`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "wallet_id", "operation_type", "amount", "balance_after", "created_at"}).
+			AddRow(7, missing, "DEPOSIT", 100, 100, time.Now()))
+	mock.ExpectRollback()
+
+	report, err := NewReconciliationRepository(db).Reconcile(context.Background(), 1)
+	require.NoError(t, err)
+	assert.Equal(t, int64(10), report.WalletsChecked)
+	assert.Equal(t, int64(2), report.MismatchCount)
+	assert.Len(t, report.Mismatches, 1, "the list is capped by the limit")
+	assert.Equal(t, drifted, report.Mismatches[0].WalletID)
+	assert.Equal(t, int64(1), report.NegativeBalanceCount)
+	assert.Equal(t, overdrawn, report.NegativeBalances[0].WalletID)
+	assert.Equal(t, int64(1), report.OrphanedTransactionCount)
+	assert.Equal(t, missing, report.OrphanedTransactions[0].WalletID)
+	assert.False(t, report.Clean())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,334 @@
+// Package repositorytest holds behaviour tests shared by every WalletRepository implementation
+package repositorytest
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"ITKtest/internal/models"
+	"ITKtest/internal/repository"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestWalletRepository runs the conformance suite against repositories built by newRepo. Every
+// case works on fresh wallet ids, so a shared database may be reused across cases.
+func TestWalletRepository(t *testing.T, newRepo func(t *testing.T) repository.WalletRepository) {
+	cases := []struct {
+		name string
+		run  func(t *testing.T, repo repository.WalletRepository)
+	}{
+		{"CreateWallet", testCreateWallet},
+		{"GetWalletNotFound", testGetWalletNotFound},
+		{"DepositAndWithdraw", testDepositAndWithdraw},
+		{"InsufficientFunds", testInsufficientFunds},
+		{"InvalidOperationType", testInvalidOperationType},
+		{"ExpectedVersion", testExpectedVersion},
+		{"ConcurrentDeposits", testConcurrentDeposits},
+		{"ConcurrentWithdrawalsNeverOverdraw", testConcurrentWithdrawals},
+		{"AtomicBatch", testAtomicBatch},
+		{"AtomicBatchRollback", testAtomicBatchRollback},
+		{"ListTransactions", testListTransactions},
+		{"Reference", testReference},
+		{"Fee", testFee},
+		{"Sharding", testSharding},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.run(t, newRepo(t))
+		})
+	}
+}
+
+func newWallet(t *testing.T, repo repository.WalletRepository, balance int64) uuid.UUID {
+	t.Helper()
+	walletID := uuid.New()
+	require.NoError(t, repo.CreateWallet(context.Background(), walletID))
+	if balance > 0 {
+		require.NoError(t, repo.UpdateWalletBalance(context.Background(), walletID, balance, models.DEPOSIT))
+	}
+	return walletID
+}
+
+func balanceOf(t *testing.T, repo repository.WalletRepository, walletID uuid.UUID) int64 {
+	t.Helper()
+	wallet, err := repo.GetWallet(context.Background(), walletID)
+	require.NoError(t, err)
+	return wallet.Balance
+}
+
+func testCreateWallet(t *testing.T, repo repository.WalletRepository) {
+	ctx := context.Background()
+	walletID := newWallet(t, repo, 0)
+
+	// Creating an existing wallet is a no-op
+	require.NoError(t, repo.UpdateWalletBalance(ctx, walletID, 100, models.DEPOSIT))
+	require.NoError(t, repo.CreateWallet(ctx, walletID))
+
+	wallet, err := repo.GetWallet(ctx, walletID)
+	require.NoError(t, err)
+	assert.Equal(t, walletID, wallet.ID)
+	assert.Equal(t, int64(100), wallet.Balance)
+	assert.Equal(t, 0, wallet.ShardCount)
+	assert.False(t, wallet.CreatedAt.IsZero())
+}
+
+func testGetWalletNotFound(t *testing.T, repo repository.WalletRepository) {
+	_, err := repo.GetWallet(context.Background(), uuid.New())
+	assert.EqualError(t, err, "wallet not found")
+}
+
+func testDepositAndWithdraw(t *testing.T, repo repository.WalletRepository) {
+	ctx := context.Background()
+	walletID := newWallet(t, repo, 0)
+
+	require.NoError(t, repo.UpdateWalletBalance(ctx, walletID, 1000, models.DEPOSIT))
+	require.NoError(t, repo.UpdateWalletBalance(ctx, walletID, 300, models.WITHDRAW))
+
+	wallet, err := repo.GetWallet(ctx, walletID)
+	require.NoError(t, err)
+	assert.Equal(t, int64(700), wallet.Balance)
+	assert.Equal(t, int64(2), wallet.Version)
+}
+
+func testInsufficientFunds(t *testing.T, repo repository.WalletRepository) {
+	walletID := newWallet(t, repo, 100)
+
+	err := repo.UpdateWalletBalance(context.Background(), walletID, 101, models.WITHDRAW)
+	assert.EqualError(t, err, "insufficient funds")
+	assert.Equal(t, int64(100), balanceOf(t, repo, walletID))
+}
+
+func testInvalidOperationType(t *testing.T, repo repository.WalletRepository) {
+	walletID := newWallet(t, repo, 0)
+
+	err := repo.UpdateWalletBalance(context.Background(), walletID, 100, models.OperationType("TRANSFER"))
+	assert.EqualError(t, err, "invalid operation type")
+}
+
+func testExpectedVersion(t *testing.T, repo repository.WalletRepository) {
+	ctx := context.Background()
+	walletID := newWallet(t, repo, 100)
+
+	wallet, err := repo.GetWallet(ctx, walletID)
+	require.NoError(t, err)
+
+	err = repo.UpdateWalletBalanceWithVersion(ctx, walletID, 10, models.WITHDRAW, wallet.Version-1)
+	assert.EqualError(t, err, "version conflict")
+	assert.Equal(t, int64(100), balanceOf(t, repo, walletID))
+
+	require.NoError(t, repo.UpdateWalletBalanceWithVersion(ctx, walletID, 10, models.WITHDRAW, wallet.Version))
+
+	updated, err := repo.GetWallet(ctx, walletID)
+	require.NoError(t, err)
+	assert.Equal(t, int64(90), updated.Balance)
+	assert.Equal(t, wallet.Version+1, updated.Version)
+}
+
+func testConcurrentDeposits(t *testing.T, repo repository.WalletRepository) {
+	const workers = 50
+	walletID := newWallet(t, repo, 0)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, workers)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- repo.UpdateWalletBalance(context.Background(), walletID, 10, models.DEPOSIT)
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, int64(workers*10), balanceOf(t, repo, walletID))
+}
+
+func testConcurrentWithdrawals(t *testing.T, repo repository.WalletRepository) {
+	const workers = 20
+	walletID := newWallet(t, repo, 100)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, workers)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- repo.UpdateWalletBalance(context.Background(), walletID, 10, models.WITHDRAW)
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	succeeded := 0
+	for err := range errs {
+		if err == nil {
+			succeeded++
+		} else {
+			assert.EqualError(t, err, "insufficient funds")
+		}
+	}
+	assert.Equal(t, 10, succeeded)
+	assert.Equal(t, int64(0), balanceOf(t, repo, walletID))
+}
+
+func testAtomicBatch(t *testing.T, repo repository.WalletRepository) {
+	from := newWallet(t, repo, 500)
+	to := newWallet(t, repo, 0)
+
+	err := repo.UpdateWalletBalancesAtomic(context.Background(), []models.WalletOperationRequest{
+		{WalletID: from, OperationType: models.WITHDRAW, Amount: 200},
+		{WalletID: to, OperationType: models.DEPOSIT, Amount: 200},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, int64(300), balanceOf(t, repo, from))
+	assert.Equal(t, int64(200), balanceOf(t, repo, to))
+}
+
+func testAtomicBatchRollback(t *testing.T, repo repository.WalletRepository) {
+	ctx := context.Background()
+	from := newWallet(t, repo, 100)
+	to := newWallet(t, repo, 0)
+
+	err := repo.UpdateWalletBalancesAtomic(ctx, []models.WalletOperationRequest{
+		{WalletID: to, OperationType: models.DEPOSIT, Amount: 150},
+		{WalletID: from, OperationType: models.WITHDRAW, Amount: 150},
+	})
+	var batchErr *models.BatchOperationError
+	require.True(t, errors.As(err, &batchErr))
+	assert.Equal(t, 1, batchErr.Index)
+	assert.EqualError(t, batchErr.Err, "insufficient funds")
+
+	assert.Equal(t, int64(100), balanceOf(t, repo, from))
+	assert.Equal(t, int64(0), balanceOf(t, repo, to))
+
+	transactions, err := repo.ListTransactions(ctx, to, 0, 10)
+	require.NoError(t, err)
+	assert.Empty(t, transactions)
+}
+
+func testListTransactions(t *testing.T, repo repository.WalletRepository) {
+	ctx := context.Background()
+	walletID := newWallet(t, repo, 0)
+	other := newWallet(t, repo, 0)
+
+	require.NoError(t, repo.UpdateWalletBalance(ctx, walletID, 100, models.DEPOSIT))
+	require.NoError(t, repo.UpdateWalletBalance(ctx, other, 999, models.DEPOSIT))
+	require.NoError(t, repo.UpdateWalletBalance(ctx, walletID, 30, models.WITHDRAW))
+	require.NoError(t, repo.UpdateWalletBalance(ctx, walletID, 50, models.DEPOSIT))
+
+	transactions, err := repo.ListTransactions(ctx, walletID, 0, 10)
+	require.NoError(t, err)
+	require.Len(t, transactions, 3)
+	assert.Equal(t, models.DEPOSIT, transactions[0].OperationType)
+	assert.Equal(t, int64(100), transactions[0].BalanceAfter)
+	assert.Equal(t, models.WITHDRAW, transactions[1].OperationType)
+	assert.Equal(t, int64(70), transactions[1].BalanceAfter)
+	assert.Equal(t, int64(120), transactions[2].BalanceAfter)
+	for _, transaction := range transactions {
+		assert.Equal(t, walletID, transaction.WalletID)
+	}
+
+	page, err := repo.ListTransactions(ctx, walletID, transactions[0].ID, 1)
+	require.NoError(t, err)
+	require.Len(t, page, 1)
+	assert.Equal(t, transactions[1].ID, page[0].ID)
+}
+
+func testReference(t *testing.T, repo repository.WalletRepository) {
+	ctx := context.Background()
+	walletID := newWallet(t, repo, 0)
+	other := newWallet(t, repo, 0)
+
+	require.NoError(t, repo.ApplyOperation(ctx, models.WalletOperationRequest{
+		WalletID: walletID, OperationType: models.DEPOSIT, Amount: 100, Reference: "bank-1",
+	}))
+	require.NoError(t, repo.UpdateWalletBalancesAtomic(ctx, []models.WalletOperationRequest{
+		{WalletID: walletID, OperationType: models.WITHDRAW, Amount: 40, Reference: "transfer-1"},
+		{WalletID: other, OperationType: models.DEPOSIT, Amount: 40},
+	}))
+	assert.Equal(t, int64(60), balanceOf(t, repo, walletID))
+
+	transactions, err := repo.ListTransactions(ctx, walletID, 0, 10)
+	require.NoError(t, err)
+	require.Len(t, transactions, 2)
+	assert.Equal(t, "bank-1", transactions[0].Reference)
+	assert.Equal(t, "transfer-1", transactions[1].Reference)
+
+	transactions, err = repo.ListTransactions(ctx, other, 0, 10)
+	require.NoError(t, err)
+	require.Len(t, transactions, 1)
+	assert.Empty(t, transactions[0].Reference)
+}
+
+func testFee(t *testing.T, repo repository.WalletRepository) {
+	ctx := context.Background()
+	walletID := newWallet(t, repo, 100)
+	feeWallet := newWallet(t, repo, 0)
+	fee := &models.OperationFee{Amount: 5, FeeWalletID: feeWallet, Rule: "test"}
+
+	require.NoError(t, repo.ApplyOperation(ctx, models.WalletOperationRequest{
+		WalletID: walletID, OperationType: models.WITHDRAW, Amount: 50, Fee: fee,
+	}))
+	assert.Equal(t, int64(45), balanceOf(t, repo, walletID))
+	assert.Equal(t, int64(5), balanceOf(t, repo, feeWallet))
+
+	transactions, err := repo.ListTransactions(ctx, walletID, 0, 10)
+	require.NoError(t, err)
+	require.Len(t, transactions, 3)
+	withdrawal, charge := transactions[1], transactions[2]
+	assert.Nil(t, withdrawal.FeeFor)
+	assert.Equal(t, int64(50), withdrawal.Amount)
+	assert.Equal(t, models.WITHDRAW, charge.OperationType)
+	assert.Equal(t, int64(5), charge.Amount)
+	require.NotNil(t, charge.FeeFor)
+	assert.Equal(t, withdrawal.ID, *charge.FeeFor)
+
+	credits, err := repo.ListTransactions(ctx, feeWallet, 0, 10)
+	require.NoError(t, err)
+	require.Len(t, credits, 1)
+	assert.Equal(t, withdrawal.ID, *credits[0].FeeFor)
+
+	// A fee the wallet cannot pay fails the operation with it, in batches too
+	err = repo.ApplyOperation(ctx, models.WalletOperationRequest{
+		WalletID: walletID, OperationType: models.WITHDRAW, Amount: 45, Fee: fee,
+	})
+	assert.EqualError(t, err, "insufficient funds")
+	err = repo.UpdateWalletBalancesAtomic(ctx, []models.WalletOperationRequest{
+		{WalletID: walletID, OperationType: models.DEPOSIT, Amount: 10, Fee: fee},
+		{WalletID: walletID, OperationType: models.WITHDRAW, Amount: 46, Fee: fee},
+	})
+	var batchErr *models.BatchOperationError
+	require.ErrorAs(t, err, &batchErr)
+	assert.Equal(t, 1, batchErr.Index)
+	assert.Equal(t, int64(45), balanceOf(t, repo, walletID))
+	assert.Equal(t, int64(5), balanceOf(t, repo, feeWallet))
+}
+
+func testSharding(t *testing.T, repo repository.WalletRepository) {
+	ctx := context.Background()
+	walletID := newWallet(t, repo, 500)
+
+	require.NoError(t, repo.EnableSharding(ctx, walletID, 4))
+	assert.EqualError(t, repo.EnableSharding(ctx, walletID, 2), "shard count cannot be decreased")
+
+	wallet, err := repo.GetWallet(ctx, walletID)
+	require.NoError(t, err)
+	assert.Equal(t, 4, wallet.ShardCount)
+	assert.Equal(t, int64(500), wallet.Balance)
+
+	require.NoError(t, repo.UpdateWalletBalance(ctx, walletID, 200, models.DEPOSIT))
+	require.NoError(t, repo.UpdateWalletBalance(ctx, walletID, 600, models.WITHDRAW))
+	assert.Equal(t, int64(100), balanceOf(t, repo, walletID))
+
+	err = repo.UpdateWalletBalanceWithVersion(ctx, walletID, 10, models.DEPOSIT, wallet.Version)
+	assert.EqualError(t, err, "expected version is not supported for sharded wallets")
+}
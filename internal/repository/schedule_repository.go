@@ -0,0 +1,230 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strconv"
+	"time"
+
+	"ITKtest/internal/models"
+
+	"github.com/google/uuid"
+)
+
+// ScheduleRepository stores scheduled wallet operations and the outcome of their runs
+type ScheduleRepository interface {
+	// CreateSchedule stores s and sets its id and timestamps
+	CreateSchedule(ctx context.Context, s *models.Schedule) error
+	GetSchedule(ctx context.Context, id int64) (*models.Schedule, error)
+	// ListSchedules returns the schedules debiting or crediting walletID in id order
+	ListSchedules(ctx context.Context, walletID uuid.UUID) ([]models.Schedule, error)
+	// UpdateSchedule locks the schedule, lets update change its status and next run, and stores
+	// the result. An error from update leaves the schedule unchanged.
+	UpdateSchedule(ctx context.Context, id int64, update func(*models.Schedule) error) (*models.Schedule, error)
+	// ListRuns returns the runs of a schedule with id greater than afterID in id order
+	ListRuns(ctx context.Context, scheduleID int64, afterID int64, limit int) ([]models.ScheduleRun, error)
+	// ClaimDue leases up to limit active schedules due as of now until leaseUntil, so that no
+	// other worker runs them meanwhile. Schedules whose lease expired are claimed again.
+	ClaimDue(ctx context.Context, now, leaseUntil time.Time, limit int) ([]models.Schedule, error)
+	// CompleteRun records run and moves its schedule to next, completing it when next is nil,
+	// and releases the lease. A run already recorded is not recorded again.
+	CompleteRun(ctx context.Context, run models.ScheduleRun, next *time.Time) error
+}
+
+type scheduleRepository struct {
+	db *sql.DB
+	tx *txRunner
+}
+
+func NewScheduleRepository(db *sql.DB, txConfig TxConfig) ScheduleRepository {
+	return &scheduleRepository{db: db, tx: newTxRunner(db, txConfig)}
+}
+
+const scheduleColumns = `id, wallet_id, operation_type, amount, to_wallet_id, COALESCE(reference, ''),
+	run_at, COALESCE(cron, ''), COALESCE(time_zone, ''), COALESCE(interval_seconds, 0), max_runs,
+	status, next_run_at, run_count, COALESCE(principal, ''), created_by, created_at, updated_at`
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanSchedule(row rowScanner) (*models.Schedule, error) {
+	var s models.Schedule
+	var toWalletID uuid.NullUUID
+	err := row.Scan(&s.ID, &s.WalletID, &s.OperationType, &s.Amount, &toWalletID, &s.Reference,
+		&s.RunAt, &s.Cron, &s.TimeZone, &s.IntervalSeconds, &s.MaxRuns,
+		&s.Status, &s.NextRunAt, &s.RunCount, &s.Principal, &s.CreatedBy, &s.CreatedAt, &s.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if toWalletID.Valid {
+		s.ToWalletID = &toWalletID.UUID
+	}
+	return &s, nil
+}
+
+func (r *scheduleRepository) CreateSchedule(ctx context.Context, s *models.Schedule) error {
+	return r.tx.run(ctx, func(tx *sql.Tx) error {
+		now := time.Now()
+		err := tx.QueryRowContext(ctx, `
+			INSERT INTO wallet_schedules (wallet_id, operation_type, amount, to_wallet_id, reference, run_at, cron,
+				time_zone, interval_seconds, max_runs, status, next_run_at, principal, created_by, created_at, updated_at)
+			VALUES ($1, $2, $3, $4, NULLIF($5, ''), $6, NULLIF($7, ''), NULLIF($8, ''), NULLIF($9, 0), $10, $11, $12,
+				NULLIF($13, ''), $14, $15, $15)
+			RETURNING id
+		`, s.WalletID, s.OperationType, s.Amount, s.ToWalletID, s.Reference, s.RunAt, s.Cron,
+			s.TimeZone, s.IntervalSeconds, s.MaxRuns, s.Status, s.NextRunAt, s.Principal, s.CreatedBy, now).Scan(&s.ID)
+		if err != nil {
+			return err
+		}
+		s.CreatedAt, s.UpdatedAt = now, now
+		return insertAuditRecord(ctx, tx, models.AuditScheduleCreated, strconv.FormatInt(s.ID, 10), nil, s)
+	})
+}
+
+func (r *scheduleRepository) GetSchedule(ctx context.Context, id int64) (*models.Schedule, error) {
+	s, err := scanSchedule(r.db.QueryRowContext(ctx, "SELECT "+scheduleColumns+" FROM wallet_schedules WHERE id = $1", id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrScheduleNotFound
+	}
+	return s, err
+}
+
+// ErrScheduleNotFound is returned for schedules that do not exist
+var ErrScheduleNotFound = errors.New("schedule not found")
+
+func (r *scheduleRepository) ListSchedules(ctx context.Context, walletID uuid.UUID) ([]models.Schedule, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT `+scheduleColumns+`
+		FROM wallet_schedules
+		WHERE wallet_id = $1 OR to_wallet_id = $1
+		ORDER BY id
+	`, walletID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	schedules := []models.Schedule{}
+	for rows.Next() {
+		s, err := scanSchedule(rows)
+		if err != nil {
+			return nil, err
+		}
+		schedules = append(schedules, *s)
+	}
+	return schedules, rows.Err()
+}
+
+// auditSchedule is the audited state of a schedule around a status change
+type auditSchedule struct {
+	Status    string     `json:"status"`
+	NextRunAt *time.Time `json:"nextRunAt,omitempty"`
+}
+
+func (r *scheduleRepository) UpdateSchedule(ctx context.Context, id int64, update func(*models.Schedule) error) (*models.Schedule, error) {
+	var updated *models.Schedule
+	err := r.tx.run(ctx, func(tx *sql.Tx) error {
+		s, err := scanSchedule(tx.QueryRowContext(ctx, "SELECT "+scheduleColumns+" FROM wallet_schedules WHERE id = $1 FOR UPDATE", id))
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrScheduleNotFound
+		}
+		if err != nil {
+			return err
+		}
+		before := auditSchedule{Status: s.Status, NextRunAt: s.NextRunAt}
+		if err := update(s); err != nil {
+			return err
+		}
+
+		s.UpdatedAt = time.Now()
+		_, err = tx.ExecContext(ctx,
+			"UPDATE wallet_schedules SET status = $2, next_run_at = $3, updated_at = $4 WHERE id = $1",
+			id, s.Status, s.NextRunAt, s.UpdatedAt)
+		if err != nil {
+			return err
+		}
+		updated = s
+		return insertAuditRecord(ctx, tx, models.AuditScheduleStatusChanged, strconv.FormatInt(id, 10),
+			before, auditSchedule{Status: s.Status, NextRunAt: s.NextRunAt})
+	})
+	return updated, err
+}
+
+func (r *scheduleRepository) ListRuns(ctx context.Context, scheduleID int64, afterID int64, limit int) ([]models.ScheduleRun, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT id, schedule_id, due_at, idempotency_key, status, COALESCE(error, ''), finished_at
+		FROM wallet_schedule_runs
+		WHERE schedule_id = $1 AND id > $2
+		ORDER BY id
+		LIMIT $3
+	`, scheduleID, afterID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	runs := []models.ScheduleRun{}
+	for rows.Next() {
+		var run models.ScheduleRun
+		if err := rows.Scan(&run.ID, &run.ScheduleID, &run.DueAt, &run.IdempotencyKey, &run.Status, &run.Error, &run.FinishedAt); err != nil {
+			return nil, err
+		}
+		runs = append(runs, run)
+	}
+	return runs, rows.Err()
+}
+
+func (r *scheduleRepository) ClaimDue(ctx context.Context, now, leaseUntil time.Time, limit int) ([]models.Schedule, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		UPDATE wallet_schedules SET locked_until = $2
+		WHERE id IN (
+			SELECT id FROM wallet_schedules
+			WHERE status = 'active' AND next_run_at <= $1 AND (locked_until IS NULL OR locked_until <= $1)
+			ORDER BY next_run_at
+			LIMIT $3
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING `+scheduleColumns, now, leaseUntil, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var schedules []models.Schedule
+	for rows.Next() {
+		s, err := scanSchedule(rows)
+		if err != nil {
+			return nil, err
+		}
+		schedules = append(schedules, *s)
+	}
+	return schedules, rows.Err()
+}
+
+func (r *scheduleRepository) CompleteRun(ctx context.Context, run models.ScheduleRun, next *time.Time) error {
+	return r.tx.run(ctx, func(tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx, `
+			INSERT INTO wallet_schedule_runs (schedule_id, due_at, idempotency_key, status, error, finished_at)
+			VALUES ($1, $2, $3, $4, NULLIF($5, ''), $6)
+			ON CONFLICT (schedule_id, due_at) DO NOTHING
+		`, run.ScheduleID, run.DueAt, run.IdempotencyKey, run.Status, run.Error, run.FinishedAt)
+		if err != nil {
+			return err
+		}
+
+		// A schedule paused or cancelled during the run keeps its status; one resumed with a new
+		// next run is left alone
+		_, err = tx.ExecContext(ctx, `
+			UPDATE wallet_schedules
+			SET next_run_at = $3,
+				run_count = run_count + 1,
+				status = CASE WHEN $3::timestamptz IS NULL AND status = 'active' THEN 'completed' ELSE status END,
+				locked_until = NULL,
+				updated_at = $4
+			WHERE id = $1 AND next_run_at = $2
+		`, run.ScheduleID, run.DueAt, next, run.FinishedAt)
+		return err
+	})
+}
@@ -0,0 +1,96 @@
+package repository_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"ITKtest/internal/models"
+	"ITKtest/internal/repository"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScheduleRepository_Postgres(t *testing.T) {
+	db := openConformanceDB(t)
+	ctx := context.Background()
+
+	wallets := repository.NewWalletRepository(db, repository.TxConfig{})
+	schedules := repository.NewScheduleRepository(db, repository.TxConfig{})
+	walletID, toWalletID := uuid.New(), uuid.New()
+	require.NoError(t, wallets.UpdateWalletBalance(ctx, walletID, 100, models.DEPOSIT))
+
+	// Runs are due in the past so that schedules of other tests are not due with them
+	due := time.Date(2001, 1, 1, 0, 0, 0, 0, time.UTC).Add(time.Duration(time.Now().UnixNano() % int64(time.Hour)))
+	s := &models.Schedule{WalletID: walletID, OperationType: models.TRANSFER, Amount: 10, ToWalletID: &toWalletID,
+		IntervalSeconds: 3600, MaxRuns: 2, Status: models.ScheduleActive, NextRunAt: &due, CreatedBy: "test"}
+	require.NoError(t, schedules.CreateSchedule(ctx, s))
+
+	listed, err := schedules.ListSchedules(ctx, toWalletID)
+	require.NoError(t, err)
+	require.Len(t, listed, 1)
+	assert.Equal(t, s.ID, listed[0].ID)
+
+	claim := func(now time.Time) []int64 {
+		claimed, err := schedules.ClaimDue(ctx, now, now.Add(time.Minute), 100)
+		require.NoError(t, err)
+		var ids []int64
+		for _, c := range claimed {
+			if c.ID == s.ID {
+				ids = append(ids, c.ID)
+			}
+		}
+		return ids
+	}
+
+	// A leased schedule is not claimed again until its lease expires
+	now := due.Add(time.Second)
+	assert.Equal(t, []int64{s.ID}, claim(now))
+	assert.Empty(t, claim(now))
+	assert.Equal(t, []int64{s.ID}, claim(now.Add(2*time.Minute)))
+
+	// A run is recorded once; the second run completes the schedule
+	next := due.Add(time.Hour)
+	run := models.ScheduleRun{ScheduleID: s.ID, DueAt: due, IdempotencyKey: "first", Status: models.RunSucceeded, FinishedAt: now}
+	require.NoError(t, schedules.CompleteRun(ctx, run, &next))
+	require.NoError(t, schedules.CompleteRun(ctx, run, &next))
+	assert.Empty(t, claim(now))
+
+	run = models.ScheduleRun{ScheduleID: s.ID, DueAt: next, IdempotencyKey: "second", Status: models.RunFailed, Error: "insufficient funds", FinishedAt: next}
+	assert.Equal(t, []int64{s.ID}, claim(next))
+	require.NoError(t, schedules.CompleteRun(ctx, run, nil))
+
+	got, err := schedules.GetSchedule(ctx, s.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.ScheduleCompleted, got.Status)
+	assert.Equal(t, 2, got.RunCount)
+	assert.Nil(t, got.NextRunAt)
+
+	runs, err := schedules.ListRuns(ctx, s.ID, 0, 10)
+	require.NoError(t, err)
+	require.Len(t, runs, 2)
+	assert.Equal(t, "insufficient funds", runs[1].Error)
+
+	// A status change that fails leaves the schedule unchanged
+	_, err = schedules.UpdateSchedule(ctx, s.ID, func(s *models.Schedule) error {
+		s.Status = models.SchedulePaused
+		return assert.AnError
+	})
+	assert.ErrorIs(t, err, assert.AnError)
+	got, err = schedules.GetSchedule(ctx, s.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.ScheduleCompleted, got.Status)
+
+	_, err = schedules.GetSchedule(ctx, -1)
+	assert.ErrorIs(t, err, repository.ErrScheduleNotFound)
+
+	// An idempotency key is claimed by the first operation carrying it
+	op := models.WalletOperationRequest{WalletID: walletID, OperationType: models.WITHDRAW, Amount: 10, IdempotencyKey: "schedule-test-" + walletID.String()}
+	require.NoError(t, wallets.ApplyOperation(ctx, op))
+	assert.ErrorIs(t, wallets.ApplyOperation(ctx, op), repository.ErrDuplicateOperation)
+	wallet, err := wallets.GetWallet(ctx, walletID)
+	require.NoError(t, err)
+	assert.Equal(t, int64(90), wallet.Balance)
+}
@@ -0,0 +1,58 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+
+	"ITKtest/internal/models"
+
+	"github.com/lib/pq"
+)
+
+// settlementLookupChunk is the number of references looked up per query, keeping the array
+// parameter of large settlement files to a reasonable size
+const settlementLookupChunk = 1000
+
+// SettlementRepository finds the deposits that settlement file lines refer to
+type SettlementRepository interface {
+	// DepositsByReference returns the deposits carrying each of references, keyed by reference
+	DepositsByReference(ctx context.Context, references []string) (map[string][]models.Transaction, error)
+}
+
+type settlementRepository struct {
+	db *sql.DB
+}
+
+func NewSettlementRepository(db *sql.DB) SettlementRepository {
+	return &settlementRepository{db: db}
+}
+
+func (r *settlementRepository) DepositsByReference(ctx context.Context, references []string) (map[string][]models.Transaction, error) {
+	deposits := make(map[string][]models.Transaction)
+	for start := 0; start < len(references); start += settlementLookupChunk {
+		chunk := references[start:min(start+settlementLookupChunk, len(references))]
+		rows, err := r.db.QueryContext(ctx, `
+			SELECT id, wallet_id, operation_type, amount, balance_after, created_at, reference
+			FROM wallet_transactions
+			WHERE reference = ANY($1) AND operation_type = 'DEPOSIT'
+			ORDER BY id
+		`, pq.Array(chunk))
+		if err != nil {
+			return nil, err
+		}
+		for rows.Next() {
+			var t models.Transaction
+			if err := rows.Scan(&t.ID, &t.WalletID, &t.OperationType, &t.Amount, &t.BalanceAfter, &t.CreatedAt, &t.Reference); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			deposits[t.Reference] = append(deposits[t.Reference], t)
+		}
+		err = rows.Err()
+		rows.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return deposits, nil
+}
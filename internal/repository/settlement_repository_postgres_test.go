@@ -0,0 +1,40 @@
+package repository_test
+
+import (
+	"context"
+	"testing"
+
+	"ITKtest/internal/models"
+	"ITKtest/internal/repository"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSettlementRepository_Postgres(t *testing.T) {
+	db := openConformanceDB(t)
+	ctx := context.Background()
+
+	repo := repository.NewWalletRepository(db, repository.TxConfig{})
+	walletID := uuid.New()
+	reference := "settlement-" + walletID.String()
+	require.NoError(t, repo.CreateWallet(ctx, walletID))
+	require.NoError(t, repo.ApplyOperation(ctx, models.WalletOperationRequest{WalletID: walletID, OperationType: models.DEPOSIT, Amount: 500, Reference: reference}))
+	require.NoError(t, repo.ApplyOperation(ctx, models.WalletOperationRequest{WalletID: walletID, OperationType: models.WITHDRAW, Amount: 200, Reference: reference}))
+	require.NoError(t, repo.UpdateWalletBalance(ctx, walletID, 100, models.DEPOSIT))
+
+	// Only deposits carrying the reference are found
+	deposits, err := repository.NewSettlementRepository(db).DepositsByReference(ctx, []string{reference, "settlement-unknown"})
+	require.NoError(t, err)
+	require.Len(t, deposits, 1)
+	require.Len(t, deposits[reference], 1)
+	assert.Equal(t, int64(500), deposits[reference][0].Amount)
+	assert.Equal(t, walletID, deposits[reference][0].WalletID)
+
+	transactions, err := repo.ListTransactions(ctx, walletID, 0, 10)
+	require.NoError(t, err)
+	require.Len(t, transactions, 3)
+	assert.Equal(t, reference, transactions[0].Reference)
+	assert.Empty(t, transactions[2].Reference)
+}
@@ -0,0 +1,44 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSettlementRepository_DepositsByReference(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	// References are looked up in chunks
+	references := make([]string, settlementLookupChunk+1)
+	for i := range references {
+		references[i] = fmt.Sprintf("bank-%d", i)
+	}
+	walletID := uuid.New()
+	columns := []string{"id", "wallet_id", "operation_type", "amount", "balance_after", "created_at", "reference"}
+	mock.ExpectQuery(`FROM wallet_transactions\s+WHERE reference = ANY\(\$1\) AND operation_type = 'DEPOSIT'`).
+		WithArgs(pq.Array(references[:settlementLookupChunk])).
+		WillReturnRows(sqlmock.NewRows(columns).
+			AddRow(1, walletID, "DEPOSIT", 100, 100, time.Now(), "bank-0").
+			AddRow(2, walletID, "DEPOSIT", 100, 200, time.Now(), "bank-0"))
+	mock.ExpectQuery(`FROM wallet_transactions\s+WHERE reference = ANY\(\$1\) AND operation_type = 'DEPOSIT'`).
+		WithArgs(pq.Array(references[settlementLookupChunk:])).
+		WillReturnRows(sqlmock.NewRows(columns).
+			AddRow(3, walletID, "DEPOSIT", 50, 250, time.Now(), references[settlementLookupChunk]))
+
+	deposits, err := NewSettlementRepository(db).DepositsByReference(context.Background(), references)
+	require.NoError(t, err)
+	assert.Len(t, deposits, 2)
+	assert.Len(t, deposits["bank-0"], 2)
+	assert.Equal(t, int64(3), deposits[references[settlementLookupChunk]][0].ID)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
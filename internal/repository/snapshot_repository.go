@@ -0,0 +1,105 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"ITKtest/internal/models"
+
+	"github.com/google/uuid"
+)
+
+// SnapshotRepository stores end-of-period wallet balances and answers point-in-time balance
+// queries from the latest snapshot plus the journal entries after it
+type SnapshotRepository interface {
+	// TakeSnapshots records the balance of every wallet as of asOf and returns how many were
+	// written; wallets that already have a snapshot at asOf are skipped
+	TakeSnapshots(ctx context.Context, asOf time.Time) (int64, error)
+	BalanceAt(ctx context.Context, walletID uuid.UUID, at time.Time) (int64, error)
+	// StreamTransactions calls fn for each journal entry of the wallet created after from and up
+	// to and including to, oldest first, without loading them all into memory. The query stays
+	// open until fn has seen every row, so fn should not block for long.
+	StreamTransactions(ctx context.Context, walletID uuid.UUID, from, to time.Time, fn func(models.Transaction) error) error
+}
+
+type snapshotRepository struct {
+	db *sql.DB
+}
+
+func NewSnapshotRepository(db *sql.DB) SnapshotRepository {
+	return &snapshotRepository{db: db}
+}
+
+// journalDelta sums the signed journal amounts of wallet w after the snapshot s (or from the
+// start if there is none) up to and including $1. The journal has an entry for every balance
+// change, so this also holds for sharded wallets, whose balance_after is only approximate.
+const journalDelta = `
+	COALESCE(s.balance, 0) + COALESCE((
+		SELECT SUM(CASE WHEN t.operation_type = 'WITHDRAW' THEN -t.amount ELSE t.amount END)
+		FROM wallet_transactions t
+		WHERE t.wallet_id = w.id
+			AND t.created_at > COALESCE(s.as_of, '-infinity'::timestamptz)
+			AND t.created_at <= $1
+	), 0)`
+
+// latestSnapshot joins the latest snapshot of wallet w taken at or before $1
+const latestSnapshot = `
+	LEFT JOIN LATERAL (
+		SELECT as_of, balance FROM wallet_balance_snapshots
+		WHERE wallet_id = w.id AND as_of <= $1
+		ORDER BY as_of DESC
+		LIMIT 1
+	) s ON true`
+
+func (r *snapshotRepository) TakeSnapshots(ctx context.Context, asOf time.Time) (int64, error) {
+	result, err := r.db.ExecContext(ctx, `
+		INSERT INTO wallet_balance_snapshots (wallet_id, as_of, balance)
+		SELECT w.id, $1::timestamptz, `+journalDelta+`
+		FROM wallets w`+latestSnapshot+`
+		WHERE w.created_at <= $1
+		ON CONFLICT (wallet_id, as_of) DO NOTHING
+	`, asOf)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+func (r *snapshotRepository) BalanceAt(ctx context.Context, walletID uuid.UUID, at time.Time) (int64, error) {
+	var balance int64
+	err := r.db.QueryRowContext(ctx, `
+		SELECT `+journalDelta+`
+		FROM wallets w`+latestSnapshot+`
+		WHERE w.id = $2
+	`, at, walletID).Scan(&balance)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, ErrWalletNotFound
+	}
+	return balance, err
+}
+
+func (r *snapshotRepository) StreamTransactions(ctx context.Context, walletID uuid.UUID, from, to time.Time, fn func(models.Transaction) error) error {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT id, wallet_id, operation_type, amount, balance_after, created_at, COALESCE(reference, ''), fee_for
+		FROM wallet_transactions
+		WHERE wallet_id = $1 AND created_at > $2 AND created_at <= $3
+		ORDER BY created_at, id
+	`, walletID, from, to)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var t models.Transaction
+		if err := rows.Scan(&t.ID, &t.WalletID, &t.OperationType, &t.Amount, &t.BalanceAfter, &t.CreatedAt, &t.Reference, &t.FeeFor); err != nil {
+			return err
+		}
+		if err := fn(t); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
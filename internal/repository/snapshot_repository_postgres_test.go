@@ -0,0 +1,73 @@
+package repository_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"ITKtest/internal/repository"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSnapshotRepository_Postgres(t *testing.T) {
+	db := openConformanceDB(t)
+	ctx := context.Background()
+	walletID := uuid.New()
+	day := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+
+	_, err := db.Exec("INSERT INTO wallets (id, balance, created_at, updated_at) VALUES ($1, 0, $2, $2)", walletID, day.Add(-48*time.Hour))
+	require.NoError(t, err)
+	journal := []struct {
+		operationType string
+		amount        int64
+		at            time.Time
+	}{
+		{"DEPOSIT", 1000, day.Add(-36 * time.Hour)},
+		{"WITHDRAW", 300, day.Add(-time.Hour)},
+		{"DEPOSIT", 50, day},
+		{"DEPOSIT", 200, day.Add(time.Hour)},
+	}
+	for _, entry := range journal {
+		_, err := db.Exec(`
+			INSERT INTO wallet_transactions (wallet_id, operation_type, amount, balance_after, created_at)
+			VALUES ($1, $2, $3, 0, $4)
+		`, walletID, entry.operationType, entry.amount, entry.at)
+		require.NoError(t, err)
+	}
+
+	repo := repository.NewSnapshotRepository(db)
+	balanceAt := func(at time.Time) int64 {
+		balance, err := repo.BalanceAt(ctx, walletID, at)
+		require.NoError(t, err)
+		return balance
+	}
+
+	// Without snapshots the balance comes from the journal alone
+	assert.Equal(t, int64(0), balanceAt(day.Add(-40*time.Hour)))
+	assert.Equal(t, int64(750), balanceAt(day))
+
+	n, err := repo.TakeSnapshots(ctx, day.Add(-24*time.Hour))
+	require.NoError(t, err)
+	assert.GreaterOrEqual(t, n, int64(1))
+	_, err = repo.TakeSnapshots(ctx, day)
+	require.NoError(t, err)
+
+	// With snapshots the results are the same
+	assert.Equal(t, int64(1000), balanceAt(day.Add(-24*time.Hour)))
+	assert.Equal(t, int64(700), balanceAt(day.Add(-time.Minute)))
+	assert.Equal(t, int64(750), balanceAt(day))
+	assert.Equal(t, int64(950), balanceAt(day.Add(2*time.Hour)))
+
+	// Taking a snapshot again changes nothing
+	var count int
+	_, err = repo.TakeSnapshots(ctx, day)
+	require.NoError(t, err)
+	require.NoError(t, db.QueryRow("SELECT COUNT(*) FROM wallet_balance_snapshots WHERE wallet_id = $1", walletID).Scan(&count))
+	assert.Equal(t, 2, count)
+
+	_, err = repo.BalanceAt(ctx, uuid.New(), day)
+	assert.EqualError(t, err, "wallet not found")
+}
@@ -0,0 +1,88 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"ITKtest/internal/models"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSnapshotRepository(t *testing.T) {
+	walletID := uuid.New()
+	at := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	ctx := context.Background()
+
+	t.Run("balance at", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		assert.NoError(t, err)
+		defer db.Close()
+
+		mock.ExpectQuery(`FROM wallets w\s+LEFT JOIN LATERAL .* WHERE w.id = \$2`).
+			WithArgs(at, walletID).
+			WillReturnRows(sqlmock.NewRows([]string{"balance"}).AddRow(1500))
+
+		balance, err := NewSnapshotRepository(db).BalanceAt(ctx, walletID, at)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(1500), balance)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("unknown wallet", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		assert.NoError(t, err)
+		defer db.Close()
+
+		mock.ExpectQuery(`FROM wallets w`).WithArgs(at, walletID).WillReturnError(sql.ErrNoRows)
+
+		_, err = NewSnapshotRepository(db).BalanceAt(ctx, walletID, at)
+		assert.EqualError(t, err, "wallet not found")
+	})
+
+	t.Run("take snapshots", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		assert.NoError(t, err)
+		defer db.Close()
+
+		mock.ExpectExec(`INSERT INTO wallet_balance_snapshots .* ON CONFLICT \(wallet_id, as_of\) DO NOTHING`).
+			WithArgs(at).
+			WillReturnResult(sqlmock.NewResult(0, 3))
+
+		n, err := NewSnapshotRepository(db).TakeSnapshots(ctx, at)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(3), n)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+	t.Run("stream transactions", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		assert.NoError(t, err)
+		defer db.Close()
+
+		from, to := at.Add(-24*time.Hour), at
+		mock.ExpectQuery(`FROM wallet_transactions\s+WHERE wallet_id = \$1 AND created_at > \$2 AND created_at <= \$3\s+ORDER BY created_at, id`).
+			WithArgs(walletID, from, to).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "wallet_id", "operation_type", "amount", "balance_after", "created_at", "reference", "fee_for"}).
+				AddRow(1, walletID, "DEPOSIT", 500, 500, from.Add(time.Hour), "bank-1", nil).
+				AddRow(2, walletID, "WITHDRAW", 200, 300, from.Add(2*time.Hour), "", nil).
+				AddRow(3, walletID, "WITHDRAW", 5, 295, from.Add(2*time.Hour), "", 2))
+
+		var got []models.Transaction
+		err = NewSnapshotRepository(db).StreamTransactions(ctx, walletID, from, to, func(t models.Transaction) error {
+			got = append(got, t)
+			return nil
+		})
+		assert.NoError(t, err)
+		assert.Len(t, got, 3)
+		assert.Equal(t, "bank-1", got[0].Reference)
+		assert.Equal(t, models.WITHDRAW, got[1].OperationType)
+		assert.Equal(t, int64(-200), got[1].SignedAmount())
+		assert.Nil(t, got[1].FeeFor)
+		assert.Equal(t, int64(2), *got[2].FeeFor)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
@@ -0,0 +1,110 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"expvar"
+	"math/rand"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// TxConfig controls how multi-statement writes run. The zero value uses the database default
+// isolation level and does not retry.
+type TxConfig struct {
+	Isolation   sql.IsolationLevel
+	MaxRetries  int
+	BaseBackoff time.Duration
+	MaxBackoff  time.Duration
+}
+
+const (
+	sqlStateSerializationFailure = "40001"
+	sqlStateDeadlockDetected     = "40P01"
+)
+
+// txMetrics is published under /debug/vars
+var txMetrics = expvar.NewMap("db_transactions")
+
+// txRunner runs closures in a transaction and retries them when Postgres aborts the
+// transaction with a serialization failure or a deadlock
+type txRunner struct {
+	db  *sql.DB
+	cfg TxConfig
+}
+
+func newTxRunner(db *sql.DB, cfg TxConfig) *txRunner {
+	return &txRunner{db: db, cfg: cfg}
+}
+
+// run calls fn inside a transaction and commits it if fn returns nil. fn may be called several
+// times, so it must not have side effects outside tx that are unsafe to repeat.
+func (r *txRunner) run(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	for attempt := 0; ; attempt++ {
+		txMetrics.Add("started", 1)
+		err := r.runOnce(ctx, fn)
+		if err == nil {
+			txMetrics.Add("committed", 1)
+			return nil
+		}
+
+		code, retryable := retryableSQLState(err)
+		if !retryable {
+			txMetrics.Add("failed", 1)
+			return err
+		}
+		switch code {
+		case sqlStateSerializationFailure:
+			txMetrics.Add("serialization_failures", 1)
+		case sqlStateDeadlockDetected:
+			txMetrics.Add("deadlocks", 1)
+		}
+		if attempt >= r.cfg.MaxRetries {
+			txMetrics.Add("retries_exhausted", 1)
+			return err
+		}
+
+		txMetrics.Add("retries", 1)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(r.backoff(attempt)):
+		}
+	}
+}
+
+func (r *txRunner) runOnce(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	tx, err := r.db.BeginTx(ctx, &sql.TxOptions{Isolation: r.cfg.Isolation})
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// backoff returns a random delay up to BaseBackoff * 2^attempt, capped at MaxBackoff
+func (r *txRunner) backoff(attempt int) time.Duration {
+	if r.cfg.BaseBackoff <= 0 {
+		return 0
+	}
+	d := r.cfg.BaseBackoff << attempt
+	if d <= 0 || (r.cfg.MaxBackoff > 0 && d > r.cfg.MaxBackoff) {
+		d = r.cfg.MaxBackoff
+	}
+	return time.Duration(rand.Int63n(int64(d) + 1))
+}
+
+func retryableSQLState(err error) (string, bool) {
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) {
+		return "", false
+	}
+	code := string(pqErr.Code)
+	return code, code == sqlStateSerializationFailure || code == sqlStateDeadlockDetected
+}
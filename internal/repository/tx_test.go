@@ -0,0 +1,143 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"ITKtest/internal/models"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTxRunner_Run(t *testing.T) {
+	ctx := context.Background()
+	cfg := TxConfig{MaxRetries: 2, BaseBackoff: time.Millisecond, MaxBackoff: 2 * time.Millisecond}
+
+	t.Run("retries serialization failures and deadlocks", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		assert.NoError(t, err)
+		defer db.Close()
+
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE wallets").WillReturnError(&pq.Error{Code: "40P01"})
+		mock.ExpectRollback()
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE wallets").WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit().WillReturnError(&pq.Error{Code: "40001"})
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE wallets").WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		calls := 0
+		err = newTxRunner(db, cfg).run(ctx, func(tx *sql.Tx) error {
+			calls++
+			_, err := tx.ExecContext(ctx, "UPDATE wallets SET balance = 0")
+			return err
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, 3, calls)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("gives up after max retries", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		assert.NoError(t, err)
+		defer db.Close()
+
+		for i := 0; i < 3; i++ {
+			mock.ExpectBegin()
+			mock.ExpectRollback()
+		}
+
+		err = newTxRunner(db, cfg).run(ctx, func(tx *sql.Tx) error {
+			return &pq.Error{Code: "40001"}
+		})
+		var pqErr *pq.Error
+		assert.True(t, errors.As(err, &pqErr))
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("does not retry other errors", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		assert.NoError(t, err)
+		defer db.Close()
+
+		mock.ExpectBegin()
+		mock.ExpectRollback()
+
+		err = newTxRunner(db, cfg).run(ctx, func(tx *sql.Tx) error {
+			return &pq.Error{Code: "23505"}
+		})
+		assert.Error(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("retries wrapped batch errors", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		assert.NoError(t, err)
+		defer db.Close()
+
+		mock.ExpectBegin()
+		mock.ExpectRollback()
+		mock.ExpectBegin()
+		mock.ExpectCommit()
+
+		calls := 0
+		err = newTxRunner(db, cfg).run(ctx, func(tx *sql.Tx) error {
+			calls++
+			if calls == 1 {
+				return &models.BatchOperationError{Index: 1, Err: &pq.Error{Code: "40P01"}}
+			}
+			return nil
+		})
+		assert.NoError(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestTxRunner_Backoff(t *testing.T) {
+	r := newTxRunner(nil, TxConfig{BaseBackoff: 10 * time.Millisecond, MaxBackoff: 50 * time.Millisecond})
+	for attempt := 0; attempt < 10; attempt++ {
+		d := r.backoff(attempt)
+		assert.GreaterOrEqual(t, d, time.Duration(0))
+		assert.LessOrEqual(t, d, 50*time.Millisecond)
+	}
+	assert.Equal(t, time.Duration(0), newTxRunner(nil, TxConfig{}).backoff(3))
+}
+
+func TestWalletRepository_UpdateWalletBalance_RetriesDeadlock(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	walletID := uuid.New()
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT shard_count FROM wallets WHERE id = \$1`).
+		WithArgs(walletID).
+		WillReturnError(&pq.Error{Code: "40P01"})
+	mock.ExpectRollback()
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT shard_count FROM wallets WHERE id = \$1`).
+		WithArgs(walletID).
+		WillReturnRows(sqlmock.NewRows([]string{"shard_count"}).AddRow(0))
+	mock.ExpectQuery(`SELECT balance, shard_count, version FROM wallets WHERE id = \$1 FOR UPDATE`).
+		WithArgs(walletID).
+		WillReturnRows(sqlmock.NewRows([]string{"balance", "shard_count", "version"}).AddRow(100, 0, 1))
+	mock.ExpectExec(`UPDATE wallets SET balance`).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(`INSERT INTO wallet_transactions`).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectExec(`SELECT pg_notify`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`INSERT INTO outbox_events`).WillReturnResult(sqlmock.NewResult(1, 1))
+	expectAuditRecord(mock)
+	mock.ExpectCommit()
+
+	repo := NewWalletRepository(db, TxConfig{MaxRetries: 1})
+	err = repo.UpdateWalletBalance(context.Background(), walletID, 50, models.DEPOSIT)
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -1,10 +1,12 @@
 package repository
 
 import (
+	"bytes"
 	"context"
 	"database/sql"
+	"encoding/json"
 	"errors"
-	"fmt"
+	"sort"
 	"time"
 
 	"ITKtest/internal/models"
@@ -15,15 +17,30 @@ import (
 type WalletRepository interface {
 	CreateWallet(ctx context.Context, walletID uuid.UUID) error
 	GetWallet(ctx context.Context, walletID uuid.UUID) (*models.Wallet, error)
+	// ApplyOperation changes the balance by op, honoring its expected version and recording its
+	// reference in the journal. UpdateWalletBalance and UpdateWalletBalanceWithVersion are
+	// shorthands for operations without a reference.
+	ApplyOperation(ctx context.Context, op models.WalletOperationRequest) error
 	UpdateWalletBalance(ctx context.Context, walletID uuid.UUID, amount int64, operationType models.OperationType) error
+	UpdateWalletBalanceWithVersion(ctx context.Context, walletID uuid.UUID, amount int64, operationType models.OperationType, expectedVersion int64) error
+	UpdateWalletBalancesAtomic(ctx context.Context, operations []models.WalletOperationRequest) error
+	EnableSharding(ctx context.Context, walletID uuid.UUID, shards int) error
+	ListTransactions(ctx context.Context, walletID uuid.UUID, afterID int64, limit int) ([]models.Transaction, error)
+	// ResumeAfterID returns the id from which a stream resumed after entry lastID replays the
+	// journal of walletID, stepping back over entries that may have committed after lastID
+	ResumeAfterID(ctx context.Context, walletID uuid.UUID, lastID int64, window time.Duration) (int64, error)
 }
 
+// TransactionsChannel is the Postgres NOTIFY channel carrying every committed journal entry
+const TransactionsChannel = "wallet_transactions"
+
 type walletRepository struct {
 	db *sql.DB
+	tx *txRunner
 }
 
-func NewWalletRepository(db *sql.DB) WalletRepository {
-	return &walletRepository{db: db}
+func NewWalletRepository(db *sql.DB, txConfig TxConfig) WalletRepository {
+	return &walletRepository{db: db, tx: newTxRunner(db, txConfig)}
 }
 
 func (r *walletRepository) CreateWallet(ctx context.Context, walletID uuid.UUID) error {
@@ -38,7 +55,7 @@ func (r *walletRepository) CreateWallet(ctx context.Context, walletID uuid.UUID)
 
 func (r *walletRepository) GetWallet(ctx context.Context, walletID uuid.UUID) (*models.Wallet, error) {
 	query := `
-		SELECT id, balance, created_at, updated_at
+		SELECT id, balance, shard_count, version, created_at, updated_at
 		FROM wallets
 		WHERE id = $1
 	`
@@ -47,62 +64,375 @@ func (r *walletRepository) GetWallet(ctx context.Context, walletID uuid.UUID) (*
 	err := r.db.QueryRowContext(ctx, query, walletID).Scan(
 		&wallet.ID,
 		&wallet.Balance,
+		&wallet.ShardCount,
+		&wallet.Version,
 		&wallet.CreatedAt,
 		&wallet.UpdatedAt,
 	)
 	
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			return nil, fmt.Errorf("wallet not found")
+			return nil, ErrWalletNotFound
 		}
 		return nil, err
 	}
+
+	if wallet.ShardCount > 0 {
+		err := r.db.QueryRowContext(ctx,
+			"SELECT COALESCE(SUM(balance), 0) FROM wallet_shards WHERE wallet_id = $1",
+			walletID).Scan(&wallet.Balance)
+		if err != nil {
+			return nil, err
+		}
+	}
 	
 	return &wallet, nil
 }
 
 func (r *walletRepository) UpdateWalletBalance(ctx context.Context, walletID uuid.UUID, amount int64, operationType models.OperationType) error {
-	switch operationType {
+	return r.ApplyOperation(ctx, models.WalletOperationRequest{WalletID: walletID, OperationType: operationType, Amount: amount})
+}
+
+// UpdateWalletBalanceWithVersion applies the operation only if the wallet is still at expectedVersion
+func (r *walletRepository) UpdateWalletBalanceWithVersion(ctx context.Context, walletID uuid.UUID, amount int64, operationType models.OperationType, expectedVersion int64) error {
+	return r.ApplyOperation(ctx, models.WalletOperationRequest{WalletID: walletID, OperationType: operationType, Amount: amount, ExpectedVersion: &expectedVersion})
+}
+
+func (r *walletRepository) ApplyOperation(ctx context.Context, op models.WalletOperationRequest) error {
+	switch op.OperationType {
 	case models.DEPOSIT, models.WITHDRAW:
 		// Valid operation types
 	default:
 		return errors.New("invalid operation type")
 	}
 	
-	tx, err := r.db.BeginTx(ctx, nil)
+	return r.tx.run(ctx, func(tx *sql.Tx) error {
+		// The operation and fee wallets are locked in the order batches lock them. Hot wallets are
+		// skipped so that their fees are not serialized on the wallet row.
+		if op.Fee != nil {
+			for _, walletID := range sortedWalletIDs([]models.WalletOperationRequest{op}) {
+				if _, err := tx.ExecContext(ctx, "SELECT 1 FROM wallets WHERE id = $1 AND shard_count = 0 FOR UPDATE", walletID); err != nil {
+					return err
+				}
+			}
+		}
+		return applyOperation(ctx, tx, op)
+	})
+}
+
+// UpdateWalletBalancesAtomic applies all operations in one transaction: either every one of them
+// is committed or none. The rows of the operation and fee wallets are locked in sorted order up
+// front, so concurrent batches touching the same wallets cannot deadlock. Hot wallets are skipped,
+// as their shards are locked one at a time.
+func (r *walletRepository) UpdateWalletBalancesAtomic(ctx context.Context, operations []models.WalletOperationRequest) error {
+	for i, op := range operations {
+		switch op.OperationType {
+		case models.DEPOSIT, models.WITHDRAW:
+			// Valid operation types
+		default:
+			return &models.BatchOperationError{Index: i, Err: errors.New("invalid operation type")}
+		}
+	}
+
+	walletIDs := sortedWalletIDs(operations)
+
+	return r.tx.run(ctx, func(tx *sql.Tx) error {
+		for _, walletID := range walletIDs {
+			if _, err := tx.ExecContext(ctx, "SELECT 1 FROM wallets WHERE id = $1 AND shard_count = 0 FOR UPDATE", walletID); err != nil {
+				return err
+			}
+		}
+
+		for i, op := range operations {
+			if err := applyOperation(ctx, tx, op); err != nil {
+				return &models.BatchOperationError{Index: i, Err: err}
+			}
+		}
+		return nil
+	})
+}
+
+// sortedWalletIDs returns the distinct wallets changed by operations, fee wallets included, in
+// the order they are locked
+func sortedWalletIDs(operations []models.WalletOperationRequest) []uuid.UUID {
+	walletIDs := make([]uuid.UUID, 0, len(operations))
+	seen := make(map[uuid.UUID]bool, len(operations))
+	for _, op := range operations {
+		ids := []uuid.UUID{op.WalletID}
+		if op.Fee != nil {
+			ids = append(ids, op.Fee.FeeWalletID)
+		}
+		for _, walletID := range ids {
+			if !seen[walletID] {
+				seen[walletID] = true
+				walletIDs = append(walletIDs, walletID)
+			}
+		}
+	}
+	sort.Slice(walletIDs, func(i, j int) bool {
+		return bytes.Compare(walletIDs[i][:], walletIDs[j][:]) < 0
+	})
+	return walletIDs
+}
+
+// applyOperation changes the balance of one wallet inside tx and records the journal entry and
+// outbox event for it, followed by those of its fee. Withdrawals may spend bonus lots first.
+func applyOperation(ctx context.Context, tx *sql.Tx, op models.WalletOperationRequest) error {
+	var transactionID int64
+	var err error
+	if spendsBonus(op) {
+		transactionID, err = applyBonusWithdrawal(ctx, tx, op)
+	} else {
+		transactionID, err = applyEntry(ctx, tx, op, nil)
+	}
 	if err != nil {
 		return err
 	}
-	defer tx.Rollback()
+	return completeOperation(ctx, tx, op, transactionID)
+}
+
+// completeOperation charges the fee of the operation journaled as transactionID and claims its
+// idempotency key
+func completeOperation(ctx context.Context, tx *sql.Tx, op models.WalletOperationRequest, transactionID int64) error {
+	if op.Fee != nil {
+		if err := applyFee(ctx, tx, op, transactionID); err != nil {
+			return err
+		}
+	}
+	if op.IdempotencyKey == "" {
+		return nil
+	}
+	return claimIdempotencyKey(ctx, tx, op.IdempotencyKey, transactionID)
+}
+
+// ErrDuplicateOperation is returned for an operation whose idempotency key was already applied;
+// nothing is changed
+var ErrDuplicateOperation = errors.New("operation already applied")
 
-	// Lock the wallet row for update
-	var currentBalance int64
-	err = tx.QueryRowContext(ctx, "SELECT balance FROM wallets WHERE id = $1 FOR UPDATE", walletID).Scan(&currentBalance)
+// claimIdempotencyKey records key for the entry transactionID. A concurrent transaction with the
+// same key waits for this one, then finds the key taken.
+func claimIdempotencyKey(ctx context.Context, tx *sql.Tx, key string, transactionID int64) error {
+	res, err := tx.ExecContext(ctx, `
+		INSERT INTO operation_idempotency_keys (key, transaction_id)
+		VALUES ($1, $2)
+		ON CONFLICT (key) DO NOTHING
+	`, key, transactionID)
+	if err != nil {
+		return err
+	}
+	affected, err := res.RowsAffected()
 	if err != nil {
 		return err
 	}
+	if affected == 0 {
+		return ErrDuplicateOperation
+	}
+	return nil
+}
+
+// applyFee debits the fee of op from its wallet and credits it to the fee wallet, journaling
+// both as charged for the entry transactionID. The fee wallet is best sharded, as every charge
+// credits it.
+func applyFee(ctx context.Context, tx *sql.Tx, op models.WalletOperationRequest, transactionID int64) error {
+	charge := models.WalletOperationRequest{WalletID: op.WalletID, OperationType: models.WITHDRAW, Amount: op.Fee.Amount}
+	if _, err := applyEntry(ctx, tx, charge, &transactionID); err != nil {
+		return err
+	}
+	credit := models.WalletOperationRequest{WalletID: op.Fee.FeeWalletID, OperationType: models.DEPOSIT, Amount: op.Fee.Amount}
+	_, err := applyEntry(ctx, tx, credit, &transactionID)
+	return err
+}
+
+// applyEntry changes the balance of one wallet inside tx and records it, returning the id of
+// the journal entry
+func applyEntry(ctx context.Context, tx *sql.Tx, op models.WalletOperationRequest, feeFor *int64) (int64, error) {
+	walletID, amount, operationType, expectedVersion := op.WalletID, op.Amount, op.OperationType, op.ExpectedVersion
+
+	// Hot wallets keep their balance in shard rows and must not take the wallet row lock
+	var shardCount int
+	err := tx.QueryRowContext(ctx, "SELECT shard_count FROM wallets WHERE id = $1", walletID).Scan(&shardCount)
+	if err != nil {
+		return 0, err
+	}
+
+	var currentBalance, newBalance, version int64
+	if shardCount == 0 {
+		// Lock the wallet row for update; shard_count is re-read in case sharding was enabled meanwhile
+		err = tx.QueryRowContext(ctx, "SELECT balance, shard_count, version FROM wallets WHERE id = $1 FOR UPDATE", walletID).Scan(&currentBalance, &shardCount, &version)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	now := time.Now()
+	if shardCount > 0 {
+		if expectedVersion != nil {
+			return 0, ErrShardedVersion
+		}
+		currentBalance, newBalance, err = applyShardedOperation(ctx, tx, walletID, shardCount, amount, operationType, now)
+		if err != nil {
+			return 0, err
+		}
+	} else {
+		if expectedVersion != nil && *expectedVersion != version {
+			return 0, ErrVersionConflict
+		}
+
+		newBalance, err = calculateBalance(currentBalance, amount, operationType)
+		if err != nil {
+			return 0, err
+		}
+
+		// Update balance
+		_, err = tx.ExecContext(ctx, 
+			"UPDATE wallets SET balance = $1, updated_at = $2, version = version + 1 WHERE id = $3",
+			newBalance, now, walletID)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	return recordOperation(ctx, tx, op, feeFor, currentBalance, newBalance, now)
+}
 
-	// Calculate new balance
-	var newBalance int64
+// Errors returned by every WalletRepository implementation for operations it refuses
+var (
+	ErrWalletNotFound    = errors.New("wallet not found")
+	ErrInsufficientFunds = errors.New("insufficient funds")
+	ErrVersionConflict   = errors.New("version conflict")
+	ErrShardedVersion    = errors.New("expected version is not supported for sharded wallets")
+)
+
+func calculateBalance(currentBalance, amount int64, operationType models.OperationType) (int64, error) {
 	switch operationType {
 	case models.DEPOSIT:
-		newBalance = currentBalance + amount
+		return currentBalance + amount, nil
 	case models.WITHDRAW:
-		newBalance = currentBalance - amount
+		newBalance := currentBalance - amount
 		if newBalance < 0 {
-			return errors.New("insufficient funds")
+			return 0, ErrInsufficientFunds
 		}
+		return newBalance, nil
 	default:
-		return errors.New("invalid operation type")
+		return 0, errors.New("invalid operation type")
+	}
+}
+
+// recordOperation writes the journal entry, outbox event and audit record of an applied
+// operation and returns the id of the entry. feeFor is set for the entries of a fee.
+func recordOperation(ctx context.Context, tx *sql.Tx, op models.WalletOperationRequest, feeFor *int64, balanceBefore, balanceAfter int64, now time.Time) (int64, error) {
+	walletID, amount, operationType := op.WalletID, op.Amount, op.OperationType
+
+	// Journal the operation; NOTIFY is delivered to listeners only once the transaction commits
+	transaction := models.Transaction{
+		WalletID:      walletID,
+		OperationType: operationType,
+		Amount:        amount,
+		BalanceAfter:  balanceAfter,
+		CreatedAt:     now,
+		Reference:     op.Reference,
+		FeeFor:        feeFor,
+	}
+	if err := insertTransaction(ctx, tx, &transaction); err != nil {
+		return 0, err
+	}
+
+	// Record the event in the same transaction so it is published only if the balance change is committed
+	event := models.WalletBalanceChangedEvent{
+		TransactionID: transaction.ID,
+		WalletID:      walletID,
+		OperationType: operationType,
+		Amount:        amount,
+		BalanceBefore: balanceBefore,
+		BalanceAfter:  balanceAfter,
+		OccurredAt:    now,
+		Reference:     op.Reference,
+		FeeFor:        feeFor,
+	}
+	if err := insertOutboxEvent(ctx, tx, walletID, models.EventWalletBalanceChanged, event); err != nil {
+		return 0, err
 	}
 
-	// Update balance
-	_, err = tx.ExecContext(ctx, 
-		"UPDATE wallets SET balance = $1, updated_at = $2 WHERE id = $3",
-		newBalance, time.Now(), walletID)
+	action := models.AuditWalletDeposit
+	if operationType == models.WITHDRAW {
+		action = models.AuditWalletWithdraw
+	}
+	err := insertAuditRecord(ctx, tx, action, walletID.String(),
+		auditBalance{Balance: balanceBefore},
+		auditBalance{Balance: balanceAfter, TransactionID: transaction.ID, Reference: op.Reference, FeeFor: feeFor})
+	return transaction.ID, err
+}
+
+// auditBalance is the audited state of a wallet around an operation
+type auditBalance struct {
+	Balance       int64 `json:"balance"`
+	TransactionID int64  `json:"transactionId,omitempty"`
+	Reference     string `json:"reference,omitempty"`
+	FeeFor        *int64 `json:"feeFor,omitempty"`
+}
+
+func insertTransaction(ctx context.Context, tx *sql.Tx, transaction *models.Transaction) error {
+	err := tx.QueryRowContext(ctx, `
+		INSERT INTO wallet_transactions (wallet_id, operation_type, amount, balance_after, created_at, reference, fee_for)
+		VALUES ($1, $2, $3, $4, $5, NULLIF($6, ''), $7)
+		RETURNING id
+	`, transaction.WalletID, transaction.OperationType, transaction.Amount, transaction.BalanceAfter, transaction.CreatedAt, transaction.Reference, transaction.FeeFor).Scan(&transaction.ID)
+	if err != nil {
+		return err
+	}
+
+	payload, err := json.Marshal(transaction)
 	if err != nil {
 		return err
 	}
+	_, err = tx.ExecContext(ctx, "SELECT pg_notify($1, $2)", TransactionsChannel, string(payload))
+	return err
+}
+
+// ListTransactions returns journal entries of a wallet with id greater than afterID in id order
+func (r *walletRepository) ListTransactions(ctx context.Context, walletID uuid.UUID, afterID int64, limit int) ([]models.Transaction, error) {
+	query := `
+		SELECT id, wallet_id, operation_type, amount, balance_after, created_at, COALESCE(reference, ''), fee_for
+		FROM wallet_transactions
+		WHERE wallet_id = $1 AND id > $2
+		ORDER BY id
+		LIMIT $3
+	`
+	rows, err := r.db.QueryContext(ctx, query, walletID, afterID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	transactions := []models.Transaction{}
+	for rows.Next() {
+		var t models.Transaction
+		if err := rows.Scan(&t.ID, &t.WalletID, &t.OperationType, &t.Amount, &t.BalanceAfter, &t.CreatedAt, &t.Reference, &t.FeeFor); err != nil {
+			return nil, err
+		}
+		transactions = append(transactions, t)
+	}
+	return transactions, rows.Err()
+}
 
-	return tx.Commit()
-}
\ No newline at end of file
+// ResumeAfterID steps back to just before the first entry of walletID created at most window
+// before entry lastID. Entries of sharded wallets take their ids before they commit, so one with
+// a lower id than lastID may have become visible after it; those created longer than window
+// before lastID are assumed to have committed by then.
+func (r *walletRepository) ResumeAfterID(ctx context.Context, walletID uuid.UUID, lastID int64, window time.Duration) (int64, error) {
+	var firstID sql.NullInt64
+	err := r.db.QueryRowContext(ctx, `
+		SELECT MIN(t.id)
+		FROM wallet_transactions last
+		JOIN wallet_transactions t
+			ON t.wallet_id = last.wallet_id AND t.id < last.id AND t.created_at >= last.created_at - $3 * INTERVAL '1 microsecond'
+		WHERE last.id = $2 AND last.wallet_id = $1
+	`, walletID, lastID, window.Microseconds()).Scan(&firstID)
+	if err != nil {
+		return 0, err
+	}
+	if !firstID.Valid {
+		return lastID, nil
+	}
+	return firstID.Int64 - 1, nil
+}
@@ -0,0 +1,77 @@
+package repository_test
+
+import (
+	"database/sql"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"ITKtest/database"
+	"ITKtest/internal/repository"
+	"ITKtest/internal/repository/repositorytest"
+
+	_ "github.com/lib/pq"
+)
+
+func TestMemoryWalletRepository_Conformance(t *testing.T) {
+	repositorytest.TestWalletRepository(t, func(t *testing.T) repository.WalletRepository {
+		return repository.NewMemoryWalletRepository(nil)
+	})
+}
+
+func TestSQLiteWalletRepository_Conformance(t *testing.T) {
+	repositorytest.TestWalletRepository(t, func(t *testing.T) repository.WalletRepository {
+		db, err := database.ConnectSQLite(filepath.Join(t.TempDir(), "wallet.db"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { db.Close() })
+
+		migrator, err := database.NewSQLiteMigrator(db)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := migrator.Up(); err != nil {
+			t.Fatal(err)
+		}
+		return repository.NewSQLiteWalletRepository(db, nil)
+	})
+}
+
+// The Postgres runs need a real server, see openBenchDB
+func TestPostgresWalletRepository_Conformance(t *testing.T) {
+	db := openConformanceDB(t)
+
+	t.Run("pessimistic", func(t *testing.T) {
+		repositorytest.TestWalletRepository(t, func(t *testing.T) repository.WalletRepository {
+			return repository.NewWalletRepository(db, repository.TxConfig{MaxRetries: 3})
+		})
+	})
+	t.Run("optimistic", func(t *testing.T) {
+		repositorytest.TestWalletRepository(t, func(t *testing.T) repository.WalletRepository {
+			return repository.NewOptimisticWalletRepository(db, 100, repository.TxConfig{MaxRetries: 3})
+		})
+	})
+}
+
+func openConformanceDB(t *testing.T) *sql.DB {
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	migrator, err := database.NewMigrator(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := migrator.Up(); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
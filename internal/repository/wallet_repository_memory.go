@@ -0,0 +1,297 @@
+package repository
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"ITKtest/internal/models"
+
+	"github.com/google/uuid"
+)
+
+// memoryWallet is the in-memory counterpart of a wallets row; mu plays the role of the row lock
+type memoryWallet struct {
+	mu     sync.Mutex
+	wallet models.Wallet
+}
+
+// memoryWalletRepository keeps wallets and the journal in process memory. Operations on one
+// wallet are serialized by its lock and batches lock their wallets in sorted order, as the
+// Postgres repository does. Sharding only records the shard count, since there is no row
+// contention to spread. Nothing survives a restart and no outbox events are written.
+type memoryWalletRepository struct {
+	mu      sync.RWMutex
+	wallets map[uuid.UUID]*memoryWallet
+
+	journalMu    sync.RWMutex
+	transactions []models.Transaction
+
+	// publish, if set, receives every journal entry once it is recorded
+	publish func(models.Transaction)
+}
+
+func NewMemoryWalletRepository(publish func(models.Transaction)) WalletRepository {
+	return &memoryWalletRepository{
+		wallets: make(map[uuid.UUID]*memoryWallet),
+		publish: publish,
+	}
+}
+
+func (r *memoryWalletRepository) CreateWallet(ctx context.Context, walletID uuid.UUID) error {
+	r.getOrCreate(walletID)
+	return nil
+}
+
+func (r *memoryWalletRepository) getOrCreate(walletID uuid.UUID) *memoryWallet {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	w, ok := r.wallets[walletID]
+	if !ok {
+		now := time.Now()
+		w = &memoryWallet{wallet: models.Wallet{ID: walletID, CreatedAt: now, UpdatedAt: now}}
+		r.wallets[walletID] = w
+	}
+	return w
+}
+
+func (r *memoryWalletRepository) get(walletID uuid.UUID) (*memoryWallet, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	w, ok := r.wallets[walletID]
+	if !ok {
+		return nil, ErrWalletNotFound
+	}
+	return w, nil
+}
+
+func (r *memoryWalletRepository) GetWallet(ctx context.Context, walletID uuid.UUID) (*models.Wallet, error) {
+	w, err := r.get(walletID)
+	if err != nil {
+		return nil, err
+	}
+
+	w.mu.Lock()
+	wallet := w.wallet
+	w.mu.Unlock()
+	return &wallet, nil
+}
+
+func (r *memoryWalletRepository) UpdateWalletBalance(ctx context.Context, walletID uuid.UUID, amount int64, operationType models.OperationType) error {
+	return r.ApplyOperation(ctx, models.WalletOperationRequest{WalletID: walletID, OperationType: operationType, Amount: amount})
+}
+
+func (r *memoryWalletRepository) UpdateWalletBalanceWithVersion(ctx context.Context, walletID uuid.UUID, amount int64, operationType models.OperationType, expectedVersion int64) error {
+	return r.ApplyOperation(ctx, models.WalletOperationRequest{WalletID: walletID, OperationType: operationType, Amount: amount, ExpectedVersion: &expectedVersion})
+}
+
+func (r *memoryWalletRepository) ApplyOperation(ctx context.Context, op models.WalletOperationRequest) error {
+	err := r.apply([]models.WalletOperationRequest{op})
+	var batchErr *models.BatchOperationError
+	if errors.As(err, &batchErr) {
+		return batchErr.Err
+	}
+	return err
+}
+
+func (r *memoryWalletRepository) UpdateWalletBalancesAtomic(ctx context.Context, operations []models.WalletOperationRequest) error {
+	return r.apply(operations)
+}
+
+// memoryEntry is a journal entry waiting to be recorded; feeOf is the index of the entry a fee
+// entry was charged for, or -1
+type memoryEntry struct {
+	transaction models.Transaction
+	feeOf       int
+}
+
+// apply locks every wallet of the operations and their fees in sorted order, applies the
+// operations to copies and stores them only if all operations succeed
+func (r *memoryWalletRepository) apply(operations []models.WalletOperationRequest) error {
+	for i, op := range operations {
+		switch op.OperationType {
+		case models.DEPOSIT, models.WITHDRAW:
+			// Valid operation types
+		default:
+			return &models.BatchOperationError{Index: i, Err: errors.New("invalid operation type")}
+		}
+	}
+
+	walletIDs := make([]uuid.UUID, 0, len(operations))
+	seen := make(map[uuid.UUID]bool, len(operations))
+	for _, op := range operations {
+		ids := []uuid.UUID{op.WalletID}
+		if op.Fee != nil {
+			ids = append(ids, op.Fee.FeeWalletID)
+		}
+		for _, walletID := range ids {
+			if !seen[walletID] {
+				seen[walletID] = true
+				walletIDs = append(walletIDs, walletID)
+			}
+		}
+	}
+	sort.Slice(walletIDs, func(i, j int) bool {
+		return bytes.Compare(walletIDs[i][:], walletIDs[j][:]) < 0
+	})
+
+	locked := make(map[uuid.UUID]*memoryWallet, len(walletIDs))
+	defer func() {
+		for _, w := range locked {
+			w.mu.Unlock()
+		}
+	}()
+	for _, walletID := range walletIDs {
+		w, err := r.get(walletID)
+		if err != nil {
+			continue // reported by the first operation on this wallet below
+		}
+		w.mu.Lock()
+		locked[walletID] = w
+	}
+
+	pending := make(map[uuid.UUID]models.Wallet, len(locked))
+	for walletID, w := range locked {
+		pending[walletID] = w.wallet
+	}
+
+	entries := make([]memoryEntry, 0, len(operations))
+	applyEntry := func(op models.WalletOperationRequest, feeOf int) error {
+		wallet, ok := pending[op.WalletID]
+		if !ok {
+			return ErrWalletNotFound
+		}
+		if err := applyMemoryOperation(&wallet, op.Amount, op.OperationType, op.ExpectedVersion); err != nil {
+			return err
+		}
+		pending[op.WalletID] = wallet
+
+		entries = append(entries, memoryEntry{
+			transaction: models.Transaction{
+				WalletID:      op.WalletID,
+				OperationType: op.OperationType,
+				Amount:        op.Amount,
+				BalanceAfter:  wallet.Balance,
+				CreatedAt:     wallet.UpdatedAt,
+				Reference:     op.Reference,
+			},
+			feeOf: feeOf,
+		})
+		return nil
+	}
+	for i, op := range operations {
+		if err := applyEntry(op, -1); err != nil {
+			return &models.BatchOperationError{Index: i, Err: err}
+		}
+		if op.Fee == nil {
+			continue
+		}
+		feeOf := len(entries) - 1
+		if err := applyEntry(models.WalletOperationRequest{WalletID: op.WalletID, OperationType: models.WITHDRAW, Amount: op.Fee.Amount}, feeOf); err != nil {
+			return &models.BatchOperationError{Index: i, Err: err}
+		}
+		if err := applyEntry(models.WalletOperationRequest{WalletID: op.Fee.FeeWalletID, OperationType: models.DEPOSIT, Amount: op.Fee.Amount}, feeOf); err != nil {
+			return &models.BatchOperationError{Index: i, Err: err}
+		}
+	}
+
+	for walletID, wallet := range pending {
+		locked[walletID].wallet = wallet
+	}
+	r.record(entries)
+	return nil
+}
+
+// applyMemoryOperation mirrors applyOperation: sharded wallets reject an expected version and
+// do not bump the version
+func applyMemoryOperation(wallet *models.Wallet, amount int64, operationType models.OperationType, expectedVersion *int64) error {
+	if expectedVersion != nil {
+		if wallet.ShardCount > 0 {
+			return ErrShardedVersion
+		}
+		if *expectedVersion != wallet.Version {
+			return ErrVersionConflict
+		}
+	}
+
+	newBalance, err := calculateBalance(wallet.Balance, amount, operationType)
+	if err != nil {
+		return err
+	}
+
+	wallet.Balance = newBalance
+	wallet.UpdatedAt = time.Now()
+	if wallet.ShardCount == 0 {
+		wallet.Version++
+	}
+	return nil
+}
+
+// record appends entries to the journal, assigning their ids
+func (r *memoryWalletRepository) record(entries []memoryEntry) {
+	transactions := make([]models.Transaction, len(entries))
+	r.journalMu.Lock()
+	for i, entry := range entries {
+		transactions[i] = entry.transaction
+		transactions[i].ID = int64(len(r.transactions)) + 1
+		if entry.feeOf >= 0 {
+			feeFor := transactions[entry.feeOf].ID
+			transactions[i].FeeFor = &feeFor
+		}
+		r.transactions = append(r.transactions, transactions[i])
+	}
+	r.journalMu.Unlock()
+
+	if r.publish != nil {
+		for _, transaction := range transactions {
+			r.publish(transaction)
+		}
+	}
+}
+
+func (r *memoryWalletRepository) EnableSharding(ctx context.Context, walletID uuid.UUID, shards int) error {
+	if shards <= 0 {
+		return errors.New("shard count must be positive")
+	}
+
+	w := r.getOrCreate(walletID)
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if shards < w.wallet.ShardCount {
+		return errors.New("shard count cannot be decreased")
+	}
+	if shards > w.wallet.ShardCount {
+		w.wallet.ShardCount = shards
+		w.wallet.UpdatedAt = time.Now()
+	}
+	return nil
+}
+
+// ResumeAfterID returns lastID: entries are journaled under journalMu, so ids follow commit order
+func (r *memoryWalletRepository) ResumeAfterID(ctx context.Context, walletID uuid.UUID, lastID int64, window time.Duration) (int64, error) {
+	return lastID, nil
+}
+
+func (r *memoryWalletRepository) ListTransactions(ctx context.Context, walletID uuid.UUID, afterID int64, limit int) ([]models.Transaction, error) {
+	r.journalMu.RLock()
+	defer r.journalMu.RUnlock()
+
+	transactions := []models.Transaction{}
+	// Ids are positions in the journal, so entries before afterID can be skipped
+	start := afterID
+	if start < 0 {
+		start = 0
+	}
+	for i := start; i < int64(len(r.transactions)) && len(transactions) < limit; i++ {
+		if r.transactions[i].WalletID == walletID {
+			transactions = append(transactions, r.transactions[i])
+		}
+	}
+	return transactions, nil
+}
@@ -0,0 +1,104 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"ITKtest/internal/models"
+
+	"github.com/google/uuid"
+)
+
+// optimisticWalletRepository updates balances with compare-and-set on wallets.version instead of
+// holding a row lock for the whole transaction. A lost race is retried up to maxRetries times;
+// an explicit expected version is never retried. Batches, sharded wallets, bonus purchases and
+// operations charged a fee keep their locking behaviour.
+type optimisticWalletRepository struct {
+	*walletRepository
+	maxRetries int
+}
+
+func NewOptimisticWalletRepository(db *sql.DB, maxRetries int, txConfig TxConfig) WalletRepository {
+	return &optimisticWalletRepository{
+		walletRepository: &walletRepository{db: db, tx: newTxRunner(db, txConfig)},
+		maxRetries:       maxRetries,
+	}
+}
+
+func (r *optimisticWalletRepository) UpdateWalletBalance(ctx context.Context, walletID uuid.UUID, amount int64, operationType models.OperationType) error {
+	return r.ApplyOperation(ctx, models.WalletOperationRequest{WalletID: walletID, OperationType: operationType, Amount: amount})
+}
+
+func (r *optimisticWalletRepository) UpdateWalletBalanceWithVersion(ctx context.Context, walletID uuid.UUID, amount int64, operationType models.OperationType, expectedVersion int64) error {
+	return r.ApplyOperation(ctx, models.WalletOperationRequest{WalletID: walletID, OperationType: operationType, Amount: amount, ExpectedVersion: &expectedVersion})
+}
+
+func (r *optimisticWalletRepository) ApplyOperation(ctx context.Context, op models.WalletOperationRequest) error {
+	switch op.OperationType {
+	case models.DEPOSIT, models.WITHDRAW:
+		// Valid operation types
+	default:
+		return errors.New("invalid operation type")
+	}
+	// The fee wallet is locked too, and like in batches the wallets are locked in sorted order
+	if op.Fee != nil {
+		return r.walletRepository.ApplyOperation(ctx, op)
+	}
+
+	for attempt := 0; ; attempt++ {
+		err := r.tryUpdate(ctx, op)
+		if !errors.Is(err, ErrVersionConflict) || op.ExpectedVersion != nil || attempt >= r.maxRetries {
+			return err
+		}
+	}
+}
+
+func (r *optimisticWalletRepository) tryUpdate(ctx context.Context, op models.WalletOperationRequest) error {
+	walletID, amount, operationType, expectedVersion := op.WalletID, op.Amount, op.OperationType, op.ExpectedVersion
+
+	return r.tx.run(ctx, func(tx *sql.Tx) error {
+		var currentBalance, version int64
+		var shardCount int
+		err := tx.QueryRowContext(ctx, "SELECT balance, shard_count, version FROM wallets WHERE id = $1", walletID).Scan(&currentBalance, &shardCount, &version)
+		if err != nil {
+			return err
+		}
+
+		// Spending bonus lots takes their row locks, and with them the wallet row lock
+		if shardCount > 0 || spendsBonus(op) {
+			return applyOperation(ctx, tx, op)
+		}
+
+		if expectedVersion != nil && *expectedVersion != version {
+			return ErrVersionConflict
+		}
+
+		newBalance, err := calculateBalance(currentBalance, amount, operationType)
+		if err != nil {
+			return err
+		}
+
+		now := time.Now()
+		res, err := tx.ExecContext(ctx,
+			"UPDATE wallets SET balance = $1, updated_at = $2, version = version + 1 WHERE id = $3 AND version = $4",
+			newBalance, now, walletID, version)
+		if err != nil {
+			return err
+		}
+		affected, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if affected == 0 {
+			return ErrVersionConflict
+		}
+
+		transactionID, err := recordOperation(ctx, tx, op, nil, currentBalance, newBalance, now)
+		if err != nil {
+			return err
+		}
+		return completeOperation(ctx, tx, op, transactionID)
+	})
+}
@@ -0,0 +1,102 @@
+package repository
+
+import (
+	"context"
+	"testing"
+
+	"ITKtest/internal/models"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOptimisticWalletRepository_UpdateWalletBalance(t *testing.T) {
+	walletID := uuid.New()
+	ctx := context.Background()
+
+	expectRead := func(mock sqlmock.Sqlmock, balance, version int) {
+		mock.ExpectBegin()
+		mock.ExpectQuery(`SELECT balance, shard_count, version FROM wallets WHERE id = \$1$`).
+			WithArgs(walletID).
+			WillReturnRows(sqlmock.NewRows([]string{"balance", "shard_count", "version"}).AddRow(balance, 0, version))
+	}
+	expectCAS := func(mock sqlmock.Sqlmock, newBalance, version int, affected int64) {
+		mock.ExpectExec(`UPDATE wallets SET balance = \$1, updated_at = \$2, version = version \+ 1 WHERE id = \$3 AND version = \$4`).
+			WithArgs(newBalance, sqlmock.AnyArg(), walletID, version).
+			WillReturnResult(sqlmock.NewResult(0, affected))
+	}
+	expectRecord := func(mock sqlmock.Sqlmock) {
+		mock.ExpectQuery(`INSERT INTO wallet_transactions`).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+		mock.ExpectExec(`SELECT pg_notify`).WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec(`INSERT INTO outbox_events`).WillReturnResult(sqlmock.NewResult(1, 1))
+		expectAuditRecord(mock)
+		mock.ExpectCommit()
+	}
+
+	t.Run("retries after losing a race", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		assert.NoError(t, err)
+		defer db.Close()
+
+		expectRead(mock, 100, 3)
+		expectCAS(mock, 150, 3, 0)
+		mock.ExpectRollback()
+		expectRead(mock, 120, 4)
+		expectCAS(mock, 170, 4, 1)
+		expectRecord(mock)
+
+		err = NewOptimisticWalletRepository(db, 3, TxConfig{}).UpdateWalletBalance(ctx, walletID, 50, models.DEPOSIT)
+		assert.NoError(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("gives up after max retries", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		assert.NoError(t, err)
+		defer db.Close()
+
+		for version := 0; version < 2; version++ {
+			expectRead(mock, 100, version)
+			expectCAS(mock, 150, version, 0)
+			mock.ExpectRollback()
+		}
+
+		err = NewOptimisticWalletRepository(db, 1, TxConfig{}).UpdateWalletBalance(ctx, walletID, 50, models.DEPOSIT)
+		assert.EqualError(t, err, "version conflict")
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("stale expected version is not retried", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		assert.NoError(t, err)
+		defer db.Close()
+
+		expectRead(mock, 100, 5)
+		mock.ExpectRollback()
+
+		err = NewOptimisticWalletRepository(db, 3, TxConfig{}).UpdateWalletBalanceWithVersion(ctx, walletID, 50, models.WITHDRAW, 4)
+		assert.EqualError(t, err, "version conflict")
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestWalletRepository_UpdateWalletBalanceWithVersion(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	walletID := uuid.New()
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT shard_count FROM wallets WHERE id = \$1`).
+		WithArgs(walletID).
+		WillReturnRows(sqlmock.NewRows([]string{"shard_count"}).AddRow(0))
+	mock.ExpectQuery(`SELECT balance, shard_count, version FROM wallets WHERE id = \$1 FOR UPDATE`).
+		WithArgs(walletID).
+		WillReturnRows(sqlmock.NewRows([]string{"balance", "shard_count", "version"}).AddRow(100, 0, 2))
+	mock.ExpectRollback()
+
+	err = NewWalletRepository(db, TxConfig{}).UpdateWalletBalanceWithVersion(context.Background(), walletID, 50, models.DEPOSIT, 1)
+	assert.EqualError(t, err, "version conflict")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,143 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"expvar"
+	"log"
+	"sync/atomic"
+	"time"
+
+	"ITKtest/internal/models"
+
+	"github.com/google/uuid"
+)
+
+type primaryReadsKey struct{}
+
+// WithPrimaryReads marks ctx as requiring read-your-writes consistency, so reads skip the replica
+func WithPrimaryReads(ctx context.Context) context.Context {
+	return context.WithValue(ctx, primaryReadsKey{}, true)
+}
+
+// PrimaryReads reports whether ctx was marked by WithPrimaryReads
+func PrimaryReads(ctx context.Context) bool {
+	v, _ := ctx.Value(primaryReadsKey{}).(bool)
+	return v
+}
+
+// replicaMetrics is published under /debug/vars
+var replicaMetrics = expvar.NewMap("read_replica")
+
+// ReplicaMonitor tracks whether a read replica is reachable and within the allowed replay lag
+type ReplicaMonitor struct {
+	db      *sql.DB
+	maxLag  time.Duration
+	healthy atomic.Bool
+}
+
+func NewReplicaMonitor(db *sql.DB, maxLag time.Duration) *ReplicaMonitor {
+	return &ReplicaMonitor{db: db, maxLag: maxLag}
+}
+
+// Healthy reports the result of the last check; a new monitor is unhealthy until checked
+func (m *ReplicaMonitor) Healthy() bool {
+	return m.healthy.Load()
+}
+
+// Run checks the replica immediately and then every interval until ctx is cancelled
+func (m *ReplicaMonitor) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		m.Check(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Check measures the replay lag of the replica. A replica that has replayed everything it
+// received counts as up to date even if the primary has been idle for a while.
+func (m *ReplicaMonitor) Check(ctx context.Context) {
+	var lagSeconds sql.NullFloat64
+	err := m.db.QueryRowContext(ctx, `
+		SELECT CASE
+			WHEN pg_last_wal_receive_lsn() = pg_last_wal_replay_lsn() THEN 0
+			ELSE EXTRACT(EPOCH FROM now() - pg_last_xact_replay_timestamp())
+		END
+	`).Scan(&lagSeconds)
+
+	healthy := err == nil && time.Duration(lagSeconds.Float64*float64(time.Second)) <= m.maxLag
+	if m.healthy.Swap(healthy) != healthy {
+		if healthy {
+			log.Println("Read replica is available")
+		} else {
+			log.Printf("Read replica is unavailable, reading from primary (lag %.1fs, error %v)", lagSeconds.Float64, err)
+		}
+	}
+}
+
+func (m *ReplicaMonitor) markUnavailable(err error) {
+	if m.healthy.Swap(false) {
+		log.Printf("Read replica is unavailable, reading from primary: %v", err)
+	}
+}
+
+// replicaWalletRepository sends GetWallet and ListTransactions to a read replica and everything
+// else to the primary. Reads go to the primary when the context asks for read-your-writes, when
+// the replica is unhealthy, and when a replica read fails or misses the wallet, since it may
+// simply not have replayed the wallet's creation yet.
+type replicaWalletRepository struct {
+	WalletRepository
+	replica WalletRepository
+	monitor *ReplicaMonitor
+}
+
+func NewReplicaWalletRepository(primary, replica WalletRepository, monitor *ReplicaMonitor) WalletRepository {
+	return &replicaWalletRepository{
+		WalletRepository: primary,
+		replica:          replica,
+		monitor:          monitor,
+	}
+}
+
+func (r *replicaWalletRepository) useReplica(ctx context.Context) bool {
+	return !PrimaryReads(ctx) && r.monitor.Healthy()
+}
+
+func (r *replicaWalletRepository) GetWallet(ctx context.Context, walletID uuid.UUID) (*models.Wallet, error) {
+	if r.useReplica(ctx) {
+		wallet, err := r.replica.GetWallet(ctx, walletID)
+		if err == nil {
+			replicaMetrics.Add("replica_reads", 1)
+			return wallet, nil
+		}
+		if !errors.Is(err, ErrWalletNotFound) && ctx.Err() == nil {
+			r.monitor.markUnavailable(err)
+		}
+		replicaMetrics.Add("primary_fallbacks", 1)
+	}
+	replicaMetrics.Add("primary_reads", 1)
+	return r.WalletRepository.GetWallet(ctx, walletID)
+}
+
+func (r *replicaWalletRepository) ListTransactions(ctx context.Context, walletID uuid.UUID, afterID int64, limit int) ([]models.Transaction, error) {
+	if r.useReplica(ctx) {
+		transactions, err := r.replica.ListTransactions(ctx, walletID, afterID, limit)
+		if err == nil {
+			replicaMetrics.Add("replica_reads", 1)
+			return transactions, nil
+		}
+		if ctx.Err() == nil {
+			r.monitor.markUnavailable(err)
+		}
+		replicaMetrics.Add("primary_fallbacks", 1)
+	}
+	replicaMetrics.Add("primary_reads", 1)
+	return r.WalletRepository.ListTransactions(ctx, walletID, afterID, limit)
+}
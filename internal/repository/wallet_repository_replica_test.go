@@ -0,0 +1,128 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"ITKtest/internal/models"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestReplicaMonitor_Check(t *testing.T) {
+	tests := []struct {
+		name    string
+		lag     interface{}
+		err     error
+		healthy bool
+	}{
+		{name: "caught up", lag: 0.0, healthy: true},
+		{name: "lag within limit", lag: 2.5, healthy: true},
+		{name: "lag beyond limit", lag: 12.0, healthy: false},
+		{name: "not replaying", lag: nil, healthy: true},
+		{name: "unreachable", err: errors.New("connection refused"), healthy: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			assert.NoError(t, err)
+			defer db.Close()
+
+			query := mock.ExpectQuery(`SELECT CASE WHEN pg_last_wal_receive_lsn\(\) = pg_last_wal_replay_lsn\(\)`)
+			if tt.err != nil {
+				query.WillReturnError(tt.err)
+			} else {
+				query.WillReturnRows(sqlmock.NewRows([]string{"lag"}).AddRow(tt.lag))
+			}
+
+			monitor := NewReplicaMonitor(db, 5*time.Second)
+			monitor.Check(context.Background())
+			assert.Equal(t, tt.healthy, monitor.Healthy())
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func healthyMonitor() *ReplicaMonitor {
+	monitor := &ReplicaMonitor{}
+	monitor.healthy.Store(true)
+	return monitor
+}
+
+func TestReplicaWalletRepository_GetWallet(t *testing.T) {
+	walletID := uuid.New()
+	primaryWallet := &models.Wallet{ID: walletID, Balance: 200}
+	replicaWallet := &models.Wallet{ID: walletID, Balance: 100}
+
+	t.Run("reads from a healthy replica", func(t *testing.T) {
+		primary, replica := &MockWalletRepository{}, &MockWalletRepository{}
+		replica.On("GetWallet", mock.Anything, walletID).Return(replicaWallet, nil)
+
+		wallet, err := NewReplicaWalletRepository(primary, replica, healthyMonitor()).GetWallet(context.Background(), walletID)
+		assert.NoError(t, err)
+		assert.Equal(t, replicaWallet, wallet)
+		primary.AssertExpectations(t)
+		replica.AssertExpectations(t)
+	})
+
+	t.Run("read-your-writes uses the primary", func(t *testing.T) {
+		primary, replica := &MockWalletRepository{}, &MockWalletRepository{}
+		primary.On("GetWallet", mock.Anything, walletID).Return(primaryWallet, nil)
+
+		ctx := WithPrimaryReads(context.Background())
+		wallet, err := NewReplicaWalletRepository(primary, replica, healthyMonitor()).GetWallet(ctx, walletID)
+		assert.NoError(t, err)
+		assert.Equal(t, primaryWallet, wallet)
+		replica.AssertNotCalled(t, "GetWallet", mock.Anything, mock.Anything)
+	})
+
+	t.Run("unhealthy replica is skipped", func(t *testing.T) {
+		primary, replica := &MockWalletRepository{}, &MockWalletRepository{}
+		primary.On("GetWallet", mock.Anything, walletID).Return(primaryWallet, nil)
+
+		wallet, err := NewReplicaWalletRepository(primary, replica, &ReplicaMonitor{}).GetWallet(context.Background(), walletID)
+		assert.NoError(t, err)
+		assert.Equal(t, primaryWallet, wallet)
+		replica.AssertNotCalled(t, "GetWallet", mock.Anything, mock.Anything)
+	})
+
+	t.Run("replica miss falls back without marking it unhealthy", func(t *testing.T) {
+		primary, replica := &MockWalletRepository{}, &MockWalletRepository{}
+		replica.On("GetWallet", mock.Anything, walletID).Return((*models.Wallet)(nil), ErrWalletNotFound)
+		primary.On("GetWallet", mock.Anything, walletID).Return(primaryWallet, nil)
+
+		monitor := healthyMonitor()
+		wallet, err := NewReplicaWalletRepository(primary, replica, monitor).GetWallet(context.Background(), walletID)
+		assert.NoError(t, err)
+		assert.Equal(t, primaryWallet, wallet)
+		assert.True(t, monitor.Healthy())
+	})
+
+	t.Run("replica error falls back and marks it unhealthy", func(t *testing.T) {
+		primary, replica := &MockWalletRepository{}, &MockWalletRepository{}
+		replica.On("GetWallet", mock.Anything, walletID).Return((*models.Wallet)(nil), errors.New("connection refused"))
+		primary.On("GetWallet", mock.Anything, walletID).Return(primaryWallet, nil)
+
+		monitor := healthyMonitor()
+		wallet, err := NewReplicaWalletRepository(primary, replica, monitor).GetWallet(context.Background(), walletID)
+		assert.NoError(t, err)
+		assert.Equal(t, primaryWallet, wallet)
+		assert.False(t, monitor.Healthy())
+	})
+}
+
+func TestReplicaWalletRepository_WritesGoToPrimary(t *testing.T) {
+	walletID := uuid.New()
+	primary, replica := &MockWalletRepository{}, &MockWalletRepository{}
+	primary.On("UpdateWalletBalance", mock.Anything, walletID, int64(100), models.DEPOSIT).Return(nil)
+
+	err := NewReplicaWalletRepository(primary, replica, healthyMonitor()).UpdateWalletBalance(context.Background(), walletID, 100, models.DEPOSIT)
+	assert.NoError(t, err)
+	primary.AssertExpectations(t)
+}
@@ -0,0 +1,258 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sync"
+	"time"
+
+	"ITKtest/internal/models"
+
+	"github.com/google/uuid"
+)
+
+// sqliteWalletRepository stores wallets in a single SQLite file. SQLite allows one writer at a
+// time, so writes are serialized by writeMu inside the process and by BEGIN IMMEDIATE (the
+// _txlock=immediate DSN option, see database.ConnectSQLite) across processes; balances are
+// checked inside that write transaction, so they can never go negative. There are no row locks
+// to spread, so sharding only records the shard count, and no outbox events are written.
+type sqliteWalletRepository struct {
+	db      *sql.DB
+	writeMu sync.Mutex
+
+	// publish, if set, receives every journal entry once it is committed
+	publish func(models.Transaction)
+}
+
+func NewSQLiteWalletRepository(db *sql.DB, publish func(models.Transaction)) WalletRepository {
+	return &sqliteWalletRepository{db: db, publish: publish}
+}
+
+func (r *sqliteWalletRepository) CreateWallet(ctx context.Context, walletID uuid.UUID) error {
+	now := time.Now().UTC()
+	_, err := r.db.ExecContext(ctx,
+		"INSERT INTO wallets (id, balance, created_at, updated_at) VALUES (?, 0, ?, ?) ON CONFLICT (id) DO NOTHING",
+		walletID, now, now)
+	return err
+}
+
+func (r *sqliteWalletRepository) GetWallet(ctx context.Context, walletID uuid.UUID) (*models.Wallet, error) {
+	var wallet models.Wallet
+	err := r.db.QueryRowContext(ctx,
+		"SELECT id, balance, shard_count, version, created_at, updated_at FROM wallets WHERE id = ?",
+		walletID).Scan(&wallet.ID, &wallet.Balance, &wallet.ShardCount, &wallet.Version, &wallet.CreatedAt, &wallet.UpdatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrWalletNotFound
+		}
+		return nil, err
+	}
+	return &wallet, nil
+}
+
+func (r *sqliteWalletRepository) UpdateWalletBalance(ctx context.Context, walletID uuid.UUID, amount int64, operationType models.OperationType) error {
+	return r.ApplyOperation(ctx, models.WalletOperationRequest{WalletID: walletID, OperationType: operationType, Amount: amount})
+}
+
+func (r *sqliteWalletRepository) UpdateWalletBalanceWithVersion(ctx context.Context, walletID uuid.UUID, amount int64, operationType models.OperationType, expectedVersion int64) error {
+	return r.ApplyOperation(ctx, models.WalletOperationRequest{WalletID: walletID, OperationType: operationType, Amount: amount, ExpectedVersion: &expectedVersion})
+}
+
+func (r *sqliteWalletRepository) ApplyOperation(ctx context.Context, op models.WalletOperationRequest) error {
+	switch op.OperationType {
+	case models.DEPOSIT, models.WITHDRAW:
+		// Valid operation types
+	default:
+		return errors.New("invalid operation type")
+	}
+
+	return r.write(ctx, func(tx *sql.Tx) ([]models.Transaction, error) {
+		return applySQLiteOperation(ctx, tx, op)
+	})
+}
+
+func (r *sqliteWalletRepository) UpdateWalletBalancesAtomic(ctx context.Context, operations []models.WalletOperationRequest) error {
+	for i, op := range operations {
+		switch op.OperationType {
+		case models.DEPOSIT, models.WITHDRAW:
+			// Valid operation types
+		default:
+			return &models.BatchOperationError{Index: i, Err: errors.New("invalid operation type")}
+		}
+	}
+
+	return r.write(ctx, func(tx *sql.Tx) ([]models.Transaction, error) {
+		transactions := make([]models.Transaction, 0, len(operations))
+		for i, op := range operations {
+			applied, err := applySQLiteOperation(ctx, tx, op)
+			if err != nil {
+				return nil, &models.BatchOperationError{Index: i, Err: err}
+			}
+			transactions = append(transactions, applied...)
+		}
+		return transactions, nil
+	})
+}
+
+// write runs fn in a write transaction and publishes the journal entries it returns after commit
+func (r *sqliteWalletRepository) write(ctx context.Context, fn func(tx *sql.Tx) ([]models.Transaction, error)) error {
+	r.writeMu.Lock()
+	defer r.writeMu.Unlock()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	transactions, err := fn(tx)
+	if err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	if r.publish != nil {
+		for _, transaction := range transactions {
+			r.publish(transaction)
+		}
+	}
+	return nil
+}
+
+// applySQLiteOperation mirrors applyOperation, returning the journal entries of the operation
+// and of its fee
+func applySQLiteOperation(ctx context.Context, tx *sql.Tx, op models.WalletOperationRequest) ([]models.Transaction, error) {
+	transaction, err := applySQLiteEntry(ctx, tx, op, nil)
+	if err != nil {
+		return nil, err
+	}
+	if op.Fee == nil {
+		return []models.Transaction{transaction}, nil
+	}
+
+	charge, err := applySQLiteEntry(ctx, tx, models.WalletOperationRequest{WalletID: op.WalletID, OperationType: models.WITHDRAW, Amount: op.Fee.Amount}, &transaction.ID)
+	if err != nil {
+		return nil, err
+	}
+	credit, err := applySQLiteEntry(ctx, tx, models.WalletOperationRequest{WalletID: op.Fee.FeeWalletID, OperationType: models.DEPOSIT, Amount: op.Fee.Amount}, &transaction.ID)
+	if err != nil {
+		return nil, err
+	}
+	return []models.Transaction{transaction, charge, credit}, nil
+}
+
+// applySQLiteEntry mirrors applyEntry: sharded wallets reject an expected version and do not
+// bump the version
+func applySQLiteEntry(ctx context.Context, tx *sql.Tx, op models.WalletOperationRequest, feeFor *int64) (models.Transaction, error) {
+	walletID, amount, operationType, expectedVersion := op.WalletID, op.Amount, op.OperationType, op.ExpectedVersion
+
+	var currentBalance, version int64
+	var shardCount int
+	err := tx.QueryRowContext(ctx,
+		"SELECT balance, shard_count, version FROM wallets WHERE id = ?",
+		walletID).Scan(&currentBalance, &shardCount, &version)
+	if err != nil {
+		return models.Transaction{}, err
+	}
+
+	if expectedVersion != nil {
+		if shardCount > 0 {
+			return models.Transaction{}, ErrShardedVersion
+		}
+		if *expectedVersion != version {
+			return models.Transaction{}, ErrVersionConflict
+		}
+	}
+
+	newBalance, err := calculateBalance(currentBalance, amount, operationType)
+	if err != nil {
+		return models.Transaction{}, err
+	}
+
+	now := time.Now().UTC()
+	query := "UPDATE wallets SET balance = ?, updated_at = ?, version = version + 1 WHERE id = ?"
+	if shardCount > 0 {
+		query = "UPDATE wallets SET balance = ?, updated_at = ? WHERE id = ?"
+	}
+	if _, err := tx.ExecContext(ctx, query, newBalance, now, walletID); err != nil {
+		return models.Transaction{}, err
+	}
+
+	transaction := models.Transaction{
+		WalletID:      walletID,
+		OperationType: operationType,
+		Amount:        amount,
+		BalanceAfter:  newBalance,
+		CreatedAt:     now,
+		Reference:     op.Reference,
+		FeeFor:        feeFor,
+	}
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO wallet_transactions (wallet_id, operation_type, amount, balance_after, created_at, reference, fee_for)
+		VALUES (?, ?, ?, ?, ?, NULLIF(?, ''), ?)
+		RETURNING id
+	`, transaction.WalletID, transaction.OperationType, transaction.Amount, transaction.BalanceAfter, transaction.CreatedAt, transaction.Reference, transaction.FeeFor).Scan(&transaction.ID)
+	if err != nil {
+		return models.Transaction{}, err
+	}
+	return transaction, nil
+}
+
+func (r *sqliteWalletRepository) EnableSharding(ctx context.Context, walletID uuid.UUID, shards int) error {
+	if shards <= 0 {
+		return errors.New("shard count must be positive")
+	}
+
+	return r.write(ctx, func(tx *sql.Tx) ([]models.Transaction, error) {
+		now := time.Now().UTC()
+		_, err := tx.ExecContext(ctx,
+			"INSERT INTO wallets (id, balance, created_at, updated_at) VALUES (?, 0, ?, ?) ON CONFLICT (id) DO NOTHING",
+			walletID, now, now)
+		if err != nil {
+			return nil, err
+		}
+
+		var current int
+		if err := tx.QueryRowContext(ctx, "SELECT shard_count FROM wallets WHERE id = ?", walletID).Scan(&current); err != nil {
+			return nil, err
+		}
+		if shards < current {
+			return nil, errors.New("shard count cannot be decreased")
+		}
+
+		_, err = tx.ExecContext(ctx, "UPDATE wallets SET shard_count = ?, updated_at = ? WHERE id = ?", shards, now, walletID)
+		return nil, err
+	})
+}
+
+// ResumeAfterID returns lastID: SQLite has a single writer, so ids follow commit order
+func (r *sqliteWalletRepository) ResumeAfterID(ctx context.Context, walletID uuid.UUID, lastID int64, window time.Duration) (int64, error) {
+	return lastID, nil
+}
+
+func (r *sqliteWalletRepository) ListTransactions(ctx context.Context, walletID uuid.UUID, afterID int64, limit int) ([]models.Transaction, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT id, wallet_id, operation_type, amount, balance_after, created_at, COALESCE(reference, ''), fee_for
+		FROM wallet_transactions
+		WHERE wallet_id = ? AND id > ?
+		ORDER BY id
+		LIMIT ?
+	`, walletID, afterID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	transactions := []models.Transaction{}
+	for rows.Next() {
+		var t models.Transaction
+		if err := rows.Scan(&t.ID, &t.WalletID, &t.OperationType, &t.Amount, &t.BalanceAfter, &t.CreatedAt, &t.Reference, &t.FeeFor); err != nil {
+			return nil, err
+		}
+		transactions = append(transactions, t)
+	}
+	return transactions, rows.Err()
+}
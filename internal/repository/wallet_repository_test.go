@@ -18,7 +18,7 @@ func TestWalletRepository_CompleteFlow(t *testing.T) {
 	assert.NoError(t, err)
 	defer db.Close()
 
-	repo := NewWalletRepository(db)
+	repo := NewWalletRepository(db, TxConfig{})
 	walletID := uuid.New()
 	now := time.Now()
 	ctx := context.Background()
@@ -35,10 +35,10 @@ func TestWalletRepository_CompleteFlow(t *testing.T) {
 
 	// 2. Тестируем получение кошелька (должен быть пустой)
 	t.Run("GetWallet after creation", func(t *testing.T) {
-		rows := sqlmock.NewRows([]string{"id", "balance", "created_at", "updated_at"}).
-			AddRow(walletID, 0, now, now)
+		rows := sqlmock.NewRows([]string{"id", "balance", "shard_count", "version", "created_at", "updated_at"}).
+			AddRow(walletID, 0, 0, 0, now, now)
 
-		mock.ExpectQuery(`SELECT id, balance, created_at, updated_at FROM wallets WHERE id = \$1`).
+		mock.ExpectQuery(`SELECT id, balance, shard_count, version, created_at, updated_at FROM wallets WHERE id = \$1`).
 			WithArgs(walletID).
 			WillReturnRows(rows)
 
@@ -52,15 +52,30 @@ func TestWalletRepository_CompleteFlow(t *testing.T) {
 	// 3. Тестируем пополнение счета
 	t.Run("Deposit funds", func(t *testing.T) {
 		mock.ExpectBegin()
-		rows := sqlmock.NewRows([]string{"balance"}).AddRow(0)
-		mock.ExpectQuery(`SELECT balance FROM wallets WHERE id = \$1 FOR UPDATE`).
+		mock.ExpectQuery(`SELECT shard_count FROM wallets WHERE id = \$1`).
+			WithArgs(walletID).
+			WillReturnRows(sqlmock.NewRows([]string{"shard_count"}).AddRow(0))
+		rows := sqlmock.NewRows([]string{"balance", "shard_count", "version"}).AddRow(0, 0, 0)
+		mock.ExpectQuery(`SELECT balance, shard_count, version FROM wallets WHERE id = \$1 FOR UPDATE`).
 			WithArgs(walletID).
 			WillReturnRows(rows)
 
-		mock.ExpectExec(`UPDATE wallets SET balance = \$1, updated_at = \$2 WHERE id = \$3`).
+		mock.ExpectExec(`UPDATE wallets SET balance = \$1, updated_at = \$2, version = version \+ 1 WHERE id = \$3`).
 			WithArgs(1000, sqlmock.AnyArg(), walletID).
 			WillReturnResult(sqlmock.NewResult(1, 1))
 
+		mock.ExpectQuery(`INSERT INTO wallet_transactions \(wallet_id, operation_type, amount, balance_after, created_at, reference, fee_for\)`).
+			WithArgs(walletID, sqlmock.AnyArg(), sqlmock.AnyArg(), 1000, sqlmock.AnyArg(), "", nil).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+		mock.ExpectExec(`SELECT pg_notify\(\$1, \$2\)`).
+			WithArgs(TransactionsChannel, sqlmock.AnyArg()).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+
+		mock.ExpectExec(`INSERT INTO outbox_events \(aggregate_id, event_type, payload, created_at\)`).
+			WithArgs(walletID, models.EventWalletBalanceChanged, sqlmock.AnyArg(), sqlmock.AnyArg()).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		expectAuditRecord(mock)
+
 		mock.ExpectCommit()
 
 		err := repo.UpdateWalletBalance(ctx, walletID, 1000, models.DEPOSIT)
@@ -69,10 +84,10 @@ func TestWalletRepository_CompleteFlow(t *testing.T) {
 
 	// 4. Проверяем баланс после пополнения
 	t.Run("GetWallet after deposit", func(t *testing.T) {
-		rows := sqlmock.NewRows([]string{"id", "balance", "created_at", "updated_at"}).
-			AddRow(walletID, 1000, now, now)
+		rows := sqlmock.NewRows([]string{"id", "balance", "shard_count", "version", "created_at", "updated_at"}).
+			AddRow(walletID, 1000, 0, 0, now, now)
 
-		mock.ExpectQuery(`SELECT id, balance, created_at, updated_at FROM wallets WHERE id = \$1`).
+		mock.ExpectQuery(`SELECT id, balance, shard_count, version, created_at, updated_at FROM wallets WHERE id = \$1`).
 			WithArgs(walletID).
 			WillReturnRows(rows)
 
@@ -84,15 +99,30 @@ func TestWalletRepository_CompleteFlow(t *testing.T) {
 	// 5. Тестируем снятие средств (успешное)
 	t.Run("Withdraw funds successfully", func(t *testing.T) {
 		mock.ExpectBegin()
-		rows := sqlmock.NewRows([]string{"balance"}).AddRow(1000)
-		mock.ExpectQuery(`SELECT balance FROM wallets WHERE id = \$1 FOR UPDATE`).
+		mock.ExpectQuery(`SELECT shard_count FROM wallets WHERE id = \$1`).
+			WithArgs(walletID).
+			WillReturnRows(sqlmock.NewRows([]string{"shard_count"}).AddRow(0))
+		rows := sqlmock.NewRows([]string{"balance", "shard_count", "version"}).AddRow(1000, 0, 0)
+		mock.ExpectQuery(`SELECT balance, shard_count, version FROM wallets WHERE id = \$1 FOR UPDATE`).
 			WithArgs(walletID).
 			WillReturnRows(rows)
 
-		mock.ExpectExec(`UPDATE wallets SET balance = \$1, updated_at = \$2 WHERE id = \$3`).
+		mock.ExpectExec(`UPDATE wallets SET balance = \$1, updated_at = \$2, version = version \+ 1 WHERE id = \$3`).
 			WithArgs(500, sqlmock.AnyArg(), walletID).
 			WillReturnResult(sqlmock.NewResult(1, 1))
 
+		mock.ExpectQuery(`INSERT INTO wallet_transactions \(wallet_id, operation_type, amount, balance_after, created_at, reference, fee_for\)`).
+			WithArgs(walletID, sqlmock.AnyArg(), sqlmock.AnyArg(), 500, sqlmock.AnyArg(), "", nil).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+		mock.ExpectExec(`SELECT pg_notify\(\$1, \$2\)`).
+			WithArgs(TransactionsChannel, sqlmock.AnyArg()).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+
+		mock.ExpectExec(`INSERT INTO outbox_events \(aggregate_id, event_type, payload, created_at\)`).
+			WithArgs(walletID, models.EventWalletBalanceChanged, sqlmock.AnyArg(), sqlmock.AnyArg()).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		expectAuditRecord(mock)
+
 		mock.ExpectCommit()
 
 		err := repo.UpdateWalletBalance(ctx, walletID, 500, models.WITHDRAW)
@@ -101,10 +131,10 @@ func TestWalletRepository_CompleteFlow(t *testing.T) {
 
 	// 6. Проверяем баланс после снятия
 	t.Run("GetWallet after withdraw", func(t *testing.T) {
-		rows := sqlmock.NewRows([]string{"id", "balance", "created_at", "updated_at"}).
-			AddRow(walletID, 500, now, now)
+		rows := sqlmock.NewRows([]string{"id", "balance", "shard_count", "version", "created_at", "updated_at"}).
+			AddRow(walletID, 500, 0, 0, now, now)
 
-		mock.ExpectQuery(`SELECT id, balance, created_at, updated_at FROM wallets WHERE id = \$1`).
+		mock.ExpectQuery(`SELECT id, balance, shard_count, version, created_at, updated_at FROM wallets WHERE id = \$1`).
 			WithArgs(walletID).
 			WillReturnRows(rows)
 
@@ -116,8 +146,11 @@ func TestWalletRepository_CompleteFlow(t *testing.T) {
 	// 7. Тестируем попытку снять больше чем есть (должна быть ошибка)
 	t.Run("Withdraw insufficient funds", func(t *testing.T) {
 		mock.ExpectBegin()
-		rows := sqlmock.NewRows([]string{"balance"}).AddRow(500)
-		mock.ExpectQuery(`SELECT balance FROM wallets WHERE id = \$1 FOR UPDATE`).
+		mock.ExpectQuery(`SELECT shard_count FROM wallets WHERE id = \$1`).
+			WithArgs(walletID).
+			WillReturnRows(sqlmock.NewRows([]string{"shard_count"}).AddRow(0))
+		rows := sqlmock.NewRows([]string{"balance", "shard_count", "version"}).AddRow(500, 0, 0)
+		mock.ExpectQuery(`SELECT balance, shard_count, version FROM wallets WHERE id = \$1 FOR UPDATE`).
 			WithArgs(walletID).
 			WillReturnRows(rows)
 
@@ -130,10 +163,10 @@ func TestWalletRepository_CompleteFlow(t *testing.T) {
 
 	// 8. Проверяем что баланс не изменился после неудачного снятия
 	t.Run("GetWallet after failed withdraw", func(t *testing.T) {
-		rows := sqlmock.NewRows([]string{"id", "balance", "created_at", "updated_at"}).
-			AddRow(walletID, 500, now, now)
+		rows := sqlmock.NewRows([]string{"id", "balance", "shard_count", "version", "created_at", "updated_at"}).
+			AddRow(walletID, 500, 0, 0, now, now)
 
-		mock.ExpectQuery(`SELECT id, balance, created_at, updated_at FROM wallets WHERE id = \$1`).
+		mock.ExpectQuery(`SELECT id, balance, shard_count, version, created_at, updated_at FROM wallets WHERE id = \$1`).
 			WithArgs(walletID).
 			WillReturnRows(rows)
 
@@ -145,15 +178,30 @@ func TestWalletRepository_CompleteFlow(t *testing.T) {
 	// 9. Тестируем еще одно пополнение
 	t.Run("Deposit more funds", func(t *testing.T) {
 		mock.ExpectBegin()
-		rows := sqlmock.NewRows([]string{"balance"}).AddRow(500)
-		mock.ExpectQuery(`SELECT balance FROM wallets WHERE id = \$1 FOR UPDATE`).
+		mock.ExpectQuery(`SELECT shard_count FROM wallets WHERE id = \$1`).
+			WithArgs(walletID).
+			WillReturnRows(sqlmock.NewRows([]string{"shard_count"}).AddRow(0))
+		rows := sqlmock.NewRows([]string{"balance", "shard_count", "version"}).AddRow(500, 0, 0)
+		mock.ExpectQuery(`SELECT balance, shard_count, version FROM wallets WHERE id = \$1 FOR UPDATE`).
 			WithArgs(walletID).
 			WillReturnRows(rows)
 
-		mock.ExpectExec(`UPDATE wallets SET balance = \$1, updated_at = \$2 WHERE id = \$3`).
+		mock.ExpectExec(`UPDATE wallets SET balance = \$1, updated_at = \$2, version = version \+ 1 WHERE id = \$3`).
 			WithArgs(1500, sqlmock.AnyArg(), walletID).
 			WillReturnResult(sqlmock.NewResult(1, 1))
 
+		mock.ExpectQuery(`INSERT INTO wallet_transactions \(wallet_id, operation_type, amount, balance_after, created_at, reference, fee_for\)`).
+			WithArgs(walletID, sqlmock.AnyArg(), sqlmock.AnyArg(), 1500, sqlmock.AnyArg(), "", nil).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+		mock.ExpectExec(`SELECT pg_notify\(\$1, \$2\)`).
+			WithArgs(TransactionsChannel, sqlmock.AnyArg()).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+
+		mock.ExpectExec(`INSERT INTO outbox_events \(aggregate_id, event_type, payload, created_at\)`).
+			WithArgs(walletID, models.EventWalletBalanceChanged, sqlmock.AnyArg(), sqlmock.AnyArg()).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		expectAuditRecord(mock)
+
 		mock.ExpectCommit()
 
 		err := repo.UpdateWalletBalance(ctx, walletID, 1000, models.DEPOSIT)
@@ -162,10 +210,10 @@ func TestWalletRepository_CompleteFlow(t *testing.T) {
 
 	// 10. Финальная проверка баланса
 	t.Run("Final balance check", func(t *testing.T) {
-		rows := sqlmock.NewRows([]string{"id", "balance", "created_at", "updated_at"}).
-			AddRow(walletID, 1500, now, now)
+		rows := sqlmock.NewRows([]string{"id", "balance", "shard_count", "version", "created_at", "updated_at"}).
+			AddRow(walletID, 1500, 0, 0, now, now)
 
-		mock.ExpectQuery(`SELECT id, balance, created_at, updated_at FROM wallets WHERE id = \$1`).
+		mock.ExpectQuery(`SELECT id, balance, shard_count, version, created_at, updated_at FROM wallets WHERE id = \$1`).
 			WithArgs(walletID).
 			WillReturnRows(rows)
 
@@ -183,13 +231,13 @@ func TestWalletRepository_EdgeCases(t *testing.T) {
 	assert.NoError(t, err)
 	defer db.Close()
 
-	repo := NewWalletRepository(db)
+	repo := NewWalletRepository(db, TxConfig{})
 	walletID := uuid.New()
 	ctx := context.Background()
 
 	// 1. Тестируем получение несуществующего кошелька
 	t.Run("Get non-existent wallet", func(t *testing.T) {
-		mock.ExpectQuery(`SELECT id, balance, created_at, updated_at FROM wallets WHERE id = \$1`).
+		mock.ExpectQuery(`SELECT id, balance, shard_count, version, created_at, updated_at FROM wallets WHERE id = \$1`).
 			WithArgs(walletID).
 			WillReturnError(sql.ErrNoRows)
 
@@ -216,4 +264,261 @@ func TestWalletRepository_EdgeCases(t *testing.T) {
 	})
 
 	assert.NoError(t, mock.ExpectationsWereMet())
-}
\ No newline at end of file
+}
+
+func TestWalletRepository_ListTransactions(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := NewWalletRepository(db, TxConfig{})
+	walletID := uuid.New()
+	now := time.Now()
+
+	rows := sqlmock.NewRows([]string{"id", "wallet_id", "operation_type", "amount", "balance_after", "created_at", "reference", "fee_for"}).
+		AddRow(11, walletID, models.DEPOSIT, 1000, 1000, now, "bank-11", nil).
+		AddRow(12, walletID, models.WITHDRAW, 300, 700, now, "", 11)
+	mock.ExpectQuery(`SELECT id, wallet_id, operation_type, amount, balance_after, created_at, COALESCE\(reference, ''\), fee_for FROM wallet_transactions WHERE wallet_id = \$1 AND id > \$2 ORDER BY id LIMIT \$3`).
+		WithArgs(walletID, 10, 100).
+		WillReturnRows(rows)
+
+	transactions, err := repo.ListTransactions(context.Background(), walletID, 10, 100)
+	assert.NoError(t, err)
+	assert.Len(t, transactions, 2)
+	assert.Equal(t, "bank-11", transactions[0].Reference)
+	assert.Nil(t, transactions[0].FeeFor)
+	assert.Equal(t, int64(11), *transactions[1].FeeFor)
+	assert.Equal(t, int64(12), transactions[1].ID)
+	assert.Equal(t, models.WITHDRAW, transactions[1].OperationType)
+	assert.Equal(t, int64(700), transactions[1].BalanceAfter)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestWalletRepository_ResumeAfterID(t *testing.T) {
+	walletID := uuid.New()
+	query := `SELECT MIN\(t.id\) FROM wallet_transactions last JOIN wallet_transactions t ON t.wallet_id = last.wallet_id AND t.id < last.id`
+
+	tests := []struct {
+		name     string
+		firstID  interface{}
+		expected int64
+	}{
+		{name: "steps back over recently created entries", firstID: 7, expected: 6},
+		{name: "nothing created within the window", firstID: nil, expected: 10},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			assert.NoError(t, err)
+			defer db.Close()
+
+			mock.ExpectQuery(query).
+				WithArgs(walletID, 10, time.Minute.Microseconds()).
+				WillReturnRows(sqlmock.NewRows([]string{"min"}).AddRow(tt.firstID))
+
+			afterID, err := NewWalletRepository(db, TxConfig{}).ResumeAfterID(context.Background(), walletID, 10, time.Minute)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, afterID)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestWalletRepository_UpdateWalletBalancesAtomic(t *testing.T) {
+	// Wallet IDs are chosen so that sorted order differs from request order
+	first := uuid.MustParse("00000000-0000-0000-0000-000000000001")
+	second := uuid.MustParse("00000000-0000-0000-0000-000000000002")
+	ctx := context.Background()
+
+	expectOperation := func(mock sqlmock.Sqlmock, walletID uuid.UUID, balance, newBalance int) {
+		mock.ExpectQuery(`SELECT shard_count FROM wallets WHERE id = \$1`).
+			WithArgs(walletID).
+			WillReturnRows(sqlmock.NewRows([]string{"shard_count"}).AddRow(0))
+		mock.ExpectQuery(`SELECT balance, shard_count, version FROM wallets WHERE id = \$1 FOR UPDATE`).
+			WithArgs(walletID).
+			WillReturnRows(sqlmock.NewRows([]string{"balance", "shard_count", "version"}).AddRow(balance, 0, 0))
+		mock.ExpectExec(`UPDATE wallets SET balance = \$1, updated_at = \$2, version = version \+ 1 WHERE id = \$3`).
+			WithArgs(newBalance, sqlmock.AnyArg(), walletID).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectQuery(`INSERT INTO wallet_transactions`).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+		mock.ExpectExec(`SELECT pg_notify`).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec(`INSERT INTO outbox_events`).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		expectAuditRecord(mock)
+	}
+
+	t.Run("locks wallets in sorted order and commits", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		assert.NoError(t, err)
+		defer db.Close()
+
+		mock.ExpectBegin()
+		mock.ExpectExec(`SELECT 1 FROM wallets WHERE id = \$1 AND shard_count = 0 FOR UPDATE`).WithArgs(first).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(`SELECT 1 FROM wallets WHERE id = \$1 AND shard_count = 0 FOR UPDATE`).WithArgs(second).WillReturnResult(sqlmock.NewResult(0, 1))
+		expectOperation(mock, second, 100, 50)
+		expectOperation(mock, first, 0, 50)
+		mock.ExpectCommit()
+
+		repo := NewWalletRepository(db, TxConfig{})
+		err = repo.UpdateWalletBalancesAtomic(ctx, []models.WalletOperationRequest{
+			{WalletID: second, OperationType: models.WITHDRAW, Amount: 50},
+			{WalletID: first, OperationType: models.DEPOSIT, Amount: 50},
+		})
+		assert.NoError(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("rolls back when one operation fails", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		assert.NoError(t, err)
+		defer db.Close()
+
+		mock.ExpectBegin()
+		mock.ExpectExec(`SELECT 1 FROM wallets WHERE id = \$1 AND shard_count = 0 FOR UPDATE`).WithArgs(first).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(`SELECT 1 FROM wallets WHERE id = \$1 AND shard_count = 0 FOR UPDATE`).WithArgs(second).WillReturnResult(sqlmock.NewResult(0, 1))
+		expectOperation(mock, first, 0, 50)
+		mock.ExpectQuery(`SELECT shard_count FROM wallets WHERE id = \$1`).
+			WithArgs(second).
+			WillReturnRows(sqlmock.NewRows([]string{"shard_count"}).AddRow(0))
+		mock.ExpectQuery(`SELECT balance, shard_count, version FROM wallets WHERE id = \$1 FOR UPDATE`).
+			WithArgs(second).
+			WillReturnRows(sqlmock.NewRows([]string{"balance", "shard_count", "version"}).AddRow(10, 0, 0))
+		mock.ExpectRollback()
+
+		repo := NewWalletRepository(db, TxConfig{})
+		err = repo.UpdateWalletBalancesAtomic(ctx, []models.WalletOperationRequest{
+			{WalletID: first, OperationType: models.DEPOSIT, Amount: 50},
+			{WalletID: second, OperationType: models.WITHDRAW, Amount: 50},
+		})
+
+		var batchErr *models.BatchOperationError
+		assert.ErrorAs(t, err, &batchErr)
+		assert.Equal(t, 1, batchErr.Index)
+		assert.Contains(t, err.Error(), "insufficient funds")
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestWalletRepository_ApplyOperationWithFee(t *testing.T) {
+	walletID, feeWallet := uuid.New(), uuid.New()
+
+	// The optimistic repository locks like the pessimistic one, as the fee wallet has to be
+	// locked in sorted order
+	repos := map[string]func(db *sql.DB) WalletRepository{
+		"pessimistic": func(db *sql.DB) WalletRepository { return NewWalletRepository(db, TxConfig{}) },
+		"optimistic":  func(db *sql.DB) WalletRepository { return NewOptimisticWalletRepository(db, 3, TxConfig{}) },
+	}
+	for name, newRepo := range repos {
+		t.Run(name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			assert.NoError(t, err)
+			defer db.Close()
+
+			expectEntry := func(walletID uuid.UUID, balance, newBalance, amount int, feeFor interface{}, id int64) {
+				mock.ExpectQuery(`SELECT shard_count FROM wallets WHERE id = \$1`).
+					WithArgs(walletID).
+					WillReturnRows(sqlmock.NewRows([]string{"shard_count"}).AddRow(0))
+				mock.ExpectQuery(`SELECT balance, shard_count, version FROM wallets WHERE id = \$1 FOR UPDATE`).
+					WithArgs(walletID).
+					WillReturnRows(sqlmock.NewRows([]string{"balance", "shard_count", "version"}).AddRow(balance, 0, 0))
+				mock.ExpectExec(`UPDATE wallets SET balance`).
+					WithArgs(newBalance, sqlmock.AnyArg(), walletID).
+					WillReturnResult(sqlmock.NewResult(1, 1))
+				mock.ExpectQuery(`INSERT INTO wallet_transactions`).
+					WithArgs(walletID, sqlmock.AnyArg(), amount, newBalance, sqlmock.AnyArg(), "", feeFor).
+					WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(id))
+				mock.ExpectExec(`SELECT pg_notify`).WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectExec(`INSERT INTO outbox_events`).WillReturnResult(sqlmock.NewResult(1, 1))
+				expectAuditRecord(mock)
+			}
+
+			// Both wallets are locked in sorted order first. The fee is debited after the withdrawal
+			// and credited to the fee wallet, both linked to it.
+			mock.ExpectBegin()
+			for _, id := range sortedWalletIDs([]models.WalletOperationRequest{{WalletID: walletID, Fee: &models.OperationFee{FeeWalletID: feeWallet}}}) {
+				mock.ExpectExec(`SELECT 1 FROM wallets WHERE id = \$1 AND shard_count = 0 FOR UPDATE`).
+					WithArgs(id).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+			}
+			expectEntry(walletID, 1000, 500, 500, nil, 7)
+			expectEntry(walletID, 500, 490, 10, int64(7), 8)
+			expectEntry(feeWallet, 0, 10, 10, int64(7), 9)
+			mock.ExpectCommit()
+
+			err = newRepo(db).ApplyOperation(context.Background(), models.WalletOperationRequest{
+				WalletID:      walletID,
+				OperationType: models.WITHDRAW,
+				Amount:        500,
+				Fee:           &models.OperationFee{Amount: 10, FeeWalletID: feeWallet, Rule: "withdrawals"},
+			})
+			assert.NoError(t, err)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestSortedWalletIDs(t *testing.T) {
+	first := uuid.MustParse("00000000-0000-0000-0000-000000000001")
+	second := uuid.MustParse("00000000-0000-0000-0000-000000000002")
+	fees := uuid.MustParse("00000000-0000-0000-0000-000000000003")
+
+	walletIDs := sortedWalletIDs([]models.WalletOperationRequest{
+		{WalletID: second, Fee: &models.OperationFee{FeeWalletID: fees}},
+		{WalletID: fees},
+		{WalletID: first, Fee: &models.OperationFee{FeeWalletID: fees}},
+	})
+
+	assert.Equal(t, []uuid.UUID{first, second, fees}, walletIDs)
+}
+
+func TestWalletRepository_ApplyOperationWithIdempotencyKey(t *testing.T) {
+	walletID := uuid.New()
+	op := models.WalletOperationRequest{WalletID: walletID, OperationType: models.DEPOSIT, Amount: 100, IdempotencyKey: "schedule-5-1772355600"}
+
+	for _, tt := range []struct {
+		name     string
+		affected int64
+		err      error
+	}{
+		{"first application claims the key", 1, nil},
+		{"a key already claimed rolls the operation back", 0, ErrDuplicateOperation},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			assert.NoError(t, err)
+			defer db.Close()
+
+			mock.ExpectBegin()
+			mock.ExpectQuery(`SELECT shard_count FROM wallets WHERE id = \$1`).
+				WithArgs(walletID).
+				WillReturnRows(sqlmock.NewRows([]string{"shard_count"}).AddRow(0))
+			mock.ExpectQuery(`SELECT balance, shard_count, version FROM wallets WHERE id = \$1 FOR UPDATE`).
+				WithArgs(walletID).
+				WillReturnRows(sqlmock.NewRows([]string{"balance", "shard_count", "version"}).AddRow(0, 0, 0))
+			mock.ExpectExec(`UPDATE wallets SET balance`).
+				WithArgs(100, sqlmock.AnyArg(), walletID).
+				WillReturnResult(sqlmock.NewResult(1, 1))
+			mock.ExpectQuery(`INSERT INTO wallet_transactions`).
+				WithArgs(walletID, models.DEPOSIT, 100, 100, sqlmock.AnyArg(), "", nil).
+				WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(7))
+			mock.ExpectExec(`SELECT pg_notify`).WillReturnResult(sqlmock.NewResult(0, 0))
+			mock.ExpectExec(`INSERT INTO outbox_events`).WillReturnResult(sqlmock.NewResult(1, 1))
+			expectAuditRecord(mock)
+			mock.ExpectExec(`INSERT INTO operation_idempotency_keys`).
+				WithArgs(op.IdempotencyKey, int64(7)).
+				WillReturnResult(sqlmock.NewResult(0, tt.affected))
+			if tt.err == nil {
+				mock.ExpectCommit()
+			} else {
+				mock.ExpectRollback()
+			}
+
+			err = NewWalletRepository(db, TxConfig{}).ApplyOperation(context.Background(), op)
+			assert.ErrorIs(t, err, tt.err)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
@@ -0,0 +1,195 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"math/rand"
+	"time"
+
+	"ITKtest/internal/models"
+
+	"github.com/google/uuid"
+)
+
+// EnableSharding splits the balance of a hot wallet across shards rows so that concurrent
+// operations lock different rows instead of serializing on the wallet row. The shard count
+// can only grow; the current balance is moved to shard 0.
+func (r *walletRepository) EnableSharding(ctx context.Context, walletID uuid.UUID, shards int) error {
+	if shards <= 0 {
+		return errors.New("shard count must be positive")
+	}
+
+	return r.tx.run(ctx, func(tx *sql.Tx) error {
+		now := time.Now()
+		_, err := tx.ExecContext(ctx, `
+			INSERT INTO wallets (id, balance, created_at, updated_at)
+			VALUES ($1, 0, $2, $2)
+			ON CONFLICT (id) DO NOTHING
+		`, walletID, now)
+		if err != nil {
+			return err
+		}
+
+		var balance int64
+		var current int
+		err = tx.QueryRowContext(ctx, "SELECT balance, shard_count FROM wallets WHERE id = $1 FOR UPDATE", walletID).Scan(&balance, &current)
+		if err != nil {
+			return err
+		}
+		if shards < current {
+			return errors.New("shard count cannot be decreased")
+		}
+		if shards == current {
+			return nil
+		}
+
+		_, err = tx.ExecContext(ctx, `
+			INSERT INTO wallet_shards (wallet_id, shard, balance, updated_at)
+			SELECT $1, s, 0, $2 FROM generate_series($3::int, $4::int - 1) AS s
+		`, walletID, now, current, shards)
+		if err != nil {
+			return err
+		}
+
+		if balance != 0 {
+			_, err = tx.ExecContext(ctx,
+				"UPDATE wallet_shards SET balance = balance + $1, updated_at = $2 WHERE wallet_id = $3 AND shard = 0",
+				balance, now, walletID)
+			if err != nil {
+				return err
+			}
+		}
+
+		_, err = tx.ExecContext(ctx,
+			"UPDATE wallets SET balance = 0, shard_count = $1, updated_at = $2 WHERE id = $3",
+			shards, now, walletID)
+		if err != nil {
+			return err
+		}
+
+		return insertAuditRecord(ctx, tx, models.AuditWalletShardingEnabled, walletID.String(),
+			auditSharding{ShardCount: current, Balance: balance},
+			auditSharding{ShardCount: shards, Balance: balance})
+	})
+}
+
+// auditSharding is the audited state of a wallet whose shard count changes
+type auditSharding struct {
+	ShardCount int   `json:"shardCount"`
+	Balance    int64 `json:"balance"`
+}
+
+// applyShardedOperation changes a sharded wallet and returns its total balance before and after.
+// Deposits go to a random shard. Withdrawals take one random unlocked shard that covers the
+// amount, and only when none does fall back to locking every shard in order and draining them.
+func applyShardedOperation(ctx context.Context, tx *sql.Tx, walletID uuid.UUID, shardCount int, amount int64, operationType models.OperationType, now time.Time) (int64, int64, error) {
+	switch operationType {
+	case models.DEPOSIT:
+		_, err := tx.ExecContext(ctx,
+			"UPDATE wallet_shards SET balance = balance + $1, updated_at = $2 WHERE wallet_id = $3 AND shard = $4",
+			amount, now, walletID, rand.Intn(shardCount))
+		if err != nil {
+			return 0, 0, err
+		}
+
+	case models.WITHDRAW:
+		var shard int
+		err := tx.QueryRowContext(ctx, `
+			SELECT shard FROM wallet_shards
+			WHERE wallet_id = $1 AND balance >= $2
+			ORDER BY random()
+			LIMIT 1
+			FOR UPDATE SKIP LOCKED
+		`, walletID, amount).Scan(&shard)
+		switch {
+		case err == nil:
+			_, err = tx.ExecContext(ctx,
+				"UPDATE wallet_shards SET balance = balance - $1, updated_at = $2 WHERE wallet_id = $3 AND shard = $4",
+				amount, now, walletID, shard)
+			if err != nil {
+				return 0, 0, err
+			}
+		case errors.Is(err, sql.ErrNoRows):
+			if err := drainShards(ctx, tx, walletID, amount, now); err != nil {
+				return 0, 0, err
+			}
+		default:
+			return 0, 0, err
+		}
+
+	default:
+		return 0, 0, errors.New("invalid operation type")
+	}
+
+	// Under concurrent operations this is the total as seen by this transaction
+	var balanceAfter int64
+	err := tx.QueryRowContext(ctx,
+		"SELECT COALESCE(SUM(balance), 0) FROM wallet_shards WHERE wallet_id = $1",
+		walletID).Scan(&balanceAfter)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	balanceBefore := balanceAfter - amount
+	if operationType == models.WITHDRAW {
+		balanceBefore = balanceAfter + amount
+	}
+	return balanceBefore, balanceAfter, nil
+}
+
+// drainShards withdraws amount spread over several shards, locking all of them in shard order
+func drainShards(ctx context.Context, tx *sql.Tx, walletID uuid.UUID, amount int64, now time.Time) error {
+	rows, err := tx.QueryContext(ctx,
+		"SELECT shard, balance FROM wallet_shards WHERE wallet_id = $1 ORDER BY shard FOR UPDATE",
+		walletID)
+	if err != nil {
+		return err
+	}
+
+	type shardBalance struct {
+		shard   int
+		balance int64
+	}
+	var shards []shardBalance
+	var total int64
+	for rows.Next() {
+		var sb shardBalance
+		if err := rows.Scan(&sb.shard, &sb.balance); err != nil {
+			rows.Close()
+			return err
+		}
+		shards = append(shards, sb)
+		total += sb.balance
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	if total < amount {
+		return ErrInsufficientFunds
+	}
+
+	remaining := amount
+	for _, sb := range shards {
+		if remaining == 0 {
+			break
+		}
+		take := sb.balance
+		if take > remaining {
+			take = remaining
+		}
+		if take == 0 {
+			continue
+		}
+		_, err := tx.ExecContext(ctx,
+			"UPDATE wallet_shards SET balance = balance - $1, updated_at = $2 WHERE wallet_id = $3 AND shard = $4",
+			take, now, walletID, sb.shard)
+		if err != nil {
+			return err
+		}
+		remaining -= take
+	}
+	return nil
+}
@@ -0,0 +1,186 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"ITKtest/internal/models"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func expectShardedWallet(mock sqlmock.Sqlmock, walletID uuid.UUID, shards int) {
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT shard_count FROM wallets WHERE id = \$1`).
+		WithArgs(walletID).
+		WillReturnRows(sqlmock.NewRows([]string{"shard_count"}).AddRow(shards))
+}
+
+func expectShardedJournal(mock sqlmock.Sqlmock, walletID uuid.UUID, total int) {
+	mock.ExpectQuery(`SELECT COALESCE\(SUM\(balance\), 0\) FROM wallet_shards WHERE wallet_id = \$1`).
+		WithArgs(walletID).
+		WillReturnRows(sqlmock.NewRows([]string{"sum"}).AddRow(total))
+	mock.ExpectQuery(`INSERT INTO wallet_transactions`).
+		WithArgs(walletID, sqlmock.AnyArg(), sqlmock.AnyArg(), total, sqlmock.AnyArg(), "", nil).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectExec(`SELECT pg_notify`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`INSERT INTO outbox_events`).WillReturnResult(sqlmock.NewResult(1, 1))
+	expectAuditRecord(mock)
+	mock.ExpectCommit()
+}
+
+func TestWalletRepository_ShardedWallet(t *testing.T) {
+	walletID := uuid.New()
+	ctx := context.Background()
+
+	t.Run("deposit goes to one shard without locking the wallet row", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		assert.NoError(t, err)
+		defer db.Close()
+
+		expectShardedWallet(mock, walletID, 4)
+		mock.ExpectExec(`UPDATE wallet_shards SET balance = balance \+ \$1, updated_at = \$2 WHERE wallet_id = \$3 AND shard = \$4`).
+			WithArgs(100, sqlmock.AnyArg(), walletID, sqlmock.AnyArg()).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		expectShardedJournal(mock, walletID, 1100)
+
+		err = NewWalletRepository(db, TxConfig{}).UpdateWalletBalance(ctx, walletID, 100, models.DEPOSIT)
+		assert.NoError(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("withdraw from a shard that covers the amount", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		assert.NoError(t, err)
+		defer db.Close()
+
+		expectShardedWallet(mock, walletID, 4)
+		mock.ExpectQuery(`SELECT shard FROM wallet_shards WHERE wallet_id = \$1 AND balance >= \$2 ORDER BY random\(\) LIMIT 1 FOR UPDATE SKIP LOCKED`).
+			WithArgs(walletID, 300).
+			WillReturnRows(sqlmock.NewRows([]string{"shard"}).AddRow(2))
+		mock.ExpectExec(`UPDATE wallet_shards SET balance = balance - \$1, updated_at = \$2 WHERE wallet_id = \$3 AND shard = \$4`).
+			WithArgs(300, sqlmock.AnyArg(), walletID, 2).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		expectShardedJournal(mock, walletID, 700)
+
+		err = NewWalletRepository(db, TxConfig{}).UpdateWalletBalance(ctx, walletID, 300, models.WITHDRAW)
+		assert.NoError(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("withdraw spread over several shards", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		assert.NoError(t, err)
+		defer db.Close()
+
+		expectShardedWallet(mock, walletID, 3)
+		mock.ExpectQuery(`SELECT shard FROM wallet_shards WHERE wallet_id = \$1 AND balance >= \$2`).
+			WithArgs(walletID, 500).
+			WillReturnError(sql.ErrNoRows)
+		mock.ExpectQuery(`SELECT shard, balance FROM wallet_shards WHERE wallet_id = \$1 ORDER BY shard FOR UPDATE`).
+			WithArgs(walletID).
+			WillReturnRows(sqlmock.NewRows([]string{"shard", "balance"}).AddRow(0, 200).AddRow(1, 0).AddRow(2, 400))
+		mock.ExpectExec(`UPDATE wallet_shards SET balance = balance - \$1`).
+			WithArgs(200, sqlmock.AnyArg(), walletID, 0).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(`UPDATE wallet_shards SET balance = balance - \$1`).
+			WithArgs(300, sqlmock.AnyArg(), walletID, 2).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		expectShardedJournal(mock, walletID, 100)
+
+		err = NewWalletRepository(db, TxConfig{}).UpdateWalletBalance(ctx, walletID, 500, models.WITHDRAW)
+		assert.NoError(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("withdraw more than the sum of shards", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		assert.NoError(t, err)
+		defer db.Close()
+
+		expectShardedWallet(mock, walletID, 2)
+		mock.ExpectQuery(`SELECT shard FROM wallet_shards WHERE wallet_id = \$1 AND balance >= \$2`).
+			WithArgs(walletID, 1000).
+			WillReturnError(sql.ErrNoRows)
+		mock.ExpectQuery(`SELECT shard, balance FROM wallet_shards WHERE wallet_id = \$1 ORDER BY shard FOR UPDATE`).
+			WithArgs(walletID).
+			WillReturnRows(sqlmock.NewRows([]string{"shard", "balance"}).AddRow(0, 300).AddRow(1, 400))
+		mock.ExpectRollback()
+
+		err = NewWalletRepository(db, TxConfig{}).UpdateWalletBalance(ctx, walletID, 1000, models.WITHDRAW)
+		assert.EqualError(t, err, "insufficient funds")
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("balance is the sum of shards", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		assert.NoError(t, err)
+		defer db.Close()
+
+		now := time.Now()
+		mock.ExpectQuery(`SELECT id, balance, shard_count, version, created_at, updated_at FROM wallets WHERE id = \$1`).
+			WithArgs(walletID).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "balance", "shard_count", "version", "created_at", "updated_at"}).
+				AddRow(walletID, 0, 4, 0, now, now))
+		mock.ExpectQuery(`SELECT COALESCE\(SUM\(balance\), 0\) FROM wallet_shards WHERE wallet_id = \$1`).
+			WithArgs(walletID).
+			WillReturnRows(sqlmock.NewRows([]string{"sum"}).AddRow(4200))
+
+		wallet, err := NewWalletRepository(db, TxConfig{}).GetWallet(ctx, walletID)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(4200), wallet.Balance)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestWalletRepository_EnableSharding(t *testing.T) {
+	walletID := uuid.New()
+	ctx := context.Background()
+
+	t.Run("moves the balance to shard 0", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		assert.NoError(t, err)
+		defer db.Close()
+
+		mock.ExpectBegin()
+		mock.ExpectExec(`INSERT INTO wallets`).WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectQuery(`SELECT balance, shard_count FROM wallets WHERE id = \$1 FOR UPDATE`).
+			WithArgs(walletID).
+			WillReturnRows(sqlmock.NewRows([]string{"balance", "shard_count"}).AddRow(900, 0))
+		mock.ExpectExec(`INSERT INTO wallet_shards`).
+			WithArgs(walletID, sqlmock.AnyArg(), 0, 8).
+			WillReturnResult(sqlmock.NewResult(0, 8))
+		mock.ExpectExec(`UPDATE wallet_shards SET balance = balance \+ \$1, updated_at = \$2 WHERE wallet_id = \$3 AND shard = 0`).
+			WithArgs(900, sqlmock.AnyArg(), walletID).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(`UPDATE wallets SET balance = 0, shard_count = \$1`).
+			WithArgs(8, sqlmock.AnyArg(), walletID).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		expectAuditRecord(mock)
+		mock.ExpectCommit()
+
+		assert.NoError(t, NewWalletRepository(db, TxConfig{}).EnableSharding(ctx, walletID, 8))
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("refuses to reduce the shard count", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		assert.NoError(t, err)
+		defer db.Close()
+
+		mock.ExpectBegin()
+		mock.ExpectExec(`INSERT INTO wallets`).WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectQuery(`SELECT balance, shard_count FROM wallets WHERE id = \$1 FOR UPDATE`).
+			WithArgs(walletID).
+			WillReturnRows(sqlmock.NewRows([]string{"balance", "shard_count"}).AddRow(0, 8))
+		mock.ExpectRollback()
+
+		err = NewWalletRepository(db, TxConfig{}).EnableSharding(ctx, walletID, 4)
+		assert.EqualError(t, err, "shard count cannot be decreased")
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
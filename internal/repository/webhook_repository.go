@@ -0,0 +1,258 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strconv"
+	"time"
+
+	"ITKtest/internal/models"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+type WebhookRepository interface {
+	CreateEndpoint(ctx context.Context, endpoint *models.WebhookEndpoint) error
+	ListEndpoints(ctx context.Context) ([]models.WebhookEndpoint, error)
+	DeleteEndpoint(ctx context.Context, endpointID uuid.UUID) error
+	EnqueueDeliveries(ctx context.Context, eventID int64, eventType models.WebhookEventType, payload []byte) (int, error)
+	ClaimDueDeliveries(ctx context.Context, limit int, lease time.Duration) ([]models.WebhookDelivery, error)
+	MarkDelivered(ctx context.Context, deliveryID int64) error
+	MarkFailed(ctx context.Context, deliveryID int64, lastError string, nextAttemptAt time.Time) error
+	MoveToDeadLetter(ctx context.Context, deliveryID int64, lastError string) error
+	ListDeadLetters(ctx context.Context, limit int) ([]models.WebhookDeadLetter, error)
+	ReplayDeadLetter(ctx context.Context, deadLetterID int64) error
+}
+
+type webhookRepository struct {
+	db *sql.DB
+	tx *txRunner
+}
+
+// Errors returned by WebhookRepository for records that do not exist
+var (
+	ErrWebhookEndpointNotFound = errors.New("webhook endpoint not found")
+	ErrDeadLetterNotFound      = errors.New("dead letter not found")
+)
+
+func NewWebhookRepository(db *sql.DB, txConfig TxConfig) WebhookRepository {
+	return &webhookRepository{db: db, tx: newTxRunner(db, txConfig)}
+}
+
+func (r *webhookRepository) CreateEndpoint(ctx context.Context, endpoint *models.WebhookEndpoint) error {
+	query := `
+		INSERT INTO webhook_endpoints (id, url, secret, event_types, active, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+	`
+	eventTypes := make([]string, len(endpoint.EventTypes))
+	for i, eventType := range endpoint.EventTypes {
+		eventTypes[i] = string(eventType)
+	}
+
+	return r.tx.run(ctx, func(tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx, query,
+			endpoint.ID, endpoint.URL, endpoint.Secret, pq.Array(eventTypes), endpoint.Active, endpoint.CreatedAt)
+		if err != nil {
+			return err
+		}
+		return insertAuditRecord(ctx, tx, models.AuditWebhookRegistered, endpoint.ID.String(),
+			nil, auditEndpoint{URL: endpoint.URL, EventTypes: eventTypes, Active: endpoint.Active})
+	})
+}
+
+// auditEndpoint is the audited state of a webhook endpoint; the secret is left out
+type auditEndpoint struct {
+	URL        string   `json:"url"`
+	EventTypes []string `json:"eventTypes"`
+	Active     bool     `json:"active"`
+}
+
+func (r *webhookRepository) ListEndpoints(ctx context.Context) ([]models.WebhookEndpoint, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT id, url, event_types, active, created_at
+		FROM webhook_endpoints
+		ORDER BY created_at
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	endpoints := []models.WebhookEndpoint{}
+	for rows.Next() {
+		var endpoint models.WebhookEndpoint
+		var eventTypes []string
+		if err := rows.Scan(&endpoint.ID, &endpoint.URL, pq.Array(&eventTypes), &endpoint.Active, &endpoint.CreatedAt); err != nil {
+			return nil, err
+		}
+		for _, eventType := range eventTypes {
+			endpoint.EventTypes = append(endpoint.EventTypes, models.WebhookEventType(eventType))
+		}
+		endpoints = append(endpoints, endpoint)
+	}
+	return endpoints, rows.Err()
+}
+
+func (r *webhookRepository) DeleteEndpoint(ctx context.Context, endpointID uuid.UUID) error {
+	return r.tx.run(ctx, func(tx *sql.Tx) error {
+		var deleted auditEndpoint
+		err := tx.QueryRowContext(ctx,
+			"DELETE FROM webhook_endpoints WHERE id = $1 RETURNING url, event_types, active",
+			endpointID).Scan(&deleted.URL, pq.Array(&deleted.EventTypes), &deleted.Active)
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrWebhookEndpointNotFound
+		}
+		if err != nil {
+			return err
+		}
+		return insertAuditRecord(ctx, tx, models.AuditWebhookDeleted, endpointID.String(), deleted, nil)
+	})
+}
+
+// EnqueueDeliveries creates one delivery per active endpoint subscribed to eventType.
+// Re-enqueueing the same event is a no-op, so the outbox relay may safely retry.
+func (r *webhookRepository) EnqueueDeliveries(ctx context.Context, eventID int64, eventType models.WebhookEventType, payload []byte) (int, error) {
+	query := `
+		INSERT INTO webhook_deliveries (endpoint_id, event_id, event_type, payload, next_attempt_at)
+		SELECT id, $1, $2, $3, $4
+		FROM webhook_endpoints
+		WHERE active AND $2 = ANY(event_types)
+		ON CONFLICT (endpoint_id, event_id, event_type) DO NOTHING
+	`
+	res, err := r.db.ExecContext(ctx, query, eventID, string(eventType), payload, time.Now())
+	if err != nil {
+		return 0, err
+	}
+	affected, err := res.RowsAffected()
+	return int(affected), err
+}
+
+// ClaimDueDeliveries leases due deliveries by pushing next_attempt_at forward, so concurrent
+// workers skip them while the HTTP call is in flight
+func (r *webhookRepository) ClaimDueDeliveries(ctx context.Context, limit int, lease time.Duration) ([]models.WebhookDelivery, error) {
+	now := time.Now()
+	query := `
+		WITH due AS (
+			SELECT id
+			FROM webhook_deliveries
+			WHERE delivered_at IS NULL AND next_attempt_at <= $1
+			ORDER BY next_attempt_at
+			LIMIT $2
+			FOR UPDATE SKIP LOCKED
+		)
+		UPDATE webhook_deliveries d
+		SET next_attempt_at = $3
+		FROM due, webhook_endpoints e
+		WHERE d.id = due.id AND e.id = d.endpoint_id
+		RETURNING d.id, d.endpoint_id, e.url, e.secret, d.event_id, d.event_type, d.payload, d.attempts
+	`
+	rows, err := r.db.QueryContext(ctx, query, now, limit, now.Add(lease))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var deliveries []models.WebhookDelivery
+	for rows.Next() {
+		var d models.WebhookDelivery
+		if err := rows.Scan(&d.ID, &d.EndpointID, &d.EndpointURL, &d.Secret, &d.EventID, &d.EventType, &d.Payload, &d.Attempts); err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, d)
+	}
+	return deliveries, rows.Err()
+}
+
+func (r *webhookRepository) MarkDelivered(ctx context.Context, deliveryID int64) error {
+	_, err := r.db.ExecContext(ctx,
+		"UPDATE webhook_deliveries SET delivered_at = $1, attempts = attempts + 1, last_error = NULL WHERE id = $2",
+		time.Now(), deliveryID)
+	return err
+}
+
+func (r *webhookRepository) MarkFailed(ctx context.Context, deliveryID int64, lastError string, nextAttemptAt time.Time) error {
+	_, err := r.db.ExecContext(ctx,
+		"UPDATE webhook_deliveries SET attempts = attempts + 1, last_error = $1, next_attempt_at = $2 WHERE id = $3",
+		lastError, nextAttemptAt, deliveryID)
+	return err
+}
+
+func (r *webhookRepository) MoveToDeadLetter(ctx context.Context, deliveryID int64, lastError string) error {
+	return r.tx.run(ctx, func(tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx, `
+			INSERT INTO webhook_dead_letters (endpoint_id, event_id, event_type, payload, attempts, last_error, failed_at)
+			SELECT endpoint_id, event_id, event_type, payload, attempts + 1, $1, $2
+			FROM webhook_deliveries
+			WHERE id = $3
+		`, lastError, time.Now(), deliveryID)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx, "DELETE FROM webhook_deliveries WHERE id = $1", deliveryID)
+		return err
+	})
+}
+
+func (r *webhookRepository) ListDeadLetters(ctx context.Context, limit int) ([]models.WebhookDeadLetter, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT id, endpoint_id, event_id, event_type, payload, attempts, last_error, failed_at, replayed_at
+		FROM webhook_dead_letters
+		ORDER BY id DESC
+		LIMIT $1
+	`, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	deadLetters := []models.WebhookDeadLetter{}
+	for rows.Next() {
+		var dl models.WebhookDeadLetter
+		if err := rows.Scan(&dl.ID, &dl.EndpointID, &dl.EventID, &dl.EventType, &dl.Payload, &dl.Attempts, &dl.LastError, &dl.FailedAt, &dl.ReplayedAt); err != nil {
+			return nil, err
+		}
+		deadLetters = append(deadLetters, dl)
+	}
+	return deadLetters, rows.Err()
+}
+
+// auditDeadLetter is the audited state of a dead letter
+type auditDeadLetter struct {
+	Replayed bool `json:"replayed"`
+}
+
+// ReplayDeadLetter puts a dead delivery back into the queue with a fresh attempt counter
+func (r *webhookRepository) ReplayDeadLetter(ctx context.Context, deadLetterID int64) error {
+	return r.tx.run(ctx, func(tx *sql.Tx) error {
+		now := time.Now()
+		res, err := tx.ExecContext(ctx,
+			"UPDATE webhook_dead_letters SET replayed_at = $1 WHERE id = $2 AND replayed_at IS NULL",
+			now, deadLetterID)
+		if err != nil {
+			return err
+		}
+		affected, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if affected == 0 {
+			return ErrDeadLetterNotFound
+		}
+
+		_, err = tx.ExecContext(ctx, `
+			INSERT INTO webhook_deliveries (endpoint_id, event_id, event_type, payload, next_attempt_at)
+			SELECT endpoint_id, event_id, event_type, payload, $1
+			FROM webhook_dead_letters
+			WHERE id = $2
+			ON CONFLICT (endpoint_id, event_id, event_type) DO NOTHING
+		`, now, deadLetterID)
+		if err != nil {
+			return err
+		}
+		return insertAuditRecord(ctx, tx, models.AuditWebhookDeadLetterReplay, strconv.FormatInt(deadLetterID, 10),
+			auditDeadLetter{Replayed: false}, auditDeadLetter{Replayed: true})
+	})
+}
@@ -0,0 +1,179 @@
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"ITKtest/internal/models"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWebhookRepository_Endpoints(t *testing.T) {
+	endpoint := &models.WebhookEndpoint{
+		ID:         uuid.New(),
+		URL:        "https://partner.example.com/hooks",
+		Secret:     "secret",
+		EventTypes: []models.WebhookEventType{models.WebhookDeposit, models.WebhookFee},
+		Active:     true,
+		CreatedAt:  time.Now(),
+	}
+
+	t.Run("create records the endpoint and its audit entry", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		assert.NoError(t, err)
+		defer db.Close()
+
+		mock.ExpectBegin()
+		mock.ExpectExec(`INSERT INTO webhook_endpoints \(id, url, secret, event_types, active, created_at\)`).
+			WithArgs(endpoint.ID, endpoint.URL, endpoint.Secret, pq.Array([]string{"deposit", "fee"}), true, endpoint.CreatedAt).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		expectAuditRecord(mock)
+		mock.ExpectCommit()
+
+		assert.NoError(t, NewWebhookRepository(db, TxConfig{}).CreateEndpoint(context.Background(), endpoint))
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("list", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		assert.NoError(t, err)
+		defer db.Close()
+
+		mock.ExpectQuery(`SELECT id, url, event_types, active, created_at FROM webhook_endpoints ORDER BY created_at`).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "url", "event_types", "active", "created_at"}).
+				AddRow(endpoint.ID, endpoint.URL, "{deposit,fee}", true, endpoint.CreatedAt))
+
+		endpoints, err := NewWebhookRepository(db, TxConfig{}).ListEndpoints(context.Background())
+		assert.NoError(t, err)
+		assert.Len(t, endpoints, 1)
+		assert.Equal(t, endpoint.EventTypes, endpoints[0].EventTypes)
+		assert.Empty(t, endpoints[0].Secret)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("delete unknown endpoint", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		assert.NoError(t, err)
+		defer db.Close()
+
+		mock.ExpectBegin()
+		mock.ExpectQuery(`DELETE FROM webhook_endpoints WHERE id = \$1 RETURNING url, event_types, active`).
+			WithArgs(endpoint.ID).
+			WillReturnRows(sqlmock.NewRows([]string{"url", "event_types", "active"}))
+		mock.ExpectRollback()
+
+		err = NewWebhookRepository(db, TxConfig{}).DeleteEndpoint(context.Background(), endpoint.ID)
+		assert.ErrorIs(t, err, ErrWebhookEndpointNotFound)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestWebhookRepository_Deliveries(t *testing.T) {
+	endpointID := uuid.New()
+
+	t.Run("enqueue for subscribed endpoints", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		assert.NoError(t, err)
+		defer db.Close()
+
+		mock.ExpectExec(`INSERT INTO webhook_deliveries .* WHERE active AND \$2 = ANY\(event_types\) ON CONFLICT \(endpoint_id, event_id, event_type\) DO NOTHING`).
+			WithArgs(7, "deposit", []byte(`{}`), sqlmock.AnyArg()).
+			WillReturnResult(sqlmock.NewResult(0, 2))
+
+		n, err := NewWebhookRepository(db, TxConfig{}).EnqueueDeliveries(context.Background(), 7, models.WebhookDeposit, []byte(`{}`))
+		assert.NoError(t, err)
+		assert.Equal(t, 2, n)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("claim leases due deliveries", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		assert.NoError(t, err)
+		defer db.Close()
+
+		mock.ExpectQuery(`WITH due AS \( SELECT id FROM webhook_deliveries WHERE delivered_at IS NULL AND next_attempt_at <= \$1 .* FOR UPDATE SKIP LOCKED \) UPDATE webhook_deliveries d SET next_attempt_at = \$3`).
+			WithArgs(sqlmock.AnyArg(), 50, sqlmock.AnyArg()).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "endpoint_id", "url", "secret", "event_id", "event_type", "payload", "attempts"}).
+				AddRow(3, endpointID, "https://partner.example.com/hooks", "secret", 7, "deposit", []byte(`{}`), 2))
+
+		deliveries, err := NewWebhookRepository(db, TxConfig{}).ClaimDueDeliveries(context.Background(), 50, time.Minute)
+		assert.NoError(t, err)
+		assert.Len(t, deliveries, 1)
+		assert.Equal(t, "secret", deliveries[0].Secret)
+		assert.Equal(t, models.WebhookDeposit, deliveries[0].EventType)
+		assert.Equal(t, 2, deliveries[0].Attempts)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("record outcomes", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		assert.NoError(t, err)
+		defer db.Close()
+		retryAt := time.Now().Add(time.Minute)
+
+		mock.ExpectExec(`UPDATE webhook_deliveries SET delivered_at = \$1, attempts = attempts \+ 1, last_error = NULL WHERE id = \$2`).
+			WithArgs(sqlmock.AnyArg(), 1).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(`UPDATE webhook_deliveries SET attempts = attempts \+ 1, last_error = \$1, next_attempt_at = \$2 WHERE id = \$3`).
+			WithArgs("endpoint responded with status 500", retryAt, 2).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectBegin()
+		mock.ExpectExec(`INSERT INTO webhook_dead_letters .* FROM webhook_deliveries WHERE id = \$3`).
+			WithArgs("endpoint responded with status 500", sqlmock.AnyArg(), 3).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec(`DELETE FROM webhook_deliveries WHERE id = \$1`).
+			WithArgs(3).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		repo := NewWebhookRepository(db, TxConfig{})
+		assert.NoError(t, repo.MarkDelivered(context.Background(), 1))
+		assert.NoError(t, repo.MarkFailed(context.Background(), 2, "endpoint responded with status 500", retryAt))
+		assert.NoError(t, repo.MoveToDeadLetter(context.Background(), 3, "endpoint responded with status 500"))
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestWebhookRepository_ReplayDeadLetter(t *testing.T) {
+	replay := `UPDATE webhook_dead_letters SET replayed_at = \$1 WHERE id = \$2 AND replayed_at IS NULL`
+
+	t.Run("requeues the delivery", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		assert.NoError(t, err)
+		defer db.Close()
+
+		mock.ExpectBegin()
+		mock.ExpectExec(replay).
+			WithArgs(sqlmock.AnyArg(), 4).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(`INSERT INTO webhook_deliveries .* FROM webhook_dead_letters WHERE id = \$2`).
+			WithArgs(sqlmock.AnyArg(), 4).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		expectAuditRecord(mock)
+		mock.ExpectCommit()
+
+		assert.NoError(t, NewWebhookRepository(db, TxConfig{}).ReplayDeadLetter(context.Background(), 4))
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("already replayed", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		assert.NoError(t, err)
+		defer db.Close()
+
+		mock.ExpectBegin()
+		mock.ExpectExec(replay).
+			WithArgs(sqlmock.AnyArg(), 4).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectRollback()
+
+		err = NewWebhookRepository(db, TxConfig{}).ReplayDeadLetter(context.Background(), 4)
+		assert.ErrorIs(t, err, ErrDeadLetterNotFound)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
@@ -0,0 +1,64 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseCron_Invalid(t *testing.T) {
+	for _, expr := range []string{
+		"* * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"*/0 * * * *",
+		"5-1 * * * *",
+		"a * * * *",
+		"@every 5m",
+	} {
+		_, err := ParseCron(expr)
+		assert.ErrorContains(t, err, "invalid cron expression", expr)
+	}
+}
+
+func TestCron_Next(t *testing.T) {
+	moscow, err := time.LoadLocation("Europe/Moscow")
+	require.NoError(t, err)
+	newYork, err := time.LoadLocation("America/New_York")
+	require.NoError(t, err)
+	after := time.Date(2026, 3, 1, 10, 7, 30, 0, time.UTC) // a Sunday
+
+	tests := []struct {
+		expr     string
+		loc      *time.Location
+		after    time.Time
+		expected time.Time
+	}{
+		{"*/15 * * * *", time.UTC, after, time.Date(2026, 3, 1, 10, 15, 0, 0, time.UTC)},
+		{"0 9 1 * *", moscow, after, time.Date(2026, 4, 1, 9, 0, 0, 0, moscow)},
+		{"30 8 * * 1-5", time.UTC, after, time.Date(2026, 3, 2, 8, 30, 0, 0, time.UTC)},
+		{"0 12 * * 7", time.UTC, after, time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)},
+		// Either restricted day field matches
+		{"0 0 15 * 3", time.UTC, after, time.Date(2026, 3, 4, 0, 0, 0, 0, time.UTC)},
+		{"@daily", time.UTC, after, time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.UTC, after, time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{"0 0 30 2 *", time.UTC, after, time.Time{}},
+		// 02:30 does not exist on the day clocks go forward; the search moves on to the next day
+		{"30 2 * * *", newYork, time.Date(2026, 3, 8, 0, 0, 0, 0, newYork), time.Date(2026, 3, 9, 2, 30, 0, 0, newYork)},
+		// The hour repeated when clocks go back is not skipped
+		{"30 1 * * *", newYork, time.Date(2026, 11, 1, 0, 0, 0, 0, newYork), time.Date(2026, 11, 1, 1, 30, 0, 0, newYork)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			cron, err := ParseCron(tt.expr)
+			require.NoError(t, err)
+			next := cron.Next(tt.after, tt.loc)
+			assert.True(t, tt.expected.Equal(next), "expected %s, got %s", tt.expected, next)
+		})
+	}
+}
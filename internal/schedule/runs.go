@@ -0,0 +1,89 @@
+package schedule
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"ITKtest/internal/models"
+)
+
+// FirstRun returns when a new schedule runs first: at RunAt, or for cron schedules without
+// one at the first match after now, or for interval schedules without one right away
+func FirstRun(s models.Schedule, now time.Time) (time.Time, error) {
+	if s.RunAt != nil {
+		return *s.RunAt, nil
+	}
+	if s.Cron == "" {
+		return now, nil
+	}
+	return nextCron(s, now)
+}
+
+// NextRun returns the run of s following the one due at due, or nil if s does not recur or
+// has reached MaxRuns with the run due at due
+func NextRun(s models.Schedule, due time.Time) (*time.Time, error) {
+	if !s.Recurring() || (s.MaxRuns > 0 && s.RunCount+1 >= s.MaxRuns) {
+		return nil, nil
+	}
+	if s.IntervalSeconds > 0 {
+		next := due.Add(time.Duration(s.IntervalSeconds) * time.Second)
+		return &next, nil
+	}
+	next, err := nextCron(s, due)
+	if err != nil {
+		return nil, err
+	}
+	return &next, nil
+}
+
+// ResumeRun returns the run of a recurring schedule resumed at now, skipping the runs missed
+// while it was paused. One-off schedules keep their run, however late.
+func ResumeRun(s models.Schedule, now time.Time) (time.Time, error) {
+	if s.NextRunAt == nil {
+		return time.Time{}, errors.New("schedule has no pending run")
+	}
+	next := *s.NextRunAt
+	if !s.Recurring() || next.After(now) {
+		return next, nil
+	}
+	if s.IntervalSeconds > 0 {
+		interval := time.Duration(s.IntervalSeconds) * time.Second
+		missed := now.Sub(next)/interval + 1
+		return next.Add(missed * interval), nil
+	}
+	return nextCron(s, now)
+}
+
+// Location returns the time zone of the cron expression of s, UTC by default
+func Location(s models.Schedule) (*time.Location, error) {
+	if s.TimeZone == "" {
+		return time.UTC, nil
+	}
+	loc, err := time.LoadLocation(s.TimeZone)
+	if err != nil {
+		return nil, fmt.Errorf("invalid time zone %q", s.TimeZone)
+	}
+	return loc, nil
+}
+
+func nextCron(s models.Schedule, after time.Time) (time.Time, error) {
+	cron, err := ParseCron(s.Cron)
+	if err != nil {
+		return time.Time{}, err
+	}
+	loc, err := Location(s)
+	if err != nil {
+		return time.Time{}, err
+	}
+	next := cron.Next(after, loc)
+	if next.IsZero() {
+		return time.Time{}, fmt.Errorf("invalid cron expression %q: no run within five years", s.Cron)
+	}
+	return next, nil
+}
+
+// IdempotencyKey identifies the run of schedule id due at due in the journal
+func IdempotencyKey(id int64, due time.Time) string {
+	return fmt.Sprintf("schedule-%d-%d", id, due.Unix())
+}
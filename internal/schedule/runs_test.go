@@ -0,0 +1,51 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+
+	"ITKtest/internal/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNextRun(t *testing.T) {
+	due := time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+
+	next, err := NextRun(models.Schedule{RunAt: &due}, due)
+	require.NoError(t, err)
+	assert.Nil(t, next, "one-off schedules do not recur")
+
+	next, err = NextRun(models.Schedule{IntervalSeconds: 3600}, due)
+	require.NoError(t, err)
+	assert.Equal(t, due.Add(time.Hour), *next)
+
+	next, err = NextRun(models.Schedule{Cron: "0 9 * * *", TimeZone: "Europe/Moscow"}, due)
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2026, 3, 2, 6, 0, 0, 0, time.UTC), next.UTC())
+
+	// The run due now is the last one allowed
+	next, err = NextRun(models.Schedule{IntervalSeconds: 3600, MaxRuns: 3, RunCount: 2}, due)
+	require.NoError(t, err)
+	assert.Nil(t, next)
+}
+
+func TestResumeRun(t *testing.T) {
+	next := time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+	now := next.Add(150 * time.Minute)
+
+	// Runs missed while paused are skipped, keeping the phase of the interval
+	resumed, err := ResumeRun(models.Schedule{IntervalSeconds: 3600, NextRunAt: &next}, now)
+	require.NoError(t, err)
+	assert.Equal(t, next.Add(3*time.Hour), resumed)
+
+	resumed, err = ResumeRun(models.Schedule{Cron: "0 * * * *", NextRunAt: &next}, now)
+	require.NoError(t, err)
+	assert.Equal(t, next.Add(3*time.Hour), resumed)
+
+	// A one-off schedule runs as soon as it is resumed
+	resumed, err = ResumeRun(models.Schedule{RunAt: &next, NextRunAt: &next}, now)
+	require.NoError(t, err)
+	assert.Equal(t, next, resumed)
+}
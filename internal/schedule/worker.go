@@ -0,0 +1,143 @@
+package schedule
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strconv"
+	"time"
+
+	"ITKtest/internal/audit"
+	"ITKtest/internal/auth"
+	"ITKtest/internal/models"
+	"ITKtest/internal/repository"
+)
+
+// Wallets executes the operations of schedule runs; it is the wallet service, so that runs are
+// checked, charged and authorized like the requests they replace
+type Wallets interface {
+	ProcessWalletOperation(ctx context.Context, req models.WalletOperationRequest) error
+	ProcessBatch(ctx context.Context, req models.BatchOperationRequest) (*models.BatchOperationResponse, error)
+}
+
+// Worker executes due schedule runs. Each run is leased while it executes and carries an
+// idempotency key derived from its schedule and due time, so a run whose worker died is retried
+// once the lease expires without being applied twice. Several instances may run the worker.
+type Worker struct {
+	repo      repository.ScheduleRepository
+	wallets   Wallets
+	declined  []error
+	interval  time.Duration
+	lease     time.Duration
+	batchSize int
+	now       func() time.Time
+}
+
+// NewWorker creates a worker recording runs that fail with one of the declined errors as failed;
+// runs failing otherwise are retried
+func NewWorker(repo repository.ScheduleRepository, wallets Wallets, declined []error, interval, lease time.Duration, batchSize int) *Worker {
+	return &Worker{
+		repo:      repo,
+		wallets:   wallets,
+		declined:  declined,
+		interval:  interval,
+		lease:     lease,
+		batchSize: batchSize,
+		now:       time.Now,
+	}
+}
+
+// Run executes due runs every interval until ctx is cancelled
+func (w *Worker) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := w.RunDue(ctx); err != nil && ctx.Err() == nil {
+			log.Printf("scheduled operations: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunDue claims the schedules due now and executes one run of each, returning how many it
+// claimed. Runs missed while no worker was running are executed late, one per call.
+func (w *Worker) RunDue(ctx context.Context) (int, error) {
+	now := w.now()
+	schedules, err := w.repo.ClaimDue(ctx, now, now.Add(w.lease), w.batchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	var errs []error
+	for _, s := range schedules {
+		if err := w.run(ctx, s); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return len(schedules), errors.Join(errs...)
+}
+
+// run executes the run of s due at s.NextRunAt and records its outcome. Errors that may go away,
+// such as a lost connection or read-only mode, are returned and leave the run to be retried.
+func (w *Worker) run(ctx context.Context, s models.Schedule) error {
+	due := *s.NextRunAt
+	key := IdempotencyKey(s.ID, due)
+	runCtx := auth.WithPrincipal(ctx, s.Principal)
+	runCtx = audit.WithRequest(runCtx, "schedule:"+strconv.FormatInt(s.ID, 10), key)
+
+	run := models.ScheduleRun{ScheduleID: s.ID, DueAt: due, IdempotencyKey: key, Status: models.RunSucceeded}
+	err := w.execute(runCtx, s, key)
+	switch {
+	case err == nil, errors.Is(err, repository.ErrDuplicateOperation):
+	case w.isDeclined(err):
+		run.Status, run.Error = models.RunFailed, err.Error()
+	default:
+		return err
+	}
+
+	next, err := NextRun(s, due)
+	if err != nil {
+		return err
+	}
+	run.FinishedAt = w.now()
+	return w.repo.CompleteRun(ctx, run, next)
+}
+
+func (w *Worker) execute(ctx context.Context, s models.Schedule, key string) error {
+	if s.OperationType != models.TRANSFER {
+		return w.wallets.ProcessWalletOperation(ctx, models.WalletOperationRequest{
+			WalletID:       s.WalletID,
+			OperationType:  s.OperationType,
+			Amount:         s.Amount,
+			Reference:      s.Reference,
+			IdempotencyKey: key,
+		})
+	}
+
+	// The key is claimed by the withdrawal; the deposit commits or rolls back with it
+	_, err := w.wallets.ProcessBatch(ctx, models.BatchOperationRequest{
+		Mode: models.BatchAtomic,
+		Operations: []models.WalletOperationRequest{
+			{WalletID: s.WalletID, OperationType: models.WITHDRAW, Amount: s.Amount, Reference: s.Reference, IdempotencyKey: key},
+			{WalletID: *s.ToWalletID, OperationType: models.DEPOSIT, Amount: s.Amount, Reference: s.Reference},
+		},
+	})
+	return err
+}
+
+// isDeclined reports whether err declines the operation for good: the run failed and the
+// schedule moves on to its next run
+func (w *Worker) isDeclined(err error) bool {
+	for _, target := range w.declined {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,117 @@
+package schedule
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"ITKtest/internal/auth"
+	"ITKtest/internal/models"
+	"ITKtest/internal/repository"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeWallets records the operations of runs and fails them with err
+type fakeWallets struct {
+	operations []models.WalletOperationRequest
+	batches    []models.BatchOperationRequest
+	principals []string
+	err        error
+}
+
+func (f *fakeWallets) ProcessWalletOperation(ctx context.Context, req models.WalletOperationRequest) error {
+	f.operations = append(f.operations, req)
+	f.principals = append(f.principals, auth.Principal(ctx))
+	return f.err
+}
+
+func (f *fakeWallets) ProcessBatch(ctx context.Context, req models.BatchOperationRequest) (*models.BatchOperationResponse, error) {
+	f.batches = append(f.batches, req)
+	f.principals = append(f.principals, auth.Principal(ctx))
+	return &models.BatchOperationResponse{}, f.err
+}
+
+func TestWorker_RunDue(t *testing.T) {
+	now := time.Date(2026, 3, 1, 9, 0, 30, 0, time.UTC)
+	due := now.Add(-30 * time.Second)
+	walletID, toWalletID := uuid.New(), uuid.New()
+	key := fmt.Sprintf("schedule-7-%d", due.Unix())
+	recurring := models.Schedule{ID: 7, WalletID: walletID, OperationType: models.WITHDRAW, Amount: 500, IntervalSeconds: 3600, NextRunAt: &due, Principal: "billing"}
+
+	newWorker := func(repo repository.ScheduleRepository, wallets Wallets) *Worker {
+		w := NewWorker(repo, wallets, []error{repository.ErrInsufficientFunds}, time.Second, time.Minute, 10)
+		w.now = func() time.Time { return now }
+		return w
+	}
+	succeeded := models.ScheduleRun{ScheduleID: 7, DueAt: due, IdempotencyKey: key, Status: models.RunSucceeded, FinishedAt: now}
+	nextRun := due.Add(time.Hour)
+
+	t.Run("run is executed as its creator with an idempotency key", func(t *testing.T) {
+		repo := &repository.MockScheduleRepository{}
+		repo.On("ClaimDue", mock.Anything, now, now.Add(time.Minute), 10).Return([]models.Schedule{recurring}, nil)
+		repo.On("CompleteRun", mock.Anything, succeeded, &nextRun).Return(nil)
+		wallets := &fakeWallets{}
+
+		n, err := newWorker(repo, wallets).RunDue(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, 1, n)
+		assert.Equal(t, []models.WalletOperationRequest{{WalletID: walletID, OperationType: models.WITHDRAW, Amount: 500, IdempotencyKey: key}}, wallets.operations)
+		assert.Equal(t, []string{"billing"}, wallets.principals)
+		repo.AssertExpectations(t)
+	})
+
+	t.Run("a run already applied is recorded as succeeded", func(t *testing.T) {
+		repo := &repository.MockScheduleRepository{}
+		repo.On("ClaimDue", mock.Anything, now, now.Add(time.Minute), 10).Return([]models.Schedule{recurring}, nil)
+		repo.On("CompleteRun", mock.Anything, succeeded, &nextRun).Return(nil)
+
+		_, err := newWorker(repo, &fakeWallets{err: repository.ErrDuplicateOperation}).RunDue(context.Background())
+		require.NoError(t, err)
+		repo.AssertExpectations(t)
+	})
+
+	t.Run("a declined run is recorded as failed and the schedule moves on", func(t *testing.T) {
+		repo := &repository.MockScheduleRepository{}
+		repo.On("ClaimDue", mock.Anything, now, now.Add(time.Minute), 10).Return([]models.Schedule{recurring}, nil)
+		failed := succeeded
+		failed.Status, failed.Error = models.RunFailed, "withdraw: insufficient funds"
+		repo.On("CompleteRun", mock.Anything, failed, &nextRun).Return(nil)
+
+		_, err := newWorker(repo, &fakeWallets{err: fmt.Errorf("withdraw: %w", repository.ErrInsufficientFunds)}).RunDue(context.Background())
+		require.NoError(t, err)
+		repo.AssertExpectations(t)
+	})
+
+	t.Run("a run that could not execute is left for retry", func(t *testing.T) {
+		repo := &repository.MockScheduleRepository{}
+		repo.On("ClaimDue", mock.Anything, now, now.Add(time.Minute), 10).Return([]models.Schedule{recurring}, nil)
+
+		_, err := newWorker(repo, &fakeWallets{err: errors.New("service is in read-only mode")}).RunDue(context.Background())
+		assert.ErrorContains(t, err, "read-only mode")
+		repo.AssertNotCalled(t, "CompleteRun", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("transfer runs as an atomic batch and one-off schedules complete", func(t *testing.T) {
+		transfer := models.Schedule{ID: 7, WalletID: walletID, OperationType: models.TRANSFER, Amount: 500, ToWalletID: &toWalletID, RunAt: &due, NextRunAt: &due}
+		repo := &repository.MockScheduleRepository{}
+		repo.On("ClaimDue", mock.Anything, now, now.Add(time.Minute), 10).Return([]models.Schedule{transfer}, nil)
+		repo.On("CompleteRun", mock.Anything, succeeded, (*time.Time)(nil)).Return(nil)
+		wallets := &fakeWallets{}
+
+		_, err := newWorker(repo, wallets).RunDue(context.Background())
+		require.NoError(t, err)
+		require.Len(t, wallets.batches, 1)
+		assert.Equal(t, models.BatchAtomic, wallets.batches[0].Mode)
+		assert.Equal(t, []models.WalletOperationRequest{
+			{WalletID: walletID, OperationType: models.WITHDRAW, Amount: 500, IdempotencyKey: key},
+			{WalletID: toWalletID, OperationType: models.DEPOSIT, Amount: 500},
+		}, wallets.batches[0].Operations)
+		repo.AssertExpectations(t)
+	})
+}
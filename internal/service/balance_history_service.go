@@ -0,0 +1,78 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"ITKtest/internal/models"
+	"ITKtest/internal/repository"
+
+	"github.com/google/uuid"
+)
+
+type BalanceHistoryService interface {
+	GetBalanceAt(ctx context.Context, walletID uuid.UUID, at time.Time) (int64, error)
+	// WriteStatement passes write the opening balance as of from, every transaction after it up
+	// to and including to with the running balance, and the closing balance as of to. Nothing is
+	// written if the wallet does not exist or the range is invalid.
+	WriteStatement(ctx context.Context, walletID uuid.UUID, from, to time.Time, write func(models.StatementLine) error) error
+}
+
+type balanceHistoryService struct {
+	repo repository.SnapshotRepository
+	now  func() time.Time
+}
+
+// Errors returned by BalanceHistoryService for times it cannot answer for
+var (
+	ErrFutureTime   = errors.New("time must not be in the future")
+	ErrInvalidRange = errors.New("from must not be after to")
+)
+
+func NewBalanceHistoryService(repo repository.SnapshotRepository) BalanceHistoryService {
+	return &balanceHistoryService{repo: repo, now: time.Now}
+}
+
+// GetBalanceAt returns the balance as of at, which must not be in the future
+func (s *balanceHistoryService) GetBalanceAt(ctx context.Context, walletID uuid.UUID, at time.Time) (int64, error) {
+	if at.After(s.now()) {
+		return 0, ErrFutureTime
+	}
+	return s.repo.BalanceAt(ctx, walletID, at)
+}
+
+func (s *balanceHistoryService) WriteStatement(ctx context.Context, walletID uuid.UUID, from, to time.Time, write func(models.StatementLine) error) error {
+	if from.After(to) {
+		return ErrInvalidRange
+	}
+	if to.After(s.now()) {
+		return ErrFutureTime
+	}
+
+	balance, err := s.repo.BalanceAt(ctx, walletID, from)
+	if err != nil {
+		return err
+	}
+	if err := write(models.StatementLine{Type: models.StatementOpening, Balance: balance, At: from}); err != nil {
+		return err
+	}
+
+	err = s.repo.StreamTransactions(ctx, walletID, from, to, func(t models.Transaction) error {
+		balance += t.SignedAmount()
+		return write(models.StatementLine{
+			Type:          models.StatementTransaction,
+			TransactionID: t.ID,
+			OperationType: t.OperationType,
+			Amount:        t.Amount,
+			Reference:     t.Reference,
+			Balance:       balance,
+			At:            t.CreatedAt,
+		})
+	})
+	if err != nil {
+		return err
+	}
+
+	return write(models.StatementLine{Type: models.StatementClosing, Balance: balance, At: to})
+}
@@ -0,0 +1,69 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"ITKtest/internal/models"
+	"ITKtest/internal/repository"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestBalanceHistoryService_WriteStatement(t *testing.T) {
+	walletID := uuid.New()
+	from := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	to := from.Add(24 * time.Hour)
+	ctx := context.Background()
+
+	newService := func(repo repository.SnapshotRepository) *balanceHistoryService {
+		return &balanceHistoryService{repo: repo, now: func() time.Time { return to.Add(time.Hour) }}
+	}
+
+	t.Run("running balance", func(t *testing.T) {
+		repo := &repository.MockSnapshotRepository{}
+		repo.On("BalanceAt", ctx, walletID, from).Return(int64(1000), nil)
+		repo.On("StreamTransactions", ctx, walletID, from, to, mock.Anything).Return([]models.Transaction{
+			{ID: 1, OperationType: models.DEPOSIT, Amount: 500, CreatedAt: from.Add(time.Hour)},
+			{ID: 2, OperationType: models.WITHDRAW, Amount: 300, CreatedAt: from.Add(2 * time.Hour)},
+		}, nil)
+
+		var lines []models.StatementLine
+		err := newService(repo).WriteStatement(ctx, walletID, from, to, func(line models.StatementLine) error {
+			lines = append(lines, line)
+			return nil
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, []models.StatementLine{
+			{Type: models.StatementOpening, Balance: 1000, At: from},
+			{Type: models.StatementTransaction, TransactionID: 1, OperationType: models.DEPOSIT, Amount: 500, Balance: 1500, At: from.Add(time.Hour)},
+			{Type: models.StatementTransaction, TransactionID: 2, OperationType: models.WITHDRAW, Amount: 300, Balance: 1200, At: from.Add(2 * time.Hour)},
+			{Type: models.StatementClosing, Balance: 1200, At: to},
+		}, lines)
+		repo.AssertExpectations(t)
+	})
+
+	t.Run("unknown wallet writes nothing", func(t *testing.T) {
+		repo := &repository.MockSnapshotRepository{}
+		repo.On("BalanceAt", ctx, walletID, from).Return(int64(0), errors.New("wallet not found"))
+
+		err := newService(repo).WriteStatement(ctx, walletID, from, to, func(models.StatementLine) error {
+			t.Fatal("unexpected write")
+			return nil
+		})
+		assert.EqualError(t, err, "wallet not found")
+	})
+
+	t.Run("invalid range", func(t *testing.T) {
+		repo := &repository.MockSnapshotRepository{}
+		err := newService(repo).WriteStatement(ctx, walletID, to, from, nil)
+		assert.ErrorIs(t, err, ErrInvalidRange)
+
+		err = newService(repo).WriteStatement(ctx, walletID, from, to.Add(2*time.Hour), nil)
+		assert.ErrorIs(t, err, ErrFutureTime)
+	})
+}
@@ -0,0 +1,77 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"ITKtest/internal/models"
+	"ITKtest/internal/repository"
+
+	"github.com/google/uuid"
+)
+
+// BonusService credits promotional lots to wallets and reports what is left of them. Lots are
+// spent by withdrawals, see NewBonusWalletService, and expired by the bonus.Expirer.
+type BonusService interface {
+	CreditBonus(ctx context.Context, walletID uuid.UUID, req models.BonusCreditRequest) (*models.BonusLot, error)
+	GetBonusBalance(ctx context.Context, walletID uuid.UUID) (*models.BonusBalanceResponse, error)
+	ListBonusEntries(ctx context.Context, walletID uuid.UUID, afterID int64, limit int) ([]models.BonusEntry, error)
+}
+
+type bonusService struct {
+	repo       repository.BonusRepository
+	defaultTTL time.Duration
+	now        func() time.Time
+}
+
+// ErrPastExpiry is returned for credits whose lot would already be expired
+var ErrPastExpiry = errors.New("expiry must be in the future")
+
+// NewBonusService returns a service crediting lots that expire after defaultTTL unless the
+// request sets their expiry
+func NewBonusService(repo repository.BonusRepository, defaultTTL time.Duration) BonusService {
+	return &bonusService{repo: repo, defaultTTL: defaultTTL, now: time.Now}
+}
+
+func (s *bonusService) CreditBonus(ctx context.Context, walletID uuid.UUID, req models.BonusCreditRequest) (*models.BonusLot, error) {
+	if req.Amount <= 0 {
+		return nil, ErrNonPositiveAmount
+	}
+	if err := validateReference(req.Reference); err != nil {
+		return nil, err
+	}
+
+	now := s.now()
+	expiresAt := now.Add(s.defaultTTL)
+	if req.ExpiresAt != nil {
+		if !req.ExpiresAt.After(now) {
+			return nil, ErrPastExpiry
+		}
+		expiresAt = *req.ExpiresAt
+	}
+
+	lot := &models.BonusLot{WalletID: walletID, Amount: req.Amount, ExpiresAt: expiresAt, Reference: req.Reference}
+	if err := s.repo.CreditLot(ctx, lot); err != nil {
+		return nil, err
+	}
+	return lot, nil
+}
+
+// GetBonusBalance sums the lots not yet expired, including those the expirer has not reached
+// yet. Wallets without bonus have a balance of zero.
+func (s *bonusService) GetBonusBalance(ctx context.Context, walletID uuid.UUID) (*models.BonusBalanceResponse, error) {
+	lots, err := s.repo.ActiveLots(ctx, walletID, s.now())
+	if err != nil {
+		return nil, err
+	}
+	resp := &models.BonusBalanceResponse{WalletID: walletID, Lots: lots}
+	for _, lot := range lots {
+		resp.Balance += lot.Remaining
+	}
+	return resp, nil
+}
+
+func (s *bonusService) ListBonusEntries(ctx context.Context, walletID uuid.UUID, afterID int64, limit int) ([]models.BonusEntry, error) {
+	return s.repo.ListEntries(ctx, walletID, afterID, limit)
+}
@@ -0,0 +1,67 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"ITKtest/internal/models"
+	"ITKtest/internal/repository"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBonusService(t *testing.T) {
+	walletID := uuid.New()
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	ctx := context.Background()
+
+	newService := func(repo repository.BonusRepository) *bonusService {
+		return &bonusService{repo: repo, defaultTTL: 30 * 24 * time.Hour, now: func() time.Time { return now }}
+	}
+
+	t.Run("credits expire after the default lifetime", func(t *testing.T) {
+		repo := &repository.MockBonusRepository{}
+		repo.On("CreditLot", ctx, mock.MatchedBy(func(lot *models.BonusLot) bool {
+			return lot.WalletID == walletID && lot.Amount == 500 && lot.ExpiresAt.Equal(now.Add(30*24*time.Hour)) && lot.Reference == "spring"
+		})).Return(int64(3), nil)
+
+		lot, err := newService(repo).CreditBonus(ctx, walletID, models.BonusCreditRequest{Amount: 500, Reference: "spring"})
+		require.NoError(t, err)
+		assert.Equal(t, int64(3), lot.ID)
+		assert.Equal(t, int64(500), lot.Remaining)
+		repo.AssertExpectations(t)
+	})
+
+	t.Run("credits may set their own expiry in the future", func(t *testing.T) {
+		repo := &repository.MockBonusRepository{}
+		expiresAt := now.Add(time.Hour)
+		repo.On("CreditLot", ctx, mock.MatchedBy(func(lot *models.BonusLot) bool {
+			return lot.ExpiresAt.Equal(expiresAt)
+		})).Return(int64(4), nil)
+
+		_, err := newService(repo).CreditBonus(ctx, walletID, models.BonusCreditRequest{Amount: 500, ExpiresAt: &expiresAt})
+		require.NoError(t, err)
+
+		past := now.Add(-time.Second)
+		_, err = newService(repo).CreditBonus(ctx, walletID, models.BonusCreditRequest{Amount: 500, ExpiresAt: &past})
+		assert.ErrorIs(t, err, ErrPastExpiry)
+		_, err = newService(repo).CreditBonus(ctx, walletID, models.BonusCreditRequest{Amount: 0})
+		assert.ErrorIs(t, err, ErrNonPositiveAmount)
+		repo.AssertNumberOfCalls(t, "CreditLot", 1)
+	})
+
+	t.Run("balance sums the unexpired lots", func(t *testing.T) {
+		repo := &repository.MockBonusRepository{}
+		lots := []models.BonusLot{{ID: 1, Remaining: 30}, {ID: 2, Remaining: 45}}
+		repo.On("ActiveLots", ctx, walletID, now).Return(lots, nil)
+
+		balance, err := newService(repo).GetBonusBalance(ctx, walletID)
+		require.NoError(t, err)
+		assert.Equal(t, int64(75), balance.Balance)
+		assert.Equal(t, lots, balance.Lots)
+	})
+}
@@ -0,0 +1,33 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"ITKtest/internal/models"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/mock"
+)
+
+// MockBalanceHistoryService мок сервиса истории балансов
+type MockBalanceHistoryService struct {
+	mock.Mock
+}
+
+func (m *MockBalanceHistoryService) GetBalanceAt(ctx context.Context, walletID uuid.UUID, at time.Time) (int64, error) {
+	args := m.Called(ctx, walletID, at)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+// WriteStatement passes the lines set with Return(lines, err) to write
+func (m *MockBalanceHistoryService) WriteStatement(ctx context.Context, walletID uuid.UUID, from, to time.Time, write func(models.StatementLine) error) error {
+	args := m.Called(ctx, walletID, from, to, write)
+	lines, _ := args.Get(0).([]models.StatementLine)
+	for _, line := range lines {
+		if err := write(line); err != nil {
+			return err
+		}
+	}
+	return args.Error(1)
+}
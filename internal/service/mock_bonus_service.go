@@ -0,0 +1,33 @@
+package service
+
+import (
+	"context"
+
+	"ITKtest/internal/models"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/mock"
+)
+
+// MockBonusService мок сервиса бонусных балансов
+type MockBonusService struct {
+	mock.Mock
+}
+
+func (m *MockBonusService) CreditBonus(ctx context.Context, walletID uuid.UUID, req models.BonusCreditRequest) (*models.BonusLot, error) {
+	args := m.Called(ctx, walletID, req)
+	lot, _ := args.Get(0).(*models.BonusLot)
+	return lot, args.Error(1)
+}
+
+func (m *MockBonusService) GetBonusBalance(ctx context.Context, walletID uuid.UUID) (*models.BonusBalanceResponse, error) {
+	args := m.Called(ctx, walletID)
+	balance, _ := args.Get(0).(*models.BonusBalanceResponse)
+	return balance, args.Error(1)
+}
+
+func (m *MockBonusService) ListBonusEntries(ctx context.Context, walletID uuid.UUID, afterID int64, limit int) ([]models.BonusEntry, error) {
+	args := m.Called(ctx, walletID, afterID, limit)
+	entries, _ := args.Get(0).([]models.BonusEntry)
+	return entries, args.Error(1)
+}
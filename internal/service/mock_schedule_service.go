@@ -0,0 +1,57 @@
+package service
+
+import (
+	"context"
+
+	"ITKtest/internal/models"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/mock"
+)
+
+// MockScheduleService мок сервиса запланированных операций
+type MockScheduleService struct {
+	mock.Mock
+}
+
+func (m *MockScheduleService) CreateSchedule(ctx context.Context, req models.ScheduleRequest) (*models.Schedule, error) {
+	args := m.Called(ctx, req)
+	s, _ := args.Get(0).(*models.Schedule)
+	return s, args.Error(1)
+}
+
+func (m *MockScheduleService) GetSchedule(ctx context.Context, id int64) (*models.Schedule, error) {
+	args := m.Called(ctx, id)
+	s, _ := args.Get(0).(*models.Schedule)
+	return s, args.Error(1)
+}
+
+func (m *MockScheduleService) ListSchedules(ctx context.Context, walletID uuid.UUID) ([]models.Schedule, error) {
+	args := m.Called(ctx, walletID)
+	schedules, _ := args.Get(0).([]models.Schedule)
+	return schedules, args.Error(1)
+}
+
+func (m *MockScheduleService) ListRuns(ctx context.Context, id int64, afterID int64, limit int) ([]models.ScheduleRun, error) {
+	args := m.Called(ctx, id, afterID, limit)
+	runs, _ := args.Get(0).([]models.ScheduleRun)
+	return runs, args.Error(1)
+}
+
+func (m *MockScheduleService) PauseSchedule(ctx context.Context, id int64) (*models.Schedule, error) {
+	args := m.Called(ctx, id)
+	s, _ := args.Get(0).(*models.Schedule)
+	return s, args.Error(1)
+}
+
+func (m *MockScheduleService) ResumeSchedule(ctx context.Context, id int64) (*models.Schedule, error) {
+	args := m.Called(ctx, id)
+	s, _ := args.Get(0).(*models.Schedule)
+	return s, args.Error(1)
+}
+
+func (m *MockScheduleService) CancelSchedule(ctx context.Context, id int64) (*models.Schedule, error) {
+	args := m.Called(ctx, id)
+	s, _ := args.Get(0).(*models.Schedule)
+	return s, args.Error(1)
+}
@@ -2,6 +2,7 @@ package service
 
 import (
 	"context"
+	"time"
 
 	"ITKtest/internal/models"
 
@@ -22,4 +23,35 @@ func (m *MockWalletService) ProcessWalletOperation(ctx context.Context, req mode
 func (m *MockWalletService) GetWalletBalance(ctx context.Context, walletID uuid.UUID) (int64, error) {
 	args := m.Called(ctx, walletID)
 	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockWalletService) ListTransactions(ctx context.Context, walletID uuid.UUID, afterID int64, limit int) ([]models.Transaction, error) {
+	args := m.Called(ctx, walletID, afterID, limit)
+	return args.Get(0).([]models.Transaction), args.Error(1)
+}
+
+func (m *MockWalletService) ResumeAfterID(ctx context.Context, walletID uuid.UUID, lastID int64, window time.Duration) (int64, error) {
+	args := m.Called(ctx, walletID, lastID, window)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockWalletService) ProcessBatch(ctx context.Context, req models.BatchOperationRequest) (*models.BatchOperationResponse, error) {
+	args := m.Called(ctx, req)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.BatchOperationResponse), args.Error(1)
+}
+
+func (m *MockWalletService) EnableSharding(ctx context.Context, walletID uuid.UUID, shards int) error {
+	args := m.Called(ctx, walletID, shards)
+	return args.Error(0)
+}
+
+func (m *MockWalletService) GetWallet(ctx context.Context, walletID uuid.UUID) (*models.Wallet, error) {
+	args := m.Called(ctx, walletID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Wallet), args.Error(1)
 }
\ No newline at end of file
@@ -0,0 +1,43 @@
+package service
+
+import (
+	"context"
+
+	"ITKtest/internal/models"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/mock"
+)
+
+// MockWebhookService мок сервиса вебхуков
+type MockWebhookService struct {
+	mock.Mock
+}
+
+func (m *MockWebhookService) RegisterEndpoint(ctx context.Context, req models.RegisterWebhookRequest) (*models.WebhookEndpoint, error) {
+	args := m.Called(ctx, req)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.WebhookEndpoint), args.Error(1)
+}
+
+func (m *MockWebhookService) ListEndpoints(ctx context.Context) ([]models.WebhookEndpoint, error) {
+	args := m.Called(ctx)
+	return args.Get(0).([]models.WebhookEndpoint), args.Error(1)
+}
+
+func (m *MockWebhookService) DeleteEndpoint(ctx context.Context, endpointID uuid.UUID) error {
+	args := m.Called(ctx, endpointID)
+	return args.Error(0)
+}
+
+func (m *MockWebhookService) ListDeadLetters(ctx context.Context, limit int) ([]models.WebhookDeadLetter, error) {
+	args := m.Called(ctx, limit)
+	return args.Get(0).([]models.WebhookDeadLetter), args.Error(1)
+}
+
+func (m *MockWebhookService) ReplayDeadLetter(ctx context.Context, deadLetterID int64) error {
+	args := m.Called(ctx, deadLetterID)
+	return args.Error(0)
+}
@@ -0,0 +1,198 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"ITKtest/internal/audit"
+	"ITKtest/internal/auth"
+	"ITKtest/internal/models"
+	"ITKtest/internal/repository"
+	"ITKtest/internal/schedule"
+
+	"github.com/google/uuid"
+)
+
+// ScheduleService manages scheduled and recurring wallet operations; their runs are executed by
+// the schedule.Worker. Runs are authorized as the client certificate that created the schedule.
+type ScheduleService interface {
+	CreateSchedule(ctx context.Context, req models.ScheduleRequest) (*models.Schedule, error)
+	GetSchedule(ctx context.Context, id int64) (*models.Schedule, error)
+	ListSchedules(ctx context.Context, walletID uuid.UUID) ([]models.Schedule, error)
+	ListRuns(ctx context.Context, id int64, afterID int64, limit int) ([]models.ScheduleRun, error)
+	PauseSchedule(ctx context.Context, id int64) (*models.Schedule, error)
+	// ResumeSchedule skips the runs of a recurring schedule missed while it was paused
+	ResumeSchedule(ctx context.Context, id int64) (*models.Schedule, error)
+	CancelSchedule(ctx context.Context, id int64) (*models.Schedule, error)
+}
+
+type scheduleService struct {
+	repo repository.ScheduleRepository
+	now  func() time.Time
+}
+
+// ErrInvalidSchedule is matched by the *InvalidScheduleError of every schedule request refused
+var ErrInvalidSchedule = errors.New("invalid schedule")
+
+// InvalidScheduleError is returned for schedules CreateSchedule refuses or that have no run
+// left to resume. Reason says what is wrong and is written for the client.
+type InvalidScheduleError struct {
+	Reason string
+}
+
+func (e *InvalidScheduleError) Error() string {
+	return "invalid schedule: " + e.Reason
+}
+
+func (e *InvalidScheduleError) Is(target error) bool {
+	return target == ErrInvalidSchedule
+}
+
+func invalidSchedule(reason string) error {
+	return &InvalidScheduleError{Reason: reason}
+}
+
+// ErrScheduleStatus is returned for status changes the current status of the schedule forbids
+var ErrScheduleStatus = errors.New("schedule status forbids the change")
+
+func NewScheduleService(repo repository.ScheduleRepository) ScheduleService {
+	return &scheduleService{repo: repo, now: time.Now}
+}
+
+func (s *scheduleService) CreateSchedule(ctx context.Context, req models.ScheduleRequest) (*models.Schedule, error) {
+	now := s.now()
+	sched, err := newSchedule(req, now)
+	if err != nil {
+		return nil, err
+	}
+	sched.Principal = auth.Principal(ctx)
+	sched.CreatedBy = audit.Actor(ctx)
+
+	first, err := schedule.FirstRun(*sched, now)
+	if err != nil {
+		return nil, invalidSchedule(err.Error())
+	}
+	sched.NextRunAt = &first
+	if err := s.repo.CreateSchedule(ctx, sched); err != nil {
+		return nil, err
+	}
+	return sched, nil
+}
+
+// newSchedule validates req into an active schedule
+func newSchedule(req models.ScheduleRequest, now time.Time) (*models.Schedule, error) {
+	switch req.OperationType {
+	case models.DEPOSIT, models.WITHDRAW:
+		if req.ToWalletID != nil {
+			return nil, invalidSchedule("toWalletId is only valid for TRANSFER")
+		}
+	case models.TRANSFER:
+		if req.ToWalletID == nil || *req.ToWalletID == req.WalletID {
+			return nil, invalidSchedule("TRANSFER needs a toWalletId other than walletId")
+		}
+	default:
+		return nil, invalidSchedule("operation type must be DEPOSIT, WITHDRAW or TRANSFER")
+	}
+	if req.Amount <= 0 {
+		return nil, invalidSchedule("amount must be positive")
+	}
+	if err := validateReference(req.Reference); err != nil {
+		return nil, invalidSchedule(err.Error())
+	}
+	if req.RunAt != nil && !req.RunAt.After(now) {
+		return nil, invalidSchedule("runAt must be in the future")
+	}
+	if req.MaxRuns < 0 {
+		return nil, invalidSchedule("maxRuns must not be negative")
+	}
+
+	sched := &models.Schedule{
+		WalletID:      req.WalletID,
+		OperationType: req.OperationType,
+		Amount:        req.Amount,
+		ToWalletID:    req.ToWalletID,
+		Reference:     req.Reference,
+		RunAt:         req.RunAt,
+		Cron:          req.Cron,
+		TimeZone:      req.TimeZone,
+		MaxRuns:       req.MaxRuns,
+		Status:        models.ScheduleActive,
+	}
+	switch {
+	case req.Cron != "" && req.Interval != "":
+		return nil, invalidSchedule("set either cron or interval")
+	case req.Cron != "":
+		if _, err := schedule.ParseCron(req.Cron); err != nil {
+			return nil, invalidSchedule(err.Error())
+		}
+		if _, err := schedule.Location(*sched); err != nil {
+			return nil, invalidSchedule(err.Error())
+		}
+	case req.Interval != "":
+		interval, err := time.ParseDuration(req.Interval)
+		if err != nil || interval < models.MinScheduleInterval {
+			return nil, invalidSchedule(fmt.Sprintf("interval must be a duration of at least %s", models.MinScheduleInterval))
+		}
+		sched.IntervalSeconds = int64(interval / time.Second)
+	case req.RunAt == nil:
+		return nil, invalidSchedule("set runAt, cron or interval")
+	}
+	if req.TimeZone != "" && req.Cron == "" {
+		return nil, invalidSchedule("timeZone is only valid with cron")
+	}
+	if req.MaxRuns > 0 && !sched.Recurring() {
+		return nil, invalidSchedule("maxRuns is only valid for recurring schedules")
+	}
+	return sched, nil
+}
+
+func (s *scheduleService) GetSchedule(ctx context.Context, id int64) (*models.Schedule, error) {
+	return s.repo.GetSchedule(ctx, id)
+}
+
+func (s *scheduleService) ListSchedules(ctx context.Context, walletID uuid.UUID) ([]models.Schedule, error) {
+	return s.repo.ListSchedules(ctx, walletID)
+}
+
+func (s *scheduleService) ListRuns(ctx context.Context, id int64, afterID int64, limit int) ([]models.ScheduleRun, error) {
+	if _, err := s.repo.GetSchedule(ctx, id); err != nil {
+		return nil, err
+	}
+	return s.repo.ListRuns(ctx, id, afterID, limit)
+}
+
+func (s *scheduleService) PauseSchedule(ctx context.Context, id int64) (*models.Schedule, error) {
+	return s.repo.UpdateSchedule(ctx, id, func(sched *models.Schedule) error {
+		if sched.Status != models.ScheduleActive {
+			return fmt.Errorf("%w: cannot pause a %s schedule", ErrScheduleStatus, sched.Status)
+		}
+		sched.Status = models.SchedulePaused
+		return nil
+	})
+}
+
+func (s *scheduleService) ResumeSchedule(ctx context.Context, id int64) (*models.Schedule, error) {
+	return s.repo.UpdateSchedule(ctx, id, func(sched *models.Schedule) error {
+		if sched.Status != models.SchedulePaused {
+			return fmt.Errorf("%w: cannot resume a %s schedule", ErrScheduleStatus, sched.Status)
+		}
+		next, err := schedule.ResumeRun(*sched, s.now())
+		if err != nil {
+			return invalidSchedule(err.Error())
+		}
+		sched.Status, sched.NextRunAt = models.ScheduleActive, &next
+		return nil
+	})
+}
+
+func (s *scheduleService) CancelSchedule(ctx context.Context, id int64) (*models.Schedule, error) {
+	return s.repo.UpdateSchedule(ctx, id, func(sched *models.Schedule) error {
+		if sched.Status != models.ScheduleActive && sched.Status != models.SchedulePaused {
+			return fmt.Errorf("%w: cannot cancel a %s schedule", ErrScheduleStatus, sched.Status)
+		}
+		sched.Status, sched.NextRunAt = models.ScheduleCancelled, nil
+		return nil
+	})
+}
@@ -0,0 +1,129 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"ITKtest/internal/audit"
+	"ITKtest/internal/auth"
+	"ITKtest/internal/models"
+	"ITKtest/internal/repository"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScheduleService_CreateSchedule(t *testing.T) {
+	walletID, toWalletID := uuid.New(), uuid.New()
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	later := now.Add(time.Hour)
+	past := now.Add(-time.Hour)
+	ctx := audit.WithRequest(auth.WithPrincipal(context.Background(), "billing"), "ops", "req-1")
+
+	newService := func(repo repository.ScheduleRepository) *scheduleService {
+		return &scheduleService{repo: repo, now: func() time.Time { return now }}
+	}
+
+	t.Run("recurring schedules run as their creator from the first cron match", func(t *testing.T) {
+		repo := &repository.MockScheduleRepository{}
+		repo.On("CreateSchedule", ctx, mock.MatchedBy(func(s *models.Schedule) bool {
+			return s.Status == models.ScheduleActive && s.Principal == "billing" && s.CreatedBy == "ops" &&
+				s.NextRunAt.Equal(time.Date(2026, 3, 2, 6, 0, 0, 0, time.UTC))
+		})).Return(int64(5), nil)
+
+		s, err := newService(repo).CreateSchedule(ctx, models.ScheduleRequest{
+			WalletID: walletID, OperationType: models.WITHDRAW, Amount: 100, Cron: "0 9 * * *", TimeZone: "Europe/Moscow",
+		})
+		require.NoError(t, err)
+		assert.Equal(t, int64(5), s.ID)
+		repo.AssertExpectations(t)
+	})
+
+	t.Run("interval schedules run right away unless they start later", func(t *testing.T) {
+		repo := &repository.MockScheduleRepository{}
+		repo.On("CreateSchedule", ctx, mock.Anything).Return(int64(6), nil)
+
+		s, err := newService(repo).CreateSchedule(ctx, models.ScheduleRequest{
+			WalletID: walletID, OperationType: models.TRANSFER, ToWalletID: &toWalletID, Amount: 100, Interval: "24h", MaxRuns: 3,
+		})
+		require.NoError(t, err)
+		assert.Equal(t, int64(86400), s.IntervalSeconds)
+		assert.Equal(t, now, *s.NextRunAt)
+
+		s, err = newService(repo).CreateSchedule(ctx, models.ScheduleRequest{
+			WalletID: walletID, OperationType: models.DEPOSIT, Amount: 100, Interval: "1h", RunAt: &later,
+		})
+		require.NoError(t, err)
+		assert.Equal(t, later, *s.NextRunAt)
+	})
+
+	tests := []struct {
+		name string
+		req  models.ScheduleRequest
+		err  string
+	}{
+		{"unknown operation", models.ScheduleRequest{WalletID: walletID, OperationType: "REFUND", Amount: 100, RunAt: &later}, "operation type"},
+		{"transfer to itself", models.ScheduleRequest{WalletID: walletID, OperationType: models.TRANSFER, ToWalletID: &walletID, Amount: 100, RunAt: &later}, "toWalletId other than walletId"},
+		{"target of a withdrawal", models.ScheduleRequest{WalletID: walletID, OperationType: models.WITHDRAW, ToWalletID: &toWalletID, Amount: 100, RunAt: &later}, "only valid for TRANSFER"},
+		{"non-positive amount", models.ScheduleRequest{WalletID: walletID, OperationType: models.DEPOSIT, RunAt: &later}, "amount must be positive"},
+		{"run in the past", models.ScheduleRequest{WalletID: walletID, OperationType: models.DEPOSIT, Amount: 100, RunAt: &past}, "runAt must be in the future"},
+		{"no run", models.ScheduleRequest{WalletID: walletID, OperationType: models.DEPOSIT, Amount: 100}, "set runAt, cron or interval"},
+		{"cron and interval", models.ScheduleRequest{WalletID: walletID, OperationType: models.DEPOSIT, Amount: 100, Cron: "@daily", Interval: "1h"}, "either cron or interval"},
+		{"bad cron", models.ScheduleRequest{WalletID: walletID, OperationType: models.DEPOSIT, Amount: 100, Cron: "0 25 * * *"}, "invalid cron expression"},
+		{"bad time zone", models.ScheduleRequest{WalletID: walletID, OperationType: models.DEPOSIT, Amount: 100, Cron: "@daily", TimeZone: "Mars/Olympus"}, "invalid time zone"},
+		{"time zone without cron", models.ScheduleRequest{WalletID: walletID, OperationType: models.DEPOSIT, Amount: 100, Interval: "1h", TimeZone: "UTC"}, "timeZone is only valid with cron"},
+		{"short interval", models.ScheduleRequest{WalletID: walletID, OperationType: models.DEPOSIT, Amount: 100, Interval: "10s"}, "at least 1m0s"},
+		{"max runs of a one-off", models.ScheduleRequest{WalletID: walletID, OperationType: models.DEPOSIT, Amount: 100, RunAt: &later, MaxRuns: 2}, "only valid for recurring"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &repository.MockScheduleRepository{}
+			_, err := newService(repo).CreateSchedule(ctx, tt.req)
+			assert.ErrorIs(t, err, ErrInvalidSchedule)
+			assert.ErrorContains(t, err, tt.err)
+			repo.AssertNotCalled(t, "CreateSchedule", mock.Anything, mock.Anything)
+		})
+	}
+}
+
+func TestScheduleService_StatusChanges(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	missed := now.Add(-150 * time.Minute)
+	ctx := context.Background()
+	svc := &scheduleService{now: func() time.Time { return now }}
+
+	withSchedule := func(s models.Schedule) {
+		repo := &repository.MockScheduleRepository{}
+		repo.On("UpdateSchedule", ctx, int64(1), mock.Anything).Return(&s, nil)
+		svc.repo = repo
+	}
+
+	withSchedule(models.Schedule{ID: 1, Status: models.ScheduleActive, IntervalSeconds: 3600, NextRunAt: &missed})
+	s, err := svc.PauseSchedule(ctx, 1)
+	require.NoError(t, err)
+	assert.Equal(t, models.SchedulePaused, s.Status)
+
+	withSchedule(models.Schedule{ID: 1, Status: models.SchedulePaused, IntervalSeconds: 3600, NextRunAt: &missed})
+	s, err = svc.ResumeSchedule(ctx, 1)
+	require.NoError(t, err)
+	assert.Equal(t, models.ScheduleActive, s.Status)
+	assert.Equal(t, missed.Add(3*time.Hour), *s.NextRunAt, "runs missed while paused are skipped")
+
+	withSchedule(models.Schedule{ID: 1, Status: models.SchedulePaused, NextRunAt: &missed})
+	s, err = svc.CancelSchedule(ctx, 1)
+	require.NoError(t, err)
+	assert.Equal(t, models.ScheduleCancelled, s.Status)
+	assert.Nil(t, s.NextRunAt)
+
+	withSchedule(models.Schedule{ID: 1, Status: models.ScheduleCompleted})
+	_, err = svc.PauseSchedule(ctx, 1)
+	assert.ErrorIs(t, err, ErrScheduleStatus)
+	assert.ErrorContains(t, err, "cannot pause a completed schedule")
+	_, err = svc.ResumeSchedule(ctx, 1)
+	assert.ErrorIs(t, err, ErrScheduleStatus)
+	_, err = svc.CancelSchedule(ctx, 1)
+	assert.ErrorIs(t, err, ErrScheduleStatus)
+}
@@ -3,6 +3,9 @@ package service
 import (
 	"context"
 	"errors"
+	"fmt"
+	"time"
+	"unicode/utf8"
 
 	"ITKtest/internal/models"
 	"ITKtest/internal/repository"
@@ -12,7 +15,12 @@ import (
 
 type WalletService interface {
 	ProcessWalletOperation(ctx context.Context, req models.WalletOperationRequest) error
+	ProcessBatch(ctx context.Context, req models.BatchOperationRequest) (*models.BatchOperationResponse, error)
 	GetWalletBalance(ctx context.Context, walletID uuid.UUID) (int64, error)
+	GetWallet(ctx context.Context, walletID uuid.UUID) (*models.Wallet, error)
+	ListTransactions(ctx context.Context, walletID uuid.UUID, afterID int64, limit int) ([]models.Transaction, error)
+	ResumeAfterID(ctx context.Context, walletID uuid.UUID, lastID int64, window time.Duration) (int64, error)
+	EnableSharding(ctx context.Context, walletID uuid.UUID, shards int) error
 }
 
 type walletService struct {
@@ -23,10 +31,28 @@ func NewWalletService(repo repository.WalletRepository) WalletService {
 	return &walletService{repo: repo}
 }
 
+// ErrNonPositiveAmount is returned for operations of zero or negative amounts
+var ErrNonPositiveAmount = errors.New("amount must be positive")
+
+// DeclinedErrors are the errors that decline an operation for good: repeating it fails the same
+// way, unlike with read-only mode or a lost connection
+var DeclinedErrors = []error{
+	repository.ErrInsufficientFunds,
+	repository.ErrWalletNotFound,
+	ErrNonPositiveAmount,
+	ErrDebitUnauthorized,
+	ErrAmountTooLarge,
+	ErrBatchDisabled,
+	ErrBatchTooLarge,
+}
+
 func (s *walletService) ProcessWalletOperation(ctx context.Context, req models.WalletOperationRequest) error {
 	// Validate amount
 	if req.Amount <= 0 {
-		return errors.New("amount must be positive")
+		return ErrNonPositiveAmount
+	}
+	if err := validateReference(req.Reference); err != nil {
+		return err
 	}
 
 	// Check if wallet exists, create if not
@@ -39,7 +65,90 @@ func (s *walletService) ProcessWalletOperation(ctx context.Context, req models.W
 	}
 
 	// Update wallet balance
-	return s.repo.UpdateWalletBalance(ctx, req.WalletID, req.Amount, req.OperationType)
+	return s.repo.ApplyOperation(ctx, req)
+}
+
+// ProcessBatch runs operations either in one all-or-nothing transaction (atomic mode) or one by
+// one, reporting the outcome of each item (best-effort mode)
+func (s *walletService) ProcessBatch(ctx context.Context, req models.BatchOperationRequest) (*models.BatchOperationResponse, error) {
+	if len(req.Operations) == 0 {
+		return nil, errors.New("batch must contain at least one operation")
+	}
+
+	resp := &models.BatchOperationResponse{
+		Mode:    req.Mode,
+		Results: make([]models.BatchItemResult, len(req.Operations)),
+	}
+
+	switch req.Mode {
+	case models.BatchAtomic:
+		for i, op := range req.Operations {
+			if op.Amount <= 0 {
+				return nil, &models.BatchOperationError{Index: i, Err: ErrNonPositiveAmount}
+			}
+			if err := validateReference(op.Reference); err != nil {
+				return nil, &models.BatchOperationError{Index: i, Err: err}
+			}
+		}
+		if err := s.ensureWallets(ctx, req.Operations); err != nil {
+			return nil, err
+		}
+		if err := s.repo.UpdateWalletBalancesAtomic(ctx, req.Operations); err != nil {
+			return nil, err
+		}
+		for i, op := range req.Operations {
+			resp.Results[i] = models.BatchItemResult{Index: i, WalletID: op.WalletID, Status: "success", Fee: feeAmount(op)}
+		}
+		resp.Succeeded = len(req.Operations)
+
+	case models.BatchBestEffort:
+		for i, op := range req.Operations {
+			resp.Results[i] = models.BatchItemResult{Index: i, WalletID: op.WalletID, Status: "success"}
+			if err := s.ProcessWalletOperation(ctx, op); err != nil {
+				resp.Results[i].Status = "failed"
+				resp.Results[i].Err = err
+				resp.Failed++
+				continue
+			}
+			resp.Results[i].Fee = feeAmount(op)
+			resp.Succeeded++
+		}
+
+	default:
+		return nil, errors.New("batch mode must be atomic or best_effort")
+	}
+
+	return resp, nil
+}
+
+// feeAmount is the fee charged for a successful operation
+func feeAmount(op models.WalletOperationRequest) int64 {
+	if op.Fee == nil {
+		return 0
+	}
+	return op.Fee.Amount
+}
+
+func validateReference(reference string) error {
+	if utf8.RuneCountInString(reference) > models.MaxReferenceLength {
+		return fmt.Errorf("reference must be at most %d characters", models.MaxReferenceLength)
+	}
+	return nil
+}
+
+// ensureWallets creates the wallets referenced by operations that do not exist yet
+func (s *walletService) ensureWallets(ctx context.Context, operations []models.WalletOperationRequest) error {
+	seen := make(map[uuid.UUID]bool, len(operations))
+	for _, op := range operations {
+		if seen[op.WalletID] {
+			continue
+		}
+		seen[op.WalletID] = true
+		if err := s.repo.CreateWallet(ctx, op.WalletID); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 func (s *walletService) GetWalletBalance(ctx context.Context, walletID uuid.UUID) (int64, error) {
@@ -48,4 +157,21 @@ func (s *walletService) GetWalletBalance(ctx context.Context, walletID uuid.UUID
 		return 0, err
 	}
 	return wallet.Balance, nil
+}
+
+func (s *walletService) GetWallet(ctx context.Context, walletID uuid.UUID) (*models.Wallet, error) {
+	return s.repo.GetWallet(ctx, walletID)
+}
+
+func (s *walletService) ListTransactions(ctx context.Context, walletID uuid.UUID, afterID int64, limit int) ([]models.Transaction, error) {
+	return s.repo.ListTransactions(ctx, walletID, afterID, limit)
+}
+
+func (s *walletService) ResumeAfterID(ctx context.Context, walletID uuid.UUID, lastID int64, window time.Duration) (int64, error) {
+	return s.repo.ResumeAfterID(ctx, walletID, lastID, window)
+}
+
+// EnableSharding turns walletID into a hot wallet whose balance is spread over shards rows
+func (s *walletService) EnableSharding(ctx context.Context, walletID uuid.UUID, shards int) error {
+	return s.repo.EnableSharding(ctx, walletID, shards)
 }
\ No newline at end of file
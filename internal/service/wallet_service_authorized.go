@@ -0,0 +1,53 @@
+package service
+
+import (
+	"context"
+	"errors"
+
+	"ITKtest/internal/auth"
+	"ITKtest/internal/models"
+)
+
+// AnyPrincipal in the debit principals allows every client with a verified certificate
+const AnyPrincipal = "*"
+
+// debitAuthorizedWalletService only lets the listed principals withdraw, for partners that
+// require mutual TLS on debits. Deposits and reads are not restricted.
+type debitAuthorizedWalletService struct {
+	WalletService
+	principals map[string]bool
+}
+
+func NewDebitAuthorizedWalletService(next WalletService, principals []string) WalletService {
+	allowed := make(map[string]bool, len(principals))
+	for _, p := range principals {
+		allowed[p] = true
+	}
+	return &debitAuthorizedWalletService{WalletService: next, principals: allowed}
+}
+
+// ErrDebitUnauthorized is returned for withdrawals by clients missing from the debit principals
+var ErrDebitUnauthorized = errors.New("debit requires an authorized client certificate")
+
+func (s *debitAuthorizedWalletService) authorized(ctx context.Context) bool {
+	principal := auth.Principal(ctx)
+	return principal != "" && (s.principals[AnyPrincipal] || s.principals[principal])
+}
+
+func (s *debitAuthorizedWalletService) ProcessWalletOperation(ctx context.Context, req models.WalletOperationRequest) error {
+	if req.OperationType == models.WITHDRAW && !s.authorized(ctx) {
+		return ErrDebitUnauthorized
+	}
+	return s.WalletService.ProcessWalletOperation(ctx, req)
+}
+
+func (s *debitAuthorizedWalletService) ProcessBatch(ctx context.Context, req models.BatchOperationRequest) (*models.BatchOperationResponse, error) {
+	if !s.authorized(ctx) {
+		for i, op := range req.Operations {
+			if op.OperationType == models.WITHDRAW {
+				return nil, &models.BatchOperationError{Index: i, Err: ErrDebitUnauthorized}
+			}
+		}
+	}
+	return s.WalletService.ProcessBatch(ctx, req)
+}
@@ -0,0 +1,46 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"ITKtest/internal/auth"
+	"ITKtest/internal/models"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestDebitAuthorizedWalletService(t *testing.T) {
+	walletID := uuid.New()
+	deposit := models.WalletOperationRequest{WalletID: walletID, OperationType: models.DEPOSIT, Amount: 100}
+	withdraw := models.WalletOperationRequest{WalletID: walletID, OperationType: models.WITHDRAW, Amount: 100}
+
+	next := &MockWalletService{}
+	next.On("ProcessWalletOperation", mock.Anything, mock.Anything).Return(nil)
+	next.On("ProcessBatch", mock.Anything, mock.Anything).Return(&models.BatchOperationResponse{}, nil)
+	svc := NewDebitAuthorizedWalletService(next, []string{"partner-a"})
+
+	anonymous := context.Background()
+	partnerA := auth.WithPrincipal(context.Background(), "partner-a")
+	partnerB := auth.WithPrincipal(context.Background(), "partner-b")
+
+	assert.NoError(t, svc.ProcessWalletOperation(anonymous, deposit))
+	assert.EqualError(t, svc.ProcessWalletOperation(anonymous, withdraw), "debit requires an authorized client certificate")
+	assert.EqualError(t, svc.ProcessWalletOperation(partnerB, withdraw), "debit requires an authorized client certificate")
+	assert.NoError(t, svc.ProcessWalletOperation(partnerA, withdraw))
+
+	_, err := svc.ProcessBatch(anonymous, models.BatchOperationRequest{Operations: []models.WalletOperationRequest{deposit, withdraw}})
+	var batchErr *models.BatchOperationError
+	assert.True(t, errors.As(err, &batchErr))
+	assert.Equal(t, 1, batchErr.Index)
+	_, err = svc.ProcessBatch(partnerA, models.BatchOperationRequest{Operations: []models.WalletOperationRequest{deposit, withdraw}})
+	assert.NoError(t, err)
+
+	// "*" allows any client with a verified certificate, but still not anonymous ones
+	anyClient := NewDebitAuthorizedWalletService(next, []string{AnyPrincipal})
+	assert.NoError(t, anyClient.ProcessWalletOperation(partnerB, withdraw))
+	assert.Error(t, anyClient.ProcessWalletOperation(anonymous, withdraw))
+}
@@ -0,0 +1,40 @@
+package service
+
+import (
+	"context"
+
+	"ITKtest/internal/models"
+)
+
+// bonusWalletService lets purchases spend the bonus lots of their wallet, before or after the
+// real balance as the policy orders. Withdrawals not marked as purchases never spend bonus, so
+// that clients unaware of bonus funds cannot pay them out.
+type bonusWalletService struct {
+	WalletService
+	use models.BonusUse
+}
+
+func NewBonusWalletService(next WalletService, use models.BonusUse) WalletService {
+	return &bonusWalletService{WalletService: next, use: use}
+}
+
+func (s *bonusWalletService) ProcessWalletOperation(ctx context.Context, req models.WalletOperationRequest) error {
+	return s.WalletService.ProcessWalletOperation(ctx, s.withBonus(req))
+}
+
+func (s *bonusWalletService) ProcessBatch(ctx context.Context, req models.BatchOperationRequest) (*models.BatchOperationResponse, error) {
+	operations := make([]models.WalletOperationRequest, len(req.Operations))
+	for i, op := range req.Operations {
+		operations[i] = s.withBonus(op)
+	}
+	req.Operations = operations
+	return s.WalletService.ProcessBatch(ctx, req)
+}
+
+func (s *bonusWalletService) withBonus(op models.WalletOperationRequest) models.WalletOperationRequest {
+	op.Bonus = ""
+	if op.OperationType == models.WITHDRAW && op.Purchase {
+		op.Bonus = s.use
+	}
+	return op
+}
@@ -0,0 +1,48 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"ITKtest/internal/models"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestBonusWalletService(t *testing.T) {
+	walletID := uuid.New()
+	deposit := models.WalletOperationRequest{WalletID: walletID, OperationType: models.DEPOSIT, Amount: 100}
+	withdraw := models.WalletOperationRequest{WalletID: walletID, OperationType: models.WITHDRAW, Amount: 100}
+	purchase := withdraw
+	purchase.Purchase = true
+	spending := purchase
+	spending.Bonus = models.RealFirst
+
+	// Deposits and withdrawals other than purchases never spend bonus, whatever the client sent
+	forged := withdraw
+	forged.Bonus = models.BonusFirst
+
+	next := &MockWalletService{}
+	next.On("ProcessWalletOperation", mock.Anything, deposit).Return(nil)
+	next.On("ProcessWalletOperation", mock.Anything, withdraw).Return(nil)
+	next.On("ProcessWalletOperation", mock.Anything, spending).Return(nil)
+	svc := NewBonusWalletService(next, models.RealFirst)
+
+	ctx := context.Background()
+	assert.NoError(t, svc.ProcessWalletOperation(ctx, deposit))
+	assert.NoError(t, svc.ProcessWalletOperation(ctx, forged))
+	assert.NoError(t, svc.ProcessWalletOperation(ctx, purchase))
+
+	next.On("ProcessBatch", mock.Anything, models.BatchOperationRequest{
+		Mode:       models.BatchBestEffort,
+		Operations: []models.WalletOperationRequest{deposit, spending, withdraw},
+	}).Return(&models.BatchOperationResponse{}, nil)
+	_, err := svc.ProcessBatch(ctx, models.BatchOperationRequest{
+		Mode:       models.BatchBestEffort,
+		Operations: []models.WalletOperationRequest{deposit, purchase, withdraw},
+	})
+	assert.NoError(t, err)
+	next.AssertExpectations(t)
+}
@@ -0,0 +1,80 @@
+package service
+
+import (
+	"context"
+
+	"ITKtest/internal/cache"
+	"ITKtest/internal/models"
+	"ITKtest/internal/repository"
+
+	"github.com/google/uuid"
+)
+
+// cachedWalletService serves balance reads from a BalanceCache. Wallets are invalidated after
+// every write through this instance, whether or not it succeeded, since a failed commit may
+// still have been applied, along with the wallet credited with their fee; writes by other
+// instances arrive through the notification feed.
+// Reads that ask for read-your-writes consistency bypass the cache.
+type cachedWalletService struct {
+	WalletService
+	cache *cache.BalanceCache
+}
+
+func NewCachedWalletService(next WalletService, balanceCache *cache.BalanceCache) WalletService {
+	return &cachedWalletService{WalletService: next, cache: balanceCache}
+}
+
+func (s *cachedWalletService) ProcessWalletOperation(ctx context.Context, req models.WalletOperationRequest) error {
+	defer s.invalidate(req)
+	return s.WalletService.ProcessWalletOperation(ctx, req)
+}
+
+func (s *cachedWalletService) ProcessBatch(ctx context.Context, req models.BatchOperationRequest) (*models.BatchOperationResponse, error) {
+	defer func() {
+		for _, op := range req.Operations {
+			s.invalidate(op)
+		}
+	}()
+	return s.WalletService.ProcessBatch(ctx, req)
+}
+
+func (s *cachedWalletService) invalidate(op models.WalletOperationRequest) {
+	s.cache.Invalidate(op.WalletID)
+	if op.Fee != nil {
+		s.cache.Invalidate(op.Fee.FeeWalletID)
+	}
+}
+
+func (s *cachedWalletService) EnableSharding(ctx context.Context, walletID uuid.UUID, shards int) error {
+	defer s.cache.Invalidate(walletID)
+	return s.WalletService.EnableSharding(ctx, walletID, shards)
+}
+
+func (s *cachedWalletService) GetWallet(ctx context.Context, walletID uuid.UUID) (*models.Wallet, error) {
+	if repository.PrimaryReads(ctx) {
+		cache.SetStatus(ctx, cache.StatusBypass)
+		return s.WalletService.GetWallet(ctx, walletID)
+	}
+
+	cached, generation, ok := s.cache.Get(walletID)
+	if ok {
+		cache.SetStatus(ctx, cache.StatusHit)
+		return &cached, nil
+	}
+
+	cache.SetStatus(ctx, cache.StatusMiss)
+	wallet, err := s.WalletService.GetWallet(ctx, walletID)
+	if err != nil {
+		return nil, err
+	}
+	s.cache.Set(*wallet, generation)
+	return wallet, nil
+}
+
+func (s *cachedWalletService) GetWalletBalance(ctx context.Context, walletID uuid.UUID) (int64, error) {
+	wallet, err := s.GetWallet(ctx, walletID)
+	if err != nil {
+		return 0, err
+	}
+	return wallet.Balance, nil
+}
@@ -0,0 +1,98 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"ITKtest/internal/cache"
+	"ITKtest/internal/models"
+	"ITKtest/internal/repository"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestCachedWalletService(t *testing.T) {
+	walletID := uuid.New()
+	next := &MockWalletService{}
+	svc := NewCachedWalletService(next, cache.NewBalanceCache(10, time.Minute))
+
+	next.On("GetWallet", mock.Anything, walletID).Return(&models.Wallet{ID: walletID, Balance: 100}, nil).Once()
+	ctx, status := cache.WithStatus(context.Background())
+	balance, err := svc.GetWalletBalance(ctx, walletID)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(100), balance)
+	assert.Equal(t, cache.StatusMiss, *status)
+
+	ctx, status = cache.WithStatus(context.Background())
+	balance, err = svc.GetWalletBalance(ctx, walletID)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(100), balance)
+	assert.Equal(t, cache.StatusHit, *status)
+
+	// Strongly consistent reads skip the cache
+	next.On("GetWallet", mock.Anything, walletID).Return(&models.Wallet{ID: walletID, Balance: 100}, nil).Once()
+	ctx, status = cache.WithStatus(repository.WithPrimaryReads(context.Background()))
+	_, err = svc.GetWalletBalance(ctx, walletID)
+	assert.NoError(t, err)
+	assert.Equal(t, cache.StatusBypass, *status)
+
+	// A write invalidates the wallet even when it reports an error
+	req := models.WalletOperationRequest{WalletID: walletID, OperationType: models.DEPOSIT, Amount: 50}
+	next.On("ProcessWalletOperation", mock.Anything, req).Return(errors.New("connection reset"))
+	assert.Error(t, svc.ProcessWalletOperation(context.Background(), req))
+
+	next.On("GetWallet", mock.Anything, walletID).Return(&models.Wallet{ID: walletID, Balance: 150}, nil).Once()
+	ctx, status = cache.WithStatus(context.Background())
+	balance, err = svc.GetWalletBalance(ctx, walletID)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(150), balance)
+	assert.Equal(t, cache.StatusMiss, *status)
+
+	next.AssertExpectations(t)
+}
+
+func TestCachedWalletService_DoesNotCacheErrors(t *testing.T) {
+	walletID := uuid.New()
+	next := &MockWalletService{}
+	svc := NewCachedWalletService(next, cache.NewBalanceCache(10, time.Minute))
+
+	next.On("GetWallet", mock.Anything, walletID).Return(nil, errors.New("wallet not found")).Twice()
+	for i := 0; i < 2; i++ {
+		_, err := svc.GetWalletBalance(context.Background(), walletID)
+		assert.EqualError(t, err, "wallet not found")
+	}
+
+	next.AssertExpectations(t)
+}
+
+func TestCachedWalletService_InvalidatesFeeWallet(t *testing.T) {
+	walletID, feeWallet := uuid.New(), uuid.New()
+	next := &MockWalletService{}
+	svc := NewCachedWalletService(next, cache.NewBalanceCache(10, time.Minute))
+
+	next.On("GetWallet", mock.Anything, feeWallet).Return(&models.Wallet{ID: feeWallet, Balance: 0}, nil).Once()
+	_, err := svc.GetWalletBalance(context.Background(), feeWallet)
+	assert.NoError(t, err)
+
+	req := models.WalletOperationRequest{
+		WalletID:      walletID,
+		OperationType: models.WITHDRAW,
+		Amount:        500,
+		Fee:           &models.OperationFee{Amount: 10, FeeWalletID: feeWallet},
+	}
+	next.On("ProcessWalletOperation", mock.Anything, req).Return(nil)
+	assert.NoError(t, svc.ProcessWalletOperation(context.Background(), req))
+
+	next.On("GetWallet", mock.Anything, feeWallet).Return(&models.Wallet{ID: feeWallet, Balance: 10}, nil).Once()
+	ctx, status := cache.WithStatus(context.Background())
+	balance, err := svc.GetWalletBalance(ctx, feeWallet)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(10), balance)
+	assert.Equal(t, cache.StatusMiss, *status)
+
+	next.AssertExpectations(t)
+}
@@ -0,0 +1,41 @@
+package service
+
+import (
+	"context"
+
+	"ITKtest/internal/fees"
+	"ITKtest/internal/models"
+)
+
+// feeWalletService attaches the fee of the schedule to every balance change, so that the
+// repository charges it in the same transaction as the operation
+type feeWalletService struct {
+	WalletService
+	schedule *fees.Schedule
+}
+
+func NewFeeWalletService(next WalletService, schedule *fees.Schedule) WalletService {
+	return &feeWalletService{WalletService: next, schedule: schedule}
+}
+
+// ProcessWalletOperation records the fee charged in ctx, see fees.WithCharge
+func (s *feeWalletService) ProcessWalletOperation(ctx context.Context, req models.WalletOperationRequest) error {
+	req.Fee = s.schedule.Fee(req)
+	if err := s.WalletService.ProcessWalletOperation(ctx, req); err != nil {
+		return err
+	}
+	if req.Fee != nil {
+		fees.SetCharge(ctx, *req.Fee)
+	}
+	return nil
+}
+
+func (s *feeWalletService) ProcessBatch(ctx context.Context, req models.BatchOperationRequest) (*models.BatchOperationResponse, error) {
+	operations := make([]models.WalletOperationRequest, len(req.Operations))
+	for i, op := range req.Operations {
+		op.Fee = s.schedule.Fee(op)
+		operations[i] = op
+	}
+	req.Operations = operations
+	return s.WalletService.ProcessBatch(ctx, req)
+}
@@ -0,0 +1,86 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"ITKtest/internal/fees"
+	"ITKtest/internal/models"
+	"ITKtest/internal/repository"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFeeWalletService(t *testing.T) {
+	feeWallet, walletID := uuid.New(), uuid.New()
+	schedule := &fees.Schedule{
+		FeeWallet: feeWallet,
+		Rules:     []fees.Rule{{Name: "withdrawals", OperationType: models.WITHDRAW, Fixed: 10}},
+	}
+	fee := &models.OperationFee{Amount: 10, FeeWalletID: feeWallet, Rule: "withdrawals"}
+	deposit := models.WalletOperationRequest{WalletID: walletID, OperationType: models.DEPOSIT, Amount: 100}
+	withdraw := models.WalletOperationRequest{WalletID: walletID, OperationType: models.WITHDRAW, Amount: 100}
+	charged := withdraw
+	charged.Fee = fee
+
+	next := &MockWalletService{}
+	next.On("ProcessWalletOperation", mock.Anything, deposit).Return(nil)
+	next.On("ProcessWalletOperation", mock.Anything, charged).Return(nil).Once()
+	next.On("ProcessWalletOperation", mock.Anything, charged).Return(errors.New("insufficient funds")).Once()
+	svc := NewFeeWalletService(next, schedule)
+
+	ctx, charge := fees.WithCharge(context.Background())
+	require.NoError(t, svc.ProcessWalletOperation(ctx, deposit))
+	assert.Zero(t, charge.Amount)
+	require.NoError(t, svc.ProcessWalletOperation(ctx, withdraw))
+	assert.Equal(t, *fee, *charge)
+
+	// A failed operation charges nothing
+	ctx, charge = fees.WithCharge(context.Background())
+	assert.EqualError(t, svc.ProcessWalletOperation(ctx, withdraw), "insufficient funds")
+	assert.Zero(t, charge.Amount)
+
+	next.On("ProcessBatch", mock.Anything, models.BatchOperationRequest{
+		Mode:       models.BatchAtomic,
+		Operations: []models.WalletOperationRequest{deposit, charged},
+	}).Return(&models.BatchOperationResponse{}, nil)
+	_, err := svc.ProcessBatch(context.Background(), models.BatchOperationRequest{
+		Mode:       models.BatchAtomic,
+		Operations: []models.WalletOperationRequest{deposit, withdraw},
+	})
+	assert.NoError(t, err)
+	next.AssertExpectations(t)
+}
+
+func TestFeeWalletService_WithMemoryRepository(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewMemoryWalletRepository(nil)
+	feeWallet, walletID := uuid.New(), uuid.New()
+	require.NoError(t, repo.CreateWallet(ctx, feeWallet))
+	schedule := &fees.Schedule{FeeWallet: feeWallet, Rules: []fees.Rule{{BasisPoints: 100}}}
+	svc := NewFeeWalletService(NewWalletService(repo), schedule)
+
+	require.NoError(t, svc.ProcessWalletOperation(ctx, models.WalletOperationRequest{WalletID: walletID, OperationType: models.DEPOSIT, Amount: 1000}))
+	resp, err := svc.ProcessBatch(ctx, models.BatchOperationRequest{
+		Mode: models.BatchBestEffort,
+		Operations: []models.WalletOperationRequest{
+			{WalletID: walletID, OperationType: models.WITHDRAW, Amount: 500},
+			{WalletID: walletID, OperationType: models.WITHDRAW, Amount: 485},
+		},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, int64(5), resp.Results[0].Fee)
+	assert.Equal(t, "failed", resp.Results[1].Status)
+	assert.Zero(t, resp.Results[1].Fee)
+
+	balance, err := svc.GetWalletBalance(ctx, walletID)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1000-10-500-5), balance)
+	balance, err = svc.GetWalletBalance(ctx, feeWallet)
+	require.NoError(t, err)
+	assert.Equal(t, int64(15), balance)
+}
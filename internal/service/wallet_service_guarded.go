@@ -0,0 +1,61 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"ITKtest/internal/models"
+	"ITKtest/internal/settings"
+)
+
+// guardedWalletService enforces the runtime limits and toggles on balance changes. The settings
+// are read on every call, so a reload applies to the next request.
+type guardedWalletService struct {
+	WalletService
+	settings *settings.Store
+}
+
+func NewGuardedWalletService(next WalletService, store *settings.Store) WalletService {
+	return &guardedWalletService{WalletService: next, settings: store}
+}
+
+// Errors returned for operations the runtime settings refuse
+var (
+	ErrReadOnly       = errors.New("service is in read-only mode")
+	ErrBatchDisabled  = errors.New("batch operations are disabled")
+	ErrAmountTooLarge = errors.New("amount exceeds the operation limit")
+	ErrBatchTooLarge  = errors.New("batch exceeds the size limit")
+)
+
+func (s *guardedWalletService) ProcessWalletOperation(ctx context.Context, req models.WalletOperationRequest) error {
+	rt := s.settings.Load()
+	if rt.ReadOnly {
+		return ErrReadOnly
+	}
+	if rt.MaxOperationAmount > 0 && req.Amount > rt.MaxOperationAmount {
+		return ErrAmountTooLarge
+	}
+	return s.WalletService.ProcessWalletOperation(ctx, req)
+}
+
+func (s *guardedWalletService) ProcessBatch(ctx context.Context, req models.BatchOperationRequest) (*models.BatchOperationResponse, error) {
+	rt := s.settings.Load()
+	if rt.ReadOnly {
+		return nil, ErrReadOnly
+	}
+	if !rt.BatchOperations {
+		return nil, ErrBatchDisabled
+	}
+	if len(req.Operations) > rt.MaxBatchSize {
+		return nil, fmt.Errorf("%w of %d operations", ErrBatchTooLarge, rt.MaxBatchSize)
+	}
+	if rt.MaxOperationAmount > 0 {
+		for i, op := range req.Operations {
+			if op.Amount > rt.MaxOperationAmount {
+				return nil, &models.BatchOperationError{Index: i, Err: ErrAmountTooLarge}
+			}
+		}
+	}
+	return s.WalletService.ProcessBatch(ctx, req)
+}
@@ -0,0 +1,58 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"ITKtest/internal/models"
+	"ITKtest/internal/settings"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestGuardedWalletService(t *testing.T) {
+	walletID := uuid.New()
+	small := models.WalletOperationRequest{WalletID: walletID, OperationType: models.DEPOSIT, Amount: 100}
+	large := models.WalletOperationRequest{WalletID: walletID, OperationType: models.DEPOSIT, Amount: 1000}
+
+	next := &MockWalletService{}
+	next.On("ProcessWalletOperation", mock.Anything, mock.Anything).Return(nil)
+	next.On("ProcessBatch", mock.Anything, mock.Anything).Return(&models.BatchOperationResponse{}, nil)
+
+	rt := settings.Default()
+	store := settings.NewStore(rt)
+	svc := NewGuardedWalletService(next, store)
+
+	assert.NoError(t, svc.ProcessWalletOperation(context.Background(), large))
+
+	// Settings swapped in by a reload apply to the next call
+	rt.MaxOperationAmount = 500
+	store.Swap(rt)
+	assert.NoError(t, svc.ProcessWalletOperation(context.Background(), small))
+	assert.EqualError(t, svc.ProcessWalletOperation(context.Background(), large), "amount exceeds the operation limit")
+
+	_, err := svc.ProcessBatch(context.Background(), models.BatchOperationRequest{Operations: []models.WalletOperationRequest{small, large}})
+	var batchErr *models.BatchOperationError
+	assert.True(t, errors.As(err, &batchErr))
+	assert.Equal(t, 1, batchErr.Index)
+
+	rt.MaxBatchSize = 1
+	store.Swap(rt)
+	_, err = svc.ProcessBatch(context.Background(), models.BatchOperationRequest{Operations: []models.WalletOperationRequest{small, small}})
+	assert.EqualError(t, err, "batch exceeds the size limit of 1 operations")
+
+	rt.BatchOperations = false
+	store.Swap(rt)
+	_, err = svc.ProcessBatch(context.Background(), models.BatchOperationRequest{Operations: []models.WalletOperationRequest{small}})
+	assert.EqualError(t, err, "batch operations are disabled")
+
+	rt.ReadOnly = true
+	store.Swap(rt)
+	assert.EqualError(t, svc.ProcessWalletOperation(context.Background(), small), "service is in read-only mode")
+
+	next.AssertNumberOfCalls(t, "ProcessWalletOperation", 2)
+	next.AssertNotCalled(t, "ProcessBatch", mock.Anything, mock.Anything)
+}
@@ -31,7 +31,7 @@ func TestWalletService_ProcessWalletOperation(t *testing.T) {
 			mockSetup: func(m *repository.MockWalletRepository) {
 				m.On("GetWallet", mock.Anything, walletID).Return((*models.Wallet)(nil), errors.New("not found"))
 				m.On("CreateWallet", mock.Anything, walletID).Return(nil)
-				m.On("UpdateWalletBalance", mock.Anything, walletID, int64(1000), models.DEPOSIT).Return(nil)
+				m.On("ApplyOperation", mock.Anything, models.WalletOperationRequest{WalletID: walletID, OperationType: models.DEPOSIT, Amount: 1000}).Return(nil)
 			},
 		},
 		{
@@ -44,9 +44,27 @@ func TestWalletService_ProcessWalletOperation(t *testing.T) {
 			mockSetup: func(m *repository.MockWalletRepository) {
 				existingWallet := &models.Wallet{ID: walletID, Balance: 1000}
 				m.On("GetWallet", mock.Anything, walletID).Return(existingWallet, nil)
-				m.On("UpdateWalletBalance", mock.Anything, walletID, int64(500), models.WITHDRAW).Return(nil)
+				m.On("ApplyOperation", mock.Anything, models.WalletOperationRequest{WalletID: walletID, OperationType: models.WITHDRAW, Amount: 500}).Return(nil)
 			},
 		},
+		{
+			name: "withdraw with expected version",
+			request: models.WalletOperationRequest{
+				WalletID:        walletID,
+				OperationType:   models.WITHDRAW,
+				Amount:          500,
+				ExpectedVersion: func() *int64 { v := int64(4); return &v }(),
+			},
+			mockSetup: func(m *repository.MockWalletRepository) {
+				existingWallet := &models.Wallet{ID: walletID, Balance: 1000, Version: 5}
+				m.On("GetWallet", mock.Anything, walletID).Return(existingWallet, nil)
+				m.On("ApplyOperation", mock.Anything, mock.MatchedBy(func(op models.WalletOperationRequest) bool {
+					return op.Amount == 500 && op.ExpectedVersion != nil && *op.ExpectedVersion == 4
+				})).
+					Return(errors.New("version conflict"))
+			},
+			expectedError: "version conflict",
+		},
 		{
 			name: "invalid amount",
 			request: models.WalletOperationRequest{
@@ -67,7 +85,7 @@ func TestWalletService_ProcessWalletOperation(t *testing.T) {
 			mockSetup: func(m *repository.MockWalletRepository) {
 				existingWallet := &models.Wallet{ID: walletID, Balance: 1000}
 				m.On("GetWallet", mock.Anything, walletID).Return(existingWallet, nil)
-				m.On("UpdateWalletBalance", mock.Anything, walletID, int64(1500), models.WITHDRAW).
+				m.On("ApplyOperation", mock.Anything, models.WalletOperationRequest{WalletID: walletID, OperationType: models.WITHDRAW, Amount: 1500}).
 					Return(errors.New("insufficient funds"))
 			},
 			expectedError: "insufficient funds",
@@ -144,4 +162,103 @@ func TestWalletService_GetWalletBalance(t *testing.T) {
 			mockRepo.AssertExpectations(t)
 		})
 	}
-}
\ No newline at end of file
+}
+
+func TestWalletService_ProcessBatch(t *testing.T) {
+	first := uuid.New()
+	second := uuid.New()
+	operations := []models.WalletOperationRequest{
+		{WalletID: first, OperationType: models.DEPOSIT, Amount: 100},
+		{WalletID: second, OperationType: models.WITHDRAW, Amount: 50},
+		{WalletID: first, OperationType: models.WITHDRAW, Amount: 30},
+	}
+
+	t.Run("atomic", func(t *testing.T) {
+		mockRepo := &repository.MockWalletRepository{}
+		mockRepo.On("CreateWallet", mock.Anything, first).Return(nil).Once()
+		mockRepo.On("CreateWallet", mock.Anything, second).Return(nil).Once()
+		mockRepo.On("UpdateWalletBalancesAtomic", mock.Anything, operations).Return(nil)
+
+		resp, err := NewWalletService(mockRepo).ProcessBatch(context.Background(), models.BatchOperationRequest{
+			Mode:       models.BatchAtomic,
+			Operations: operations,
+		})
+
+		assert.NoError(t, err)
+		assert.Equal(t, 3, resp.Succeeded)
+		assert.Equal(t, 0, resp.Failed)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("atomic rolled back", func(t *testing.T) {
+		mockRepo := &repository.MockWalletRepository{}
+		mockRepo.On("CreateWallet", mock.Anything, mock.Anything).Return(nil)
+		mockRepo.On("UpdateWalletBalancesAtomic", mock.Anything, operations).
+			Return(&models.BatchOperationError{Index: 1, Err: errors.New("insufficient funds")})
+
+		resp, err := NewWalletService(mockRepo).ProcessBatch(context.Background(), models.BatchOperationRequest{
+			Mode:       models.BatchAtomic,
+			Operations: operations,
+		})
+
+		assert.Nil(t, resp)
+		assert.Contains(t, err.Error(), "operation 1: insufficient funds")
+	})
+
+	t.Run("best effort", func(t *testing.T) {
+		mockRepo := &repository.MockWalletRepository{}
+		mockRepo.On("GetWallet", mock.Anything, mock.Anything).Return(&models.Wallet{}, nil)
+		mockRepo.On("ApplyOperation", mock.Anything, models.WalletOperationRequest{WalletID: first, OperationType: models.DEPOSIT, Amount: 100}).Return(nil)
+		mockRepo.On("ApplyOperation", mock.Anything, models.WalletOperationRequest{WalletID: second, OperationType: models.WITHDRAW, Amount: 50}).Return(errors.New("insufficient funds"))
+		mockRepo.On("ApplyOperation", mock.Anything, models.WalletOperationRequest{WalletID: first, OperationType: models.WITHDRAW, Amount: 30}).Return(nil)
+
+		resp, err := NewWalletService(mockRepo).ProcessBatch(context.Background(), models.BatchOperationRequest{
+			Mode:       models.BatchBestEffort,
+			Operations: operations,
+		})
+
+		assert.NoError(t, err)
+		assert.Equal(t, 2, resp.Succeeded)
+		assert.Equal(t, 1, resp.Failed)
+		assert.Equal(t, "failed", resp.Results[1].Status)
+		assert.EqualError(t, resp.Results[1].Err, "insufficient funds")
+		mockRepo.AssertExpectations(t)
+	})
+}
+
+func TestWalletService_WithMemoryRepository(t *testing.T) {
+	ctx := context.Background()
+	walletService := NewWalletService(repository.NewMemoryWalletRepository(nil))
+	walletID := uuid.New()
+
+	// The first operation creates the wallet
+	assert.NoError(t, walletService.ProcessWalletOperation(ctx, models.WalletOperationRequest{
+		WalletID: walletID, OperationType: models.DEPOSIT, Amount: 1000,
+	}))
+	err := walletService.ProcessWalletOperation(ctx, models.WalletOperationRequest{
+		WalletID: walletID, OperationType: models.WITHDRAW, Amount: 5000,
+	})
+	assert.EqualError(t, err, "insufficient funds")
+
+	other := uuid.New()
+	resp, err := walletService.ProcessBatch(ctx, models.BatchOperationRequest{
+		Mode: models.BatchAtomic,
+		Operations: []models.WalletOperationRequest{
+			{WalletID: walletID, OperationType: models.WITHDRAW, Amount: 400},
+			{WalletID: other, OperationType: models.DEPOSIT, Amount: 400},
+		},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, resp.Succeeded)
+
+	balance, err := walletService.GetWalletBalance(ctx, walletID)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(600), balance)
+	balance, err = walletService.GetWalletBalance(ctx, other)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(400), balance)
+
+	transactions, err := walletService.ListTransactions(ctx, walletID, 0, 10)
+	assert.NoError(t, err)
+	assert.Len(t, transactions, 2)
+}
@@ -0,0 +1,94 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	"ITKtest/internal/models"
+	"ITKtest/internal/repository"
+
+	"github.com/google/uuid"
+)
+
+type WebhookService interface {
+	RegisterEndpoint(ctx context.Context, req models.RegisterWebhookRequest) (*models.WebhookEndpoint, error)
+	ListEndpoints(ctx context.Context) ([]models.WebhookEndpoint, error)
+	DeleteEndpoint(ctx context.Context, endpointID uuid.UUID) error
+	ListDeadLetters(ctx context.Context, limit int) ([]models.WebhookDeadLetter, error)
+	ReplayDeadLetter(ctx context.Context, deadLetterID int64) error
+}
+
+type webhookService struct {
+	repo repository.WebhookRepository
+}
+
+// Errors returned by RegisterEndpoint for requests it refuses
+var (
+	ErrInvalidWebhookURL = errors.New("invalid webhook url")
+	ErrInvalidEventTypes = errors.New("invalid event types")
+)
+
+func NewWebhookService(repo repository.WebhookRepository) WebhookService {
+	return &webhookService{repo: repo}
+}
+
+// RegisterEndpoint validates and stores an endpoint. The secret is generated when omitted
+// and is only returned in this response.
+func (s *webhookService) RegisterEndpoint(ctx context.Context, req models.RegisterWebhookRequest) (*models.WebhookEndpoint, error) {
+	u, err := url.Parse(req.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, ErrInvalidWebhookURL
+	}
+
+	if len(req.EventTypes) == 0 {
+		return nil, fmt.Errorf("%w: at least one is required", ErrInvalidEventTypes)
+	}
+	for _, eventType := range req.EventTypes {
+		if !eventType.Valid() {
+			return nil, fmt.Errorf("%w: unknown %q", ErrInvalidEventTypes, eventType)
+		}
+	}
+
+	secret := req.Secret
+	if secret == "" {
+		buf := make([]byte, 32)
+		if _, err := rand.Read(buf); err != nil {
+			return nil, err
+		}
+		secret = hex.EncodeToString(buf)
+	}
+
+	endpoint := &models.WebhookEndpoint{
+		ID:         uuid.New(),
+		URL:        req.URL,
+		Secret:     secret,
+		EventTypes: req.EventTypes,
+		Active:     true,
+		CreatedAt:  time.Now(),
+	}
+	if err := s.repo.CreateEndpoint(ctx, endpoint); err != nil {
+		return nil, err
+	}
+	return endpoint, nil
+}
+
+func (s *webhookService) ListEndpoints(ctx context.Context) ([]models.WebhookEndpoint, error) {
+	return s.repo.ListEndpoints(ctx)
+}
+
+func (s *webhookService) DeleteEndpoint(ctx context.Context, endpointID uuid.UUID) error {
+	return s.repo.DeleteEndpoint(ctx, endpointID)
+}
+
+func (s *webhookService) ListDeadLetters(ctx context.Context, limit int) ([]models.WebhookDeadLetter, error) {
+	return s.repo.ListDeadLetters(ctx, limit)
+}
+
+func (s *webhookService) ReplayDeadLetter(ctx context.Context, deadLetterID int64) error {
+	return s.repo.ReplayDeadLetter(ctx, deadLetterID)
+}
@@ -0,0 +1,53 @@
+package settings
+
+import (
+	"log/slog"
+	"sync/atomic"
+)
+
+// Runtime holds the settings that can change while the service is running. A Runtime is
+// never modified after it is stored; a reload stores a new one.
+type Runtime struct {
+	LogLevel slog.Level
+	// MaxOperationAmount caps the amount of a single operation; 0 means no limit
+	MaxOperationAmount int64
+	MaxBatchSize       int
+	// RateLimit is the number of requests per second allowed per client, with bursts of up to
+	// RateLimitBurst; 0 disables rate limiting
+	RateLimit      float64
+	RateLimitBurst int
+	// ReadOnly rejects every balance change, e.g. during maintenance
+	ReadOnly        bool
+	BatchOperations bool
+}
+
+// Default returns the settings used when nothing is configured
+func Default() Runtime {
+	return Runtime{
+		LogLevel:        slog.LevelInfo,
+		MaxBatchSize:    1000,
+		RateLimitBurst:  20,
+		BatchOperations: true,
+	}
+}
+
+// Store gives concurrent readers the current Runtime and lets a reload replace it atomically
+type Store struct {
+	current atomic.Pointer[Runtime]
+}
+
+func NewStore(runtime Runtime) *Store {
+	s := &Store{}
+	s.current.Store(&runtime)
+	return s
+}
+
+// Load returns the current settings; callers must not modify them
+func (s *Store) Load() *Runtime {
+	return s.current.Load()
+}
+
+// Swap replaces the settings and returns the previous ones
+func (s *Store) Swap(runtime Runtime) *Runtime {
+	return s.current.Swap(&runtime)
+}
@@ -0,0 +1,99 @@
+package settlement
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"ITKtest/internal/models"
+	"ITKtest/internal/repository"
+)
+
+// Matcher matches settlement file lines to wallet deposits by reference and amount
+type Matcher struct {
+	repo repository.SettlementRepository
+}
+
+func NewMatcher(repo repository.SettlementRepository) *Matcher {
+	return &Matcher{repo: repo}
+}
+
+// Import parses a settlement file and matches its lines. A malformed file returns a *ParseError.
+func (m *Matcher) Import(ctx context.Context, r io.Reader, mapping Mapping) (*models.SettlementReport, error) {
+	lines, err := Parse(r, mapping)
+	if err != nil {
+		return nil, err
+	}
+	return m.Match(ctx, lines)
+}
+
+// Match reports a line as matched when exactly one deposit carries its reference, with the same
+// amount and, if the line names one, into the same wallet. A reference listed on several lines
+// or carried by several deposits cannot be told apart, so all of its lines are mismatched.
+func (m *Matcher) Match(ctx context.Context, lines []models.SettlementLine) (*models.SettlementReport, error) {
+	occurrences := make(map[string]int, len(lines))
+	var references []string
+	for _, line := range lines {
+		if occurrences[line.Reference] == 0 {
+			references = append(references, line.Reference)
+		}
+		occurrences[line.Reference]++
+	}
+	deposits, err := m.repo.DepositsByReference(ctx, references)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &models.SettlementReport{Lines: len(lines), Results: make([]models.SettlementResult, 0, len(lines))}
+	for _, line := range lines {
+		result := match(line, deposits[line.Reference], occurrences[line.Reference])
+		switch result.Status {
+		case models.SettlementMatched:
+			report.Matched++
+		case models.SettlementUnmatched:
+			report.Unmatched++
+		default:
+			report.Mismatched++
+		}
+		report.Results = append(report.Results, result)
+	}
+	return report, nil
+}
+
+func match(line models.SettlementLine, deposits []models.Transaction, occurrences int) models.SettlementResult {
+	result := models.SettlementResult{SettlementLine: line}
+	for _, d := range deposits {
+		result.TransactionIDs = append(result.TransactionIDs, d.ID)
+	}
+
+	var reasons []string
+	if occurrences > 1 {
+		reasons = append(reasons, fmt.Sprintf("reference is listed on %d lines of the file", occurrences))
+	}
+	switch {
+	case len(deposits) == 0:
+		if len(reasons) == 0 {
+			result.Status = models.SettlementUnmatched
+			result.Reason = "no deposit carries this reference"
+			return result
+		}
+	case len(deposits) > 1:
+		reasons = append(reasons, fmt.Sprintf("%d deposits carry this reference", len(deposits)))
+	default:
+		if line.WalletID != nil && *line.WalletID != deposits[0].WalletID {
+			reasons = append(reasons, fmt.Sprintf("deposit credited wallet %s", deposits[0].WalletID))
+		}
+		if line.Amount != deposits[0].Amount {
+			reasons = append(reasons, fmt.Sprintf("deposit amount is %d", deposits[0].Amount))
+		}
+	}
+
+	if len(reasons) == 0 {
+		result.Status = models.SettlementMatched
+	} else {
+		result.Status = models.SettlementMismatched
+		result.Reason = strings.Join(reasons, "; ")
+	}
+	return result
+}
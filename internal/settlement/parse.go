@@ -0,0 +1,169 @@
+package settlement
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"ITKtest/internal/models"
+
+	"github.com/google/uuid"
+)
+
+// Mapping names the columns of a settlement file. The file starts with a header row; columns are
+// found by name, ignoring case, and any others are ignored. WalletColumn is optional. Amounts are
+// decimal numbers with at most Decimals fractional digits, converted to minor units.
+type Mapping struct {
+	ReferenceColumn string
+	AmountColumn    string
+	WalletColumn    string
+	Delimiter       rune
+	Decimals        int
+}
+
+// ParseError rejects a settlement file, naming the line at fault
+type ParseError struct {
+	Line int
+	Err  error
+}
+
+func (e *ParseError) Error() string {
+	if e.Line == 0 {
+		return e.Err.Error()
+	}
+	return fmt.Sprintf("line %d: %v", e.Line, e.Err)
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// Parse reads the settlement lines of a file. Any malformed line rejects the whole file, so
+// that a report never silently leaves out payments.
+func Parse(r io.Reader, m Mapping) ([]models.SettlementLine, error) {
+	reader := csv.NewReader(r)
+	reader.Comma = m.Delimiter
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, &ParseError{Err: errors.New("the file is empty")}
+	}
+	if err != nil {
+		return nil, parseError(err)
+	}
+	// Spreadsheet exports often start with a byte order mark
+	header[0] = strings.TrimPrefix(header[0], "\ufeff")
+
+	referenceIndex, err := columnIndex(header, m.ReferenceColumn)
+	if err != nil {
+		return nil, err
+	}
+	amountIndex, err := columnIndex(header, m.AmountColumn)
+	if err != nil {
+		return nil, err
+	}
+	walletIndex := -1
+	if m.WalletColumn != "" {
+		if walletIndex, err = columnIndex(header, m.WalletColumn); err != nil {
+			return nil, err
+		}
+	}
+
+	var lines []models.SettlementLine
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return lines, nil
+		}
+		if err != nil {
+			return nil, parseError(err)
+		}
+		number, _ := reader.FieldPos(0)
+		if len(record) == 1 && strings.TrimSpace(record[0]) == "" {
+			continue
+		}
+
+		field := func(index int) (string, error) {
+			if index >= len(record) {
+				return "", &ParseError{Line: number, Err: fmt.Errorf("expected at least %d fields, got %d", index+1, len(record))}
+			}
+			return strings.TrimSpace(record[index]), nil
+		}
+		line := models.SettlementLine{Line: number}
+		if line.Reference, err = field(referenceIndex); err != nil {
+			return nil, err
+		}
+		if line.Reference == "" {
+			return nil, &ParseError{Line: number, Err: errors.New("reference is empty")}
+		}
+		if len([]rune(line.Reference)) > models.MaxReferenceLength {
+			return nil, &ParseError{Line: number, Err: fmt.Errorf("reference must be at most %d characters", models.MaxReferenceLength)}
+		}
+		amount, err := field(amountIndex)
+		if err != nil {
+			return nil, err
+		}
+		if line.Amount, err = parseAmount(amount, m.Decimals); err != nil {
+			return nil, &ParseError{Line: number, Err: err}
+		}
+		if walletIndex >= 0 {
+			wallet, err := field(walletIndex)
+			if err != nil {
+				return nil, err
+			}
+			if wallet != "" {
+				walletID, err := uuid.Parse(wallet)
+				if err != nil {
+					return nil, &ParseError{Line: number, Err: fmt.Errorf("invalid wallet ID %q", wallet)}
+				}
+				line.WalletID = &walletID
+			}
+		}
+		lines = append(lines, line)
+	}
+}
+
+func columnIndex(header []string, name string) (int, error) {
+	for i, column := range header {
+		if strings.EqualFold(strings.TrimSpace(column), name) {
+			return i, nil
+		}
+	}
+	return 0, &ParseError{Line: 1, Err: fmt.Errorf("column %q not found in the header", name)}
+}
+
+// parseError keeps the line number of a CSV syntax error out of its message, as ParseError
+// adds it
+func parseError(err error) error {
+	var csvErr *csv.ParseError
+	if errors.As(err, &csvErr) {
+		return &ParseError{Line: csvErr.Line, Err: csvErr.Err}
+	}
+	return &ParseError{Err: err}
+}
+
+// parseAmount converts a positive decimal amount to minor units without going through floating
+// point, so that no cent is lost to rounding
+func parseAmount(s string, decimals int) (int64, error) {
+	whole, fraction, _ := strings.Cut(s, ".")
+	if len(fraction) > decimals {
+		return 0, fmt.Errorf("amount %q has more than %d decimal places", s, decimals)
+	}
+	digits := whole + fraction + strings.Repeat("0", decimals-len(fraction))
+	if whole == "" || strings.TrimLeft(digits, "0123456789") != "" {
+		return 0, fmt.Errorf("invalid amount %q", s)
+	}
+	amount, err := strconv.ParseInt(digits, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("amount %q is out of range", s)
+	}
+	if amount <= 0 {
+		return 0, fmt.Errorf("amount %q must be positive", s)
+	}
+	return amount, nil
+}
@@ -0,0 +1,126 @@
+package settlement
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"ITKtest/internal/models"
+	"ITKtest/internal/repository"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+var defaultMapping = Mapping{ReferenceColumn: "reference", AmountColumn: "amount", Delimiter: ',', Decimals: 2}
+
+func TestParse(t *testing.T) {
+	walletID := uuid.New()
+
+	t.Run("columns are found by name", func(t *testing.T) {
+		file := "\ufeffDate;Amount;Wallet;Reference\n" +
+			"2026-03-01;12.34;" + walletID.String() + ";bank-1\n" +
+			"\n" +
+			"2026-03-01; 5 ;;bank-2\n"
+		mapping := defaultMapping
+		mapping.Delimiter = ';'
+		mapping.WalletColumn = "wallet"
+
+		lines, err := Parse(strings.NewReader(file), mapping)
+		require.NoError(t, err)
+		assert.Equal(t, []models.SettlementLine{
+			{Line: 2, Reference: "bank-1", Amount: 1234, WalletID: &walletID},
+			{Line: 4, Reference: "bank-2", Amount: 500},
+		}, lines)
+	})
+
+	tests := []struct {
+		name          string
+		file          string
+		expectedError string
+	}{
+		{"empty file", "", "the file is empty"},
+		{"missing column", "reference,sum\nbank-1,10\n", `line 1: column "amount" not found in the header`},
+		{"empty reference", "reference,amount\n,10\n", "line 2: reference is empty"},
+		{"short line", "reference,amount\nbank-1\n", "line 2: expected at least 2 fields, got 1"},
+		{"too many decimals", "reference,amount\nbank-1,1.005\n", `line 2: amount "1.005" has more than 2 decimal places`},
+		{"not a number", "reference,amount\nbank-1,1e3\n", `line 2: invalid amount "1e3"`},
+		{"negative amount", "reference,amount\nbank-1,-3\n", `line 2: invalid amount "-3"`},
+		{"zero amount", "reference,amount\nbank-1,0.00\n", `line 2: amount "0.00" must be positive`},
+		{"out of range", "reference,amount\nbank-1,99999999999999999999\n", `line 2: amount "99999999999999999999" is out of range`},
+		{"bad quoting", "reference,amount\n\"bank-1,10\n", "line 2: extraneous or missing \" in quoted-field"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse(strings.NewReader(tt.file), defaultMapping)
+			var parseErr *ParseError
+			require.ErrorAs(t, err, &parseErr)
+			assert.Equal(t, tt.expectedError, err.Error())
+		})
+	}
+}
+
+func TestMatcher_Match(t *testing.T) {
+	walletID, otherWallet := uuid.New(), uuid.New()
+	lines := []models.SettlementLine{
+		{Line: 2, Reference: "ok", Amount: 100},
+		{Line: 3, Reference: "missing", Amount: 100},
+		{Line: 4, Reference: "short", Amount: 100},
+		{Line: 5, Reference: "twice", Amount: 100},
+		{Line: 6, Reference: "elsewhere", Amount: 100, WalletID: &walletID},
+		{Line: 7, Reference: "repeated", Amount: 100},
+		{Line: 8, Reference: "repeated", Amount: 100},
+	}
+
+	repo := &repository.MockSettlementRepository{}
+	repo.On("DepositsByReference", mock.Anything, []string{"ok", "missing", "short", "twice", "elsewhere", "repeated"}).
+		Return(map[string][]models.Transaction{
+			"ok":        {{ID: 1, WalletID: walletID, Amount: 100}},
+			"short":     {{ID: 2, WalletID: walletID, Amount: 90}},
+			"twice":     {{ID: 3, WalletID: walletID, Amount: 100}, {ID: 4, WalletID: walletID, Amount: 100}},
+			"elsewhere": {{ID: 5, WalletID: otherWallet, Amount: 100}},
+			"repeated":  {{ID: 6, WalletID: walletID, Amount: 100}},
+		}, nil)
+
+	report, err := NewMatcher(repo).Match(context.Background(), lines)
+	require.NoError(t, err)
+	assert.Equal(t, 7, report.Lines)
+	assert.Equal(t, 1, report.Matched)
+	assert.Equal(t, 1, report.Unmatched)
+	assert.Equal(t, 5, report.Mismatched)
+	assert.False(t, report.Clean())
+
+	reasons := make([]string, len(report.Results))
+	for i, result := range report.Results {
+		reasons[i] = result.Status + ": " + result.Reason
+	}
+	assert.Equal(t, []string{
+		"matched: ",
+		"unmatched: no deposit carries this reference",
+		"mismatched: deposit amount is 90",
+		"mismatched: 2 deposits carry this reference",
+		"mismatched: deposit credited wallet " + otherWallet.String(),
+		"mismatched: reference is listed on 2 lines of the file",
+		"mismatched: reference is listed on 2 lines of the file",
+	}, reasons)
+	assert.Equal(t, []int64{3, 4}, report.Results[3].TransactionIDs)
+}
+
+func TestMatcher_Import(t *testing.T) {
+	repo := &repository.MockSettlementRepository{}
+	matcher := NewMatcher(repo)
+
+	// A malformed file never reaches the database
+	_, err := matcher.Import(context.Background(), strings.NewReader("reference,amount\nbank-1,abc\n"), defaultMapping)
+	var parseErr *ParseError
+	require.ErrorAs(t, err, &parseErr)
+	assert.Equal(t, 2, parseErr.Line)
+	repo.AssertNotCalled(t, "DepositsByReference", mock.Anything, mock.Anything)
+
+	repo.On("DepositsByReference", mock.Anything, []string{"bank-1"}).Return(nil, errors.New("connection refused"))
+	_, err = matcher.Import(context.Background(), strings.NewReader("reference,amount\nbank-1,10\n"), defaultMapping)
+	assert.EqualError(t, err, "connection refused")
+}
@@ -0,0 +1,76 @@
+package snapshot
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"ITKtest/internal/repository"
+)
+
+// Scheduler writes a balance snapshot of every wallet at the end of each day. A day is
+// snapshotted only once grace has passed after midnight, so that transactions still in flight
+// at midnight have committed; their journal entries carry the time they started.
+type Scheduler struct {
+	repo     repository.SnapshotRepository
+	location *time.Location
+	grace    time.Duration
+	interval time.Duration
+	now      func() time.Time
+
+	// last is the end of the latest day snapshotted by this instance
+	last time.Time
+}
+
+func NewScheduler(repo repository.SnapshotRepository, location *time.Location, grace, interval time.Duration) *Scheduler {
+	return &Scheduler{
+		repo:     repo,
+		location: location,
+		grace:    grace,
+		interval: interval,
+		now:      time.Now,
+	}
+}
+
+// Run checks for a due snapshot every interval until ctx is cancelled
+func (s *Scheduler) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		if err := s.RunDue(ctx); err != nil && ctx.Err() == nil {
+			log.Printf("balance snapshots: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunDue snapshots the latest finished day unless this instance already did. Snapshots are
+// idempotent, so several instances may run the scheduler.
+func (s *Scheduler) RunDue(ctx context.Context) error {
+	due := s.dueAt()
+	if !due.After(s.last) {
+		return nil
+	}
+
+	n, err := s.repo.TakeSnapshots(ctx, due)
+	if err != nil {
+		return err
+	}
+	s.last = due
+	if n > 0 {
+		log.Printf("Wrote %d balance snapshots as of %s", n, due.Format(time.RFC3339))
+	}
+	return nil
+}
+
+// dueAt is the latest midnight in the scheduler's time zone that is at least grace ago
+func (s *Scheduler) dueAt() time.Time {
+	t := s.now().Add(-s.grace).In(s.location)
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, s.location)
+}
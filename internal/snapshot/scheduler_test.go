@@ -0,0 +1,40 @@
+package snapshot
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"ITKtest/internal/repository"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestScheduler_RunDue(t *testing.T) {
+	moscow := time.FixedZone("MSK", 3*60*60)
+	repo := &repository.MockSnapshotRepository{}
+	scheduler := NewScheduler(repo, moscow, 5*time.Minute, time.Minute)
+
+	// 00:03 in Moscow is still within the grace period of the new day
+	now := time.Date(2026, 3, 1, 21, 3, 0, 0, time.UTC)
+	scheduler.now = func() time.Time { return now }
+	previousMidnight := time.Date(2026, 3, 1, 0, 0, 0, 0, moscow)
+	repo.On("TakeSnapshots", mock.Anything, previousMidnight).Return(int64(2), nil).Once()
+	assert.NoError(t, scheduler.RunDue(context.Background()))
+
+	// The same day is not snapshotted twice
+	now = now.Add(time.Minute)
+	assert.NoError(t, scheduler.RunDue(context.Background()))
+
+	// Once the grace period is over the new day is due; failures are retried
+	now = now.Add(2 * time.Minute)
+	midnight := time.Date(2026, 3, 2, 0, 0, 0, 0, moscow)
+	repo.On("TakeSnapshots", mock.Anything, midnight).Return(int64(0), errors.New("connection reset")).Once()
+	assert.Error(t, scheduler.RunDue(context.Background()))
+	repo.On("TakeSnapshots", mock.Anything, midnight).Return(int64(2), nil).Once()
+	assert.NoError(t, scheduler.RunDue(context.Background()))
+
+	repo.AssertExpectations(t)
+}
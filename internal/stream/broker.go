@@ -0,0 +1,87 @@
+package stream
+
+import (
+	"sync"
+
+	"ITKtest/internal/models"
+
+	"github.com/google/uuid"
+)
+
+const subscriberBuffer = 64
+
+// Broker fans committed journal entries out to in-process subscribers of a wallet
+type Broker struct {
+	mu          sync.Mutex
+	subscribers map[uuid.UUID]map[chan models.Transaction]struct{}
+}
+
+func NewBroker() *Broker {
+	return &Broker{subscribers: make(map[uuid.UUID]map[chan models.Transaction]struct{})}
+}
+
+// Subscribe returns a channel of new entries for walletID and a function that releases it.
+// The channel is closed when the subscriber falls behind or the feed is interrupted; the
+// client is expected to reconnect and resume from the journal.
+func (b *Broker) Subscribe(walletID uuid.UUID) (<-chan models.Transaction, func()) {
+	ch := make(chan models.Transaction, subscriberBuffer)
+
+	b.mu.Lock()
+	if b.subscribers[walletID] == nil {
+		b.subscribers[walletID] = make(map[chan models.Transaction]struct{})
+	}
+	b.subscribers[walletID][ch] = struct{}{}
+	b.mu.Unlock()
+
+	return ch, func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		b.remove(walletID, ch)
+	}
+}
+
+func (b *Broker) Publish(transaction models.Transaction) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for ch := range b.subscribers[transaction.WalletID] {
+		select {
+		case ch <- transaction:
+		default:
+			b.remove(transaction.WalletID, ch)
+		}
+	}
+}
+
+// DisconnectAll closes every subscription, e.g. after the notification feed was interrupted
+// and entries may have been missed
+func (b *Broker) DisconnectAll() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for walletID, subs := range b.subscribers {
+		for ch := range subs {
+			b.remove(walletID, ch)
+		}
+	}
+}
+
+// FeedInterrupted makes clients resume from the journal, since entries may have been missed
+func (b *Broker) FeedInterrupted() {
+	b.DisconnectAll()
+}
+
+func (b *Broker) remove(walletID uuid.UUID, ch chan models.Transaction) {
+	subs, ok := b.subscribers[walletID]
+	if !ok {
+		return
+	}
+	if _, ok := subs[ch]; !ok {
+		return
+	}
+	delete(subs, ch)
+	close(ch)
+	if len(subs) == 0 {
+		delete(b.subscribers, walletID)
+	}
+}
@@ -0,0 +1,55 @@
+package stream
+
+import (
+	"testing"
+
+	"ITKtest/internal/models"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBroker_PublishToWalletSubscribers(t *testing.T) {
+	broker := NewBroker()
+	walletID := uuid.New()
+
+	events, unsubscribe := broker.Subscribe(walletID)
+	defer unsubscribe()
+	other, unsubscribeOther := broker.Subscribe(uuid.New())
+	defer unsubscribeOther()
+
+	broker.Publish(models.Transaction{ID: 1, WalletID: walletID})
+
+	assert.Equal(t, int64(1), (<-events).ID)
+	assert.Len(t, other, 0)
+}
+
+func TestBroker_SlowSubscriberIsDisconnected(t *testing.T) {
+	broker := NewBroker()
+	walletID := uuid.New()
+
+	events, unsubscribe := broker.Subscribe(walletID)
+	defer unsubscribe()
+
+	for i := 0; i <= subscriberBuffer; i++ {
+		broker.Publish(models.Transaction{ID: int64(i), WalletID: walletID})
+	}
+
+	received := 0
+	for range events {
+		received++
+	}
+	assert.Equal(t, subscriberBuffer, received)
+}
+
+func TestBroker_DisconnectAll(t *testing.T) {
+	broker := NewBroker()
+
+	events, unsubscribe := broker.Subscribe(uuid.New())
+	broker.DisconnectAll()
+
+	_, ok := <-events
+	assert.False(t, ok)
+	// Releasing an already closed subscription must not panic
+	unsubscribe()
+}
@@ -0,0 +1,62 @@
+package stream
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"time"
+
+	"ITKtest/internal/models"
+	"ITKtest/internal/repository"
+
+	"github.com/lib/pq"
+)
+
+// Sink consumes the journal entries received by ListenTransactions
+type Sink interface {
+	Publish(transaction models.Transaction)
+	// FeedInterrupted is called when notifications may have been missed
+	FeedInterrupted()
+}
+
+// ListenTransactions feeds the sinks from Postgres LISTEN/NOTIFY so that every instance sees
+// operations committed by any instance. It blocks until ctx is cancelled.
+func ListenTransactions(ctx context.Context, connStr string, sinks ...Sink) error {
+	listener := pq.NewListener(connStr, time.Second, time.Minute, func(event pq.ListenerEventType, err error) {
+		switch event {
+		case pq.ListenerEventDisconnected:
+			log.Printf("transaction listener disconnected: %v", err)
+		case pq.ListenerEventReconnected:
+			// Notifications sent while disconnected are lost
+			for _, sink := range sinks {
+				sink.FeedInterrupted()
+			}
+		}
+	})
+	defer listener.Close()
+
+	if err := listener.Listen(repository.TransactionsChannel); err != nil {
+		return err
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case n := <-listener.Notify:
+			if n == nil {
+				continue
+			}
+			var transaction models.Transaction
+			if err := json.Unmarshal([]byte(n.Extra), &transaction); err != nil {
+				log.Printf("transaction listener: invalid payload: %v", err)
+				continue
+			}
+			for _, sink := range sinks {
+				sink.Publish(transaction)
+			}
+		case <-time.After(90 * time.Second):
+			go listener.Ping()
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"ITKtest/internal/models"
+	"ITKtest/internal/repository"
+)
+
+// Deliverer sends queued webhook deliveries and reschedules failures with exponential backoff
+type Deliverer struct {
+	repo         repository.WebhookRepository
+	client       *http.Client
+	maxAttempts  int
+	baseBackoff  time.Duration
+	maxBackoff   time.Duration
+	pollInterval time.Duration
+	batchSize    int
+}
+
+func NewDeliverer(repo repository.WebhookRepository, client *http.Client, maxAttempts int, baseBackoff, maxBackoff, pollInterval time.Duration, batchSize int) *Deliverer {
+	return &Deliverer{
+		repo:         repo,
+		client:       client,
+		maxAttempts:  maxAttempts,
+		baseBackoff:  baseBackoff,
+		maxBackoff:   maxBackoff,
+		pollInterval: pollInterval,
+		batchSize:    batchSize,
+	}
+}
+
+// Run polls for due deliveries until ctx is cancelled
+func (d *Deliverer) Run(ctx context.Context) {
+	ticker := time.NewTicker(d.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := d.DeliverDue(ctx); err != nil && ctx.Err() == nil {
+			log.Printf("webhook deliverer: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// DeliverDue attempts one batch of due deliveries and returns how many succeeded
+func (d *Deliverer) DeliverDue(ctx context.Context) (int, error) {
+	// The lease must outlive the HTTP timeout so a slow endpoint is not picked up twice
+	lease := d.client.Timeout + d.baseBackoff
+	deliveries, err := d.repo.ClaimDueDeliveries(ctx, d.batchSize, lease)
+	if err != nil {
+		return 0, err
+	}
+
+	delivered := 0
+	for _, delivery := range deliveries {
+		sendErr := d.send(ctx, delivery)
+		switch {
+		case sendErr == nil:
+			err = d.repo.MarkDelivered(ctx, delivery.ID)
+			delivered++
+		case delivery.Attempts+1 >= d.maxAttempts:
+			err = d.repo.MoveToDeadLetter(ctx, delivery.ID, sendErr.Error())
+		default:
+			err = d.repo.MarkFailed(ctx, delivery.ID, sendErr.Error(), time.Now().Add(d.Backoff(delivery.Attempts+1)))
+		}
+		if err != nil {
+			return delivered, err
+		}
+	}
+	return delivered, nil
+}
+
+// Backoff returns the delay before the next attempt after the given number of failed attempts
+func (d *Deliverer) Backoff(attempts int) time.Duration {
+	backoff := d.baseBackoff
+	for i := 1; i < attempts; i++ {
+		backoff *= 2
+		if backoff >= d.maxBackoff {
+			return d.maxBackoff
+		}
+	}
+	return backoff
+}
+
+func (d *Deliverer) send(ctx context.Context, delivery models.WebhookDelivery) error {
+	timestamp := time.Now().Unix()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.EndpointURL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderEvent, string(delivery.EventType))
+	req.Header.Set(HeaderDelivery, strconv.FormatInt(delivery.ID, 10))
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(HeaderSignature, Sign(delivery.Secret, timestamp, delivery.Payload))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("endpoint responded with status %d", resp.StatusCode)
+	}
+	return nil
+}
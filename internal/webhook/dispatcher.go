@@ -0,0 +1,74 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"ITKtest/internal/models"
+	"ITKtest/internal/repository"
+)
+
+// Dispatcher is an outbox Publisher that turns wallet events into webhook deliveries
+type Dispatcher struct {
+	repo                repository.WebhookRepository
+	lowBalanceThreshold int64
+}
+
+func NewDispatcher(repo repository.WebhookRepository, lowBalanceThreshold int64) *Dispatcher {
+	return &Dispatcher{
+		repo:                repo,
+		lowBalanceThreshold: lowBalanceThreshold,
+	}
+}
+
+func (d *Dispatcher) Publish(ctx context.Context, event models.OutboxEvent) error {
+	if event.EventType != models.EventWalletBalanceChanged {
+		return nil
+	}
+
+	var changed models.WalletBalanceChangedEvent
+	if err := json.Unmarshal(event.Payload, &changed); err != nil {
+		return fmt.Errorf("failed to decode %s event %d: %w", event.EventType, event.ID, err)
+	}
+
+	for _, eventType := range d.eventTypes(changed) {
+		body, err := json.Marshal(models.WebhookPayload{
+			EventID:   event.ID,
+			EventType: eventType,
+			CreatedAt: changed.OccurredAt,
+			Data:      event.Payload,
+		})
+		if err != nil {
+			return err
+		}
+		if _, err := d.repo.EnqueueDeliveries(ctx, event.ID, eventType, body); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// eventTypes returns the webhooks announcing a journal entry. Fee entries are sent as fees rather
+// than deposits and withdrawals, and entries that move nothing, such as the real-balance part of
+// a purchase paid entirely with bonus, are not sent at all.
+func (d *Dispatcher) eventTypes(changed models.WalletBalanceChangedEvent) []models.WebhookEventType {
+	if changed.Amount == 0 {
+		return nil
+	}
+
+	var eventTypes []models.WebhookEventType
+	switch {
+	case changed.FeeFor != nil:
+		eventTypes = append(eventTypes, models.WebhookFee)
+	case changed.OperationType == models.DEPOSIT:
+		eventTypes = append(eventTypes, models.WebhookDeposit)
+	case changed.OperationType == models.WITHDRAW:
+		eventTypes = append(eventTypes, models.WebhookWithdrawal)
+	}
+	// Fire once when the balance crosses the threshold rather than on every withdrawal below it
+	if changed.OperationType == models.WITHDRAW && changed.BalanceBefore >= d.lowBalanceThreshold && changed.BalanceAfter < d.lowBalanceThreshold {
+		eventTypes = append(eventTypes, models.WebhookLowBalance)
+	}
+	return eventTypes
+}
@@ -0,0 +1,185 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"ITKtest/internal/models"
+	"ITKtest/internal/repository"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestSignAndVerify(t *testing.T) {
+	body := []byte(`{"eventId":1}`)
+	signature := Sign("secret", 1700000000, body)
+
+	assert.True(t, Verify("secret", 1700000000, body, signature))
+	assert.False(t, Verify("other", 1700000000, body, signature))
+	assert.False(t, Verify("secret", 1700000001, body, signature))
+	assert.False(t, Verify("secret", 1700000000, []byte(`{"eventId":2}`), signature))
+}
+
+func TestDispatcher_Publish(t *testing.T) {
+	walletID := uuid.New()
+	feeFor := int64(6)
+	tests := []struct {
+		name          string
+		event         models.WalletBalanceChangedEvent
+		expectedTypes []models.WebhookEventType
+	}{
+		{
+			name:          "deposit",
+			event:         models.WalletBalanceChangedEvent{WalletID: walletID, OperationType: models.DEPOSIT, Amount: 100, BalanceBefore: 0, BalanceAfter: 100},
+			expectedTypes: []models.WebhookEventType{models.WebhookDeposit},
+		},
+		{
+			name:          "withdrawal above threshold",
+			event:         models.WalletBalanceChangedEvent{WalletID: walletID, OperationType: models.WITHDRAW, Amount: 100, BalanceBefore: 5000, BalanceAfter: 4900},
+			expectedTypes: []models.WebhookEventType{models.WebhookWithdrawal},
+		},
+		{
+			name:          "withdrawal crossing threshold",
+			event:         models.WalletBalanceChangedEvent{WalletID: walletID, OperationType: models.WITHDRAW, Amount: 500, BalanceBefore: 1200, BalanceAfter: 700},
+			expectedTypes: []models.WebhookEventType{models.WebhookWithdrawal, models.WebhookLowBalance},
+		},
+		{
+			name:          "withdrawal already below threshold",
+			event:         models.WalletBalanceChangedEvent{WalletID: walletID, OperationType: models.WITHDRAW, Amount: 100, BalanceBefore: 700, BalanceAfter: 600},
+			expectedTypes: []models.WebhookEventType{models.WebhookWithdrawal},
+		},
+		{
+			name:          "fee charge crossing threshold",
+			event:         models.WalletBalanceChangedEvent{WalletID: walletID, OperationType: models.WITHDRAW, Amount: 10, BalanceBefore: 1005, BalanceAfter: 995, FeeFor: &feeFor},
+			expectedTypes: []models.WebhookEventType{models.WebhookFee, models.WebhookLowBalance},
+		},
+		{
+			name:          "fee credit",
+			event:         models.WalletBalanceChangedEvent{WalletID: walletID, OperationType: models.DEPOSIT, Amount: 10, BalanceBefore: 0, BalanceAfter: 10, FeeFor: &feeFor},
+			expectedTypes: []models.WebhookEventType{models.WebhookFee},
+		},
+		{
+			name:  "zero amount",
+			event: models.WalletBalanceChangedEvent{WalletID: walletID, OperationType: models.WITHDRAW, Amount: 0, BalanceBefore: 1200, BalanceAfter: 1200},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := &repository.MockWebhookRepository{}
+			for _, eventType := range tt.expectedTypes {
+				mockRepo.On("EnqueueDeliveries", mock.Anything, int64(7), eventType, mock.Anything).Return(1, nil).Once()
+			}
+
+			payload, _ := json.Marshal(tt.event)
+			dispatcher := NewDispatcher(mockRepo, 1000)
+			err := dispatcher.Publish(context.Background(), models.OutboxEvent{
+				ID:          7,
+				AggregateID: walletID,
+				EventType:   models.EventWalletBalanceChanged,
+				Payload:     payload,
+			})
+
+			assert.NoError(t, err)
+			mockRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestDeliverer_DeliverDue(t *testing.T) {
+	var received atomic.Int32
+	var failing atomic.Bool
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		timestamp, _ := strconv.ParseInt(r.Header.Get(HeaderTimestamp), 10, 64)
+		if !Verify("secret", timestamp, body, r.Header.Get(HeaderSignature)) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if failing.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		received.Add(1)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer receiver.Close()
+
+	delivery := func(attempts int) models.WebhookDelivery {
+		return models.WebhookDelivery{
+			ID:          1,
+			EndpointURL: receiver.URL,
+			Secret:      "secret",
+			EventType:   models.WebhookDeposit,
+			Payload:     json.RawMessage(`{"eventId":1}`),
+			Attempts:    attempts,
+		}
+	}
+
+	tests := []struct {
+		name      string
+		failing   bool
+		mockSetup func(*repository.MockWebhookRepository)
+		delivered int
+	}{
+		{
+			name: "successful delivery",
+			mockSetup: func(m *repository.MockWebhookRepository) {
+				m.On("ClaimDueDeliveries", mock.Anything, 10, mock.Anything).Return([]models.WebhookDelivery{delivery(0)}, nil)
+				m.On("MarkDelivered", mock.Anything, int64(1)).Return(nil)
+			},
+			delivered: 1,
+		},
+		{
+			name:    "failure is rescheduled",
+			failing: true,
+			mockSetup: func(m *repository.MockWebhookRepository) {
+				m.On("ClaimDueDeliveries", mock.Anything, 10, mock.Anything).Return([]models.WebhookDelivery{delivery(1)}, nil)
+				m.On("MarkFailed", mock.Anything, int64(1), "endpoint responded with status 503", mock.AnythingOfType("time.Time")).Return(nil)
+			},
+		},
+		{
+			name:    "last attempt goes to dead letter",
+			failing: true,
+			mockSetup: func(m *repository.MockWebhookRepository) {
+				m.On("ClaimDueDeliveries", mock.Anything, 10, mock.Anything).Return([]models.WebhookDelivery{delivery(2)}, nil)
+				m.On("MoveToDeadLetter", mock.Anything, int64(1), "endpoint responded with status 503").Return(nil)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			received.Store(0)
+			failing.Store(tt.failing)
+			mockRepo := &repository.MockWebhookRepository{}
+			tt.mockSetup(mockRepo)
+
+			deliverer := NewDeliverer(mockRepo, receiver.Client(), 3, time.Second, time.Minute, time.Second, 10)
+			delivered, err := deliverer.DeliverDue(context.Background())
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.delivered, delivered)
+			assert.Equal(t, int32(tt.delivered), received.Load())
+			mockRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestDeliverer_Backoff(t *testing.T) {
+	deliverer := NewDeliverer(nil, http.DefaultClient, 5, time.Second, 10*time.Second, time.Second, 10)
+
+	assert.Equal(t, time.Second, deliverer.Backoff(1))
+	assert.Equal(t, 2*time.Second, deliverer.Backoff(2))
+	assert.Equal(t, 8*time.Second, deliverer.Backoff(4))
+	assert.Equal(t, 10*time.Second, deliverer.Backoff(5))
+}
@@ -1,45 +1,259 @@
 package main
 
 import (
+	"context"
+	"crypto/tls"
+	"database/sql"
+	"fmt"
 	"log"
+	"log/slog"
+	"net"
 	"net/http"
+	"os"
 	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 
+	walletv1 "ITKtest/api/wallet/v1"
 	"ITKtest/config"
 	"ITKtest/database"
+	"ITKtest/internal/audit"
+	"ITKtest/internal/auth"
+	"ITKtest/internal/bonus"
+	"ITKtest/internal/cache"
 	"ITKtest/internal/controller"
+	"ITKtest/internal/fees"
+	"ITKtest/internal/models"
+	"ITKtest/internal/outbox"
+	"ITKtest/internal/reconcile"
 	"ITKtest/internal/repository"
+	"ITKtest/internal/schedule"
 	"ITKtest/internal/service"
+	"ITKtest/internal/settings"
+	"ITKtest/internal/settlement"
+	"ITKtest/internal/snapshot"
+	"ITKtest/internal/stream"
+	"ITKtest/internal/webhook"
 	"ITKtest/responder"
 )
 
 func main() {
 	// Load configuration
-	cfg, err := config.LoadConfig()
+	cfg, args, err := config.LoadConfig(os.Args[1:])
 	if err != nil {
 		log.Fatalf("Error loading config: %v", err)
 	}
 
-	// Connect to database
-	db, err := database.Connect(cfg.DB)
-	if err != nil {
-		log.Fatalf("Error connecting to database: %v", err)
+	if len(args) > 0 {
+		var err error
+		switch args[0] {
+		case "migrate":
+			err = runMigrate(cfg, args[1:])
+		case "config":
+			err = runConfig(cfg, args[1:])
+		case "audit":
+			err = runAudit(cfg, args[1:])
+		case "reconcile":
+			err = runReconcile(cfg, args[1:])
+		case "settlement":
+			err = runSettlement(cfg, args[1:])
+		default:
+			err = fmt.Errorf("unknown command %q: expected migrate, config, audit, reconcile or settlement", args[0])
+		}
+		if err != nil {
+			log.Fatalf("Error: %v", err)
+		}
+		return
 	}
-	defer db.Close()
 
-	// Run migrations
-	if err := database.RunMigrations(db, false); err != nil {
-		log.Fatalf("Error running migrations: %v", err)
+	runtimeSettings := settings.NewStore(cfg.Runtime)
+	slog.SetLogLoggerLevel(cfg.Runtime.LogLevel)
+
+	broker := stream.NewBroker()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Initialize storage
+	var db *sql.DB
+	var walletRepo repository.WalletRepository
+	switch cfg.StorageDriver {
+	case "postgres", "sqlite":
+		sqlDB, migrator, err := openDatabase(cfg)
+		if err != nil {
+			log.Fatalf("Error connecting to database: %v", err)
+		}
+		defer sqlDB.Close()
+
+		if err := database.PrepareSchema(migrator, cfg.AutoMigrate, cfg.DBReset); err != nil {
+			log.Fatalf("Error preparing database schema: %v", err)
+		}
+
+		if cfg.StorageDriver == "sqlite" {
+			walletRepo = repository.NewSQLiteWalletRepository(sqlDB, broker.Publish)
+			log.Println("Using SQLite storage: webhooks are disabled")
+			break
+		}
+		db = sqlDB
+		walletRepo, err = newPostgresWalletRepository(cfg, db)
+		if err != nil {
+			log.Fatalf("Error creating wallet repository: %v", err)
+		}
+
+		// Serve balance and history reads from the replica when one is configured
+		if cfg.Replica.URL != "" {
+			replicaCfg := cfg.DB
+			replicaCfg.URL = cfg.Replica.URL
+			replicaDB, err := database.Open(replicaCfg)
+			if err != nil {
+				log.Fatalf("Error opening read replica: %v", err)
+			}
+			defer replicaDB.Close()
+
+			monitor := repository.NewReplicaMonitor(replicaDB, cfg.Replica.MaxLag)
+			go monitor.Run(ctx, cfg.Replica.CheckInterval)
+			walletRepo = repository.NewReplicaWalletRepository(walletRepo, repository.NewWalletRepository(replicaDB, repository.TxConfig{}), monitor)
+			log.Println("Reading balances and history from the read replica")
+		}
+	case "memory":
+		walletRepo = repository.NewMemoryWalletRepository(broker.Publish)
+		log.Println("Using in-memory storage: data is lost on restart, webhooks are disabled")
+	default:
+		log.Fatalf("Unknown storage driver %q", cfg.StorageDriver)
 	}
 
-	// Initialize dependencies
-	walletRepo := repository.NewWalletRepository(db)
 	walletService := service.NewWalletService(walletRepo)
+	// The cache sits below the fee service, so that it sees the fee wallet of each write
+	sinks := []stream.Sink{broker}
+	if cfg.Cache.Enabled {
+		balanceCache := cache.NewBalanceCache(cfg.Cache.Size, cfg.Cache.TTL)
+		walletService = service.NewCachedWalletService(walletService, balanceCache)
+		sinks = append(sinks, balanceCache)
+		log.Printf("Balance cache enabled: %d wallets, TTL %s", cfg.Cache.Size, cfg.Cache.TTL)
+	}
+
+	// Charge fees from the schedule in the same transaction as each operation
+	var feeSchedule *fees.Schedule
+	if cfg.FeeScheduleFile != "" {
+		feeSchedule, err = fees.LoadSchedule(cfg.FeeScheduleFile)
+		if err != nil {
+			log.Fatalf("Error loading fee schedule: %v", err)
+		}
+		if err := walletRepo.CreateWallet(ctx, feeSchedule.FeeWallet); err != nil {
+			log.Fatalf("Error creating fee wallet: %v", err)
+		}
+		walletService = service.NewFeeWalletService(walletService, feeSchedule)
+		log.Printf("Charging fees of %d rules into wallet %s", len(feeSchedule.Rules), feeSchedule.FeeWallet)
+	}
+	// Bonus lots live in Postgres; purchases spend them as the policy orders
+	if db != nil {
+		walletService = service.NewBonusWalletService(walletService, models.BonusUse(cfg.Bonus.ConsumptionOrder))
+	}
+	if len(cfg.TLS.DebitPrincipals) > 0 {
+		walletService = service.NewDebitAuthorizedWalletService(walletService, cfg.TLS.DebitPrincipals)
+	}
+	walletService = service.NewGuardedWalletService(walletService, runtimeSettings)
 	resp := responder.NewJSONResponder()
+
+	// Designate hot wallets
+	for _, hw := range cfg.HotWallets {
+		if err := walletService.EnableSharding(context.Background(), hw.WalletID, hw.Shards); err != nil {
+			log.Fatalf("Error enabling sharding for wallet %s: %v", hw.WalletID, err)
+		}
+		log.Printf("Wallet %s is sharded across %d rows", hw.WalletID, hw.Shards)
+	}
 	walletController := controller.NewWalletController(walletService, resp)
+	feeController := controller.NewFeeController(feeSchedule, resp)
+	walletEventsController := controller.NewWalletEventsController(walletService, broker, resp)
+
+	// The outbox, webhooks, snapshots and notifications live in Postgres
+	var webhookController *controller.WebhookController
+	var balanceHistoryController *controller.BalanceHistoryController
+	var auditLog repository.AuditRepository
+	var reconciliationController *controller.ReconciliationController
+	var settlementController *controller.SettlementController
+	var bonusController *controller.BonusController
+	var scheduleController *controller.ScheduleController
+	if db != nil {
+		txConfig := newTxConfig(cfg.Tx)
+
+		// Record the runtime settings in effect, so that the audit log shows every limit from startup on
+		auditLog = repository.NewAuditRepository(db, txConfig)
+		if err := auditLog.Append(ctx, models.AuditRuntimeSettingsLoaded, auditSettingsSubject, nil, cfg.RuntimeValues()); err != nil {
+			log.Fatalf("Error writing the audit log: %v", err)
+		}
+		// Changes write their audit records unchained; link them into the chain in the background
+		go audit.NewChainer(auditLog, cfg.AuditChainInterval).Run(ctx)
+
+		webhookRepo := repository.NewWebhookRepository(db, txConfig)
+		webhookController = controller.NewWebhookController(service.NewWebhookService(webhookRepo), resp)
+
+		// Write daily balance snapshots for point-in-time balance queries
+		snapshotRepo := repository.NewSnapshotRepository(db)
+		balanceHistoryController = controller.NewBalanceHistoryController(service.NewBalanceHistoryService(snapshotRepo), resp)
+		go snapshot.NewScheduler(snapshotRepo, cfg.Snapshot.Location, cfg.Snapshot.Grace, cfg.Snapshot.CheckInterval).Run(ctx)
+
+		// Check balances against the journal at startup, periodically and on demand
+		reconciler := reconcile.NewReconciler(repository.NewReconciliationRepository(db), cfg.Reconcile.Interval, cfg.Reconcile.ReportLimit)
+		reconciliationController = controller.NewReconciliationController(reconciler, resp)
+		go reconciler.Run(ctx)
+
+		// Match uploaded bank and PSP settlement files against deposits by reference
+		matcher := settlement.NewMatcher(repository.NewSettlementRepository(db))
+		settlementController = controller.NewSettlementController(matcher, settlementMapping(cfg.Settlement), resp)
+
+		// Credit promotional lots and journal their expiry
+		bonusRepo := repository.NewBonusRepository(db, txConfig)
+		bonusController = controller.NewBonusController(service.NewBonusService(bonusRepo, cfg.Bonus.DefaultTTL), resp)
+		go bonus.NewExpirer(bonusRepo, cfg.Bonus.ExpiryInterval).Run(ctx)
+
+		// Execute scheduled and recurring operations through the wallet service
+		scheduleRepo := repository.NewScheduleRepository(db, txConfig)
+		scheduleController = controller.NewScheduleController(service.NewScheduleService(scheduleRepo), resp)
+		go schedule.NewWorker(scheduleRepo, walletService, service.DeclinedErrors, cfg.Schedule.PollInterval, cfg.Schedule.Lease, cfg.Schedule.BatchSize).Run(ctx)
+
+		// Start outbox relay
+		publisher, err := newOutboxPublisher(cfg.Outbox)
+		if err != nil {
+			log.Fatalf("Error creating outbox publisher: %v", err)
+		}
+		dispatcher := webhook.NewDispatcher(webhookRepo, cfg.Webhook.LowBalanceThreshold)
+		relay := outbox.NewRelay(
+			repository.NewOutboxRepository(db),
+			outbox.NewMultiPublisher(publisher, dispatcher),
+			cfg.Outbox.PollInterval,
+			cfg.Outbox.BatchSize,
+			cfg.Outbox.MaxAttempts,
+			cfg.Outbox.MaxBackoff,
+			cfg.Outbox.Lease,
+		)
+		go relay.Run(ctx)
+
+		// Start webhook deliverer
+		deliverer := webhook.NewDeliverer(
+			webhookRepo,
+			&http.Client{Timeout: cfg.Webhook.Timeout},
+			cfg.Webhook.MaxAttempts,
+			cfg.Webhook.BaseBackoff,
+			cfg.Webhook.MaxBackoff,
+			cfg.Webhook.PollInterval,
+			cfg.Webhook.BatchSize,
+		)
+		go deliverer.Run(ctx)
+
+		// Feed balance change streams and cache invalidation from Postgres notifications
+		go func() {
+			if err := stream.ListenTransactions(ctx, database.ConnectionString(cfg.DB), sinks...); err != nil {
+				log.Printf("Error listening for transactions: %v", err)
+			}
+		}()
+	}
+
+	// Apply runtime settings from a changed config without a restart
+	go watchConfig(ctx, os.Args[1:], cfg, runtimeSettings, auditLog)
+	rateLimiter := controller.NewRateLimiter(runtimeSettings, resp)
 
 	// Create router
 	r := chi.NewRouter()
@@ -47,17 +261,178 @@ func main() {
 	// Middleware
 	r.Use(middleware.Logger)
 	r.Use(middleware.Recoverer)
-	r.Use(middleware.Timeout(60 * time.Second))
+	r.Use(controller.ReadConsistency)
+	r.Use(controller.ClientPrincipal)
+	r.Use(controller.AuditContext)
+
+	// Transaction retry, replica and reconciliation counters, limited to the allowed client
+	// certificates
+	r.With(controller.RequirePrincipal(cfg.TLS.AdminPrincipals, resp)).
+		Handle("/debug/vars", controller.MetricsHandler("db_transactions", "read_replica", "reconciliation"))
 
 	// Routes
 	r.Route("/api/v1", func(r chi.Router) {
-		r.Post("/wallet", walletController.HandleWalletOperation)
-		r.Get("/wallets/{walletId}", walletController.GetWalletBalance)
+		r.Use(rateLimiter.Middleware)
+
+		r.Group(func(r chi.Router) {
+			r.Use(middleware.Timeout(60 * time.Second))
+
+			r.Post("/wallet", walletController.HandleWalletOperation)
+			r.Post("/wallet/batch", walletController.HandleBatchOperation)
+			r.Post("/wallet/quote", feeController.QuoteOperation)
+			r.Get("/wallets/{walletId}", walletController.GetWalletBalance)
+
+			if balanceHistoryController != nil {
+				r.Get("/wallets/{walletId}/balance", balanceHistoryController.GetBalanceAt)
+			}
+
+			if bonusController != nil {
+				r.Post("/wallets/{walletId}/bonus", bonusController.CreditBonus)
+				r.Get("/wallets/{walletId}/bonus", bonusController.GetBonusBalance)
+				r.Get("/wallets/{walletId}/bonus/entries", bonusController.ListBonusEntries)
+			}
+
+			if scheduleController != nil {
+				r.Route("/schedules", func(r chi.Router) {
+					r.Post("/", scheduleController.CreateSchedule)
+					r.Get("/", scheduleController.ListSchedules)
+					r.Get("/{scheduleId}", scheduleController.GetSchedule)
+					r.Get("/{scheduleId}/runs", scheduleController.ListRuns)
+					r.Post("/{scheduleId}/pause", scheduleController.PauseSchedule)
+					r.Post("/{scheduleId}/resume", scheduleController.ResumeSchedule)
+					r.Post("/{scheduleId}/cancel", scheduleController.CancelSchedule)
+				})
+			}
+
+			if reconciliationController != nil {
+				r.Route("/admin/reconciliation", func(r chi.Router) {
+					// Reconciliation is limited to the allowed client certificates
+					r.Use(controller.RequirePrincipal(cfg.TLS.AdminPrincipals, resp))
+					r.Get("/", reconciliationController.GetStatus)
+					r.Post("/", reconciliationController.Run)
+				})
+			}
+
+			if settlementController != nil {
+				r.Group(func(r chi.Router) {
+					// Opt-in: once clients are listed, only they import settlement files
+					if len(cfg.TLS.SettlementPrincipals) > 0 {
+						r.Use(controller.RequirePrincipal(cfg.TLS.SettlementPrincipals, resp))
+					}
+					r.Post("/admin/settlements", settlementController.ImportSettlement)
+				})
+			}
+
+			if webhookController != nil {
+				r.Route("/webhooks", func(r chi.Router) {
+					// Opt-in: once clients are listed, only they manage webhooks
+					if len(cfg.TLS.WebhookPrincipals) > 0 {
+						r.Use(controller.RequirePrincipal(cfg.TLS.WebhookPrincipals, resp))
+					}
+					r.Post("/", webhookController.RegisterEndpoint)
+					r.Get("/", webhookController.ListEndpoints)
+					r.Delete("/{endpointId}", webhookController.DeleteEndpoint)
+					r.Get("/dead-letters", webhookController.ListDeadLetters)
+					r.Post("/dead-letters/{deadLetterId}/replay", webhookController.ReplayDeadLetter)
+				})
+			}
+		})
+
+		// Streams stay open for as long as the client is connected and statements of long ranges
+		// take as long as the download, so they bypass the timeout
+		r.Get("/wallets/{walletId}/events", walletEventsController.StreamWalletEvents)
+		if balanceHistoryController != nil {
+			r.Get("/wallets/{walletId}/statement", balanceHistoryController.GetStatement)
+		}
 	})
 
+	// Serve TLS with certificates that are reloaded when the files change
+	var certs *auth.CertReloader
+	if cfg.TLS.Enabled() {
+		certs, err = auth.NewCertReloader(cfg.TLS.CertFile, cfg.TLS.KeyFile, cfg.TLS.ClientCAFile, clientAuthType(cfg.TLS.ClientAuth))
+		if err != nil {
+			log.Fatalf("Error loading TLS certificates: %v", err)
+		}
+		if cfg.WatchInterval > 0 {
+			go certs.Run(ctx, cfg.WatchInterval)
+		}
+	}
+
+	// Start gRPC server
+	grpcOptions := []grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(rateLimiter.UnaryInterceptor, controller.ReadConsistencyUnaryInterceptor, controller.ClientPrincipalUnaryInterceptor, controller.AuditContextUnaryInterceptor),
+		grpc.ChainStreamInterceptor(rateLimiter.StreamInterceptor, controller.ReadConsistencyStreamInterceptor, controller.ClientPrincipalStreamInterceptor, controller.AuditContextStreamInterceptor),
+	}
+	if certs != nil {
+		grpcOptions = append(grpcOptions, grpc.Creds(credentials.NewTLS(certs.ServerConfig("h2"))))
+	}
+	grpcServer := grpc.NewServer(grpcOptions...)
+	walletv1.RegisterWalletServiceServer(grpcServer, controller.NewWalletGRPCServer(walletService, broker))
+	lis, err := net.Listen("tcp", ":"+cfg.GRPCPort)
+	if err != nil {
+		log.Fatalf("Error listening on gRPC port: %v", err)
+	}
+	go func() {
+		log.Printf("gRPC server starting on port %s", cfg.GRPCPort)
+		if err := grpcServer.Serve(lis); err != nil {
+			log.Fatalf("Error starting gRPC server: %v", err)
+		}
+	}()
+	defer grpcServer.GracefulStop()
+
 	// Start server
-	log.Printf("Server starting on port %s", cfg.ServerPort)
-	if err := http.ListenAndServe(":"+cfg.ServerPort, r); err != nil {
+	server := &http.Server{Addr: ":" + cfg.ServerPort, Handler: r}
+	if certs != nil {
+		server.TLSConfig = certs.ServerConfig("h2", "http/1.1")
+		log.Printf("Server starting on port %s with TLS (client certificates: %s)", cfg.ServerPort, cfg.TLS.ClientAuth)
+		err = server.ListenAndServeTLS("", "")
+	} else {
+		log.Printf("Server starting on port %s", cfg.ServerPort)
+		err = server.ListenAndServe()
+	}
+	if err != nil {
 		log.Fatalf("Error starting server: %v", err)
 	}
 }
+
+func clientAuthType(mode string) tls.ClientAuthType {
+	switch mode {
+	case "optional":
+		return tls.VerifyClientCertIfGiven
+	case "require":
+		return tls.RequireAndVerifyClientCert
+	default:
+		return tls.NoClientCert
+	}
+}
+
+func newTxConfig(cfg config.TxConfig) repository.TxConfig {
+	return repository.TxConfig{
+		Isolation:   cfg.Isolation,
+		MaxRetries:  cfg.MaxRetries,
+		BaseBackoff: cfg.BaseBackoff,
+		MaxBackoff:  cfg.MaxBackoff,
+	}
+}
+
+func newPostgresWalletRepository(cfg *config.Config, db *sql.DB) (repository.WalletRepository, error) {
+	switch cfg.LockingStrategy {
+	case "pessimistic":
+		return repository.NewWalletRepository(db, newTxConfig(cfg.Tx)), nil
+	case "optimistic":
+		return repository.NewOptimisticWalletRepository(db, cfg.OptimisticMaxRetries, newTxConfig(cfg.Tx)), nil
+	default:
+		return nil, fmt.Errorf("unknown locking strategy %q", cfg.LockingStrategy)
+	}
+}
+
+func newOutboxPublisher(cfg config.OutboxConfig) (outbox.Publisher, error) {
+	switch cfg.Publisher {
+	case "log":
+		return outbox.NewLogPublisher(), nil
+	case "file":
+		return outbox.NewFilePublisher(cfg.FilePath)
+	default:
+		return nil, fmt.Errorf("unknown outbox publisher %q", cfg.Publisher)
+	}
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"ITKtest/config"
+	"ITKtest/database"
+)
+
+const migrateUsage = "usage: main migrate up | down N | version | force V | reset"
+
+// openDatabase connects to the SQL database of the configured storage driver
+func openDatabase(cfg *config.Config) (*sql.DB, *database.Migrator, error) {
+	var db *sql.DB
+	var err error
+	switch cfg.StorageDriver {
+	case "postgres":
+		db, err = database.Connect(cfg.DB)
+	case "sqlite":
+		db, err = database.ConnectSQLite(cfg.SQLitePath)
+	default:
+		return nil, nil, fmt.Errorf("storage driver %q has no database schema", cfg.StorageDriver)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var migrator *database.Migrator
+	if cfg.StorageDriver == "sqlite" {
+		migrator, err = database.NewSQLiteMigrator(db)
+	} else {
+		migrator, err = database.NewMigrator(db)
+	}
+	if err != nil {
+		db.Close()
+		return nil, nil, err
+	}
+	return db, migrator, nil
+}
+
+// runMigrate implements the migrate subcommand
+func runMigrate(cfg *config.Config, args []string) error {
+	if len(args) == 0 {
+		return errors.New(migrateUsage)
+	}
+
+	db, migrator, err := openDatabase(cfg)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	switch args[0] {
+	case "up":
+		if err := migrator.Up(); err != nil {
+			return err
+		}
+	case "down":
+		if len(args) != 2 {
+			return errors.New(migrateUsage)
+		}
+		n, err := strconv.Atoi(args[1])
+		if err != nil {
+			return fmt.Errorf("invalid number of migrations %q", args[1])
+		}
+		if err := migrator.Down(n); err != nil {
+			return err
+		}
+	case "version":
+		// Printed below
+	case "force":
+		if len(args) != 2 {
+			return errors.New(migrateUsage)
+		}
+		version, err := strconv.Atoi(args[1])
+		if err != nil {
+			return fmt.Errorf("invalid version %q", args[1])
+		}
+		if err := migrator.Force(version); err != nil {
+			return err
+		}
+	case "reset":
+		if err := migrator.Reset(); err != nil {
+			return err
+		}
+	default:
+		return errors.New(migrateUsage)
+	}
+
+	version, dirty, err := migrator.Version()
+	if err != nil {
+		return err
+	}
+	fmt.Printf("version %d (binary %d)", version, migrator.Latest())
+	if dirty {
+		fmt.Print(" dirty")
+	}
+	fmt.Println()
+	return nil
+}
@@ -0,0 +1,10 @@
+CREATE TABLE wallet_transactions (
+    id BIGSERIAL PRIMARY KEY,
+    wallet_id UUID NOT NULL REFERENCES wallets(id),
+    operation_type VARCHAR(16) NOT NULL,
+    amount BIGINT NOT NULL,
+    balance_after BIGINT NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_wallet_transactions_wallet_id ON wallet_transactions(wallet_id, id);
@@ -0,0 +1,9 @@
+ALTER TABLE wallets ADD COLUMN shard_count INTEGER NOT NULL DEFAULT 0;
+
+CREATE TABLE wallet_shards (
+    wallet_id UUID NOT NULL REFERENCES wallets(id),
+    shard INTEGER NOT NULL,
+    balance BIGINT NOT NULL DEFAULT 0 CHECK (balance >= 0),
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (wallet_id, shard)
+);
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_wallet_transactions_wallet_id_created_at;
+DROP TABLE IF EXISTS wallet_balance_snapshots;
@@ -0,0 +1,9 @@
+CREATE TABLE wallet_balance_snapshots (
+    wallet_id UUID NOT NULL REFERENCES wallets(id),
+    as_of TIMESTAMP WITH TIME ZONE NOT NULL,
+    balance BIGINT NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (wallet_id, as_of)
+);
+
+CREATE INDEX idx_wallet_transactions_wallet_id_created_at ON wallet_transactions(wallet_id, created_at);
@@ -0,0 +1,48 @@
+-- before_value and after_value are TEXT rather than JSONB so that they read back byte for
+-- byte as they were hashed. Changes write their records unchained, so that they do not queue
+-- behind one lock on the head of the chain; the chainer then gives each record its seq and
+-- hashes. id orders the records awaiting chaining.
+CREATE TABLE audit_log (
+    id BIGSERIAL PRIMARY KEY,
+    seq BIGINT UNIQUE,
+    action VARCHAR(64) NOT NULL,
+    actor TEXT NOT NULL,
+    request_id TEXT NOT NULL,
+    subject TEXT NOT NULL,
+    before_value TEXT NOT NULL,
+    after_value TEXT NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    prev_hash TEXT,
+    hash TEXT
+);
+
+CREATE INDEX idx_audit_log_subject ON audit_log(subject);
+CREATE INDEX idx_audit_log_unchained ON audit_log(id) WHERE seq IS NULL;
+
+-- The log is append-only; the hash chain detects changes made with the triggers disabled
+CREATE FUNCTION audit_log_append_only() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'audit_log is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+-- The only update allowed is chaining a record, once
+CREATE FUNCTION audit_log_chain_only() RETURNS trigger AS $$
+BEGIN
+    IF OLD.seq IS NOT NULL
+        OR (NEW.id, NEW.action, NEW.actor, NEW.request_id, NEW.subject, NEW.before_value, NEW.after_value, NEW.created_at)
+            IS DISTINCT FROM
+           (OLD.id, OLD.action, OLD.actor, OLD.request_id, OLD.subject, OLD.before_value, OLD.after_value, OLD.created_at) THEN
+        RAISE EXCEPTION 'audit_log is append-only';
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER audit_log_append_only
+    BEFORE DELETE OR TRUNCATE ON audit_log
+    FOR EACH STATEMENT EXECUTE FUNCTION audit_log_append_only();
+
+CREATE TRIGGER audit_log_chain_only
+    BEFORE UPDATE ON audit_log
+    FOR EACH ROW EXECUTE FUNCTION audit_log_chain_only();
@@ -0,0 +1,3 @@
+ALTER TABLE wallet_transactions ADD COLUMN fee_for BIGINT REFERENCES wallet_transactions(id);
+
+CREATE INDEX idx_wallet_transactions_fee_for ON wallet_transactions(fee_for) WHERE fee_for IS NOT NULL;
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS bonus_entries;
+DROP TABLE IF EXISTS bonus_lots;
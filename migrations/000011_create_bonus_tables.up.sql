@@ -0,0 +1,25 @@
+CREATE TABLE bonus_lots (
+    id BIGSERIAL PRIMARY KEY,
+    wallet_id UUID NOT NULL REFERENCES wallets(id),
+    amount BIGINT NOT NULL CHECK (amount > 0),
+    remaining BIGINT NOT NULL CHECK (remaining >= 0 AND remaining <= amount),
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    reference VARCHAR(128),
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+-- Lots are spent and expired in expiry order; spent and expired lots drop out of both indexes
+CREATE INDEX idx_bonus_lots_wallet_id_expires_at ON bonus_lots(wallet_id, expires_at, id) WHERE remaining > 0;
+CREATE INDEX idx_bonus_lots_expires_at ON bonus_lots(expires_at) WHERE remaining > 0;
+
+CREATE TABLE bonus_entries (
+    id BIGSERIAL PRIMARY KEY,
+    wallet_id UUID NOT NULL REFERENCES wallets(id),
+    lot_id BIGINT NOT NULL REFERENCES bonus_lots(id),
+    kind VARCHAR(16) NOT NULL,
+    amount BIGINT NOT NULL,
+    transaction_id BIGINT REFERENCES wallet_transactions(id),
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_bonus_entries_wallet_id ON bonus_entries(wallet_id, id);
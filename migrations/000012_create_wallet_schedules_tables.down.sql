@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS operation_idempotency_keys;
+DROP TABLE IF EXISTS wallet_schedule_runs;
+DROP TABLE IF EXISTS wallet_schedules;
@@ -0,0 +1,44 @@
+CREATE TABLE wallet_schedules (
+    id BIGSERIAL PRIMARY KEY,
+    wallet_id UUID NOT NULL,
+    operation_type VARCHAR(16) NOT NULL,
+    amount BIGINT NOT NULL CHECK (amount > 0),
+    to_wallet_id UUID,
+    reference VARCHAR(128),
+    run_at TIMESTAMP WITH TIME ZONE,
+    cron VARCHAR(128),
+    time_zone VARCHAR(64),
+    interval_seconds BIGINT,
+    max_runs INTEGER NOT NULL DEFAULT 0,
+    status VARCHAR(16) NOT NULL,
+    next_run_at TIMESTAMP WITH TIME ZONE,
+    run_count INTEGER NOT NULL DEFAULT 0,
+    principal TEXT,
+    created_by TEXT NOT NULL,
+    -- locked_until is the lease of the worker running the schedule; an expired lease is taken over
+    locked_until TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_wallet_schedules_wallet_id ON wallet_schedules(wallet_id, id);
+CREATE INDEX idx_wallet_schedules_to_wallet_id ON wallet_schedules(to_wallet_id, id) WHERE to_wallet_id IS NOT NULL;
+CREATE INDEX idx_wallet_schedules_due ON wallet_schedules(next_run_at) WHERE status = 'active';
+
+CREATE TABLE wallet_schedule_runs (
+    id BIGSERIAL PRIMARY KEY,
+    schedule_id BIGINT NOT NULL REFERENCES wallet_schedules(id),
+    due_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    idempotency_key VARCHAR(128) NOT NULL,
+    status VARCHAR(16) NOT NULL,
+    error TEXT,
+    finished_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    UNIQUE (schedule_id, due_at)
+);
+
+-- Keys of operations applied on behalf of a schedule run, written in the operation's transaction
+CREATE TABLE operation_idempotency_keys (
+    key VARCHAR(128) PRIMARY KEY,
+    transaction_id BIGINT NOT NULL REFERENCES wallet_transactions(id),
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
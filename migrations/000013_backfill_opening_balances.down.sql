@@ -0,0 +1 @@
+-- The opening entries stay: the balances and snapshots of their wallets rely on them
@@ -0,0 +1,44 @@
+-- Wallets with a balance from before the journal (000004) get an opening DEPOSIT for it, so
+-- that reconciliation, point-in-time balances and statements add up for them. The opening
+-- balance is read off the first journal entry of an unsharded wallet; otherwise it is what the
+-- journal does not account for. It is dated just before the first entry, or at the last change
+-- of a wallet without entries, although its id follows theirs.
+CREATE TEMPORARY TABLE opening_balances AS
+WITH journal AS (
+    SELECT wallet_id,
+        SUM(CASE WHEN operation_type = 'WITHDRAW' THEN -amount ELSE amount END) AS balance,
+        MIN(created_at) AS first_at
+    FROM wallet_transactions
+    GROUP BY wallet_id
+), first_entry AS (
+    SELECT DISTINCT ON (wallet_id) wallet_id,
+        balance_after - CASE WHEN operation_type = 'WITHDRAW' THEN -amount ELSE amount END AS balance_before
+    FROM wallet_transactions
+    ORDER BY wallet_id, created_at, id
+), shards AS (
+    SELECT wallet_id, SUM(balance) AS balance
+    FROM wallet_shards
+    GROUP BY wallet_id
+)
+SELECT w.id AS wallet_id,
+    CASE WHEN w.shard_count = 0 AND f.wallet_id IS NOT NULL THEN f.balance_before
+        ELSE w.balance + COALESCE(s.balance, 0) - COALESCE(j.balance, 0) END AS balance,
+    COALESCE(j.first_at - INTERVAL '1 microsecond', w.updated_at) AS created_at
+FROM wallets w
+LEFT JOIN journal j ON j.wallet_id = w.id
+LEFT JOIN first_entry f ON f.wallet_id = w.id
+LEFT JOIN shards s ON s.wallet_id = w.id;
+
+DELETE FROM opening_balances WHERE balance <= 0;
+
+INSERT INTO wallet_transactions (wallet_id, operation_type, amount, balance_after, created_at)
+SELECT wallet_id, 'DEPOSIT', balance, balance, created_at
+FROM opening_balances;
+
+-- Snapshots taken since summed the journal without the opening balance
+UPDATE wallet_balance_snapshots s
+SET balance = s.balance + o.balance
+FROM opening_balances o
+WHERE s.wallet_id = o.wallet_id AND s.as_of >= o.created_at;
+
+DROP TABLE opening_balances;
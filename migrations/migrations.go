@@ -0,0 +1,14 @@
+// Package migrations embeds the SQL migrations so the binary does not depend on its working directory
+package migrations
+
+import "embed"
+
+// Postgres holds the PostgreSQL migrations at the root of the FS
+//
+//go:embed *.sql
+var Postgres embed.FS
+
+// SQLite holds the SQLite migrations under sqlite/
+//
+//go:embed sqlite/*.sql
+var SQLite embed.FS
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_wallet_transactions_reference;
+ALTER TABLE wallet_transactions DROP COLUMN reference;
@@ -0,0 +1,3 @@
+ALTER TABLE wallet_transactions ADD COLUMN reference TEXT;
+
+CREATE INDEX idx_wallet_transactions_reference ON wallet_transactions(reference) WHERE reference IS NOT NULL;
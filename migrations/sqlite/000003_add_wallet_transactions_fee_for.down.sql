@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_wallet_transactions_fee_for;
+ALTER TABLE wallet_transactions DROP COLUMN fee_for;
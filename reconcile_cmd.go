@@ -0,0 +1,48 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"ITKtest/config"
+	"ITKtest/internal/repository"
+)
+
+const reconcileUsage = "usage: main reconcile"
+
+// runReconcile implements the reconcile subcommand
+func runReconcile(cfg *config.Config, args []string) error {
+	if len(args) != 0 {
+		return errors.New(reconcileUsage)
+	}
+	if cfg.StorageDriver != "postgres" {
+		return fmt.Errorf("reconciliation needs the postgres storage driver, not %q", cfg.StorageDriver)
+	}
+
+	db, _, err := openDatabase(cfg)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	report, err := repository.NewReconciliationRepository(db).Reconcile(context.Background(), cfg.Reconcile.ReportLimit)
+	if err != nil {
+		return err
+	}
+	for _, d := range report.Mismatches {
+		fmt.Printf("mismatch: wallet %s balance %d, journal %d\n", d.WalletID, d.StoredBalance, d.JournalBalance)
+	}
+	for _, d := range report.NegativeBalances {
+		fmt.Printf("negative balance: wallet %s balance %d\n", d.WalletID, d.StoredBalance)
+	}
+	for _, t := range report.OrphanedTransactions {
+		fmt.Printf("orphaned journal entry: %d of missing wallet %s\n", t.ID, t.WalletID)
+	}
+	fmt.Printf("Checked %d wallets: %d mismatches, %d negative balances, %d orphaned journal entries\n",
+		report.WalletsChecked, report.MismatchCount, report.NegativeBalanceCount, report.OrphanedTransactionCount)
+	if !report.Clean() {
+		return errors.New("reconciliation found discrepancies")
+	}
+	return nil
+}
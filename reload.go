@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"ITKtest/config"
+	"ITKtest/internal/audit"
+	"ITKtest/internal/models"
+	"ITKtest/internal/repository"
+	"ITKtest/internal/settings"
+)
+
+// watchConfig reloads the configuration on SIGHUP and whenever the config file or config.env
+// changes. A configuration that fails validation is rejected and the current one stays in
+// effect; a valid one replaces the runtime settings in a single swap.
+func watchConfig(ctx context.Context, args []string, current *config.Config, store *settings.Store, auditLog repository.AuditRepository) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	var tick <-chan time.Time
+	if current.WatchInterval > 0 {
+		ticker := time.NewTicker(current.WatchInterval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	stamp := configStamp(current)
+	for {
+		var trigger string
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+			trigger = "SIGHUP"
+		case <-tick:
+			next := configStamp(current)
+			if next == stamp {
+				continue
+			}
+			trigger = "file change"
+		}
+
+		next, err := reloadConfig(ctx, trigger, args, current, store, auditLog)
+		if err != nil {
+			log.Printf("Config reload on %s rejected, keeping the current config: %v", trigger, err)
+		} else {
+			current = next
+		}
+		// A rejected file is not retried until it changes again
+		stamp = configStamp(current)
+	}
+}
+
+// reloadConfig loads and validates the configuration again and applies its runtime settings.
+// Applied changes are recorded in the audit log when there is one.
+func reloadConfig(ctx context.Context, trigger string, args []string, current *config.Config, store *settings.Store, auditLog repository.AuditRepository) (*config.Config, error) {
+	next, _, err := config.LoadConfig(args)
+	if err != nil {
+		return nil, err
+	}
+
+	store.Swap(next.Runtime)
+	slog.SetLogLoggerLevel(next.Runtime.LogLevel)
+
+	applied, restart := config.Changes(current, next)
+	log.Printf("Config reloaded on %s, runtime settings changed: %v", trigger, applied)
+	if len(restart) > 0 {
+		log.Printf("Settings changed that only take effect after a restart: %v", restart)
+	}
+	if auditLog != nil && len(applied) > 0 {
+		ctx = audit.WithRequest(ctx, "config:"+trigger, "")
+		if err := auditLog.Append(ctx, models.AuditRuntimeSettingsChanged, auditSettingsSubject, current.RuntimeValues(), next.RuntimeValues()); err != nil {
+			log.Printf("Error recording the settings change in the audit log: %v", err)
+		}
+	}
+	return next, nil
+}
+
+// auditSettingsSubject is the subject of audit records of runtime settings
+const auditSettingsSubject = "runtime-settings"
+
+// configStamp identifies the current contents of the watched files by size and modification time
+func configStamp(cfg *config.Config) string {
+	var stamp string
+	for _, path := range []string{cfg.File, config.EnvFile} {
+		if path == "" {
+			continue
+		}
+		if info, err := os.Stat(path); err == nil {
+			stamp += fmt.Sprintf("%s:%d:%d;", path, info.Size(), info.ModTime().UnixNano())
+		}
+	}
+	return stamp
+}
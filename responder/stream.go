@@ -0,0 +1,109 @@
+package responder
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// streamFlushEvery is the number of records after which buffered output is sent to the client
+const streamFlushEvery = 100
+
+// StreamWriter writes a download record by record, so that large responses are never held in
+// memory. Once the first record is written the status is 200 and errors can no longer be
+// reported to the client.
+type StreamWriter interface {
+	Write(record interface{}) error
+	// Close sends any buffered records
+	Close() error
+}
+
+// CSVRecord is a record that can be written as a CSV row
+type CSVRecord interface {
+	CSVHeader() []string
+	CSVRow() []string
+}
+
+// StreamFormats lists the formats accepted by NewStream
+var StreamFormats = []string{"csv", "jsonl"}
+
+// NewStream starts a download of filename (without extension) in format "csv" or "jsonl"
+func NewStream(w http.ResponseWriter, format, filename string) (StreamWriter, error) {
+	var contentType string
+	switch format {
+	case "csv":
+		contentType = "text/csv; charset=utf-8"
+	case "jsonl":
+		contentType = "application/x-ndjson"
+	default:
+		return nil, fmt.Errorf("unsupported format %q", format)
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.%s"`, filename, format))
+	w.WriteHeader(http.StatusOK)
+
+	buf := bufio.NewWriter(w)
+	s := &stream{w: w, buf: buf}
+	if format == "csv" {
+		s.csv = csv.NewWriter(buf)
+	} else {
+		s.json = json.NewEncoder(buf)
+	}
+	return s, nil
+}
+
+type stream struct {
+	w       http.ResponseWriter
+	buf     *bufio.Writer
+	csv     *csv.Writer
+	json    *json.Encoder
+	written int
+}
+
+func (s *stream) Write(record interface{}) error {
+	if s.csv != nil {
+		row, ok := record.(CSVRecord)
+		if !ok {
+			return fmt.Errorf("%T cannot be written as CSV", record)
+		}
+		if s.written == 0 {
+			if err := s.csv.Write(row.CSVHeader()); err != nil {
+				return err
+			}
+		}
+		if err := s.csv.Write(row.CSVRow()); err != nil {
+			return err
+		}
+	} else if err := s.json.Encode(record); err != nil {
+		return err
+	}
+
+	s.written++
+	if s.written%streamFlushEvery == 0 {
+		return s.flush()
+	}
+	return nil
+}
+
+func (s *stream) Close() error {
+	return s.flush()
+}
+
+func (s *stream) flush() error {
+	if s.csv != nil {
+		s.csv.Flush()
+		if err := s.csv.Error(); err != nil {
+			return err
+		}
+	}
+	if err := s.buf.Flush(); err != nil {
+		return err
+	}
+	if f, ok := s.w.(http.Flusher); ok {
+		f.Flush()
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"unicode/utf8"
+
+	"ITKtest/config"
+	"ITKtest/internal/models"
+	"ITKtest/internal/repository"
+	"ITKtest/internal/settlement"
+)
+
+const settlementUsage = "usage: main settlement import <file|->"
+
+// settlementMapping is the default mapping of settlement files
+func settlementMapping(cfg config.SettlementConfig) settlement.Mapping {
+	delimiter, _ := utf8.DecodeRuneInString(cfg.Delimiter)
+	return settlement.Mapping{
+		ReferenceColumn: cfg.ReferenceColumn,
+		AmountColumn:    cfg.AmountColumn,
+		WalletColumn:    cfg.WalletColumn,
+		Delimiter:       delimiter,
+		Decimals:        cfg.AmountDecimals,
+	}
+}
+
+// runSettlement implements the settlement subcommand
+func runSettlement(cfg *config.Config, args []string) error {
+	if len(args) != 2 || args[0] != "import" {
+		return errors.New(settlementUsage)
+	}
+	if cfg.StorageDriver != "postgres" {
+		return fmt.Errorf("settlement matching needs the postgres storage driver, not %q", cfg.StorageDriver)
+	}
+
+	var file io.Reader = os.Stdin
+	if args[1] != "-" {
+		f, err := os.Open(args[1])
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		file = f
+	}
+
+	db, _, err := openDatabase(cfg)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	matcher := settlement.NewMatcher(repository.NewSettlementRepository(db))
+	report, err := matcher.Import(context.Background(), file, settlementMapping(cfg.Settlement))
+	if err != nil {
+		return err
+	}
+	for _, result := range report.Results {
+		if result.Status != models.SettlementMatched {
+			fmt.Printf("line %d %s: reference %q amount %d: %s\n", result.Line, result.Status, result.Reference, result.Amount, result.Reason)
+		}
+	}
+	fmt.Printf("Matched %d of %d lines: %d unmatched, %d mismatched\n", report.Matched, report.Lines, report.Unmatched, report.Mismatched)
+	if !report.Clean() {
+		return errors.New("settlement file does not match the deposits")
+	}
+	return nil
+}